@@ -0,0 +1,9 @@
+package drain
+
+type Drain struct {
+	DeleteEmptyDirData string
+	DeleteLocalPVData  string
+	EvictByPriority    string
+	IgnoreDaemonSets   string
+	SkipMirrorPods     string
+}
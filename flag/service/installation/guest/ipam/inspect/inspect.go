@@ -0,0 +1,7 @@
+package inspect
+
+type Inspect struct {
+	// Address is the address the IPAM inspection endpoint listens on. It is
+	// not exposed by the operator Service.
+	Address string
+}
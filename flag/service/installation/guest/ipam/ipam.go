@@ -1,7 +1,20 @@
 package ipam
 
-import "github.com/giantswarm/azure-operator/v8/flag/service/installation/guest/ipam/network"
+import (
+	"github.com/giantswarm/azure-operator/v8/flag/service/installation/guest/ipam/inspect"
+	"github.com/giantswarm/azure-operator/v8/flag/service/installation/guest/ipam/leakcheck"
+	"github.com/giantswarm/azure-operator/v8/flag/service/installation/guest/ipam/network"
+)
 
 type IPAM struct {
-	Network network.Network
+	Inspect   inspect.Inspect
+	LeakCheck leakcheck.LeakCheck
+	Network   network.Network
+
+	// Pools is a comma separated list of additional IPAM pools in the format
+	// `name:CIDR:subnetMaskBits`. Tenant clusters select a pool with the
+	// `azure-operator.giantswarm.io/ipam-pool` label on their Cluster or
+	// Organization CR and otherwise use the default pool defined by Network.
+	// Pools must not overlap each other or the default pool.
+	Pools string
 }
@@ -0,0 +1,10 @@
+package leakcheck
+
+type LeakCheck struct {
+	// Interval is the time between two checks for orphaned IPAM allocations.
+	Interval string
+
+	// Reclaim enables releasing orphaned node pool subnets from AzureCluster
+	// CRs. Orphaned allocations are only reported when it is disabled.
+	Reclaim string
+}
@@ -5,6 +5,11 @@ type Network struct {
 	// clusters.
 	CIDR string
 
+	// IPv6CIDR is the /32 IPv6 network segment from which the IPv6 ranges of
+	// dual-stack guest clusters are derived. Dual-stack guest clusters are
+	// not supported when it is empty.
+	IPv6CIDR string
+
 	// SubnetMaskBits is number of bits in guest cluster subnet mask. This
 	// defines size of the guest cluster subnet that is allocated from CIDR.
 	SubnetMaskBits string
@@ -0,0 +1,6 @@
+package nodepool
+
+type NodePool struct {
+	LifecycleHookWebhookAllowlist string
+	StateDeadlines                string
+}
@@ -6,19 +6,25 @@ import (
 	"github.com/giantswarm/azure-operator/v8/flag/service/azure"
 	"github.com/giantswarm/azure-operator/v8/flag/service/cluster"
 	"github.com/giantswarm/azure-operator/v8/flag/service/debug"
+	"github.com/giantswarm/azure-operator/v8/flag/service/drain"
 	"github.com/giantswarm/azure-operator/v8/flag/service/installation"
+	"github.com/giantswarm/azure-operator/v8/flag/service/nodepool"
 	"github.com/giantswarm/azure-operator/v8/flag/service/registry"
 	"github.com/giantswarm/azure-operator/v8/flag/service/sentry"
 	"github.com/giantswarm/azure-operator/v8/flag/service/tenant"
+	"github.com/giantswarm/azure-operator/v8/flag/service/unhealthynode"
 )
 
 type Service struct {
-	Azure        azure.Azure
-	Cluster      cluster.Cluster
-	Installation installation.Installation
-	Kubernetes   kubernetes.Kubernetes
-	Registry     registry.Registry
-	Tenant       tenant.Tenant
-	Sentry       sentry.Sentry
-	Debug        debug.Debug
+	Azure         azure.Azure
+	Cluster       cluster.Cluster
+	Installation  installation.Installation
+	Kubernetes    kubernetes.Kubernetes
+	NodePool      nodepool.NodePool
+	Registry      registry.Registry
+	Tenant        tenant.Tenant
+	Sentry        sentry.Sentry
+	Debug         debug.Debug
+	Drain         drain.Drain
+	UnhealthyNode unhealthynode.UnhealthyNode
 }
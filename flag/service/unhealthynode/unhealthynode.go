@@ -0,0 +1,5 @@
+package unhealthynode
+
+type UnhealthyNode struct {
+	DryRun string
+}
@@ -86,6 +86,25 @@ func mainError() error {
 			}
 		}
 
+		// Create the server of the IPAM inspection endpoint, which listens on
+		// its own address.
+		{
+			c := server.IPAMConfig{
+				Logger:  logger,
+				Service: newService,
+
+				ListenAddress: v.GetString(f.Service.Installation.Guest.IPAM.Inspect.Address),
+				ProjectName:   project.Name(),
+			}
+
+			ipamServer, err := server.NewIPAM(c)
+			if err != nil {
+				panic(fmt.Sprintf("%#v", microerror.Mask(err)))
+			}
+
+			go ipamServer.Boot()
+		}
+
 		return newServer
 	}
 
@@ -152,7 +171,12 @@ func mainError() error {
 	daemonCommand.PersistentFlags().String(f.Service.Cluster.Kubernetes.Kubelet.AltNames, "", "Alternative names for guest cluster kubelet certificates.")
 	daemonCommand.PersistentFlags().Int(f.Service.Cluster.Kubernetes.Kubelet.Port, 0, "Port to bind guest cluster kubelets on.")
 	daemonCommand.PersistentFlags().String(f.Service.Cluster.Kubernetes.SSH.UserList, "", "Comma separated list of ssh users and their public key in format `username:publickey`, being installed in the guest cluster nodes.")
+	daemonCommand.PersistentFlags().String(f.Service.Installation.Guest.IPAM.Inspect.Address, "http://127.0.0.1:8001", "Address the IPAM inspection endpoint listens on. It is only reachable with kubectl port-forward by default.")
+	daemonCommand.PersistentFlags().Duration(f.Service.Installation.Guest.IPAM.LeakCheck.Interval, 10*time.Minute, "Time between two checks for orphaned IPAM allocations.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Installation.Guest.IPAM.LeakCheck.Reclaim, false, "Whether to release orphaned node pool subnets from AzureCluster CRs. Orphaned IPAM allocations are only reported otherwise.")
+	daemonCommand.PersistentFlags().String(f.Service.Installation.Guest.IPAM.Pools, "", "Comma separated list of additional IPAM pools in the format name:CIDR:subnetMaskBits, selected with the azure-operator.giantswarm.io/ipam-pool label on the Cluster or Organization CR. Pools must not overlap each other or the default pool.")
 	daemonCommand.PersistentFlags().String(f.Service.Installation.Guest.IPAM.Network.CIDR, "10.1.0.0/8", "Guest cluster network segment from which IPAM allocates subnets.")
+	daemonCommand.PersistentFlags().String(f.Service.Installation.Guest.IPAM.Network.IPv6CIDR, "", "Guest cluster /32 IPv6 network segment from which IPAM derives the IPv6 ranges of dual-stack clusters.")
 	daemonCommand.PersistentFlags().Int(f.Service.Installation.Guest.IPAM.Network.SubnetMaskBits, 16, "Number of bits in guest cluster subnet network mask.")
 	daemonCommand.PersistentFlags().String(f.Service.Installation.Name, "", "Installation name for tagging Azure resources.")
 	daemonCommand.PersistentFlags().String(f.Service.Installation.Tenant.Kubernetes.API.Auth.Provider.OIDC.ClientID, "", "OIDC authorization provider ClientID.")
@@ -176,6 +200,17 @@ func mainError() error {
 	daemonCommand.PersistentFlags().String(f.Service.Registry.Domain, "docker.io", "Image registry domain.")
 	daemonCommand.PersistentFlags().StringSlice(f.Service.Registry.Mirrors, []string{}, `Image registry mirror domains. Can be set only if registry domain is "docker.io".`)
 
+	daemonCommand.PersistentFlags().Bool(f.Service.Drain.DeleteEmptyDirData, true, "Whether to evict pods using emptyDir volumes when draining nodes.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Drain.DeleteLocalPVData, true, "Whether to evict pods using local persistent volumes when draining nodes.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Drain.EvictByPriority, true, "Whether to evict pods in order of priority when draining nodes, system critical pods last.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Drain.IgnoreDaemonSets, true, "Whether to skip DaemonSet pods when draining nodes.")
+	daemonCommand.PersistentFlags().Bool(f.Service.Drain.SkipMirrorPods, true, "Whether to skip mirror pods when draining nodes.")
+
+	daemonCommand.PersistentFlags().String(f.Service.NodePool.LifecycleHookWebhookAllowlist, "", "Comma separated URL prefixes node pool lifecycle hook webhooks may be called at, e.g. https://lb.example.com/hooks/. Webhooks are rejected when empty.")
+	daemonCommand.PersistentFlags().String(f.Service.NodePool.StateDeadlines, "", "Comma separated node pool upgrade state deadlines overriding the defaults, e.g. DrainOldWorkerInstances=2h,ScaleUpWorkerVMSS=45m.")
+
+	daemonCommand.PersistentFlags().Bool(f.Service.UnhealthyNode.DryRun, false, "Whether to only report the unhealthy nodes node auto repair would repair, without repairing them.")
+
 	daemonCommand.PersistentFlags().Bool(f.Service.Debug.InsecureStorageAccount, false, "Whether to disable the storage account firewall for tenant clusters.")
 
 	return newCommand.CobraCommand().Execute()
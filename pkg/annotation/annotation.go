@@ -3,8 +3,251 @@ package annotation
 const (
 	StateMachineCurrentState = "azure-machine-pool.giantswarm.io/state-machine-current-state"
 
+	// StateMachineStateEnteredTimestamp holds the RFC3339 time the node pool
+	// state machine entered its current state.
+	StateMachineStateEnteredTimestamp = "azure-machine-pool.giantswarm.io/state-machine-state-entered-ts"
+
+	// StateMachineStateDurations holds the time spent in each state of the
+	// node pool state machine during the current or last upgrade, e.g.
+	// "ScaleUpWorkerVMSS=2m0s,WaitForWorkersToBecomeReady=5m30s".
+	StateMachineStateDurations = "azure-machine-pool.giantswarm.io/state-machine-state-durations"
+
+	// DrainDeleteEmptyDirData is set to "true" or "false" on Cluster CRs to
+	// override if pods using emptyDir volumes are evicted when draining nodes.
+	DrainDeleteEmptyDirData = "drain.giantswarm.io/delete-emptydir-data"
+
+	// DrainDeleteLocalPVData is set to "true" or "false" on Cluster CRs to
+	// override if pods using local persistent volumes are evicted when
+	// draining nodes.
+	DrainDeleteLocalPVData = "drain.giantswarm.io/delete-local-pv-data"
+
+	// DrainEvictByPriority is set to "true" or "false" on Cluster CRs to
+	// override if pods are evicted in order of priority when draining nodes.
+	DrainEvictByPriority = "drain.giantswarm.io/evict-by-priority"
+
+	// DrainIgnoreDaemonSets is set to "true" or "false" on Cluster CRs to
+	// override if DaemonSet pods are skipped when draining nodes.
+	DrainIgnoreDaemonSets = "drain.giantswarm.io/ignore-daemonsets"
+
+	// DrainSkipMirrorPods is set to "true" or "false" on Cluster CRs to
+	// override if mirror pods are skipped when draining nodes.
+	DrainSkipMirrorPods = "drain.giantswarm.io/skip-mirror-pods"
+
+	// DrainPDBDeletionGracePeriod is set on AzureMachinePool CRs to delete
+	// pods whose eviction is blocked by a PodDisruptionBudget for longer than
+	// the given duration, e.g. "10m", while draining nodes. It must be shorter
+	// than the drain timeout of 15 minutes. Blocked pods are never deleted when
+	// it is not set or invalid.
+	DrainPDBDeletionGracePeriod = "azure-machine-pool.giantswarm.io/drain-pdb-deletion-grace-period"
+
+	// DrainProgress holds the number of drained nodes out of the nodes being
+	// drained in the current state of the node pool state machine, e.g. "2/5".
+	DrainProgress = "azure-machine-pool.giantswarm.io/drain-progress"
+
+	// RollingUpdateDesiredReplicas holds the size of the node pool before a
+	// rolling update started. It is used to compute the batches of the
+	// rolling update and removed once the rolling update is completed.
+	RollingUpdateDesiredReplicas = "azure-machine-pool.giantswarm.io/rolling-update-desired-replicas"
+
+	// RollingUpdateBatch holds the comma separated IDs of the VMSS instances
+	// cordoned in the current iteration of the rolling update, so that they
+	// are the ones drained and terminated. It is removed once the instances
+	// are terminated.
+	RollingUpdateBatch = "azure-machine-pool.giantswarm.io/rolling-update-batch"
+
+	// NodePoolMaxSurge is set on MachinePool CRs to define how many instances
+	// can be created above the node pool size during a rolling update. Value
+	// can be an absolute number (e.g. 5) or a percentage (e.g. 10%).
+	NodePoolMaxSurge = "machine-pool.giantswarm.io/max-surge"
+
+	// NodePoolMaxUnavailable is set on MachinePool CRs to define how many
+	// instances can be unavailable during a rolling update. Value can be an
+	// absolute number (e.g. 5) or a percentage (e.g. 10%).
+	NodePoolMaxUnavailable = "machine-pool.giantswarm.io/max-unavailable"
+
+	// NodePoolLabels is set on MachinePool CRs to declare the labels of the
+	// node pool nodes as a comma-separated list of key=value entries, e.g.
+	// gpu=nvidia,team=ml. Keys in the kubernetes.io, k8s.io and giantswarm.io
+	// namespaces are reserved.
+	NodePoolLabels = "machine-pool.giantswarm.io/labels"
+
+	// NodePoolTaints is set on MachinePool CRs to declare the taints of the
+	// node pool nodes as a comma-separated list of key=value:Effect entries,
+	// e.g. nvidia.com/gpu=present:NoSchedule. The value is optional. Keys in
+	// the kubernetes.io, k8s.io and giantswarm.io namespaces are reserved.
+	NodePoolTaints = "machine-pool.giantswarm.io/taints"
+
+	// NodeManagedLabels is set on workload cluster nodes with the
+	// comma-separated keys of the labels the operator applied from the
+	// MachinePool. Other labels are managed by users and never changed.
+	NodeManagedLabels = "machine-pool.giantswarm.io/managed-labels"
+
+	// NodeManagedTaints is set on workload cluster nodes with the
+	// comma-separated key:Effect pairs of the taints the operator applied
+	// from the MachinePool. Other taints are managed by users and never
+	// changed.
+	NodeManagedTaints = "machine-pool.giantswarm.io/managed-taints"
+
+	// NodeSpotEvictionNotice is set on workload cluster nodes of spot node
+	// pools by the azure-scheduled-events agent watching the Azure instance
+	// metadata scheduled events, when a Preempt event is scheduled for the
+	// instance. The agent drains the node right away. The value is the RFC3339
+	// time the instance is evicted at.
+	NodeSpotEvictionNotice = "node.giantswarm.io/spot-eviction-notice"
+
+	// SpotFallbackMachinePool is set on spot AzureMachinePool CRs to name the
+	// on-demand MachinePool in the same namespace which is scaled up while
+	// spot capacity is unavailable.
+	SpotFallbackMachinePool = "azure-machine-pool.giantswarm.io/spot-fallback-machine-pool"
+
+	// SpotFallbackAfter is set on spot AzureMachinePool CRs to define how long
+	// spot capacity must be unavailable before the fallback MachinePool is
+	// scaled up, e.g. 15m.
+	SpotFallbackAfter = "azure-machine-pool.giantswarm.io/spot-fallback-after"
+
+	// SpotCapacityUnavailableSince is set on spot AzureMachinePool CRs to
+	// track since when the node pool misses instances.
+	SpotCapacityUnavailableSince = "azure-machine-pool.giantswarm.io/spot-capacity-unavailable-since"
+
+	// SpotFallbackReplicasPrefix is the prefix of the annotations set on
+	// on-demand MachinePool CRs with the number of instances added on behalf
+	// of the spot node pool named by the suffix.
+	SpotFallbackReplicasPrefix = "spot-fallback.machine-pool.giantswarm.io/"
+
+	// NodeScaleDownDisabledByUpgrade is set on workload cluster nodes when a
+	// node pool upgrade stopped the cluster autoscaler from removing them, so
+	// that only these cluster-autoscaler.kubernetes.io/scale-down-disabled
+	// annotations are removed again.
+	NodeScaleDownDisabledByUpgrade = "azure-machine-pool.giantswarm.io/scale-down-disabled-by-upgrade"
+
+	// NodePoolPreDrainHook is set on MachinePool CRs to declare a hook run
+	// for every node after it is cordoned and before it is drained during an
+	// upgrade. The value is a JSON document with either a "webhook" URL or a
+	// Kubernetes "job" template and an optional "timeout", e.g.
+	// {"webhook":{"url":"https://lb.example.com/deregister"},"timeout":"10m"}.
+	// Webhook URLs must be allowed by the webhook allowlist of the operator.
+	NodePoolPreDrainHook = "machine-pool.giantswarm.io/pre-drain-hook"
+
+	// NodePoolPostDrainHook is set on MachinePool CRs to declare a hook run
+	// for every node after it is drained and before it is terminated during
+	// an upgrade. It has the same format as NodePoolPreDrainHook.
+	NodePoolPostDrainHook = "machine-pool.giantswarm.io/post-drain-hook"
+
+	// NodeAutoRepairAction is set on Cluster CRs to select how unhealthy
+	// nodes are repaired when node auto repair is enabled. Supported values
+	// are "delete" (default), which replaces the VMSS instance, "reimage" and
+	// "restart". Master nodes are always reimaged.
+	NodeAutoRepairAction = "node.giantswarm.io/auto-repair-action"
+
+	// NodeAutoRepairTickThreshold is set on Cluster CRs to define how many
+	// times in a row a node must be seen NotReady before it is repaired.
+	// Defaults to 6.
+	NodeAutoRepairTickThreshold = "node.giantswarm.io/auto-repair-tick-threshold"
+
+	// NodeAutoRepairMaxRepairsPerHour is set on Cluster CRs to limit how many
+	// nodes are repaired within an hour. Defaults to 5.
+	NodeAutoRepairMaxRepairsPerHour = "node.giantswarm.io/auto-repair-max-repairs-per-hour"
+
+	// NodeAutoRepairMaxUnhealthyPercentage is set on Cluster CRs to stop
+	// repairing nodes of a node pool when more than the given percentage of
+	// its nodes is unhealthy, e.g. during an outage of the whole zone or
+	// region. Defaults to 40.
+	NodeAutoRepairMaxUnhealthyPercentage = "node.giantswarm.io/auto-repair-max-unhealthy-percentage"
+
+	// NodeAutoRepairDryRunHistory holds the comma separated RFC3339 times of
+	// the node repairs of the last hour node auto repair would have done in
+	// dry-run mode.
+	NodeAutoRepairDryRunHistory = "node.giantswarm.io/auto-repair-dry-run-history"
+
+	// NodeAutoRepairUnhealthyConditions is set on Cluster CRs to define the
+	// comma separated node condition types, e.g. reported by
+	// node-problem-detector, which make nodes unhealthy when they are true.
+	// Defaults to "KernelDeadlock,ReadonlyFilesystem,ContainerRuntimeUnhealthy,RedeployScheduled".
+	// An empty value only repairs nodes which are NotReady.
+	NodeAutoRepairUnhealthyConditions = "node.giantswarm.io/auto-repair-unhealthy-conditions"
+
+	// NodeAutoRepairConditionGracePeriod is set on Cluster CRs to define how
+	// long a node must report an unhealthy condition before it is repaired,
+	// as a Go duration. Defaults to "10m".
+	NodeAutoRepairConditionGracePeriod = "node.giantswarm.io/auto-repair-condition-grace-period"
+
+	// NodeAutoRepairSingleMaster is set to "true" on Cluster CRs with a
+	// single master node to allow node auto repair to reimage it. The API
+	// server is not available while the master is reimaged, so these
+	// masters are not repaired by default.
+	NodeAutoRepairSingleMaster = "node.giantswarm.io/auto-repair-single-master"
+
+	// NodeAutoRepairRepairedTimestamp holds the RFC3339 time a workload
+	// cluster node was last repaired by node auto repair.
+	NodeAutoRepairRepairedTimestamp = "node.giantswarm.io/auto-repair-repaired-ts"
+
+	// NodeAutoRepairHistory holds the comma separated RFC3339 times of the
+	// node repairs of the last hour. It is used to rate limit repairs.
+	NodeAutoRepairHistory = "node.giantswarm.io/auto-repair-history"
+
+	// ScaleStrategy is set on AzureMachinePool CRs to select the strategy
+	// used to scale the node pool VMSS. Supported values are "staircase"
+	// (default), "incremental", "quick" and "timebased".
+	ScaleStrategy = "azure-machine-pool.giantswarm.io/scale-strategy"
+
+	// ScaleStrategyStep is set on AzureMachinePool CRs to define how many
+	// instances the "staircase" and "timebased" strategies add at a time.
+	ScaleStrategyStep = "azure-machine-pool.giantswarm.io/scale-strategy-step"
+
+	// ScaleStrategyInterval is set on AzureMachinePool CRs to define how often
+	// the "timebased" strategy adds instances, e.g. "5m".
+	ScaleStrategyInterval = "azure-machine-pool.giantswarm.io/scale-strategy-interval"
+
+	// ScaleStrategyLastScaleTimestamp holds the RFC3339 time the "timebased"
+	// strategy last increased the size of the node pool VMSS.
+	ScaleStrategyLastScaleTimestamp = "azure-machine-pool.giantswarm.io/scale-strategy-last-scale-ts"
+
+	// UpgradeMode is set on AzureMachinePool CRs to select how instances are
+	// replaced during an upgrade. The default mode adds new instances before
+	// removing the outdated ones. The "in-place" mode drains and reimages the
+	// existing instances one by one without surge capacity.
+	UpgradeMode = "azure-machine-pool.giantswarm.io/upgrade-mode"
+
+	// InPlaceUpgradeInstance holds the ID of the VMSS instance being upgraded
+	// when the node pool is upgraded in place.
+	InPlaceUpgradeInstance = "azure-machine-pool.giantswarm.io/in-place-upgrade-instance"
+
+	// UpgradePaused is set to "true" on AzureMachinePool CRs to pause the node
+	// pool state machine, e.g. to investigate a failing upgrade. Removing the
+	// annotation resumes the upgrade where it stopped.
+	UpgradePaused = "azure-machine-pool.giantswarm.io/upgrade-paused"
+
+	// UpgradeAbort is set to "true" on AzureMachinePool CRs to abort an
+	// upgrade in progress. New instances are removed, the node pool is scaled
+	// back to its original size and old nodes are uncordoned. The upgrade
+	// starts again once the annotation is removed.
+	UpgradeAbort = "azure-machine-pool.giantswarm.io/upgrade-abort"
+
+	// UpgradeTimeoutPolicy is set on AzureMachinePool CRs to select what
+	// happens when the node pool state machine stays in a state longer than
+	// its deadline. Supported values are "report" (default), which emits an
+	// event and sets a condition, "retry", which additionally restarts the
+	// rolling update, and "rollback", which additionally aborts the upgrade.
+	UpgradeTimeoutPolicy = "azure-machine-pool.giantswarm.io/upgrade-timeout-policy"
+
 	// UpgradingToNodePools is set to True during the first cluster upgrade to node pools release.
 	UpgradingToNodePools = "release.giantswarm.io/upgrading-to-node-pools"
 
 	WorkersEgressExternalPublicIP = "giantswarm.io/workers-egress-external-public-ip"
+
+	// DualStack is set to "true" on AzureCluster CRs to allocate an IPv6
+	// range in addition to the IPv4 range for the VNet and the node pool
+	// subnets of the cluster. It requires the installation IPv6 range to be
+	// configured.
+	DualStack = "azure-operator.giantswarm.io/dual-stack"
+
+	// VirtualNetworkIPv6CIDR holds the IPv6 range IPAM allocated for the VNet
+	// of a dual-stack cluster on AzureConfig CRs.
+	VirtualNetworkIPv6CIDR = "azure-operator.giantswarm.io/virtual-network-ipv6-cidr"
+
+	// ExistingVirtualNetworkID holds the resource ID of the existing VNet
+	// referenced by the AzureCluster of a cluster on AzureConfig CRs. The
+	// master and worker subnets are created in that VNet instead of a VNet
+	// owned by the cluster.
+	ExistingVirtualNetworkID = "azure-operator.giantswarm.io/existing-virtual-network-id"
 )
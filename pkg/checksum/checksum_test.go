@@ -353,10 +353,12 @@ func getDeployment(data testData) (*resources.Deployment, error) {
 	encodedMasterCloudConfig := base64.StdEncoding.EncodeToString([]byte(masterCloudConfig))
 
 	c = vmss.SmallCloudconfigConfig{
-		BlobURL:       data.workerBlobUrl,
-		EncryptionKey: data.workerEncryptionKey,
-		InitialVector: data.workerInitialVector,
-		InstanceRole:  data.workerInstanceRole,
+		BlobURL:        data.workerBlobUrl,
+		DockerDiskLun:  21,
+		EncryptionKey:  data.workerEncryptionKey,
+		InitialVector:  data.workerInitialVector,
+		InstanceRole:   data.workerInstanceRole,
+		KubeletDiskLun: 22,
 	}
 	workerCloudConfig, err := templates.Render(data.cloudConfigSmallTemplates, c)
 	if err != nil {
@@ -0,0 +1,204 @@
+package drainer
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+)
+
+const (
+	// blockedPodsAnnotation is set on nodes being drained. It lists the pods
+	// whose eviction is blocked by a PodDisruptionBudget, the budgets blocking
+	// them and when their eviction is retried.
+	blockedPodsAnnotation = "giantswarm.io/drain-blocked-pods"
+
+	evictionBlockedReason        = "EvictionBlocked"
+	podDeletedAfterBlockedReason = "PodDeletedAfterEvictionBlocked"
+
+	initialEvictionBackoff = 30 * time.Second
+	maxEvictionBackoff     = 5 * time.Minute
+)
+
+// blockedPod holds the eviction state of a pod blocked by a
+// PodDisruptionBudget.
+type blockedPod struct {
+	Pod          string    `json:"pod"`
+	PDBs         []string  `json:"pdbs,omitempty"`
+	Attempts     int       `json:"attempts"`
+	FirstBlocked time.Time `json:"firstBlocked"`
+	NextRetry    time.Time `json:"nextRetry"`
+}
+
+// evictionBackoff returns how long to wait before retrying the eviction of a
+// pod that was blocked the given number of times.
+func evictionBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	backoff := initialEvictionBackoff
+	for i := 1; i < attempts && backoff < maxEvictionBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxEvictionBackoff {
+		backoff = maxEvictionBackoff
+	}
+
+	return backoff
+}
+
+// evictWithBackoff evicts the pod unless it was blocked recently. Pods
+// blocked longer than the PDB deletion grace period are deleted when the
+// grace period is configured. The updated eviction state of blocked pods is
+// added to blocked.
+func (d *Drainer) evictWithBackoff(ctx context.Context, pod corev1.Pod, previous map[string]blockedPod, blocked map[string]blockedPod) error {
+	now := time.Now().UTC()
+	podKey := pod.GetNamespace() + "/" + pod.GetName()
+
+	b, wasBlocked := previous[podKey]
+	if wasBlocked && now.Before(b.NextRetry) {
+		d.logger.Debugf(ctx, "Eviction of pod %q is blocked by %v, retrying in %v", podKey, b.PDBs, b.NextRetry.Sub(now).Round(time.Second))
+		blocked[podKey] = b
+		return nil
+	}
+
+	if wasBlocked && d.pdbDeletionGracePeriod > 0 && now.Sub(b.FirstBlocked) > d.pdbDeletionGracePeriod {
+		d.logger.Debugf(ctx, "Eviction of pod %q has been blocked for more than %v, deleting it", podKey, d.pdbDeletionGracePeriod)
+
+		err := d.wcClients.CtrlClient().Delete(ctx, &pod, &client.DeleteOptions{GracePeriodSeconds: terminationGracePeriod(pod)})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		d.emitPodEvent(ctx, &pod, event.TypeWarning, podDeletedAfterBlockedReason, "Pod was deleted because its eviction was blocked by PodDisruptionBudgets %v for more than %v", b.PDBs, d.pdbDeletionGracePeriod)
+
+		return nil
+	}
+
+	err := d.evict(ctx, pod)
+	if IsEvictionBlocked(err) {
+		if !wasBlocked {
+			pdbs, err := d.blockingPDBs(ctx, pod)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			b = blockedPod{
+				Pod:          podKey,
+				PDBs:         pdbs,
+				FirstBlocked: now,
+			}
+
+			d.emitPodEvent(ctx, &pod, event.TypeWarning, evictionBlockedReason, "Eviction from node being drained is blocked by PodDisruptionBudgets %v", pdbs)
+		}
+
+		b.Attempts++
+		b.NextRetry = now.Add(evictionBackoff(b.Attempts))
+		blocked[podKey] = b
+
+		d.logger.Debugf(ctx, "Eviction of pod %q is blocked by %v, retrying in %v", podKey, b.PDBs, evictionBackoff(b.Attempts))
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// saveBlockedPods records the pods whose eviction is blocked in a node
+// annotation. The node is only updated when the blocked pods changed.
+func (d *Drainer) saveBlockedPods(ctx context.Context, node corev1.Node, previous map[string]blockedPod, blocked map[string]blockedPod) error {
+	previousValue, err := formatBlockedPods(previous)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	value, err := formatBlockedPods(blocked)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if value == previousValue {
+		return nil
+	}
+
+	p := client.MergeFrom(node.DeepCopy())
+	if len(blocked) == 0 {
+		delete(node.Annotations, blockedPodsAnnotation)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[blockedPodsAnnotation] = value
+	}
+
+	err = d.wcClients.CtrlClient().Patch(ctx, &node, p)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (d *Drainer) emitPodEvent(ctx context.Context, pod *corev1.Pod, eventType, reason, messageFmt string, args ...interface{}) {
+	err := d.eventRecorder.Emit(ctx, pod, eventType, reason, messageFmt, args...)
+	if err != nil {
+		// Events are informative, failing to create them must not block
+		// draining.
+		d.logger.Debugf(ctx, "failed to emit event %#q for pod %#q: %s", reason, pod.GetNamespace()+"/"+pod.GetName(), err)
+	}
+}
+
+// parseBlockedPods returns the blocked pods recorded on the node. A malformed
+// annotation is ignored, it is overwritten once the drain carries on.
+func parseBlockedPods(node corev1.Node) map[string]blockedPod {
+	blocked := map[string]blockedPod{}
+
+	v, exists := node.Annotations[blockedPodsAnnotation]
+	if !exists {
+		return blocked
+	}
+
+	var list []blockedPod
+	err := json.Unmarshal([]byte(v), &list)
+	if err != nil {
+		return blocked
+	}
+
+	for _, b := range list {
+		blocked[b.Pod] = b
+	}
+
+	return blocked
+}
+
+// formatBlockedPods formats the blocked pods annotation value with pods
+// sorted by name.
+func formatBlockedPods(blocked map[string]blockedPod) (string, error) {
+	list := make([]blockedPod, 0, len(blocked))
+	for _, b := range blocked {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pod < list[j].Pod
+	})
+
+	value, err := json.Marshal(list)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(value), nil
+}
@@ -0,0 +1,347 @@
+package drainer
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_evictionBackoff(t *testing.T) {
+	testCases := []struct {
+		name            string
+		attempts        int
+		expectedBackoff time.Duration
+	}{
+		{
+			name:            "case 0: never blocked",
+			attempts:        0,
+			expectedBackoff: 0,
+		},
+		{
+			name:            "case 1: blocked once",
+			attempts:        1,
+			expectedBackoff: 30 * time.Second,
+		},
+		{
+			name:            "case 2: backoff doubles",
+			attempts:        3,
+			expectedBackoff: 2 * time.Minute,
+		},
+		{
+			name:            "case 3: backoff is capped",
+			attempts:        10,
+			expectedBackoff: 5 * time.Minute,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			backoff := evictionBackoff(tc.attempts)
+			if backoff != tc.expectedBackoff {
+				t.Fatalf("backoff == %v, want %v", backoff, tc.expectedBackoff)
+			}
+		})
+	}
+}
+
+func Test_parseBlockedPods(t *testing.T) {
+	firstBlocked := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		annotations     map[string]string
+		expectedBlocked map[string]blockedPod
+	}{
+		{
+			name:            "case 0: no annotation",
+			expectedBlocked: map[string]blockedPod{},
+		},
+		{
+			name: "case 1: malformed annotation is ignored",
+			annotations: map[string]string{
+				blockedPodsAnnotation: "not json",
+			},
+			expectedBlocked: map[string]blockedPod{},
+		},
+		{
+			name: "case 2: blocked pods",
+			annotations: map[string]string{
+				blockedPodsAnnotation: `[{"pod":"default/web-1","pdbs":["default/web"],"attempts":2,"firstBlocked":"2021-06-01T10:00:00Z","nextRetry":"2021-06-01T10:01:30Z"}]`,
+			},
+			expectedBlocked: map[string]blockedPod{
+				"default/web-1": {
+					Pod:          "default/web-1",
+					PDBs:         []string{"default/web"},
+					Attempts:     2,
+					FirstBlocked: firstBlocked,
+					NextRetry:    firstBlocked.Add(90 * time.Second),
+				},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			node := corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+
+			blocked := parseBlockedPods(node)
+
+			if !cmp.Equal(blocked, tc.expectedBlocked) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedBlocked, blocked))
+			}
+
+			// Formatting the parsed pods must be stable.
+			if len(blocked) > 0 {
+				formatted, err := formatBlockedPods(blocked)
+				if err != nil {
+					t.Fatalf("unexpected error %#v", err)
+				}
+				if formatted != tc.annotations[blockedPodsAnnotation] {
+					t.Fatalf("formatted == %q, want %q", formatted, tc.annotations[blockedPodsAnnotation])
+				}
+			}
+		})
+	}
+}
+
+func Test_Drainer_DrainNode_BlockedEviction(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	pdbDeletionGracePeriod := time.Hour
+
+	testCases := []struct {
+		name                   string
+		previous               []blockedPod
+		pdbDeletionGracePeriod time.Duration
+		evictionBlocked        bool
+		expectedEvictions      int
+		expectedPodDeleted     bool
+		expectedBlocked        *blockedPod
+		expectedBackoff        time.Duration
+		expectedEventReasons   []string
+	}{
+		{
+			name:                   "case 0: blocked eviction is recorded with the blocking PDB",
+			pdbDeletionGracePeriod: pdbDeletionGracePeriod,
+			evictionBlocked:        true,
+			expectedEvictions:      1,
+			expectedBlocked: &blockedPod{
+				Pod:      "default/web-1",
+				PDBs:     []string{"default/web"},
+				Attempts: 1,
+			},
+			expectedBackoff:      30 * time.Second,
+			expectedEventReasons: []string{evictionBlockedReason},
+		},
+		{
+			name: "case 1: eviction is not retried before the backoff passed",
+			previous: []blockedPod{
+				{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 1, FirstBlocked: now.Add(-10 * time.Second), NextRetry: now.Add(20 * time.Second)},
+			},
+			pdbDeletionGracePeriod: pdbDeletionGracePeriod,
+			evictionBlocked:        true,
+			expectedEvictions:      0,
+			expectedBlocked:        &blockedPod{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 1, FirstBlocked: now.Add(-10 * time.Second), NextRetry: now.Add(20 * time.Second)},
+		},
+		{
+			name: "case 2: eviction blocked again doubles the backoff",
+			previous: []blockedPod{
+				{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 1, FirstBlocked: now.Add(-time.Minute), NextRetry: now.Add(-30 * time.Second)},
+			},
+			pdbDeletionGracePeriod: pdbDeletionGracePeriod,
+			evictionBlocked:        true,
+			expectedEvictions:      1,
+			expectedBlocked:        &blockedPod{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 2, FirstBlocked: now.Add(-time.Minute)},
+			expectedBackoff:        time.Minute,
+		},
+		{
+			name: "case 3: successful eviction clears the blocked pods annotation",
+			previous: []blockedPod{
+				{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 1, FirstBlocked: now.Add(-time.Minute), NextRetry: now.Add(-30 * time.Second)},
+			},
+			pdbDeletionGracePeriod: pdbDeletionGracePeriod,
+			evictionBlocked:        false,
+			expectedEvictions:      1,
+		},
+		{
+			name: "case 4: pod blocked longer than the grace period is deleted",
+			previous: []blockedPod{
+				{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 8, FirstBlocked: now.Add(-2 * time.Hour), NextRetry: now.Add(-time.Minute)},
+			},
+			pdbDeletionGracePeriod: pdbDeletionGracePeriod,
+			evictionBlocked:        true,
+			expectedEvictions:      0,
+			expectedPodDeleted:     true,
+			expectedEventReasons:   []string{podDeletedAfterBlockedReason},
+		},
+		{
+			name: "case 5: pod is never deleted without grace period",
+			previous: []blockedPod{
+				{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 8, FirstBlocked: now.Add(-2 * time.Hour), NextRetry: now.Add(-time.Minute)},
+			},
+			pdbDeletionGracePeriod: 0,
+			evictionBlocked:        true,
+			expectedEvictions:      1,
+			expectedBlocked:        &blockedPod{Pod: "default/web-1", PDBs: []string{"default/web"}, Attempts: 9, FirstBlocked: now.Add(-2 * time.Hour)},
+			expectedBackoff:        5 * time.Minute,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "worker-0",
+					Annotations: map[string]string{
+						drainStartedAnnotation: now.Format(time.RFC3339),
+					},
+				},
+			}
+			if len(tc.previous) > 0 {
+				previous := map[string]blockedPod{}
+				for _, b := range tc.previous {
+					previous[b.Pod] = b
+				}
+				value, err := formatBlockedPods(previous)
+				if err != nil {
+					t.Fatalf("unexpected error %#v", err)
+				}
+				node.Annotations[blockedPodsAnnotation] = value
+			}
+
+			pod := podOnNode(newPod("web-1", "default", nil), node.Name)
+			pod.Labels = map[string]string{"app": "web"}
+
+			objects := []client.Object{
+				node,
+				pod,
+				&policyv1.PodDisruptionBudget{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+					Spec: policyv1.PodDisruptionBudgetSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					},
+				},
+				&policyv1.PodDisruptionBudget{
+					ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+					Spec: policyv1.PodDisruptionBudgetSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+					},
+				},
+			}
+
+			k8sClient := kubernetesfake.NewSimpleClientset()
+			k8sClient.Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1"},
+					},
+				},
+			}
+
+			var evictions int
+			k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+
+				evictions++
+				if tc.evictionBlocked {
+					return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+				}
+				return true, nil, nil
+			})
+
+			d := newTestDrainer(t, DefaultRules(), k8sClient, objects...)
+			d.pdbDeletionGracePeriod = tc.pdbDeletionGracePeriod
+			ctrlClient := d.wcClients.CtrlClient()
+
+			before := time.Now().UTC()
+			err := d.DrainNode(context.Background(), node.Name, time.Hour)
+			after := time.Now().UTC()
+			if !IsEvictionInProgress(err) {
+				t.Fatalf("expected evictionInProgressError, got %#v", err)
+			}
+
+			if evictions != tc.expectedEvictions {
+				t.Fatalf("evictions == %d, want %d", evictions, tc.expectedEvictions)
+			}
+
+			err = ctrlClient.Get(context.Background(), client.ObjectKeyFromObject(pod), &corev1.Pod{})
+			if tc.expectedPodDeleted && !apierrors.IsNotFound(err) {
+				t.Fatalf("expected pod to be deleted, got %#v", err)
+			} else if !tc.expectedPodDeleted && err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			updatedNode := corev1.Node{}
+			err = ctrlClient.Get(context.Background(), client.ObjectKeyFromObject(node), &updatedNode)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			blocked := parseBlockedPods(updatedNode)
+
+			if tc.expectedBlocked == nil {
+				if _, exists := updatedNode.Annotations[blockedPodsAnnotation]; exists {
+					t.Fatalf("expected annotation %#q to be removed, got %q", blockedPodsAnnotation, updatedNode.Annotations[blockedPodsAnnotation])
+				}
+			} else {
+				b, ok := blocked[tc.expectedBlocked.Pod]
+				if !ok {
+					t.Fatalf("expected pod %#q to be blocked, got %v", tc.expectedBlocked.Pod, blocked)
+				}
+
+				expected := *tc.expectedBlocked
+				if tc.expectedBackoff > 0 {
+					if b.NextRetry.Before(before.Add(tc.expectedBackoff)) || b.NextRetry.After(after.Add(tc.expectedBackoff)) {
+						t.Fatalf("next retry == %s, want %s after the eviction", b.NextRetry, tc.expectedBackoff)
+					}
+					expected.NextRetry = b.NextRetry
+				}
+				if expected.FirstBlocked.IsZero() {
+					if b.FirstBlocked.Before(before) || b.FirstBlocked.After(after) {
+						t.Fatalf("first blocked == %s, want time of the eviction", b.FirstBlocked)
+					}
+					expected.FirstBlocked = b.FirstBlocked
+				}
+
+				if !cmp.Equal(b, expected) {
+					t.Fatalf("\n\n%s\n", cmp.Diff(expected, b))
+				}
+			}
+
+			var events corev1.EventList
+			err = ctrlClient.List(context.Background(), &events)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			var reasons []string
+			for _, e := range events.Items {
+				reasons = append(reasons, e.Reason)
+			}
+			if !cmp.Equal(reasons, tc.expectedEventReasons) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedEventReasons, reasons))
+			}
+		})
+	}
+}
@@ -11,19 +11,36 @@ import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/to"
 	corev1 "k8s.io/api/core/v1"
-	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+)
+
+const (
+	drainStartedAnnotation = "giantswarm.io/drain-started-ts"
 )
 
 type Drainer struct {
-	logger    micrologger.Logger
-	wcClients k8sclient.Interface
+	eventRecorder          *event.Recorder
+	logger                 micrologger.Logger
+	pdbDeletionGracePeriod time.Duration
+	rules                  Rules
+	wcClients              k8sclient.Interface
+
+	evictionVersion *schema.GroupVersion
 }
 
 type Config struct {
 	Logger    micrologger.Logger
 	WCClients k8sclient.Interface
+
+	// PDBDeletionGracePeriod is the time after which pods whose eviction is
+	// blocked by a PodDisruptionBudget are deleted. Pods are never deleted
+	// when it is zero.
+	PDBDeletionGracePeriod time.Duration
+	// Rules select the pods evicted when draining nodes, see DefaultRules.
+	Rules Rules
 }
 
 func New(config Config) (*Drainer, error) {
@@ -34,9 +51,20 @@ func New(config Config) (*Drainer, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.WCClients must not be empty", config)
 	}
 
+	eventRecorder, err := event.New(event.Config{
+		CtrlClient: config.WCClients.CtrlClient(),
+		Logger:     config.Logger,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
 	return &Drainer{
-		logger:    config.Logger,
-		wcClients: config.WCClients,
+		eventRecorder:          eventRecorder,
+		logger:                 config.Logger,
+		pdbDeletionGracePeriod: config.PDBDeletionGracePeriod,
+		rules:                  config.Rules,
+		wcClients:              config.WCClients,
 	}, nil
 }
 
@@ -63,6 +91,39 @@ func (d *Drainer) CordonNode(ctx context.Context, nodename string) error {
 	return nil
 }
 
+// UncordonNode makes the node schedulable again and resets the drain timeout
+// tracked on the node.
+func (d *Drainer) UncordonNode(ctx context.Context, nodename string) error {
+	node := corev1.Node{}
+	err := d.wcClients.CtrlClient().Get(ctx, client.ObjectKey{Name: nodename}, &node)
+	if apierrors.IsNotFound(err) {
+		d.logger.Debugf(ctx, "Node %q was not found, it was probably already drained and deleted", nodename)
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_, draining := node.Annotations[drainStartedAnnotation]
+	if !node.Spec.Unschedulable && !draining {
+		// Node not cordoned.
+		return nil
+	}
+
+	p := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = false
+	delete(node.Annotations, drainStartedAnnotation)
+	delete(node.Annotations, blockedPodsAnnotation)
+	err = d.wcClients.CtrlClient().Patch(ctx, &node, p)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
 func (d *Drainer) DrainNode(ctx context.Context, nodename string, timeout time.Duration) error {
 	d.logger.Debugf(ctx, "Getting node %q for draining", nodename)
 	node := corev1.Node{}
@@ -74,7 +135,7 @@ func (d *Drainer) DrainNode(ctx context.Context, nodename string, timeout time.D
 		return microerror.Mask(err)
 	}
 
-	annotationName := "giantswarm.io/drain-started-ts"
+	annotationName := drainStartedAnnotation
 	format := time.RFC3339
 
 	startDateStr, found := node.Annotations[annotationName]
@@ -94,6 +155,9 @@ func (d *Drainer) DrainNode(ctx context.Context, nodename string, timeout time.D
 	}
 
 	if !found {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
 		node.Annotations[annotationName] = time.Now().UTC().Format(format)
 		err = d.wcClients.CtrlClient().Update(ctx, &node)
 		if err != nil {
@@ -120,8 +184,8 @@ func (d *Drainer) cordon(ctx context.Context, node corev1.Node) error {
 }
 
 func (d *Drainer) evictPods(ctx context.Context, node corev1.Node) error {
-	var customPods []corev1.Pod
-	var kubesystemPods []corev1.Pod
+	var evictable []corev1.Pod
+	var notEvictable []corev1.Pod
 	{
 		podList := corev1.PodList{}
 		err := d.wcClients.CtrlClient().List(ctx, &podList, client.MatchingFields{"spec.nodeName": node.GetName()})
@@ -129,80 +193,49 @@ func (d *Drainer) evictPods(ctx context.Context, node corev1.Node) error {
 			return microerror.Mask(err)
 		}
 
-		for _, pod := range podList.Items {
-			if isCriticalPod(pod.Name) {
-				// ignore critical pods (api, controller-manager and scheduler)
-				// they are static pods so kubelet will recreate them anyway and it can cause other issues
-				continue
-			}
-			if isDaemonSetPod(pod) {
-				// ignore daemonSet owned pods
-				// daemonSets pod are recreated even on unschedulable node so draining doesn't make sense
-				// we are aligning here with community as 'kubectl drain' also ignore them
-				continue
-			}
-			if isEvictedPod(pod) {
-				// we don't need to care about already evicted pods
-				continue
-			}
+		evictable, notEvictable, err = d.filterPods(ctx, podList.Items)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
 
-			if pod.GetNamespace() == "kube-system" {
-				kubesystemPods = append(kubesystemPods, pod)
-			} else {
-				customPods = append(customPods, pod)
-			}
+	var waitingFor int
+	for _, pod := range notEvictable {
+		if isDaemonSetPod(pod) || isMirrorPod(pod) {
+			// DaemonSet and mirror pods are recreated on the node as long as
+			// it exists, waiting for them to be removed would block the
+			// drain until it times out.
+			d.logger.Debugf(ctx, "Pod %q on node %q is not evicted because of the drain rules and is left on the node", pod.GetNamespace()+"/"+pod.GetName(), node.GetName())
+			continue
 		}
+
+		d.logger.Debugf(ctx, "Pod %q on node %q is not evicted because of the drain rules, waiting for it to be removed", pod.GetNamespace()+"/"+pod.GetName(), node.GetName())
+		waitingFor++
 	}
 
-	left := len(customPods) + len(kubesystemPods)
+	left := len(evictable) + waitingFor
 	if left == 0 {
 		return nil
 	}
 
-	if len(customPods) > 0 {
-		for _, pod := range customPods {
-			err := d.evict(ctx, pod)
-			if IsCannotEvictPod(err) {
-				continue
-			} else if err != nil {
-				return microerror.Mask(err)
-			}
-		}
-	}
+	previous := parseBlockedPods(node)
+	blocked := map[string]blockedPod{}
 
-	if len(kubesystemPods) > 0 && len(customPods) == 0 {
-		for _, pod := range kubesystemPods {
-			err := d.evict(ctx, pod)
-			if IsCannotEvictPod(err) {
-				continue
-			} else if err != nil {
-				return microerror.Mask(err)
-			}
+	for _, pod := range nextEvictionTier(evictable, d.rules.EvictByPriority) {
+		err := d.evictWithBackoff(ctx, pod, previous, blocked)
+		if IsCannotEvictPod(err) {
+			continue
+		} else if err != nil {
+			return microerror.Mask(err)
 		}
 	}
 
-	return microerror.Maskf(evictionInProgressError, "%d pods still pending eviction, waiting", left)
-}
-
-func (d *Drainer) evict(ctx context.Context, pod corev1.Pod) error {
-	eviction := &policyv1beta1.Eviction{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pod.GetName(),
-			Namespace: pod.GetNamespace(),
-		},
-		DeleteOptions: &metav1.DeleteOptions{
-			GracePeriodSeconds: terminationGracePeriod(pod),
-		},
-	}
-
-	err := d.wcClients.K8sClient().PolicyV1beta1().Evictions(eviction.GetNamespace()).Evict(ctx, eviction)
-	if IsCannotEvictPod(err) {
-		return microerror.Mask(cannotEvictPodError)
-	} else if err != nil {
+	err := d.saveBlockedPods(ctx, node, previous, blocked)
+	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	return nil
+	return microerror.Maskf(evictionInProgressError, "%d pods still pending eviction, %d of them not evictable because of the drain rules, waiting", left, waitingFor)
 }
 
 func terminationGracePeriod(pod corev1.Pod) *int64 {
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 var alreadyCordonedError = &microerror.Error{
@@ -32,6 +33,27 @@ func IsCannotEvictPod(err error) bool {
 	return c == cannotEvictPodError
 }
 
+var evictionBlockedError = &microerror.Error{
+	Kind: "evictionBlockedError",
+}
+
+// IsEvictionBlocked asserts evictionBlockedError and the HTTP 429 responses
+// of the eviction API, returned when a PodDisruptionBudget does not allow the
+// eviction.
+func IsEvictionBlocked(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	c := microerror.Cause(err)
+
+	if apierrors.IsTooManyRequests(c) {
+		return true
+	}
+
+	return c == evictionBlockedError
+}
+
 var evictionInProgressError = &microerror.Error{
 	Kind: "evictionInProgressError",
 }
@@ -0,0 +1,163 @@
+package drainer
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	policyV1      = policyv1.SchemeGroupVersion
+	policyV1beta1 = policyv1beta1.SchemeGroupVersion
+)
+
+// evictionGroupVersion returns the group version of the eviction API served
+// by the workload cluster. Clusters older than Kubernetes 1.22 only serve
+// policy/v1beta1.
+func (d *Drainer) evictionGroupVersion() (schema.GroupVersion, error) {
+	if d.evictionVersion != nil {
+		return *d.evictionVersion, nil
+	}
+
+	resources, err := d.wcClients.K8sClient().Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		return schema.GroupVersion{}, microerror.Mask(err)
+	}
+
+	gv := evictionGroupVersionFromResources(resources)
+	d.evictionVersion = &gv
+
+	return gv, nil
+}
+
+// evictionGroupVersionFromResources picks the eviction group version from the
+// core API resources the same way kubectl drain does.
+func evictionGroupVersionFromResources(resources *metav1.APIResourceList) schema.GroupVersion {
+	if resources != nil {
+		for _, r := range resources.APIResources {
+			if r.Name == "pods/eviction" && r.Kind == "Eviction" && r.Group != "" && r.Version != "" {
+				return schema.GroupVersion{Group: r.Group, Version: r.Version}
+			}
+		}
+	}
+
+	return policyV1beta1
+}
+
+func (d *Drainer) evict(ctx context.Context, pod corev1.Pod) error {
+	gv, err := d.evictionGroupVersion()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	deleteOptions := &metav1.DeleteOptions{
+		GracePeriodSeconds: terminationGracePeriod(pod),
+	}
+
+	if gv == policyV1 {
+		eviction := &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.GetName(),
+				Namespace: pod.GetNamespace(),
+			},
+			DeleteOptions: deleteOptions,
+		}
+
+		err = d.wcClients.K8sClient().PolicyV1().Evictions(eviction.GetNamespace()).Evict(ctx, eviction)
+	} else {
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.GetName(),
+				Namespace: pod.GetNamespace(),
+			},
+			DeleteOptions: deleteOptions,
+		}
+
+		err = d.wcClients.K8sClient().PolicyV1beta1().Evictions(eviction.GetNamespace()).Evict(ctx, eviction)
+	}
+	if IsEvictionBlocked(err) {
+		return microerror.Mask(evictionBlockedError)
+	} else if IsCannotEvictPod(err) {
+		return microerror.Mask(cannotEvictPodError)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// podDisruptionBudget is the part of a PodDisruptionBudget of any API version
+// needed to find the budgets blocking the eviction of a pod.
+type podDisruptionBudget struct {
+	Name     string
+	Selector *metav1.LabelSelector
+	// EmptySelectorMatchesAll is true for policy/v1 budgets. An empty
+	// policy/v1beta1 selector matches no pods.
+	EmptySelectorMatchesAll bool
+}
+
+// blockingPDBs returns the names of the PodDisruptionBudgets selecting the
+// given pod.
+func (d *Drainer) blockingPDBs(ctx context.Context, pod corev1.Pod) ([]string, error) {
+	gv, err := d.evictionGroupVersion()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var pdbs []podDisruptionBudget
+	if gv == policyV1 {
+		var list policyv1.PodDisruptionBudgetList
+		err = d.wcClients.CtrlClient().List(ctx, &list, client.InNamespace(pod.GetNamespace()))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, pdb := range list.Items {
+			pdbs = append(pdbs, podDisruptionBudget{Name: pdb.Name, Selector: pdb.Spec.Selector, EmptySelectorMatchesAll: true})
+		}
+	} else {
+		var list policyv1beta1.PodDisruptionBudgetList
+		err = d.wcClients.CtrlClient().List(ctx, &list, client.InNamespace(pod.GetNamespace()))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, pdb := range list.Items {
+			pdbs = append(pdbs, podDisruptionBudget{Name: pdb.Name, Selector: pdb.Spec.Selector})
+		}
+	}
+
+	return matchingPDBs(pod, pdbs), nil
+}
+
+// matchingPDBs returns the namespaced names of the budgets selecting the pod.
+// Budgets with an invalid selector are ignored.
+func matchingPDBs(pod corev1.Pod, pdbs []podDisruptionBudget) []string {
+	var names []string
+	for _, pdb := range pdbs {
+		if pdb.Selector == nil || (len(pdb.Selector.MatchLabels) == 0 && len(pdb.Selector.MatchExpressions) == 0) {
+			if pdb.EmptySelectorMatchesAll {
+				names = append(names, pod.GetNamespace()+"/"+pdb.Name)
+			}
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Selector)
+		if err != nil {
+			continue
+		}
+
+		if selector.Matches(labels.Set(pod.GetLabels())) {
+			names = append(names, pod.GetNamespace()+"/"+pdb.Name)
+		}
+	}
+
+	return names
+}
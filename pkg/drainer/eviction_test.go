@@ -0,0 +1,108 @@
+package drainer
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_evictionGroupVersionFromResources(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		resources            *metav1.APIResourceList
+		expectedGroupVersion schema.GroupVersion
+	}{
+		{
+			name:                 "case 0: no resources",
+			expectedGroupVersion: policyV1beta1,
+		},
+		{
+			name: "case 1: policy/v1 served",
+			resources: &metav1.APIResourceList{
+				APIResources: []metav1.APIResource{
+					{Name: "pods"},
+					{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1"},
+				},
+			},
+			expectedGroupVersion: policyV1,
+		},
+		{
+			name: "case 2: eviction without group version",
+			resources: &metav1.APIResourceList{
+				APIResources: []metav1.APIResource{
+					{Name: "pods/eviction", Kind: "Eviction"},
+				},
+			},
+			expectedGroupVersion: policyV1beta1,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			gv := evictionGroupVersionFromResources(tc.resources)
+			if gv != tc.expectedGroupVersion {
+				t.Fatalf("group version == %v, want %v", gv, tc.expectedGroupVersion)
+			}
+		})
+	}
+}
+
+func Test_matchingPDBs(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-1",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "web"},
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		pdbs         []podDisruptionBudget
+		expectedPDBs []string
+	}{
+		{
+			name: "case 0: no budgets",
+		},
+		{
+			name: "case 1: matching and not matching selectors",
+			pdbs: []podDisruptionBudget{
+				{Name: "web", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+				{Name: "db", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+			},
+			expectedPDBs: []string{"default/web"},
+		},
+		{
+			name: "case 2: empty selector matches all pods in policy/v1 only",
+			pdbs: []podDisruptionBudget{
+				{Name: "all-v1", Selector: &metav1.LabelSelector{}, EmptySelectorMatchesAll: true},
+				{Name: "all-v1beta1", Selector: &metav1.LabelSelector{}},
+			},
+			expectedPDBs: []string{"default/all-v1"},
+		},
+		{
+			name: "case 3: invalid selector is ignored",
+			pdbs: []podDisruptionBudget{
+				{Name: "invalid", Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}}}},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			pdbs := matchingPDBs(pod, tc.pdbs)
+
+			if !cmp.Equal(pdbs, tc.expectedPDBs) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedPDBs, pdbs))
+			}
+		})
+	}
+}
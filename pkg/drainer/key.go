@@ -35,3 +35,18 @@ func isDaemonSetPod(pod v1.Pod) bool {
 func isEvictedPod(pod v1.Pod) bool {
 	return pod.Status.Reason == "Evicted"
 }
+
+func isMirrorPod(pod v1.Pod) bool {
+	_, ok := pod.Annotations[v1.MirrorPodAnnotationKey]
+	return ok
+}
+
+func hasEmptyDirVolume(pod v1.Pod) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.EmptyDir != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,233 @@
+package drainer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+// systemCriticalPriority is the priority of the system-cluster-critical
+// priority class. Pods with a higher priority, i.e. system-node-critical
+// pods, are critical as well.
+const systemCriticalPriority int32 = 2000000000
+
+// Rules select which pods are evicted when draining a node and in which order
+// they are evicted. Pods that are neither skipped nor allowed to be evicted
+// block the drain until they are removed or the drain times out, the same way
+// kubectl drain refuses to drain such nodes. DaemonSet and mirror pods are
+// never removed from a node while it exists, so they are left on the node
+// instead of blocking the drain.
+type Rules struct {
+	// DeleteEmptyDirData allows evicting pods using emptyDir volumes, whose
+	// data is lost.
+	DeleteEmptyDirData bool
+	// DeleteLocalPVData allows evicting pods using local or hostPath
+	// persistent volumes, whose data stays on the node being removed.
+	DeleteLocalPVData bool
+	// EvictByPriority evicts pods in order of their priority so that system
+	// critical pods go last. When false, pods in the kube-system namespace
+	// are evicted once all other pods are gone.
+	EvictByPriority bool
+	// IgnoreDaemonSets skips pods managed by a DaemonSet. DaemonSet pods are
+	// recreated on unschedulable nodes, so evicting them is pointless. When
+	// false they are reported as not evictable.
+	IgnoreDaemonSets bool
+	// SkipMirrorPods skips the mirror pods of static pods. They cannot be
+	// evicted through the API server. When false they are reported as not
+	// evictable.
+	SkipMirrorPods bool
+}
+
+// DefaultRules returns the rules used when draining nodes, equivalent to
+// kubectl drain --ignore-daemonsets --delete-emptydir-data with pods evicted
+// by priority.
+func DefaultRules() Rules {
+	return Rules{
+		DeleteEmptyDirData: true,
+		DeleteLocalPVData:  true,
+		EvictByPriority:    true,
+		IgnoreDaemonSets:   true,
+		SkipMirrorPods:     true,
+	}
+}
+
+// WithOverrides returns the rules overridden by the drain annotations of a
+// cluster. Annotations with values other than "true" or "false" are ignored.
+func (r Rules) WithOverrides(annotations map[string]string) Rules {
+	overrides := []struct {
+		annotation string
+		rule       *bool
+	}{
+		{annotation: annotation.DrainDeleteEmptyDirData, rule: &r.DeleteEmptyDirData},
+		{annotation: annotation.DrainDeleteLocalPVData, rule: &r.DeleteLocalPVData},
+		{annotation: annotation.DrainEvictByPriority, rule: &r.EvictByPriority},
+		{annotation: annotation.DrainIgnoreDaemonSets, rule: &r.IgnoreDaemonSets},
+		{annotation: annotation.DrainSkipMirrorPods, rule: &r.SkipMirrorPods},
+	}
+
+	for _, o := range overrides {
+		v, exists := annotations[o.annotation]
+		if !exists {
+			continue
+		}
+
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			continue
+		}
+
+		*o.rule = b
+	}
+
+	return r
+}
+
+// filterPods splits the pods running on a node being drained into the pods to
+// evict and the pods the rules do not allow to evict. Skipped pods are in
+// neither list.
+func (d *Drainer) filterPods(ctx context.Context, pods []corev1.Pod) ([]corev1.Pod, []corev1.Pod, error) {
+	var evictable []corev1.Pod
+	var notEvictable []corev1.Pod
+
+	for _, pod := range pods {
+		if isCriticalPod(pod.Name) {
+			// ignore critical pods (api, controller-manager and scheduler)
+			// they are static pods so kubelet will recreate them anyway and it can cause other issues
+			continue
+		}
+		if isEvictedPod(pod) {
+			// we don't need to care about already evicted pods
+			continue
+		}
+
+		if isMirrorPod(pod) {
+			if !d.rules.SkipMirrorPods {
+				notEvictable = append(notEvictable, pod)
+			}
+			continue
+		}
+		if isDaemonSetPod(pod) {
+			// daemonSets pod are recreated even on unschedulable node so draining doesn't make sense
+			// we are aligning here with community as 'kubectl drain' also ignore them
+			if !d.rules.IgnoreDaemonSets {
+				notEvictable = append(notEvictable, pod)
+			}
+			continue
+		}
+		if hasEmptyDirVolume(pod) && !d.rules.DeleteEmptyDirData {
+			notEvictable = append(notEvictable, pod)
+			continue
+		}
+		if !d.rules.DeleteLocalPVData {
+			local, err := d.hasLocalPersistentVolume(ctx, pod)
+			if err != nil {
+				return nil, nil, microerror.Mask(err)
+			}
+			if local {
+				notEvictable = append(notEvictable, pod)
+				continue
+			}
+		}
+
+		evictable = append(evictable, pod)
+	}
+
+	return evictable, notEvictable, nil
+}
+
+// hasLocalPersistentVolume returns true when the pod claims a local or
+// hostPath persistent volume.
+func (d *Drainer) hasLocalPersistentVolume(ctx context.Context, pod corev1.Pod) (bool, error) {
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		pvc := corev1.PersistentVolumeClaim{}
+		err := d.wcClients.CtrlClient().Get(ctx, client.ObjectKey{Namespace: pod.GetNamespace(), Name: v.PersistentVolumeClaim.ClaimName}, &pvc)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		if pvc.Spec.VolumeName == "" {
+			// Claim not bound.
+			continue
+		}
+
+		pv := corev1.PersistentVolume{}
+		err = d.wcClients.CtrlClient().Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, &pv)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		if pv.Spec.Local != nil || pv.Spec.HostPath != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// evictionTier orders the eviction of pods. Only pods of the lowest tier
+// left on a node are evicted at a time.
+type evictionTier struct {
+	systemCritical bool
+	kubeSystem     bool
+	priority       int32
+}
+
+func (t evictionTier) less(o evictionTier) bool {
+	if t.systemCritical != o.systemCritical {
+		return !t.systemCritical
+	}
+	if t.kubeSystem != o.kubeSystem {
+		return !t.kubeSystem
+	}
+
+	return t.priority < o.priority
+}
+
+// nextEvictionTier returns the pods to evict next. Without priority ordering
+// pods outside the kube-system namespace are evicted first. With priority
+// ordering system critical pods are evicted last, kube-system pods before
+// them and all others first, each group in order of priority.
+func nextEvictionTier(pods []corev1.Pod, byPriority bool) []corev1.Pod {
+	tierOf := func(pod corev1.Pod) evictionTier {
+		t := evictionTier{
+			kubeSystem: pod.GetNamespace() == "kube-system",
+		}
+		if byPriority && pod.Spec.Priority != nil {
+			t.priority = *pod.Spec.Priority
+			t.systemCritical = t.priority >= systemCriticalPriority
+		}
+
+		return t
+	}
+
+	sorted := make([]corev1.Pod, len(pods))
+	copy(sorted, pods)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return tierOf(sorted[i]).less(tierOf(sorted[j]))
+	})
+
+	var next []corev1.Pod
+	for _, pod := range sorted {
+		if len(next) > 0 && tierOf(next[0]).less(tierOf(pod)) {
+			break
+		}
+		next = append(next, pod)
+	}
+
+	return next
+}
@@ -0,0 +1,403 @@
+package drainer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_Rules_WithOverrides(t *testing.T) {
+	testCases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedRules Rules
+	}{
+		{
+			name:          "case 0: no overrides",
+			expectedRules: DefaultRules(),
+		},
+		{
+			name: "case 1: overrides",
+			annotations: map[string]string{
+				annotation.DrainDeleteEmptyDirData: "false",
+				annotation.DrainEvictByPriority:    "false",
+			},
+			expectedRules: Rules{
+				DeleteLocalPVData: true,
+				IgnoreDaemonSets:  true,
+				SkipMirrorPods:    true,
+			},
+		},
+		{
+			name: "case 2: invalid values are ignored",
+			annotations: map[string]string{
+				annotation.DrainIgnoreDaemonSets: "maybe",
+			},
+			expectedRules: DefaultRules(),
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			rules := DefaultRules().WithOverrides(tc.annotations)
+
+			if !cmp.Equal(rules, tc.expectedRules) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedRules, rules))
+			}
+		})
+	}
+}
+
+func Test_Drainer_filterPods(t *testing.T) {
+	objects := []client.Object{
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "local-pv"},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-pv"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					Local: &corev1.LocalVolumeSource{Path: "/mnt/disks/1"},
+				},
+			},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "disk", Namespace: "default"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "disk-pv"},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "disk-pv"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					AzureDisk: &corev1.AzureDiskVolumeSource{DiskName: "disk"},
+				},
+			},
+		},
+	}
+
+	pods := []corev1.Pod{
+		newPod("web", "default", nil),
+		newPod("k8s-api-server-master-0", "kube-system", nil),
+		withMirror(newPod("kube-proxy", "kube-system", nil)),
+		withDaemonSet(newPod("node-exporter", "monitoring", nil)),
+		withVolume(newPod("cache", "default", nil), corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}),
+		withVolume(newPod("local-db", "default", nil), corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "local"}}),
+		withVolume(newPod("disk-db", "default", nil), corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "disk"}}),
+	}
+
+	testCases := []struct {
+		name                 string
+		rules                Rules
+		expectedEvictable    []string
+		expectedNotEvictable []string
+	}{
+		{
+			name:              "case 0: default rules",
+			rules:             DefaultRules(),
+			expectedEvictable: []string{"cache", "disk-db", "local-db", "web"},
+		},
+		{
+			name:                 "case 1: nothing skipped or deleted",
+			rules:                Rules{},
+			expectedEvictable:    []string{"disk-db", "web"},
+			expectedNotEvictable: []string{"cache", "kube-proxy", "local-db", "node-exporter"},
+		},
+		{
+			name: "case 2: local persistent volume data kept",
+			rules: Rules{
+				DeleteEmptyDirData: true,
+				IgnoreDaemonSets:   true,
+				SkipMirrorPods:     true,
+			},
+			expectedEvictable:    []string{"cache", "disk-db", "web"},
+			expectedNotEvictable: []string{"local-db"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			d := newTestDrainer(t, tc.rules, kubernetesfake.NewSimpleClientset(), objects...)
+
+			evictable, notEvictable, err := d.filterPods(context.Background(), pods)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if !cmp.Equal(podNames(evictable), tc.expectedEvictable) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedEvictable, podNames(evictable)))
+			}
+			if !cmp.Equal(podNames(notEvictable), tc.expectedNotEvictable) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedNotEvictable, podNames(notEvictable)))
+			}
+		})
+	}
+}
+
+func Test_nextEvictionTier(t *testing.T) {
+	pods := []corev1.Pod{
+		newPod("coredns", "kube-system", to32(2000000000)),
+		newPod("metrics-server", "kube-system", nil),
+		newPod("web", "default", nil),
+		newPod("batch", "default", to32(-10)),
+		newPod("ingress", "ingress", to32(1000)),
+	}
+
+	testCases := []struct {
+		name              string
+		pods              []corev1.Pod
+		byPriority        bool
+		expectedPodsOrder [][]string
+	}{
+		{
+			name:       "case 0: kube-system pods last",
+			pods:       pods,
+			byPriority: false,
+			expectedPodsOrder: [][]string{
+				{"batch", "ingress", "web"},
+				{"coredns", "metrics-server"},
+			},
+		},
+		{
+			name:       "case 1: by priority, system critical pods last",
+			pods:       pods,
+			byPriority: true,
+			expectedPodsOrder: [][]string{
+				{"batch"},
+				{"web"},
+				{"ingress"},
+				{"metrics-server"},
+				{"coredns"},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var order [][]string
+			left := tc.pods
+			for len(left) > 0 {
+				next := nextEvictionTier(left, tc.byPriority)
+				order = append(order, podNames(next))
+
+				var remaining []corev1.Pod
+				for _, pod := range left {
+					if !containsPod(next, pod) {
+						remaining = append(remaining, pod)
+					}
+				}
+				left = remaining
+			}
+
+			if !cmp.Equal(order, tc.expectedPodsOrder) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedPodsOrder, order))
+			}
+		})
+	}
+}
+
+func Test_Drainer_DrainNode_EvictsByPriority(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker-0",
+			Annotations: map[string]string{},
+		},
+	}
+
+	objects := []client.Object{
+		node,
+		podOnNode(newPod("coredns", "kube-system", to32(2000000000)), node.Name),
+		podOnNode(newPod("web", "default", nil), node.Name),
+		podOnNode(withDaemonSet(newPod("node-exporter", "monitoring", nil)), node.Name),
+	}
+
+	k8sClient := kubernetesfake.NewSimpleClientset()
+	k8sClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1"},
+			},
+		},
+	}
+
+	var evicted []string
+	k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+
+		evicted = append(evicted, action.(k8stesting.CreateAction).GetObject().(client.Object).GetName())
+		return true, nil, nil
+	})
+
+	d := newTestDrainer(t, DefaultRules(), k8sClient, objects...)
+
+	err := d.DrainNode(context.Background(), node.Name, time.Hour)
+	if !IsEvictionInProgress(err) {
+		t.Fatalf("expected evictionInProgressError, got %#v", err)
+	}
+
+	expected := []string{"web"}
+	if !cmp.Equal(evicted, expected) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expected, evicted))
+	}
+}
+
+func Test_Drainer_DrainNode_ZeroRules(t *testing.T) {
+	testCases := []struct {
+		name           string
+		pods           []corev1.Pod
+		expectedFinish bool
+	}{
+		{
+			name: "case 0: DaemonSet and mirror pods do not block the drain",
+			pods: []corev1.Pod{
+				withMirror(newPod("kube-proxy", "kube-system", nil)),
+				withDaemonSet(newPod("node-exporter", "monitoring", nil)),
+			},
+			expectedFinish: true,
+		},
+		{
+			name: "case 1: pods using emptyDir volumes block the drain",
+			pods: []corev1.Pod{
+				withDaemonSet(newPod("node-exporter", "monitoring", nil)),
+				withVolume(newPod("cache", "default", nil), corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}),
+			},
+			expectedFinish: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker-0",
+					Annotations: map[string]string{},
+				},
+			}
+
+			objects := []client.Object{node}
+			for _, pod := range tc.pods {
+				objects = append(objects, podOnNode(pod, node.Name))
+			}
+
+			k8sClient := kubernetesfake.NewSimpleClientset()
+			k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() == "eviction" {
+					t.Fatalf("unexpected eviction of pod %#q", action.(k8stesting.CreateAction).GetObject().(client.Object).GetName())
+				}
+				return false, nil, nil
+			})
+
+			d := newTestDrainer(t, Rules{}, k8sClient, objects...)
+
+			err := d.DrainNode(context.Background(), node.Name, time.Hour)
+			if tc.expectedFinish && err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			} else if !tc.expectedFinish && !IsEvictionInProgress(err) {
+				t.Fatalf("expected evictionInProgressError, got %#v", err)
+			}
+		})
+	}
+}
+
+func newTestDrainer(t *testing.T, rules Rules, k8sClient *kubernetesfake.Clientset, objects ...client.Object) *Drainer {
+	wcClients := k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		CtrlClient: fake.NewClientBuilder().WithObjects(objects...).Build(),
+		K8sClient:  k8sClient,
+	})
+
+	d, err := New(Config{
+		Logger:    microloggertest.New(),
+		WCClients: wcClients,
+		Rules:     rules,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	return d
+}
+
+func newPod(name, namespace string, priority *int32) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Priority: priority,
+		},
+	}
+}
+
+func podOnNode(pod corev1.Pod, nodeName string) *corev1.Pod {
+	pod.Spec.NodeName = nodeName
+	return &pod
+}
+
+func withDaemonSet(pod corev1.Pod) corev1.Pod {
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "DaemonSet", Name: pod.Name, Controller: &controller},
+	}
+	return pod
+}
+
+func withMirror(pod corev1.Pod) corev1.Pod {
+	pod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "hash"}
+	return pod
+}
+
+func withVolume(pod corev1.Pod, source corev1.VolumeSource) corev1.Pod {
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: "data", VolumeSource: source})
+	return pod
+}
+
+func podNames(pods []corev1.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func containsPod(pods []corev1.Pod, pod corev1.Pod) bool {
+	for _, p := range pods {
+		if p.Namespace == pod.Namespace && p.Name == pod.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func to32(i int32) *int32 {
+	return &i
+}
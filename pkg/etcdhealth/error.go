@@ -0,0 +1,12 @@
+package etcdhealth
+
+import "github.com/giantswarm/microerror"
+
+var unhealthyError = &microerror.Error{
+	Kind: "unhealthyError",
+}
+
+// IsUnhealthy asserts unhealthyError.
+func IsUnhealthy(err error) bool {
+	return microerror.Cause(err) == unhealthyError
+}
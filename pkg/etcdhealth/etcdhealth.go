@@ -0,0 +1,27 @@
+// Package etcdhealth checks the etcd backend of workload cluster API servers
+// before master nodes are taken down.
+package etcdhealth
+
+import (
+	"context"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// healthPath is the API server health check of its etcd backend. It only
+	// passes when etcd can serve requests, i.e. when etcd has quorum.
+	healthPath = "/healthz/etcd"
+)
+
+// Check returns an unhealthyError when the API server of the workload cluster
+// reports its etcd backend as unhealthy.
+func Check(ctx context.Context, k8sClient k8sclient.Interface) error {
+	body, err := k8sClient.K8sClient().Discovery().RESTClient().Get().AbsPath(healthPath).DoRaw(ctx)
+	if err != nil {
+		return microerror.Maskf(unhealthyError, "%s: %s", err, body)
+	}
+
+	return nil
+}
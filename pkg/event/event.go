@@ -0,0 +1,136 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/project"
+)
+
+const (
+	TypeNormal  = corev1.EventTypeNormal
+	TypeWarning = corev1.EventTypeWarning
+)
+
+type Config struct {
+	CtrlClient client.Client
+	Logger     micrologger.Logger
+}
+
+// Recorder creates Kubernetes events for objects reconciled by the operator.
+type Recorder struct {
+	ctrlClient client.Client
+	logger     micrologger.Logger
+}
+
+func New(config Config) (*Recorder, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &Recorder{
+		ctrlClient: config.CtrlClient,
+		logger:     config.Logger,
+	}
+
+	return r, nil
+}
+
+// Emit creates an event of the given type and reason for the given object.
+// Events with the same object, type, reason and message are aggregated like
+// the client-go event recorder does: the existing event gets its count
+// incremented and its last timestamp updated instead of a new event being
+// created on every reconciliation loop.
+func (r *Recorder) Emit(ctx context.Context, obj client.Object, eventType, reason, messageFmt string, args ...interface{}) error {
+	gvk, err := apiutil.GVKForObject(obj, r.ctrlClient.Scheme())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	now := metav1.NewTime(time.Now())
+	message := fmt.Sprintf(messageFmt, args...)
+	name := eventName(obj, gvk.Kind, eventType, reason, message)
+
+	e := &corev1.Event{}
+	err = r.ctrlClient.Get(ctx, client.ObjectKey{Namespace: eventNamespace(obj), Name: name}, e)
+	if apierrors.IsNotFound(err) {
+		e = &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: eventNamespace(obj),
+			},
+			InvolvedObject: corev1.ObjectReference{
+				APIVersion:      gvk.GroupVersion().String(),
+				Kind:            gvk.Kind,
+				Name:            obj.GetName(),
+				Namespace:       obj.GetNamespace(),
+				ResourceVersion: obj.GetResourceVersion(),
+				UID:             obj.GetUID(),
+			},
+			Reason:         reason,
+			Message:        message,
+			Type:           eventType,
+			Count:          1,
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Source: corev1.EventSource{
+				Component: project.Name(),
+			},
+		}
+
+		err = r.ctrlClient.Create(ctx, e)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if err != nil {
+		return microerror.Mask(err)
+	} else {
+		e.Count++
+		e.LastTimestamp = now
+		e.InvolvedObject.ResourceVersion = obj.GetResourceVersion()
+
+		err = r.ctrlClient.Update(ctx, e)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	r.logger.Debugf(ctx, "emitted %s event %#q for %s %#q (count %d): %s", eventType, reason, gvk.Kind, obj.GetName(), e.Count, message)
+
+	return nil
+}
+
+// eventName returns the name of the event aggregating the events with the
+// given object, type, reason and message.
+func eventName(obj client.Object, kind, eventType, reason, message string) string {
+	h := fnv.New64a()
+	for _, s := range []string{kind, obj.GetNamespace(), obj.GetName(), string(obj.GetUID()), eventType, reason, message} {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return fmt.Sprintf("%s.%x", obj.GetName(), h.Sum64())
+}
+
+// eventNamespace returns the namespace events for the given object are
+// created in. Events of cluster scoped objects go to the default namespace.
+func eventNamespace(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return metav1.NamespaceDefault
+	}
+
+	return obj.GetNamespace()
+}
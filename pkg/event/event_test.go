@@ -0,0 +1,147 @@
+package event
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_Recorder_Emit(t *testing.T) {
+	testCases := []struct {
+		name              string
+		obj               client.Object
+		expectedNamespace string
+		expectedKind      string
+	}{
+		{
+			name: "case 0: namespaced object",
+			obj: &capzexp.AzureMachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "np001",
+					Namespace: "org-giantswarm",
+				},
+			},
+			expectedNamespace: "org-giantswarm",
+			expectedKind:      "AzureMachinePool",
+		},
+		{
+			name: "case 1: cluster scoped object",
+			obj: &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "c0001",
+				},
+			},
+			expectedNamespace: metav1.NamespaceDefault,
+			expectedKind:      "Cluster",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			scheme := runtime.NewScheme()
+			for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, capi.AddToScheme, capzexp.AddToScheme} {
+				err := add(scheme)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctrlClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+			recorder, err := New(Config{
+				CtrlClient: ctrlClient,
+				Logger:     microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = recorder.Emit(context.Background(), tc.obj, TypeNormal, "TestReason", "message %d", i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var events corev1.EventList
+			err = ctrlClient.List(context.Background(), &events)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events.Items) != 1 {
+				t.Fatalf("len(events) == %d, want 1", len(events.Items))
+			}
+
+			e := events.Items[0]
+			if e.Namespace != tc.expectedNamespace {
+				t.Fatalf("namespace == %q, want %q", e.Namespace, tc.expectedNamespace)
+			}
+			if e.InvolvedObject.Kind != tc.expectedKind {
+				t.Fatalf("kind == %q, want %q", e.InvolvedObject.Kind, tc.expectedKind)
+			}
+			if e.Reason != "TestReason" || e.Message != "message "+strconv.Itoa(i) || e.Type != TypeNormal {
+				t.Fatalf("unexpected event %#v", e)
+			}
+		})
+	}
+}
+
+func Test_Recorder_Emit_Aggregation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, capzexp.AddToScheme} {
+		err := add(scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctrlClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	recorder, err := New(Config{
+		CtrlClient: ctrlClient,
+		Logger:     microloggertest.New(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := &capzexp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "np001",
+			Namespace: "org-giantswarm",
+		},
+	}
+
+	// The same event is emitted three times, another message once.
+	for _, message := range []string{"draining", "draining", "draining", "drained"} {
+		err = recorder.Emit(context.Background(), obj, TypeNormal, "TestReason", message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var events corev1.EventList
+	err = ctrlClient.List(context.Background(), &events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int32{}
+	for _, e := range events.Items {
+		counts[e.Message] = e.Count
+	}
+
+	expected := map[string]int32{"draining": 3, "drained": 1}
+	if !cmp.Equal(counts, expected) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expected, counts))
+	}
+}
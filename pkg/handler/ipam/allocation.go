@@ -0,0 +1,79 @@
+package ipam
+
+import (
+	"math"
+	"net"
+)
+
+// Allocation is a network range allocated by IPAM, together with its owner.
+type Allocation struct {
+	Network net.IPNet
+	// Owner is the name of the tenant cluster or of the node pool the network
+	// range is allocated for. It is empty for network ranges which are not
+	// owned by any tenant cluster, e.g. the control plane virtual network.
+	Owner string
+}
+
+func allocatedNetworks(allocations []Allocation) []net.IPNet {
+	var networks []net.IPNet
+	for _, a := range allocations {
+		networks = append(networks, a.Network)
+	}
+
+	return networks
+}
+
+// Size returns the number of addresses in the given IPv4 or IPv6 network
+// range, capped at the maximum uint64 value.
+func Size(networkRange net.IPNet) uint64 {
+	ones, bits := networkRange.Mask.Size()
+	if bits-ones >= 64 {
+		return math.MaxUint64
+	}
+
+	return uint64(1) << uint(bits-ones)
+}
+
+// AllocatedAddresses returns the number of addresses of the parent network
+// range which are covered by the given networks. Networks outside of the
+// parent network range are ignored and overlapping networks are counted once.
+func AllocatedAddresses(parent net.IPNet, networks []net.IPNet) uint64 {
+	parentOnes, _ := parent.Mask.Size()
+
+	// Clip networks to the parent network range. Two CIDR ranges are either
+	// nested or disjoint.
+	var clipped []net.IPNet
+	for _, n := range networks {
+		ones, _ := n.Mask.Size()
+		if ones >= parentOnes && parent.Contains(n.IP) {
+			clipped = append(clipped, n)
+		} else if ones < parentOnes && n.Contains(parent.IP) {
+			clipped = append(clipped, parent)
+		}
+	}
+
+	var allocated uint64
+	for i, n := range clipped {
+		nested := false
+		for j, o := range clipped {
+			if i == j {
+				continue
+			}
+
+			nOnes, _ := n.Mask.Size()
+			oOnes, _ := o.Mask.Size()
+			// Count a network once if it is contained in another one, and
+			// count only the first of identical networks.
+			if o.Contains(n.IP) && (oOnes < nOnes || (oOnes == nOnes && j < i)) {
+				nested = true
+				break
+			}
+		}
+
+		if !nested {
+			allocated += Size(n)
+		}
+	}
+
+	return allocated
+}
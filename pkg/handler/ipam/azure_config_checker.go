@@ -45,6 +45,12 @@ func (c *AzureConfigChecker) Check(ctx context.Context, namespace string, name s
 		return nil, microerror.Mask(err)
 	}
 
+	// The range of clusters using an existing VNet is set in the AzureCluster
+	// and is neither allocated nor released by IPAM.
+	if key.ExistingVnetID(*azureCluster) != "" {
+		return nil, microerror.Maskf(networkRangeNotManagedError, "cluster %#q uses existing VNet %#q", name, key.ExistingVnetID(*azureCluster))
+	}
+
 	// We check the subnet we want to ensure in the CR status. In case there is no
 	// subnet tracked so far, we want to proceed with the allocation process. Thus
 	// we return nil.
@@ -3,7 +3,6 @@ package ipam
 import (
 	"context"
 	"net"
-	"reflect"
 
 	"github.com/giantswarm/microerror"
 )
@@ -16,40 +15,36 @@ const (
 )
 
 type AzureConfigNetworkRangeGetterConfig struct {
-	InstallationNetworkRange            net.IPNet
-	TenantClusterVirtualNetworkMaskBits int
+	PoolSelector *PoolSelector
 }
 
 // AzureConfigNetworkRangeGetter is NetworkRangeGetter implementation for
 // AzureConfig.
 type AzureConfigNetworkRangeGetter struct {
-	installationNetworkRange        net.IPNet
-	tenantClusterVirtualNetworkMask net.IPMask
+	poolSelector *PoolSelector
 }
 
 func NewAzureConfigNetworkRangeGetter(config AzureConfigNetworkRangeGetterConfig) (*AzureConfigNetworkRangeGetter, error) {
-	if reflect.DeepEqual(config.InstallationNetworkRange, net.IPNet{}) {
-		return nil, microerror.Maskf(invalidConfigError, "%T.InstallationNetworkRange must not be empty", config)
-	}
-	if config.TenantClusterVirtualNetworkMaskBits < minAllocatedVNetMaskBits {
-		return nil, microerror.Maskf(invalidConfigError, "%T.TenantClusterVirtualNetworkMaskBits (%d) must not be smaller than %d", config, config.TenantClusterVirtualNetworkMaskBits, minAllocatedVNetMaskBits)
+	if config.PoolSelector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PoolSelector must not be empty", config)
 	}
 
 	g := AzureConfigNetworkRangeGetter{
-		installationNetworkRange:        config.InstallationNetworkRange,
-		tenantClusterVirtualNetworkMask: net.CIDRMask(config.TenantClusterVirtualNetworkMaskBits, 32),
+		poolSelector: config.PoolSelector,
 	}
 
 	return &g, nil
 }
 
-// GetParentNetworkRange gets the predefined installation network range, since the tenant cluster
-// virtual network is getting its IP range from all available address ranges in the installation.
-func (g *AzureConfigNetworkRangeGetter) GetParentNetworkRange(_ context.Context, _ interface{}) (net.IPNet, error) {
-	return g.installationNetworkRange, nil
-}
+// GetParentNetworkRange gets the network range of the IPAM pool of the tenant
+// cluster, since the tenant cluster virtual network is getting its IP range
+// from all available address ranges in the pool, and the IP mask for the
+// tenant cluster virtual network defined by the same pool.
+func (g *AzureConfigNetworkRangeGetter) GetParentNetworkRange(ctx context.Context, obj interface{}) (net.IPNet, net.IPMask, error) {
+	pool, err := g.poolSelector.Select(ctx, obj)
+	if err != nil {
+		return net.IPNet{}, nil, microerror.Mask(err)
+	}
 
-// GetRequiredIPMask returns an IP mask for tenant cluster virtual network.
-func (g *AzureConfigNetworkRangeGetter) GetRequiredIPMask() net.IPMask {
-	return g.tenantClusterVirtualNetworkMask
+	return pool.NetworkRange, net.CIDRMask(pool.MaskBits, 32), nil
 }
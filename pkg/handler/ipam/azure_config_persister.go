@@ -9,17 +9,26 @@ import (
 	"github.com/giantswarm/micrologger"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 	"github.com/giantswarm/azure-operator/v8/service/network"
 )
 
 type AzureConfigPersisterConfig struct {
 	CtrlClient client.Client
 	Logger     micrologger.Logger
+
+	// IPv6NetworkRange is the installation IPv6 range from which the IPv6
+	// ranges of dual-stack clusters are derived. Dual-stack clusters are not
+	// supported when it is not set.
+	IPv6NetworkRange net.IPNet
 }
 
 type AzureConfigPersister struct {
 	ctrlClient client.Client
 	logger     micrologger.Logger
+
+	ipv6NetworkRange net.IPNet
 }
 
 func NewAzureConfigPersister(config AzureConfigPersisterConfig) (*AzureConfigPersister, error) {
@@ -29,10 +38,18 @@ func NewAzureConfigPersister(config AzureConfigPersisterConfig) (*AzureConfigPer
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
+	if config.IPv6NetworkRange.IP != nil {
+		ones, bits := config.IPv6NetworkRange.Mask.Size()
+		if bits != 128 || ones != network.IPv6RangeMaskBits {
+			return nil, microerror.Maskf(invalidConfigError, "%T.IPv6NetworkRange must be a /%d IPv6 network", config, network.IPv6RangeMaskBits)
+		}
+	}
 
 	p := &AzureConfigPersister{
 		ctrlClient: config.CtrlClient,
 		logger:     config.Logger,
+
+		ipv6NetworkRange: config.IPv6NetworkRange,
 	}
 
 	return p, nil
@@ -70,3 +87,41 @@ func (p *AzureConfigPersister) Persist(ctx context.Context, vnet net.IPNet, name
 
 	return nil
 }
+
+// PersistIPv6 derives the IPv6 range of the virtual network of a dual-stack
+// cluster from its IPv4 range and saves it in the AzureConfig CR annotations.
+func (p *AzureConfigPersister) PersistIPv6(ctx context.Context, vnet net.IPNet, namespace string, name string) error {
+	azureConfig := &v1alpha1.AzureConfig{}
+	err := p.ctrlClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, azureConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !key.DualStackEnabled(azureConfig) || key.VnetIPv6CIDR(*azureConfig) != "" {
+		return nil
+	}
+
+	if p.ipv6NetworkRange.IP == nil {
+		p.logger.LogCtx(ctx, "level", "warning", "message", "dual-stack is enabled for the cluster, but the installation IPv6 range is not configured")
+		return nil
+	}
+
+	vnet6, err := network.IPv6VirtualNetwork(p.ipv6NetworkRange, vnet)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureConfig.Annotations == nil {
+		azureConfig.Annotations = map[string]string{}
+	}
+	azureConfig.Annotations[annotation.VirtualNetworkIPv6CIDR] = vnet6.String()
+
+	err = p.ctrlClient.Update(ctx, azureConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "persisted IPv6 virtual network range %#q in AzureConfig CR", vnet6.String())
+
+	return nil
+}
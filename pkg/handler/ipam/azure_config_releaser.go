@@ -0,0 +1,70 @@
+package ipam
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+type AzureConfigReleaserConfig struct {
+	CtrlClient client.Client
+	Logger     micrologger.Logger
+}
+
+// AzureConfigReleaser is a Releaser implementation that releases the allocated
+// virtual network range of a tenant cluster by removing it from AzureConfig
+// CR. The range can't be allocated again as long as the virtual network
+// exists in Azure, because VirtualNetworkCollector also collects the virtual
+// networks of the tenant cluster resource groups.
+type AzureConfigReleaser struct {
+	ctrlClient client.Client
+	logger     micrologger.Logger
+}
+
+func NewAzureConfigReleaser(config AzureConfigReleaserConfig) (*AzureConfigReleaser, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &AzureConfigReleaser{
+		ctrlClient: config.CtrlClient,
+		logger:     config.Logger,
+	}
+
+	return r, nil
+}
+
+func (r *AzureConfigReleaser) Release(ctx context.Context, vnet net.IPNet, namespace, name string) error {
+	r.logger.Debugf(ctx, "releasing allocated virtual network range %#q from AzureConfig CR", vnet.String())
+
+	azureConfig := &v1alpha1.AzureConfig{}
+	err := r.ctrlClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, azureConfig)
+	if apierrors.IsNotFound(err) {
+		r.logger.Debugf(ctx, "AzureConfig CR is already deleted")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	azureConfig.Spec.Azure.VirtualNetwork = v1alpha1.AzureConfigSpecAzureVirtualNetwork{}
+	delete(azureConfig.Annotations, annotation.VirtualNetworkIPv6CIDR)
+
+	err = r.ctrlClient.Update(ctx, azureConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.logger.Debugf(ctx, "released allocated virtual network range %#q from AzureConfig CR", vnet.String())
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package ipam
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_AzureConfigReleaser_Release(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		azureConfig         *providerv1alpha1.AzureConfig
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "case 0 virtual network ranges are removed from AzureConfig CR",
+
+			azureConfig: &providerv1alpha1.AzureConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "c1",
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotation.VirtualNetworkIPv6CIDR: "fd00:0:1::/48",
+						"other":                           "value",
+					},
+				},
+				Spec: providerv1alpha1.AzureConfigSpec{
+					Azure: providerv1alpha1.AzureConfigSpecAzure{
+						VirtualNetwork: providerv1alpha1.AzureConfigSpecAzureVirtualNetwork{
+							CIDR:             "10.1.0.0/16",
+							MasterSubnetCIDR: "10.1.0.0/24",
+							WorkerSubnetCIDR: "10.1.1.0/24",
+						},
+					},
+				},
+			},
+			expectedAnnotations: map[string]string{
+				"other": "value",
+			},
+		},
+		{
+			name: "case 1 deleted AzureConfig CR is ignored",
+
+			azureConfig: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			var objects []client.Object
+			if tc.azureConfig != nil {
+				objects = append(objects, tc.azureConfig)
+			}
+			ctrlClient := newLeakCheckerFakeClient(t, objects...)
+
+			r, err := NewAzureConfigReleaser(AzureConfigReleaserConfig{
+				CtrlClient: ctrlClient,
+				Logger:     microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.Release(ctx, mustParseCIDR("10.1.0.0/16"), "default", "c1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			azureConfig := &providerv1alpha1.AzureConfig{}
+			err = ctrlClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "c1"}, azureConfig)
+			if tc.azureConfig == nil {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("error == %#v, want not found", err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(azureConfig.Spec.Azure.VirtualNetwork, providerv1alpha1.AzureConfigSpecAzureVirtualNetwork{}) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(providerv1alpha1.AzureConfigSpecAzureVirtualNetwork{}, azureConfig.Spec.Azure.VirtualNetwork))
+			}
+			if !cmp.Equal(azureConfig.Annotations, tc.expectedAnnotations) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedAnnotations, azureConfig.Annotations))
+			}
+		})
+	}
+}
@@ -44,8 +44,8 @@ func NewAzureMachinePoolNetworkRangeGetter(config AzureMachinePoolNetworkRangeGe
 
 // GetParentNetworkRange returns the tenant cluster virtual network range, because the node pool
 // subnet is getting its IP address range from all available address ranges in the tenant cluster
-// virtual network.
-func (g *AzureMachinePoolNetworkRangeGetter) GetParentNetworkRange(ctx context.Context, obj interface{}) (net.IPNet, error) {
+// virtual network, and the /24 IP mask that is required for the node pool subnet.
+func (g *AzureMachinePoolNetworkRangeGetter) GetParentNetworkRange(ctx context.Context, obj interface{}) (net.IPNet, net.IPMask, error) {
 	g.logger.LogCtx(
 		ctx,
 		"level", "debug",
@@ -53,13 +53,13 @@ func (g *AzureMachinePoolNetworkRangeGetter) GetParentNetworkRange(ctx context.C
 
 	azureMachinePool, err := key.ToAzureMachinePool(obj)
 	if err != nil {
-		return net.IPNet{}, microerror.Mask(err)
+		return net.IPNet{}, nil, microerror.Mask(err)
 	}
 
 	// Get AzureCluster CR where the NetworkSpec is stored.
 	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, g.client, azureMachinePool.ObjectMeta)
 	if err != nil {
-		return net.IPNet{}, microerror.Mask(err)
+		return net.IPNet{}, nil, microerror.Mask(err)
 	}
 
 	if len(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks) == 0 {
@@ -68,12 +68,12 @@ func (g *AzureMachinePoolNetworkRangeGetter) GetParentNetworkRange(ctx context.C
 		// being created).
 		errorMessage := "AzureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks is not set yet"
 		g.logger.LogCtx(ctx, "level", "warning", "message", errorMessage)
-		return net.IPNet{}, microerror.Maskf(parentNetworkRangeStillNotKnown, errorMessage)
+		return net.IPNet{}, nil, microerror.Maskf(parentNetworkRangeStillNotKnown, errorMessage)
 	}
 
 	_, ipNet, err := net.ParseCIDR(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks[0])
 	if err != nil {
-		return net.IPNet{}, microerror.Mask(err)
+		return net.IPNet{}, nil, microerror.Mask(err)
 	}
 
 	g.logger.LogCtx(
@@ -81,11 +81,5 @@ func (g *AzureMachinePoolNetworkRangeGetter) GetParentNetworkRange(ctx context.C
 		"level", "debug",
 		"message", fmt.Sprintf("got tenant cluster's VNet range %s from which the node pool subnet will be allocated", ipNet.String()))
 
-	return *ipNet, nil
-}
-
-// GetRequiredMask returns a /24 IP mask that is required for the node pools
-// subnet.
-func (g *AzureMachinePoolNetworkRangeGetter) GetRequiredIPMask() net.IPMask {
-	return nodePoolIPMask
+	return *ipNet, nodePoolIPMask, nil
 }
@@ -11,6 +11,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/azure-operator/v8/pkg/helpers"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
 type AzureMachinePoolSubnetCheckerConfig struct {
@@ -70,6 +71,13 @@ func (c *AzureMachinePoolSubnetChecker) Check(ctx context.Context, namespace str
 		}
 	}
 
+	// Node pools of clusters using an existing VNet are placed in existing
+	// subnets, which are never allocated by IPAM.
+	if key.UsesExistingVnet(azureCluster) {
+		c.logger.Debugf(ctx, "node pool uses existing subnet %#q", key.NodePoolSubnetName(azureMachinePool))
+		return nil, microerror.Maskf(networkRangeNotManagedError, "node pool %#q uses existing subnet %#q", azureMachinePool.Name, key.NodePoolSubnetName(azureMachinePool))
+	}
+
 	// In case there is no subnet tracked so far, we want to proceed with the allocation process.
 	for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
 		if subnet.Name == azureMachinePool.Name && len(subnet.CIDRBlocks) > 0 {
@@ -6,6 +6,7 @@ import (
 	"net"
 	"sync"
 
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
 	"github.com/giantswarm/ipam"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
@@ -96,13 +97,13 @@ func (c *AzureMachinePoolSubnetCollector) Collect(ctx context.Context, obj inter
 
 	g.Go(func() error {
 		// Collect subnets from AzureCluster CR.
-		azureClusterCRSubnets, err := c.collectSubnetsFromAzureClusterCR(ctx, azureCluster)
+		azureClusterCRSubnets, err := c.AzureClusterAllocations(ctx, azureCluster)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
 		mutex.Lock()
-		reservedSubnets = append(reservedSubnets, azureClusterCRSubnets...)
+		reservedSubnets = append(reservedSubnets, allocatedNetworks(azureClusterCRSubnets)...)
 		mutex.Unlock()
 
 		return nil
@@ -110,13 +111,13 @@ func (c *AzureMachinePoolSubnetCollector) Collect(ctx context.Context, obj inter
 
 	g.Go(func() error {
 		// Collect subnets from the actual Azure VNet via Azure API.
-		actualAzureVNetSubnets, err := c.collectSubnetsFromAzureVNet(ctx, azureCluster)
+		actualAzureVNetSubnets, err := c.AzureAllocations(ctx, azureCluster)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
 		mutex.Lock()
-		reservedSubnets = append(reservedSubnets, actualAzureVNetSubnets...)
+		reservedSubnets = append(reservedSubnets, allocatedNetworks(actualAzureVNetSubnets)...)
 		mutex.Unlock()
 
 		return nil
@@ -133,10 +134,11 @@ func (c *AzureMachinePoolSubnetCollector) Collect(ctx context.Context, obj inter
 	return reservedSubnets, nil
 }
 
-// collectSubnetsFromAzureClusterCR returns all subnets specified in AzureCluster CR.
-func (c *AzureMachinePoolSubnetCollector) collectSubnetsFromAzureClusterCR(ctx context.Context, azureCluster *capz.AzureCluster) ([]net.IPNet, error) {
+// AzureClusterAllocations returns all subnets specified in AzureCluster CR,
+// owned by the node pools they are named after.
+func (c *AzureMachinePoolSubnetCollector) AzureClusterAllocations(ctx context.Context, azureCluster *capz.AzureCluster) ([]Allocation, error) {
 	c.logger.Debugf(ctx, "finding allocated subnets in AzureCluster CR")
-	azureClusterCRSubnets := make([]net.IPNet, len(azureCluster.Spec.NetworkSpec.Subnets))
+	var azureClusterCRSubnets []Allocation
 
 	// Collect all the subnets from AzureCluster.Spec.NetworkSpec.Subnets field. If the Subnets
 	// field is not set, this function will simply return nil.
@@ -149,15 +151,16 @@ func (c *AzureMachinePoolSubnetCollector) collectSubnetsFromAzureClusterCR(ctx c
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-		azureClusterCRSubnets = append(azureClusterCRSubnets, *subnetIPNet)
+		azureClusterCRSubnets = append(azureClusterCRSubnets, Allocation{Network: *subnetIPNet, Owner: subnet.Name})
 	}
 
 	c.logger.Debugf(ctx, "found %d allocated subnets in AzureCluster CR", len(azureClusterCRSubnets))
 	return azureClusterCRSubnets, nil
 }
 
-// collectSubnetsFromAzureVNet returns all subnets that are deployed in Azure virtual network.
-func (c *AzureMachinePoolSubnetCollector) collectSubnetsFromAzureVNet(ctx context.Context, azureCluster *capz.AzureCluster) ([]net.IPNet, error) {
+// AzureAllocations returns all subnets that are deployed in Azure virtual
+// network, owned by the node pools they are named after.
+func (c *AzureMachinePoolSubnetCollector) AzureAllocations(ctx context.Context, azureCluster *capz.AzureCluster) ([]Allocation, error) {
 	// Not assuming VNet name here, keeping it flexible. In order to keep it correct and stable, we
 	// should have a webhook for enforcing a VNet name convention.
 	if azureCluster.Spec.NetworkSpec.Vnet.Name == "" {
@@ -193,19 +196,21 @@ func (c *AzureMachinePoolSubnetCollector) collectSubnetsFromAzureVNet(ctx contex
 		return nil, microerror.Mask(err)
 	}
 
-	var subnets []net.IPNet
+	var subnets []Allocation
 
 	for resultPage.NotDone() {
 		azureSubnets := resultPage.Values()
 
 		for _, azureSubnet := range azureSubnets {
-			_, subnetIPNet, err := net.ParseCIDR(*azureSubnet.AddressPrefix)
-			if err != nil {
-				errorMessage := fmt.Sprintf("error while parsing Azure subnet range %q", *azureSubnet.AddressPrefix)
-				c.logger.LogCtx(ctx, "level", "warning", "message", errorMessage)
-				return nil, microerror.Mask(err)
+			for _, addressPrefix := range subnetAddressPrefixes(azureSubnet) {
+				_, subnetIPNet, err := net.ParseCIDR(addressPrefix)
+				if err != nil {
+					errorMessage := fmt.Sprintf("error while parsing Azure subnet range %q", addressPrefix)
+					c.logger.LogCtx(ctx, "level", "warning", "message", errorMessage)
+					return nil, microerror.Mask(err)
+				}
+				subnets = append(subnets, Allocation{Network: *subnetIPNet, Owner: *azureSubnet.Name})
 			}
-			subnets = append(subnets, *subnetIPNet)
 		}
 
 		err = resultPage.NextWithContext(ctx)
@@ -221,3 +226,21 @@ func (c *AzureMachinePoolSubnetCollector) collectSubnetsFromAzureVNet(ctx contex
 
 	return subnets, nil
 }
+
+// subnetAddressPrefixes returns the address ranges of the Azure subnet. Azure
+// sets AddressPrefixes instead of AddressPrefix for dual-stack subnets. IPv6
+// ranges are filtered out by the caller, as they are not within the IPv4
+// virtual network range.
+func subnetAddressPrefixes(subnet network.Subnet) []string {
+	if subnet.SubnetPropertiesFormat == nil {
+		return nil
+	}
+	if subnet.AddressPrefixes != nil {
+		return *subnet.AddressPrefixes
+	}
+	if subnet.AddressPrefix != nil {
+		return []string{*subnet.AddressPrefix}
+	}
+
+	return nil
+}
@@ -11,6 +11,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/azure-operator/v8/pkg/helpers"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+	"github.com/giantswarm/azure-operator/v8/service/network"
 )
 
 type AzureMachinePoolSubnetPersisterConfig struct {
@@ -84,3 +86,55 @@ func (p *AzureMachinePoolSubnetPersister) addSubnetToAzureCluster(ctx context.Co
 
 	return nil
 }
+
+// PersistIPv6 derives the IPv6 range of the node pool subnet of a dual-stack
+// cluster from its IPv4 range and adds it to the subnet in the AzureCluster
+// CR. The cluster is dual-stack when its virtual network has an IPv6 range.
+func (p *AzureMachinePoolSubnetPersister) PersistIPv6(ctx context.Context, subnet net.IPNet, namespace string, name string) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := p.ctrlClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, p.ctrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if key.AzureClusterVnetIPv6CIDR(azureCluster) == "" {
+		return nil
+	}
+
+	_, vnet6, err := net.ParseCIDR(key.AzureClusterVnetIPv6CIDR(azureCluster))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for i, s := range azureCluster.Spec.NetworkSpec.Subnets {
+		if s.Name != azureMachinePool.Name {
+			continue
+		}
+		if key.SubnetIPv6CIDR(s) != "" {
+			return nil
+		}
+
+		subnet6, err := network.IPv6Subnet(*vnet6, subnet)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		azureCluster.Spec.NetworkSpec.Subnets[i].CIDRBlocks = append(s.CIDRBlocks, subnet6.String())
+
+		err = p.ctrlClient.Update(ctx, azureCluster)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		p.logger.Debugf(ctx, "persisted IPv6 subnet range %#q in AzureCluster CR", subnet6.String())
+
+		return nil
+	}
+
+	return nil
+}
@@ -49,12 +49,22 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 	// 1/4 Check if a vnet/subnet is already allocated.
 	{
 		subnet, err := r.checker.Check(ctx, m.GetNamespace(), m.GetName())
-		if err != nil {
+		if IsNetworkRangeNotManaged(err) {
+			r.logger.Debugf(ctx, "%s is not managed by IPAM", r.networkRangeType)
+			r.logger.Debugf(ctx, "canceling resource")
+			return nil
+		} else if err != nil {
 			return microerror.Mask(err)
 		}
 
 		if subnet != nil {
 			r.logger.Debugf(ctx, "%s already allocated", r.networkRangeType)
+
+			err = r.ensureIPv6(ctx, *subnet, m.GetNamespace(), m.GetName())
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
 			r.logger.Debugf(ctx, "canceling resource")
 			return nil
 		}
@@ -91,7 +101,7 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 	{
 		r.logger.Debugf(ctx, "finding free %s", r.networkRangeType)
 
-		parentNetworkRange, err := r.networkRangeGetter.GetParentNetworkRange(ctx, obj)
+		parentNetworkRange, requiredIPMask, err := r.networkRangeGetter.GetParentNetworkRange(ctx, obj)
 		if IsParentNetworkRangeStillNotKnown(err) {
 			// We cancel IPAM reconciliation, which should be done in one of the next
 			// reconciliation loops, as soon as the parent network range is allocated. See
@@ -104,7 +114,10 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		} else if err != nil {
 			return microerror.Mask(err)
 		}
-		requiredIPMask := r.networkRangeGetter.GetRequiredIPMask()
+
+		// Only the allocated network ranges within the parent network range
+		// are taken into account.
+		allocatedNetworkRanges = ipam.CanonicalizeSubnets(parentNetworkRange, allocatedNetworkRanges)
 
 		freeNetworkRange, err = ipam.Free(parentNetworkRange, requiredIPMask, allocatedNetworkRanges)
 		if err != nil {
@@ -124,7 +137,35 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		}
 
 		r.logger.Debugf(ctx, "allocated free %s %#q", r.networkRangeType, freeNetworkRange)
+
+		err = r.ensureIPv6(ctx, freeNetworkRange, m.GetNamespace(), m.GetName())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// ensureIPv6 persists the IPv6 range corresponding to the allocated IPv4
+// network range when the cluster is dual-stack. The IPv6 range is derived
+// from the IPv4 one, see network.IPv6VirtualNetwork.
+func (r *Resource) ensureIPv6(ctx context.Context, networkRange net.IPNet, namespace, name string) error {
+	if r.ipv6Persister == nil {
+		return nil
 	}
 
+	r.logger.Debugf(ctx, "ensuring IPv6 %s for %#q", r.networkRangeType, networkRange)
+
+	err := r.ipv6Persister.PersistIPv6(ctx, networkRange, namespace, name)
+	if IsParentNetworkRangeStillNotKnown(err) {
+		r.logger.Debugf(ctx, "parent IPv6 network range from which the IPv6 %s should be allocated is still not known", r.networkRangeType)
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.logger.Debugf(ctx, "ensured IPv6 %s for %#q", r.networkRangeType, networkRange)
+
 	return nil
 }
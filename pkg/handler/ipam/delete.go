@@ -48,7 +48,10 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		r.logger.Debugf(ctx, "finding if subnet is still allocated")
 
 		subnet, err = r.checker.Check(ctx, m.GetNamespace(), m.GetName())
-		if err != nil {
+		if IsNetworkRangeNotManaged(err) {
+			r.logger.Debugf(ctx, "%s is not managed by IPAM", r.networkRangeType)
+			return nil
+		} else if err != nil {
 			return microerror.Mask(err)
 		}
 
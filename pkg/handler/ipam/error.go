@@ -17,6 +17,17 @@ func IsParentNetworkRangeStillNotKnown(err error) bool {
 	return microerror.Cause(err) == parentNetworkRangeStillNotKnown
 }
 
+var networkRangeNotManagedError = &microerror.Error{
+	Kind: "networkRangeNotManagedError",
+}
+
+// IsNetworkRangeNotManaged asserts networkRangeNotManagedError. Checker
+// implementations return it when the network range is not allocated by IPAM,
+// e.g. for existing subnets of a VNet the tenant cluster doesn't own.
+func IsNetworkRangeNotManaged(err error) bool {
+	return microerror.Cause(err) == networkRangeNotManagedError
+}
+
 var invalidConfigError = &microerror.Error{
 	Kind: "invalid config",
 }
@@ -26,6 +37,26 @@ func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
 
+var poolNotFoundError = &microerror.Error{
+	Kind: "poolNotFoundError",
+}
+
+// IsPoolNotFound asserts poolNotFoundError. It is returned when the IPAM pool
+// selected by the Cluster or Organization CR label is not configured.
+func IsPoolNotFound(err error) bool {
+	return microerror.Cause(err) == poolNotFoundError
+}
+
+var reportNotReadyError = &microerror.Error{
+	Kind: "reportNotReadyError",
+}
+
+// IsReportNotReady asserts reportNotReadyError. Inspector returns it before
+// the first inspection report has been collected.
+func IsReportNotReady(err error) bool {
+	return microerror.Cause(err) == reportNotReadyError
+}
+
 var invalidObjectError = &microerror.Error{
 	Kind: "invalid object",
 }
@@ -0,0 +1,358 @@
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/ipam"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// Sources of the allocations in inspection reports.
+	SourceAzure        = "Azure"
+	SourceAzureCluster = "AzureCluster"
+	SourceAzureConfig  = "AzureConfig"
+	SourceReserved     = "Reserved"
+)
+
+// Report lists the network ranges allocated by IPAM.
+type Report struct {
+	// UpdatedAt is the time the network ranges have been collected at.
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Pools lists the virtual networks allocated in each IPAM pool.
+	Pools []RangeReport `json:"pools"`
+	// VirtualNetworks lists the subnets allocated in each tenant cluster
+	// virtual network managed by IPAM.
+	VirtualNetworks []RangeReport `json:"virtualNetworks"`
+}
+
+// RangeReport lists the network ranges allocated in a parent network range,
+// i.e. in an IPAM pool or in a tenant cluster virtual network.
+type RangeReport struct {
+	// Name is the name of the IPAM pool or the ID of the tenant cluster.
+	Name          string `json:"name"`
+	NetworkRange  string `json:"networkRange"`
+	SizeAddresses uint64 `json:"sizeAddresses"`
+	FreeAddresses uint64 `json:"freeAddresses"`
+	// NextFreeRange is the network range IPAM would allocate next. It is
+	// empty when the parent network range is exhausted.
+	NextFreeRange string             `json:"nextFreeRange,omitempty"`
+	Allocations   []AllocationReport `json:"allocations"`
+}
+
+// AllocationReport is an allocated network range together with the CR owning
+// it and the places it has been found in.
+type AllocationReport struct {
+	NetworkRange string `json:"networkRange"`
+	// OwnerKind and Owner are the kind and name of the CR owning the network
+	// range. They are empty when the network range is not owned by a tenant
+	// cluster.
+	OwnerKind string   `json:"ownerKind,omitempty"`
+	Owner     string   `json:"owner,omitempty"`
+	Sources   []string `json:"sources"`
+	// Overlaps lists the other allocated network ranges overlapping with this
+	// one.
+	Overlaps []string `json:"overlaps,omitempty"`
+}
+
+type InspectorConfig struct {
+	CtrlClient              client.Client
+	Logger                  micrologger.Logger
+	PoolSelector            *PoolSelector
+	SubnetCollector         *AzureMachinePoolSubnetCollector
+	VirtualNetworkCollector *VirtualNetworkCollector
+}
+
+// Inspector reports the network ranges collected by VirtualNetworkCollector
+// and AzureMachinePoolSubnetCollector, for debugging overlapping or exhausted
+// network ranges. Collecting them requires listing the virtual networks of all
+// subscriptions, so the report is cached and refreshed by the LeakChecker.
+type Inspector struct {
+	ctrlClient              client.Client
+	logger                  micrologger.Logger
+	poolSelector            *PoolSelector
+	subnetCollector         *AzureMachinePoolSubnetCollector
+	virtualNetworkCollector *VirtualNetworkCollector
+
+	mutex  sync.Mutex
+	report *Report
+}
+
+func NewInspector(config InspectorConfig) (*Inspector, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.PoolSelector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PoolSelector must not be empty", config)
+	}
+	if config.SubnetCollector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.SubnetCollector must not be empty", config)
+	}
+	if config.VirtualNetworkCollector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VirtualNetworkCollector must not be empty", config)
+	}
+
+	i := &Inspector{
+		ctrlClient:              config.CtrlClient,
+		logger:                  config.Logger,
+		poolSelector:            config.PoolSelector,
+		subnetCollector:         config.SubnetCollector,
+		virtualNetworkCollector: config.VirtualNetworkCollector,
+	}
+
+	return i, nil
+}
+
+// Refresh collects the network ranges currently allocated by IPAM and caches
+// the report returned by Report.
+func (i *Inspector) Refresh(ctx context.Context) error {
+	i.logger.Debugf(ctx, "refreshing IPAM inspection report")
+
+	report, err := i.Inspect(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	i.mutex.Lock()
+	i.report = &report
+	i.mutex.Unlock()
+
+	i.logger.Debugf(ctx, "refreshed IPAM inspection report")
+
+	return nil
+}
+
+// Report returns the report cached by the last Refresh. It returns
+// reportNotReadyError when the network ranges have not been collected yet.
+func (i *Inspector) Report() (Report, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.report == nil {
+		return Report{}, microerror.Maskf(reportNotReadyError, "IPAM allocations have not been collected yet")
+	}
+
+	return *i.report, nil
+}
+
+// Inspect returns the network ranges currently allocated by IPAM.
+func (i *Inspector) Inspect(ctx context.Context) (Report, error) {
+	report := Report{
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	{
+		allocations, err := i.virtualNetworkAllocations(ctx)
+		if err != nil {
+			return Report{}, microerror.Mask(err)
+		}
+
+		for _, p := range i.poolSelector.Pools() {
+			report.Pools = append(report.Pools, newRangeReport(p.Name, p.NetworkRange, net.CIDRMask(p.MaskBits, 32), allocations))
+		}
+	}
+
+	{
+		azureClusters := &capz.AzureClusterList{}
+		err := i.ctrlClient.List(ctx, azureClusters, client.InNamespace(metav1.NamespaceAll))
+		if err != nil {
+			return Report{}, microerror.Mask(err)
+		}
+
+		for j := range azureClusters.Items {
+			azureCluster := &azureClusters.Items[j]
+
+			// Subnets of existing VNets are not allocated by IPAM.
+			if key.UsesExistingVnet(azureCluster) || len(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks) == 0 {
+				continue
+			}
+
+			_, vnet, err := net.ParseCIDR(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks[0])
+			if err != nil {
+				return Report{}, microerror.Mask(err)
+			}
+
+			allocations, err := i.subnetAllocations(ctx, azureCluster)
+			if err != nil {
+				return Report{}, microerror.Mask(err)
+			}
+
+			report.VirtualNetworks = append(report.VirtualNetworks, newRangeReport(key.ClusterID(azureCluster), *vnet, nodePoolIPMask, allocations))
+		}
+	}
+
+	return report, nil
+}
+
+func (i *Inspector) virtualNetworkAllocations(ctx context.Context) ([]sourcedAllocation, error) {
+	var allocations []sourcedAllocation
+
+	for _, n := range i.virtualNetworkCollector.reservedCIDRs {
+		allocations = append(allocations, sourcedAllocation{Allocation: Allocation{Network: n}, Source: SourceReserved})
+	}
+
+	azureConfigAllocations, err := i.virtualNetworkCollector.AzureConfigAllocations(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, a := range azureConfigAllocations {
+		allocations = append(allocations, sourcedAllocation{Allocation: a, OwnerKind: "AzureConfig", Source: SourceAzureConfig})
+	}
+
+	azureAllocations, err := i.virtualNetworkCollector.AzureAllocations(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, a := range azureAllocations {
+		var ownerKind string
+		if a.Owner != "" {
+			ownerKind = "AzureConfig"
+		}
+		allocations = append(allocations, sourcedAllocation{Allocation: a, OwnerKind: ownerKind, Source: SourceAzure})
+	}
+
+	return allocations, nil
+}
+
+func (i *Inspector) subnetAllocations(ctx context.Context, azureCluster *capz.AzureCluster) ([]sourcedAllocation, error) {
+	var allocations []sourcedAllocation
+
+	azureClusterAllocations, err := i.subnetCollector.AzureClusterAllocations(ctx, azureCluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	for _, a := range azureClusterAllocations {
+		allocations = append(allocations, sourcedAllocation{Allocation: a, OwnerKind: subnetOwnerKind(a.Owner), Source: SourceAzureCluster})
+	}
+
+	if azureCluster.Spec.NetworkSpec.Vnet.Name != "" {
+		azureAllocations, err := i.subnetCollector.AzureAllocations(ctx, azureCluster)
+		if IsNotFound(err) {
+			i.logger.Debugf(ctx, "virtual network of cluster %#q not found", key.ClusterID(azureCluster))
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		for _, a := range azureAllocations {
+			allocations = append(allocations, sourcedAllocation{Allocation: a, OwnerKind: subnetOwnerKind(a.Owner), Source: SourceAzure})
+		}
+	}
+
+	return allocations, nil
+}
+
+// subnetOwnerKind returns the kind of the CR owning the subnet with the given
+// name. Node pool subnets are named after their AzureMachinePool CR.
+func subnetOwnerKind(name string) string {
+	if isClusterSubnet(name) {
+		return "AzureCluster"
+	}
+
+	return "AzureMachinePool"
+}
+
+// sourcedAllocation is an allocation together with the place it has been
+// found in.
+type sourcedAllocation struct {
+	Allocation
+
+	OwnerKind string
+	Source    string
+}
+
+// newRangeReport returns the report of the allocations contained in the parent
+// network range. Allocations of the same network range and owner found in
+// several sources are reported once.
+func newRangeReport(name string, parent net.IPNet, mask net.IPMask, allocations []sourcedAllocation) RangeReport {
+	var reports []AllocationReport
+	var networks []net.IPNet
+	{
+		index := map[string]int{}
+		for _, a := range allocations {
+			if !ipam.Contains(parent, a.Network) {
+				continue
+			}
+
+			k := a.Network.String() + "/" + a.OwnerKind + "/" + a.Owner
+			j, ok := index[k]
+			if ok {
+				reports[j].Sources = appendUnique(reports[j].Sources, a.Source)
+				continue
+			}
+
+			index[k] = len(reports)
+			reports = append(reports, AllocationReport{
+				NetworkRange: a.Network.String(),
+				OwnerKind:    a.OwnerKind,
+				Owner:        a.Owner,
+				Sources:      []string{a.Source},
+			})
+			networks = append(networks, a.Network)
+		}
+	}
+
+	for j := range reports {
+		for k := range reports {
+			// The same network range allocated for different owners is
+			// reported as overlapping too.
+			if j == k {
+				continue
+			}
+			if networks[j].Contains(networks[k].IP) || networks[k].Contains(networks[j].IP) {
+				reports[j].Overlaps = appendUnique(reports[j].Overlaps, reports[k].NetworkRange)
+			}
+		}
+	}
+
+	sort.SliceStable(reports, func(j, k int) bool {
+		_, nj, _ := net.ParseCIDR(reports[j].NetworkRange)
+		_, nk, _ := net.ParseCIDR(reports[k].NetworkRange)
+		if c := bytes.Compare(nj.IP.To16(), nk.IP.To16()); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(nj.Mask, nk.Mask) < 0
+	})
+
+	report := RangeReport{
+		Name:          name,
+		NetworkRange:  parent.String(),
+		SizeAddresses: Size(parent),
+		FreeAddresses: Size(parent) - AllocatedAddresses(parent, networks),
+		Allocations:   reports,
+	}
+	if report.Allocations == nil {
+		report.Allocations = []AllocationReport{}
+	}
+
+	if report.FreeAddresses > 0 {
+		free, err := ipam.Free(parent, mask, ipam.CanonicalizeSubnets(parent, networks))
+		if err == nil {
+			report.NextFreeRange = free.String()
+		}
+	}
+
+	return report
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+
+	return append(list, s)
+}
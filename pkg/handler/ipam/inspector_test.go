@@ -0,0 +1,184 @@
+package ipam
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newRangeReport(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		parent         net.IPNet
+		mask           net.IPMask
+		allocations    []sourcedAllocation
+		expectedReport RangeReport
+	}{
+		{
+			name: "case 0 empty parent range",
+
+			parent: mustParseCIDR("10.1.0.0/16"),
+			mask:   net.CIDRMask(24, 32),
+			expectedReport: RangeReport{
+				Name:          "test",
+				NetworkRange:  "10.1.0.0/16",
+				SizeAddresses: 65536,
+				FreeAddresses: 65536,
+				NextFreeRange: "10.1.0.0/24",
+				Allocations:   []AllocationReport{},
+			},
+		},
+		{
+			name: "case 1 allocations found in several sources are merged and sorted",
+
+			parent: mustParseCIDR("10.1.0.0/16"),
+			mask:   net.CIDRMask(24, 32),
+			allocations: []sourcedAllocation{
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np2"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/24"), Owner: "np1"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/24"), Owner: "np1"}, OwnerKind: "AzureMachinePool", Source: SourceAzure},
+				{Allocation: Allocation{Network: mustParseCIDR("10.2.0.0/24"), Owner: "np3"}, OwnerKind: "AzureMachinePool", Source: SourceAzure},
+			},
+			expectedReport: RangeReport{
+				Name:          "test",
+				NetworkRange:  "10.1.0.0/16",
+				SizeAddresses: 65536,
+				FreeAddresses: 65024,
+				NextFreeRange: "10.1.2.0/24",
+				Allocations: []AllocationReport{
+					{NetworkRange: "10.1.0.0/24", OwnerKind: "AzureMachinePool", Owner: "np1", Sources: []string{SourceAzureCluster, SourceAzure}},
+					{NetworkRange: "10.1.1.0/24", OwnerKind: "AzureMachinePool", Owner: "np2", Sources: []string{SourceAzureCluster}},
+				},
+			},
+		},
+		{
+			name: "case 2 overlapping allocations",
+
+			parent: mustParseCIDR("10.1.0.0/16"),
+			mask:   net.CIDRMask(24, 32),
+			allocations: []sourcedAllocation{
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/24"), Owner: "np1"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/24"), Owner: "np2"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/23"), Owner: "np3"}, OwnerKind: "AzureMachinePool", Source: SourceAzure},
+			},
+			expectedReport: RangeReport{
+				Name:          "test",
+				NetworkRange:  "10.1.0.0/16",
+				SizeAddresses: 65536,
+				FreeAddresses: 65024,
+				NextFreeRange: "10.1.2.0/24",
+				Allocations: []AllocationReport{
+					{NetworkRange: "10.1.0.0/23", OwnerKind: "AzureMachinePool", Owner: "np3", Sources: []string{SourceAzure}, Overlaps: []string{"10.1.0.0/24"}},
+					{NetworkRange: "10.1.0.0/24", OwnerKind: "AzureMachinePool", Owner: "np1", Sources: []string{SourceAzureCluster}, Overlaps: []string{"10.1.0.0/24", "10.1.0.0/23"}},
+					{NetworkRange: "10.1.0.0/24", OwnerKind: "AzureMachinePool", Owner: "np2", Sources: []string{SourceAzureCluster}, Overlaps: []string{"10.1.0.0/24", "10.1.0.0/23"}},
+				},
+			},
+		},
+		{
+			name: "case 3 exhausted parent range",
+
+			parent: mustParseCIDR("10.1.0.0/23"),
+			mask:   net.CIDRMask(24, 32),
+			allocations: []sourcedAllocation{
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.0.0/24"), Owner: "np1"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+				{Allocation: Allocation{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np2"}, OwnerKind: "AzureMachinePool", Source: SourceAzureCluster},
+			},
+			expectedReport: RangeReport{
+				Name:          "test",
+				NetworkRange:  "10.1.0.0/23",
+				SizeAddresses: 512,
+				FreeAddresses: 0,
+				Allocations: []AllocationReport{
+					{NetworkRange: "10.1.0.0/24", OwnerKind: "AzureMachinePool", Owner: "np1", Sources: []string{SourceAzureCluster}},
+					{NetworkRange: "10.1.1.0/24", OwnerKind: "AzureMachinePool", Owner: "np2", Sources: []string{SourceAzureCluster}},
+				},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			report := newRangeReport("test", tc.parent, tc.mask, tc.allocations)
+
+			if !cmp.Equal(report, tc.expectedReport) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedReport, report))
+			}
+		})
+	}
+}
+
+func Test_Inspector_Report(t *testing.T) {
+	ctx := context.Background()
+	ctrlClient := newLeakCheckerFakeClient(t)
+
+	poolSelector, err := NewPoolSelector(PoolSelectorConfig{
+		CtrlClient:  ctrlClient,
+		Logger:      microloggertest.New(),
+		DefaultPool: Pool{NetworkRange: mustParseCIDR("10.1.0.0/16"), MaskBits: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inspector, err := NewInspector(InspectorConfig{
+		CtrlClient:   ctrlClient,
+		Logger:       microloggertest.New(),
+		PoolSelector: poolSelector,
+		SubnetCollector: &AzureMachinePoolSubnetCollector{
+			ctrlClient: ctrlClient,
+			logger:     microloggertest.New(),
+		},
+		VirtualNetworkCollector: &VirtualNetworkCollector{
+			k8sclient:     k8sclienttest.NewClients(k8sclienttest.ClientsConfig{CtrlClient: ctrlClient}),
+			logger:        microloggertest.New(),
+			reservedCIDRs: []net.IPNet{mustParseCIDR("10.1.0.0/20")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The report is not available before the first refresh.
+	_, err = inspector.Report()
+	if !IsReportNotReady(err) {
+		t.Fatalf("error == %#v, want matching", err)
+	}
+
+	err = inspector.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := inspector.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.UpdatedAt.IsZero() {
+		t.Fatalf("report.UpdatedAt is not set")
+	}
+
+	expectedPools := []RangeReport{
+		{
+			Name:          DefaultPoolName,
+			NetworkRange:  "10.1.0.0/16",
+			SizeAddresses: 65536,
+			FreeAddresses: 61440,
+			NextFreeRange: "10.1.16.0/20",
+			Allocations: []AllocationReport{
+				{NetworkRange: "10.1.0.0/20", Sources: []string{SourceReserved}},
+			},
+		},
+	}
+	if !cmp.Equal(report.Pools, expectedPools) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expectedPools, report.Pools))
+	}
+}
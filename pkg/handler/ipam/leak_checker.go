@@ -0,0 +1,411 @@
+package ipam
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/helpers"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+type LeakCheckerConfig struct {
+	CtrlClient              client.Client
+	Logger                  micrologger.Logger
+	PoolSelector            *PoolSelector
+	SubnetCollector         *AzureMachinePoolSubnetCollector
+	VirtualNetworkCollector *VirtualNetworkCollector
+
+	// Inspector is optional. When set, its report is refreshed after every
+	// leak check.
+	Inspector *Inspector
+	// Interval is the time between two leak checks.
+	Interval time.Duration
+	// Reclaim makes the leak checker release the orphaned node pool subnets
+	// from AzureCluster CRs. Otherwise orphans are only reported.
+	Reclaim bool
+}
+
+// Orphan is a network range allocated by IPAM whose owner does not exist
+// anymore.
+type Orphan struct {
+	Allocation
+
+	ClusterID string
+	RangeType NetworkRangeType
+	// Reason describes where the allocation has been found.
+	Reason string
+	// Reclaimable is true for orphans the leak checker can release, i.e. node
+	// pool subnets in AzureCluster CRs. Other orphans are only reported, as
+	// releasing them requires deleting Azure resources.
+	Reclaimable bool
+}
+
+// LeakChecker periodically compares the network ranges collected by
+// VirtualNetworkCollector and AzureMachinePoolSubnetCollector with the
+// existing CRs, and reports or reclaims the allocations whose owner does not
+// exist anymore. It also reports the usage of the IPAM pools.
+type LeakChecker struct {
+	ctrlClient              client.Client
+	logger                  micrologger.Logger
+	poolSelector            *PoolSelector
+	subnetCollector         *AzureMachinePoolSubnetCollector
+	virtualNetworkCollector *VirtualNetworkCollector
+
+	inspector *Inspector
+	interval  time.Duration
+	reclaim   bool
+}
+
+func NewLeakChecker(config LeakCheckerConfig) (*LeakChecker, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.PoolSelector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PoolSelector must not be empty", config)
+	}
+	if config.SubnetCollector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.SubnetCollector must not be empty", config)
+	}
+	if config.VirtualNetworkCollector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.VirtualNetworkCollector must not be empty", config)
+	}
+
+	if config.Interval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Interval must be greater than zero", config)
+	}
+
+	l := &LeakChecker{
+		ctrlClient:              config.CtrlClient,
+		logger:                  config.Logger,
+		poolSelector:            config.PoolSelector,
+		subnetCollector:         config.SubnetCollector,
+		virtualNetworkCollector: config.VirtualNetworkCollector,
+
+		inspector: config.Inspector,
+		interval:  config.Interval,
+		reclaim:   config.Reclaim,
+	}
+
+	return l, nil
+}
+
+// Boot checks allocations for leaks and refreshes the inspection report every
+// interval until the context is canceled.
+func (l *LeakChecker) Boot(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := l.Check(ctx)
+		if err != nil {
+			l.logger.Errorf(ctx, err, "failed to check IPAM allocations for leaks")
+		}
+
+		if l.inspector != nil {
+			err = l.inspector.Refresh(ctx)
+			if err != nil {
+				l.logger.Errorf(ctx, err, "failed to refresh IPAM inspection report")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns the orphaned allocations, after reclaiming them when enabled.
+func (l *LeakChecker) Check(ctx context.Context) ([]Orphan, error) {
+	l.logger.Debugf(ctx, "checking IPAM allocations for leaks")
+
+	// AzureClusters must be listed before AzureMachinePools. Node pool
+	// subnets are only allocated for existing AzureMachinePools, so subnets
+	// of AzureMachinePools created in between are not reported.
+	azureClusters := &capz.AzureClusterList{}
+	err := l.ctrlClient.List(ctx, azureClusters, client.InNamespace(metav1.NamespaceAll))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	azureConfigs := &v1alpha1.AzureConfigList{}
+	err = l.ctrlClient.List(ctx, azureConfigs, client.InNamespace(metav1.NamespaceAll))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var orphans []Orphan
+	{
+		o, err := l.virtualNetworkOrphans(ctx, azureConfigs, azureClusters)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		orphans = append(orphans, o...)
+	}
+
+	for i := range azureClusters.Items {
+		o, err := l.subnetOrphans(ctx, &azureClusters.Items[i])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		orphans = append(orphans, o...)
+	}
+
+	for _, o := range orphans {
+		l.logger.LogCtx(ctx, "level", "warning", "message", "found orphaned IPAM allocation", "rangeType", o.RangeType, "clusterID", o.ClusterID, "owner", o.Owner, "networkRange", o.Network.String(), "reason", o.Reason)
+	}
+
+	reportOrphans(orphans)
+
+	if l.reclaim {
+		for i := range azureClusters.Items {
+			err = l.reclaimSubnets(ctx, &azureClusters.Items[i], orphans)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	}
+
+	l.logger.Debugf(ctx, "checked IPAM allocations for leaks, found %d orphaned allocations", len(orphans))
+
+	return orphans, nil
+}
+
+func (l *LeakChecker) virtualNetworkOrphans(ctx context.Context, azureConfigs *v1alpha1.AzureConfigList, azureClusters *capz.AzureClusterList) ([]Orphan, error) {
+	azureConfigAllocations, err := l.virtualNetworkCollector.AzureConfigAllocations(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	azureAllocations, err := l.virtualNetworkCollector.AzureAllocations(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// The allocated virtual networks are only collected here, so the pool
+	// usage is reported along with the orphaned virtual networks.
+	var networks []net.IPNet
+	networks = append(networks, l.virtualNetworkCollector.reservedCIDRs...)
+	networks = append(networks, allocatedNetworks(azureConfigAllocations)...)
+	networks = append(networks, allocatedNetworks(azureAllocations)...)
+	reportPoolUsage(l.poolSelector.Pools(), networks)
+
+	// Ranges of AzureConfig CRs being deleted are released together with the
+	// cluster.
+	azureConfigIDs := map[string]bool{}
+	deletedAzureConfigIDs := map[string]bool{}
+	for i := range azureConfigs.Items {
+		azureConfigIDs[key.ClusterID(&azureConfigs.Items[i])] = true
+		if key.IsDeleted(&azureConfigs.Items[i]) {
+			deletedAzureConfigIDs[key.ClusterID(&azureConfigs.Items[i])] = true
+		}
+	}
+
+	var azureConfigRanges []Allocation
+	for _, a := range azureConfigAllocations {
+		if !deletedAzureConfigIDs[a.Owner] {
+			azureConfigRanges = append(azureConfigRanges, a)
+		}
+	}
+
+	azureClusterIDs := map[string]bool{}
+	for i := range azureClusters.Items {
+		azureClusterIDs[key.ClusterID(&azureClusters.Items[i])] = true
+	}
+
+	return findVirtualNetworkOrphans(azureConfigRanges, azureAllocations, azureConfigIDs, azureClusterIDs), nil
+}
+
+func (l *LeakChecker) subnetOrphans(ctx context.Context, azureCluster *capz.AzureCluster) ([]Orphan, error) {
+	// Subnets of existing VNets are not allocated by IPAM and subnets of
+	// deleted clusters are released together with the cluster.
+	if key.UsesExistingVnet(azureCluster) || key.IsDeleted(azureCluster) {
+		return nil, nil
+	}
+
+	azureMachinePools, err := helpers.GetAzureMachinePoolsByClusterID(ctx, l.ctrlClient, azureCluster.Namespace, key.ClusterID(azureCluster))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	nodePools := map[string]bool{}
+	for _, amp := range azureMachinePools.Items {
+		nodePools[amp.Name] = true
+	}
+
+	nodeSubnets := map[string]bool{}
+	for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
+		if subnet.Role == capz.SubnetNode {
+			nodeSubnets[subnet.Name] = true
+		}
+	}
+
+	azureClusterAllocations, err := l.subnetCollector.AzureClusterAllocations(ctx, azureCluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var azureAllocations []Allocation
+	if azureCluster.Spec.NetworkSpec.Vnet.Name != "" {
+		azureAllocations, err = l.subnetCollector.AzureAllocations(ctx, azureCluster)
+		if IsNotFound(err) {
+			// The virtual network is not created yet or already deleted.
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return findSubnetOrphans(key.ClusterID(azureCluster), azureClusterAllocations, azureAllocations, nodePools, nodeSubnets), nil
+}
+
+// reclaimSubnets removes the orphaned node pool subnets from the AzureCluster
+// CR. The subnet handler then deletes them from the Azure virtual network.
+// Only subnets with the node role are removed, and AzureCluster CRs being
+// deleted are left alone.
+func (l *LeakChecker) reclaimSubnets(ctx context.Context, azureCluster *capz.AzureCluster, orphans []Orphan) error {
+	if key.IsDeleted(azureCluster) {
+		return nil
+	}
+
+	reclaimable := map[string]bool{}
+	for _, o := range orphans {
+		if o.Reclaimable && o.ClusterID == key.ClusterID(azureCluster) {
+			reclaimable[o.Owner] = true
+		}
+	}
+
+	if len(reclaimable) == 0 {
+		return nil
+	}
+
+	var subnets capz.Subnets
+	var reclaimed int
+	for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
+		if reclaimable[subnet.Name] && subnet.Role == capz.SubnetNode {
+			reclaimed++
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	if reclaimed == 0 {
+		return nil
+	}
+
+	l.logger.Debugf(ctx, "releasing %d orphaned subnets from AzureCluster CR %#q", reclaimed, azureCluster.Name)
+
+	azureCluster.Spec.NetworkSpec.Subnets = subnets
+	err := l.ctrlClient.Update(ctx, azureCluster)
+	if apierrors.IsConflict(err) {
+		l.logger.Debugf(ctx, "conflict trying to save object in k8s API concurrently")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for i := 0; i < reclaimed; i++ {
+		reportReclaimedAllocation(SubnetRange, key.ClusterID(azureCluster))
+	}
+
+	l.logger.Debugf(ctx, "released %d orphaned subnets from AzureCluster CR %#q", reclaimed, azureCluster.Name)
+
+	return nil
+}
+
+// findVirtualNetworkOrphans returns the virtual networks of resource groups
+// without AzureConfig CR and the ranges of AzureConfig CRs without
+// AzureCluster CR.
+func findVirtualNetworkOrphans(azureConfigAllocations, azureAllocations []Allocation, azureConfigIDs, azureClusterIDs map[string]bool) []Orphan {
+	var orphans []Orphan
+
+	for _, a := range azureAllocations {
+		if a.Owner == "" || azureConfigIDs[a.Owner] {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Allocation: a,
+			ClusterID:  a.Owner,
+			RangeType:  VirtualNetworkRange,
+			Reason:     "virtual network of resource group without AzureConfig CR",
+		})
+	}
+
+	for _, a := range azureConfigAllocations {
+		if azureClusterIDs[a.Owner] {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Allocation: a,
+			ClusterID:  a.Owner,
+			RangeType:  VirtualNetworkRange,
+			Reason:     "AzureConfig CR without AzureCluster CR",
+		})
+	}
+
+	return orphans
+}
+
+// findSubnetOrphans returns the node pool subnets of the AzureCluster CR and
+// of the Azure virtual network without AzureMachinePool CR. The master, worker
+// and VPN gateway subnets are not owned by node pools. Only subnets with the
+// node role in the AzureCluster CR are reclaimable.
+func findSubnetOrphans(clusterID string, azureClusterAllocations, azureAllocations []Allocation, nodePools, nodeSubnets map[string]bool) []Orphan {
+	var orphans []Orphan
+
+	inAzureCluster := map[string]bool{}
+	for _, a := range azureClusterAllocations {
+		inAzureCluster[a.Owner] = true
+
+		if nodePools[a.Owner] || isClusterSubnet(a.Owner) {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Allocation:  a,
+			ClusterID:   clusterID,
+			RangeType:   SubnetRange,
+			Reason:      "subnet in AzureCluster CR without AzureMachinePool CR",
+			Reclaimable: nodeSubnets[a.Owner],
+		})
+	}
+
+	for _, a := range azureAllocations {
+		// IPv6 ranges of dual-stack subnets are reported with their IPv4
+		// range.
+		if a.Network.IP.To4() == nil {
+			continue
+		}
+		if nodePools[a.Owner] || inAzureCluster[a.Owner] || isClusterSubnet(a.Owner) {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Allocation: a,
+			ClusterID:  clusterID,
+			RangeType:  SubnetRange,
+			Reason:     "subnet in Azure virtual network without AzureMachinePool CR",
+		})
+	}
+
+	return orphans
+}
+
+func isClusterSubnet(name string) bool {
+	return strings.HasSuffix(name, "-MasterSubnet") || strings.HasSuffix(name, "-WorkerSubnet") || name == key.VNetGatewaySubnetName()
+}
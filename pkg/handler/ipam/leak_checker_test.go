@@ -0,0 +1,440 @@
+package ipam
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+)
+
+func Test_findVirtualNetworkOrphans(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		azureConfigAllocations []Allocation
+		azureAllocations       []Allocation
+		azureConfigIDs         map[string]bool
+		azureClusterIDs        map[string]bool
+		expectedOrphans        []Orphan
+	}{
+		{
+			name: "case 0 no orphans",
+
+			azureConfigAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.0.0.0/16"), Owner: ""},
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+			},
+			azureConfigIDs:  map[string]bool{"c1": true},
+			azureClusterIDs: map[string]bool{"c1": true},
+			expectedOrphans: nil,
+		},
+		{
+			name: "case 1 virtual network without AzureConfig CR",
+
+			azureConfigAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+				{Network: mustParseCIDR("10.2.0.0/16"), Owner: "c2"},
+			},
+			azureConfigIDs:  map[string]bool{"c1": true},
+			azureClusterIDs: map[string]bool{"c1": true},
+			expectedOrphans: []Orphan{
+				{
+					Allocation: Allocation{Network: mustParseCIDR("10.2.0.0/16"), Owner: "c2"},
+					ClusterID:  "c2",
+					RangeType:  VirtualNetworkRange,
+					Reason:     "virtual network of resource group without AzureConfig CR",
+				},
+			},
+		},
+		{
+			name: "case 2 AzureConfig CR without AzureCluster CR",
+
+			azureConfigAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+				{Network: mustParseCIDR("10.3.0.0/16"), Owner: "c3"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/16"), Owner: "c1"},
+			},
+			azureConfigIDs:  map[string]bool{"c1": true, "c3": true},
+			azureClusterIDs: map[string]bool{"c1": true},
+			expectedOrphans: []Orphan{
+				{
+					Allocation: Allocation{Network: mustParseCIDR("10.3.0.0/16"), Owner: "c3"},
+					ClusterID:  "c3",
+					RangeType:  VirtualNetworkRange,
+					Reason:     "AzureConfig CR without AzureCluster CR",
+				},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			orphans := findVirtualNetworkOrphans(tc.azureConfigAllocations, tc.azureAllocations, tc.azureConfigIDs, tc.azureClusterIDs)
+
+			if !cmp.Equal(orphans, tc.expectedOrphans) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedOrphans, orphans))
+			}
+		})
+	}
+}
+
+func Test_findSubnetOrphans(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		azureClusterAllocations []Allocation
+		azureAllocations        []Allocation
+		nodePools               map[string]bool
+		nodeSubnets             map[string]bool
+		expectedOrphans         []Orphan
+	}{
+		{
+			name: "case 0 no orphans",
+
+			azureClusterAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/24"), Owner: "c1-MasterSubnet"},
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.2.0/24"), Owner: "GatewaySubnet"},
+			},
+			nodePools:       map[string]bool{"np1": true},
+			nodeSubnets:     map[string]bool{"np1": true},
+			expectedOrphans: nil,
+		},
+		{
+			name: "case 1 subnet in AzureCluster CR without AzureMachinePool CR is reclaimable",
+
+			azureClusterAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.3.0/24"), Owner: "np2"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.3.0/24"), Owner: "np2"},
+			},
+			nodePools:   map[string]bool{"np1": true},
+			nodeSubnets: map[string]bool{"np1": true, "np2": true},
+			expectedOrphans: []Orphan{
+				{
+					Allocation:  Allocation{Network: mustParseCIDR("10.1.3.0/24"), Owner: "np2"},
+					ClusterID:   "c1",
+					RangeType:   SubnetRange,
+					Reason:      "subnet in AzureCluster CR without AzureMachinePool CR",
+					Reclaimable: true,
+				},
+			},
+		},
+		{
+			name: "case 2 subnet in Azure virtual network without AzureMachinePool CR is reported",
+
+			azureClusterAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.4.0/24"), Owner: "np3"},
+				{Network: mustParseCIDR("fd00:0:1:4::/64"), Owner: "np3"},
+			},
+			nodePools:   map[string]bool{"np1": true},
+			nodeSubnets: map[string]bool{"np1": true},
+			expectedOrphans: []Orphan{
+				{
+					Allocation: Allocation{Network: mustParseCIDR("10.1.4.0/24"), Owner: "np3"},
+					ClusterID:  "c1",
+					RangeType:  SubnetRange,
+					Reason:     "subnet in Azure virtual network without AzureMachinePool CR",
+				},
+			},
+		},
+		{
+			name: "case 3 cluster subnets in AzureCluster CR are not reported",
+
+			azureClusterAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/24"), Owner: "c1-MasterSubnet"},
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.2.0/24"), Owner: "GatewaySubnet"},
+			},
+			azureAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.0.0/24"), Owner: "c1-MasterSubnet"},
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+			},
+			nodePools:       map[string]bool{"np1": true},
+			nodeSubnets:     map[string]bool{"np1": true},
+			expectedOrphans: nil,
+		},
+		{
+			name: "case 4 subnet in AzureCluster CR without node role is not reclaimable",
+
+			azureClusterAllocations: []Allocation{
+				{Network: mustParseCIDR("10.1.1.0/24"), Owner: "np1"},
+				{Network: mustParseCIDR("10.1.5.0/24"), Owner: "bastion"},
+			},
+			nodePools:   map[string]bool{"np1": true},
+			nodeSubnets: map[string]bool{"np1": true},
+			expectedOrphans: []Orphan{
+				{
+					Allocation: Allocation{Network: mustParseCIDR("10.1.5.0/24"), Owner: "bastion"},
+					ClusterID:  "c1",
+					RangeType:  SubnetRange,
+					Reason:     "subnet in AzureCluster CR without AzureMachinePool CR",
+				},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			orphans := findSubnetOrphans("c1", tc.azureClusterAllocations, tc.azureAllocations, tc.nodePools, tc.nodeSubnets)
+
+			if !cmp.Equal(orphans, tc.expectedOrphans) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedOrphans, orphans))
+			}
+		})
+	}
+}
+
+func Test_LeakChecker_reclaimSubnets(t *testing.T) {
+	now := metav1.Now()
+
+	testCases := []struct {
+		name string
+
+		deletionTimestamp *metav1.Time
+		orphans           []Orphan
+		expectedSubnets   []string
+	}{
+		{
+			name: "case 0 reclaimable node subnet is removed",
+
+			orphans: []Orphan{
+				{Allocation: Allocation{Owner: "np2"}, ClusterID: "c1", RangeType: SubnetRange, Reclaimable: true},
+			},
+			expectedSubnets: []string{"c1-MasterSubnet", "np1", "bastion"},
+		},
+		{
+			name: "case 1 orphans which are not reclaimable or of other clusters are kept",
+
+			orphans: []Orphan{
+				{Allocation: Allocation{Owner: "np2"}, ClusterID: "c1", RangeType: SubnetRange},
+				{Allocation: Allocation{Owner: "np1"}, ClusterID: "c2", RangeType: SubnetRange, Reclaimable: true},
+			},
+			expectedSubnets: []string{"c1-MasterSubnet", "np1", "np2", "bastion"},
+		},
+		{
+			name: "case 2 subnets without node role are kept",
+
+			orphans: []Orphan{
+				{Allocation: Allocation{Owner: "bastion"}, ClusterID: "c1", RangeType: SubnetRange, Reclaimable: true},
+			},
+			expectedSubnets: []string{"c1-MasterSubnet", "np1", "np2", "bastion"},
+		},
+		{
+			name: "case 3 AzureCluster CR being deleted is left alone",
+
+			deletionTimestamp: &now,
+			orphans: []Orphan{
+				{Allocation: Allocation{Owner: "np2"}, ClusterID: "c1", RangeType: SubnetRange, Reclaimable: true},
+			},
+			expectedSubnets: []string{"c1-MasterSubnet", "np1", "np2", "bastion"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			azureCluster := newLeakCheckerAzureCluster()
+			azureCluster.DeletionTimestamp = tc.deletionTimestamp
+			ctrlClient := newLeakCheckerFakeClient(t, azureCluster)
+
+			l := &LeakChecker{
+				ctrlClient: ctrlClient,
+				logger:     microloggertest.New(),
+			}
+
+			err := l.reclaimSubnets(ctx, azureCluster, tc.orphans)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated := &capz.AzureCluster{}
+			err = ctrlClient.Get(ctx, client.ObjectKey{Namespace: azureCluster.Namespace, Name: azureCluster.Name}, updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var subnets []string
+			for _, subnet := range updated.Spec.NetworkSpec.Subnets {
+				subnets = append(subnets, subnet.Name)
+			}
+
+			if !cmp.Equal(subnets, tc.expectedSubnets) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedSubnets, subnets))
+			}
+		})
+	}
+}
+
+func Test_LeakChecker_Boot(t *testing.T) {
+	azureCluster := newLeakCheckerAzureCluster()
+	azureMachinePool := &capzexp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "np1",
+			Namespace: "org-acme",
+			Labels: map[string]string{
+				capi.ClusterLabelName: "c1",
+			},
+		},
+	}
+	ctrlClient := newLeakCheckerFakeClient(t, azureCluster, azureMachinePool)
+
+	poolSelector, err := NewPoolSelector(PoolSelectorConfig{
+		CtrlClient:  ctrlClient,
+		Logger:      microloggertest.New(),
+		DefaultPool: Pool{NetworkRange: mustParseCIDR("10.1.0.0/16"), MaskBits: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subnetCollector := &AzureMachinePoolSubnetCollector{
+		ctrlClient: ctrlClient,
+		logger:     microloggertest.New(),
+	}
+	virtualNetworkCollector := &VirtualNetworkCollector{
+		k8sclient: k8sclienttest.NewClients(k8sclienttest.ClientsConfig{CtrlClient: ctrlClient}),
+		logger:    microloggertest.New(),
+	}
+
+	inspector, err := NewInspector(InspectorConfig{
+		CtrlClient:              ctrlClient,
+		Logger:                  microloggertest.New(),
+		PoolSelector:            poolSelector,
+		SubnetCollector:         subnetCollector,
+		VirtualNetworkCollector: virtualNetworkCollector,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewLeakChecker(LeakCheckerConfig{
+		CtrlClient:              ctrlClient,
+		Logger:                  microloggertest.New(),
+		PoolSelector:            poolSelector,
+		SubnetCollector:         subnetCollector,
+		VirtualNetworkCollector: virtualNetworkCollector,
+
+		Inspector: inspector,
+		Interval:  time.Hour,
+		Reclaim:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Boot checks for leaks right away and returns once the context is
+	// canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.Boot(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Boot did not return after the context was canceled")
+	}
+
+	updated := &capz.AzureCluster{}
+	err = ctrlClient.Get(context.Background(), client.ObjectKey{Namespace: azureCluster.Namespace, Name: azureCluster.Name}, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var subnets []string
+	for _, subnet := range updated.Spec.NetworkSpec.Subnets {
+		subnets = append(subnets, subnet.Name)
+	}
+
+	expectedSubnets := []string{"c1-MasterSubnet", "np1", "bastion"}
+	if !cmp.Equal(subnets, expectedSubnets) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expectedSubnets, subnets))
+	}
+
+	// The inspection report is refreshed after the leak check.
+	report, err := inspector.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Pools) != 1 || report.Pools[0].Name != DefaultPoolName {
+		t.Fatalf("report.Pools == %#v, want default pool", report.Pools)
+	}
+}
+
+func newLeakCheckerAzureCluster() *capz.AzureCluster {
+	return &capz.AzureCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "c1",
+			Namespace: "org-acme",
+			Labels: map[string]string{
+				label.Cluster: "c1",
+			},
+		},
+		Spec: capz.AzureClusterSpec{
+			NetworkSpec: capz.NetworkSpec{
+				Subnets: capz.Subnets{
+					{Name: "c1-MasterSubnet", Role: capz.SubnetControlPlane, CIDRBlocks: []string{"10.1.0.0/24"}},
+					{Name: "np1", Role: capz.SubnetNode, CIDRBlocks: []string{"10.1.1.0/24"}},
+					{Name: "np2", Role: capz.SubnetNode, CIDRBlocks: []string{"10.1.2.0/24"}},
+					{Name: "bastion", Role: "bastion", CIDRBlocks: []string{"10.1.3.0/24"}},
+				},
+			},
+		},
+	}
+}
+
+func newLeakCheckerFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{capz.AddToScheme, capzexp.AddToScheme, providerv1alpha1.AddToScheme} {
+		err := addToScheme(scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
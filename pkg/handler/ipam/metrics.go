@@ -0,0 +1,74 @@
+package ipam
+
+import (
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelClusterID    = "cluster_id"
+	labelNetworkRange = "network_range"
+	labelOwner        = "owner"
+	labelPool         = "pool"
+	labelRangeType    = "range_type"
+)
+
+var (
+	orphanedAllocations = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_ipam_orphaned_allocations",
+			Help: "Gauge representing the network ranges allocated by IPAM whose owner does not exist anymore.",
+		},
+		[]string{labelRangeType, labelClusterID, labelOwner, labelNetworkRange},
+	)
+
+	reclaimedAllocations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azure_operator_ipam_reclaimed_allocations_total",
+			Help: "Counter representing the orphaned network ranges released by the IPAM leak checker.",
+		},
+		[]string{labelRangeType, labelClusterID},
+	)
+
+	poolSizeAddresses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_ipam_pool_size_addresses",
+			Help: "Gauge representing the number of addresses in the network range of an IPAM pool.",
+		},
+		[]string{labelPool, labelNetworkRange},
+	)
+
+	poolAllocatedAddresses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_ipam_pool_allocated_addresses",
+			Help: "Gauge representing the number of addresses of an IPAM pool which are allocated or reserved.",
+		},
+		[]string{labelPool, labelNetworkRange},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(orphanedAllocations)
+	prometheus.MustRegister(reclaimedAllocations)
+	prometheus.MustRegister(poolSizeAddresses)
+	prometheus.MustRegister(poolAllocatedAddresses)
+}
+
+func reportOrphans(orphans []Orphan) {
+	orphanedAllocations.Reset()
+	for _, o := range orphans {
+		orphanedAllocations.WithLabelValues(string(o.RangeType), o.ClusterID, o.Owner, o.Network.String()).Set(1)
+	}
+}
+
+func reportReclaimedAllocation(rangeType NetworkRangeType, clusterID string) {
+	reclaimedAllocations.WithLabelValues(string(rangeType), clusterID).Inc()
+}
+
+func reportPoolUsage(pools []Pool, networks []net.IPNet) {
+	for _, p := range pools {
+		poolSizeAddresses.WithLabelValues(p.Name, p.NetworkRange.String()).Set(float64(Size(p.NetworkRange)))
+		poolAllocatedAddresses.WithLabelValues(p.Name, p.NetworkRange.String()).Set(float64(AllocatedAddresses(p.NetworkRange, networks)))
+	}
+}
@@ -0,0 +1,225 @@
+package ipam
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	securityv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/security/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// DefaultPoolName is the name of the pool configured with the
+	// installation network CIDR and subnet mask bits. It is used for clusters
+	// without IPAM pool label.
+	DefaultPoolName = "default"
+)
+
+// Pool is a named network range from which the virtual networks of tenant
+// clusters are allocated.
+type Pool struct {
+	Name string
+	// NetworkRange is the range from which the virtual networks are
+	// allocated.
+	NetworkRange net.IPNet
+	// MaskBits is the number of bits in the mask of the allocated virtual
+	// networks.
+	MaskBits int
+}
+
+// ParsePools parses a comma separated list of pools in the format
+// `name:CIDR:maskBits`, e.g. `westeurope:10.64.0.0/12:16,dev:10.80.0.0/16:20`.
+func ParsePools(pools string) ([]Pool, error) {
+	var parsed []Pool
+
+	for _, p := range strings.Split(pools, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		fields := strings.Split(p, ":")
+		if len(fields) != 3 {
+			return nil, microerror.Maskf(invalidConfigError, "IPAM pool %#q must have the format `name:CIDR:maskBits`", p)
+		}
+
+		_, ipNet, err := net.ParseCIDR(fields[1])
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "IPAM pool %#q has invalid CIDR: %s", p, err)
+		}
+
+		maskBits, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "IPAM pool %#q has invalid mask bits: %s", p, err)
+		}
+
+		parsed = append(parsed, Pool{
+			Name:         fields[0],
+			NetworkRange: *ipNet,
+			MaskBits:     maskBits,
+		})
+	}
+
+	return parsed, nil
+}
+
+func validatePool(pool Pool) error {
+	if pool.Name == "" {
+		return microerror.Maskf(invalidConfigError, "IPAM pool name must not be empty")
+	}
+	if pool.NetworkRange.IP.To4() == nil {
+		return microerror.Maskf(invalidConfigError, "IPAM pool %#q network range %#q must be an IPv4 range", pool.Name, pool.NetworkRange.String())
+	}
+	if pool.MaskBits < minAllocatedVNetMaskBits {
+		return microerror.Maskf(invalidConfigError, "IPAM pool %#q mask bits (%d) must not be smaller than %d", pool.Name, pool.MaskBits, minAllocatedVNetMaskBits)
+	}
+
+	ones, _ := pool.NetworkRange.Mask.Size()
+	if pool.MaskBits < ones || pool.MaskBits > 32 {
+		return microerror.Maskf(invalidConfigError, "IPAM pool %#q mask bits (%d) must be between %d and 32", pool.Name, pool.MaskBits, ones)
+	}
+
+	return nil
+}
+
+type PoolSelectorConfig struct {
+	CtrlClient client.Client
+	Logger     micrologger.Logger
+
+	// DefaultPool is used for clusters without IPAM pool label. Its name is
+	// always DefaultPoolName.
+	DefaultPool Pool
+	Pools       []Pool
+}
+
+// PoolSelector selects the IPAM pool of a tenant cluster based on the
+// label.IPAMPool label of its Cluster CR or, when the Cluster CR doesn't have
+// it, of its Organization CR.
+type PoolSelector struct {
+	ctrlClient client.Client
+	logger     micrologger.Logger
+
+	pools map[string]Pool
+}
+
+func NewPoolSelector(config PoolSelectorConfig) (*PoolSelector, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	defaultPool := config.DefaultPool
+	defaultPool.Name = DefaultPoolName
+
+	allPools := append([]Pool{defaultPool}, config.Pools...)
+
+	pools := map[string]Pool{}
+	for i, p := range allPools {
+		err := validatePool(p)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		_, exists := pools[p.Name]
+		if exists {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Pools must not contain pool %#q more than once", config, p.Name)
+		}
+
+		// Overlapping pools would allocate the same virtual network range to
+		// clusters of different pools, since the allocated ranges are only
+		// collected within the pool of the cluster.
+		for _, o := range allPools[:i] {
+			if p.NetworkRange.Contains(o.NetworkRange.IP) || o.NetworkRange.Contains(p.NetworkRange.IP) {
+				return nil, microerror.Maskf(invalidConfigError, "%T.Pools must not contain pool %#q overlapping with pool %#q", config, p.Name, o.Name)
+			}
+		}
+
+		pools[p.Name] = p
+	}
+
+	s := &PoolSelector{
+		ctrlClient: config.CtrlClient,
+		logger:     config.Logger,
+
+		pools: pools,
+	}
+
+	return s, nil
+}
+
+// Pools returns all configured pools sorted by name.
+func (s *PoolSelector) Pools() []Pool {
+	var pools []Pool
+	for _, p := range s.pools {
+		pools = append(pools, p)
+	}
+
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+
+	return pools
+}
+
+// Select returns the pool of the tenant cluster of the given AzureConfig CR.
+func (s *PoolSelector) Select(ctx context.Context, obj interface{}) (Pool, error) {
+	cr, err := key.ToCustomResource(obj)
+	if err != nil {
+		return Pool{}, microerror.Mask(err)
+	}
+
+	poolName, err := s.poolName(ctx, &cr)
+	if err != nil {
+		return Pool{}, microerror.Mask(err)
+	}
+
+	pool, ok := s.pools[poolName]
+	if !ok {
+		return Pool{}, microerror.Maskf(poolNotFoundError, "IPAM pool %#q selected for cluster %#q is not configured", poolName, key.ClusterID(&cr))
+	}
+
+	s.logger.Debugf(ctx, "selected IPAM pool %#q for cluster %#q", pool.Name, key.ClusterID(&cr))
+
+	return pool, nil
+}
+
+func (s *PoolSelector) poolName(ctx context.Context, cr *v1alpha1.AzureConfig) (string, error) {
+	{
+		cluster := &capi.Cluster{}
+		err := s.ctrlClient.Get(ctx, client.ObjectKey{Namespace: key.OrganizationNamespace(cr), Name: cr.Labels[capi.ClusterLabelName]}, cluster)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		if cluster.Labels[label.IPAMPool] != "" {
+			return cluster.Labels[label.IPAMPool], nil
+		}
+	}
+
+	if cr.Labels[label.Organization] != "" {
+		organization := &securityv1alpha1.Organization{}
+		err := s.ctrlClient.Get(ctx, client.ObjectKey{Name: cr.Labels[label.Organization]}, organization)
+		if apierrors.IsNotFound(err) {
+			// Fall through to the default pool.
+		} else if err != nil {
+			return "", microerror.Mask(err)
+		} else if organization.Labels[label.IPAMPool] != "" {
+			return organization.Labels[label.IPAMPool], nil
+		}
+	}
+
+	return DefaultPoolName, nil
+}
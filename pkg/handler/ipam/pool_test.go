@@ -0,0 +1,323 @@
+package ipam
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	securityv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/security/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+)
+
+func Test_ParsePools(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		pools         string
+		expectedPools []Pool
+		errorMatcher  func(error) bool
+	}{
+		{
+			name: "case 0 no pools",
+
+			pools:         "",
+			expectedPools: nil,
+		},
+		{
+			name: "case 1 two pools",
+
+			pools: "westeurope:10.64.0.0/12:16, dev:10.80.0.0/16:20",
+			expectedPools: []Pool{
+				{Name: "westeurope", NetworkRange: mustParseCIDR("10.64.0.0/12"), MaskBits: 16},
+				{Name: "dev", NetworkRange: mustParseCIDR("10.80.0.0/16"), MaskBits: 20},
+			},
+		},
+		{
+			name: "case 2 missing mask bits",
+
+			pools:        "westeurope:10.64.0.0/12",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name: "case 3 invalid CIDR",
+
+			pools:        "westeurope:10.64.0.0:16",
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			pools, err := ParsePools(tc.pools)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(pools, tc.expectedPools) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedPools, pools))
+			}
+		})
+	}
+}
+
+func Test_NewPoolSelector(t *testing.T) {
+	defaultPool := Pool{NetworkRange: mustParseCIDR("10.1.0.0/16"), MaskBits: 20}
+
+	testCases := []struct {
+		name string
+
+		pools        []Pool
+		errorMatcher func(error) bool
+	}{
+		{
+			name: "case 0 disjoint pools",
+
+			pools: []Pool{
+				{Name: "westeurope", NetworkRange: mustParseCIDR("10.64.0.0/12"), MaskBits: 16},
+				{Name: "dev", NetworkRange: mustParseCIDR("10.80.0.0/16"), MaskBits: 20},
+			},
+		},
+		{
+			name: "case 1 pool overlapping with default pool",
+
+			pools: []Pool{
+				{Name: "westeurope", NetworkRange: mustParseCIDR("10.0.0.0/12"), MaskBits: 16},
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name: "case 2 pool within another pool",
+
+			pools: []Pool{
+				{Name: "westeurope", NetworkRange: mustParseCIDR("10.64.0.0/12"), MaskBits: 16},
+				{Name: "dev", NetworkRange: mustParseCIDR("10.72.0.0/16"), MaskBits: 20},
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name: "case 3 pool configured twice",
+
+			pools: []Pool{
+				{Name: "dev", NetworkRange: mustParseCIDR("10.64.0.0/16"), MaskBits: 20},
+				{Name: "dev", NetworkRange: mustParseCIDR("10.80.0.0/16"), MaskBits: 20},
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			_, err := NewPoolSelector(PoolSelectorConfig{
+				CtrlClient:  fake.NewClientBuilder().Build(),
+				Logger:      microloggertest.New(),
+				DefaultPool: defaultPool,
+				Pools:       tc.pools,
+			})
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func Test_PoolSelector_Select(t *testing.T) {
+	defaultPool := Pool{NetworkRange: mustParseCIDR("10.1.0.0/16"), MaskBits: 20}
+	pools := []Pool{
+		{Name: "westeurope", NetworkRange: mustParseCIDR("10.64.0.0/12"), MaskBits: 16},
+		{Name: "dev", NetworkRange: mustParseCIDR("10.80.0.0/16"), MaskBits: 20},
+	}
+
+	testCases := []struct {
+		name string
+
+		clusterPool      string
+		organizationPool string
+		expectedPool     string
+		errorMatcher     func(error) bool
+	}{
+		{
+			name: "case 0 no labels selects default pool",
+
+			expectedPool: DefaultPoolName,
+		},
+		{
+			name: "case 1 organization label selects pool",
+
+			organizationPool: "westeurope",
+			expectedPool:     "westeurope",
+		},
+		{
+			name: "case 2 cluster label takes precedence over organization label",
+
+			clusterPool:      "dev",
+			organizationPool: "westeurope",
+			expectedPool:     "dev",
+		},
+		{
+			name: "case 3 unknown pool",
+
+			clusterPool:  "eastus",
+			errorMatcher: IsPoolNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			scheme := runtime.NewScheme()
+			for _, addToScheme := range []func(*runtime.Scheme) error{capi.AddToScheme, providerv1alpha1.AddToScheme, securityv1alpha1.AddToScheme} {
+				err := addToScheme(scheme)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "c1",
+					Namespace: "org-acme",
+					Labels:    map[string]string{},
+				},
+			}
+			if tc.clusterPool != "" {
+				cluster.Labels[label.IPAMPool] = tc.clusterPool
+			}
+
+			organization := &securityv1alpha1.Organization{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "acme",
+					Labels: map[string]string{},
+				},
+			}
+			if tc.organizationPool != "" {
+				organization.Labels[label.IPAMPool] = tc.organizationPool
+			}
+
+			azureConfig := &providerv1alpha1.AzureConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "c1",
+					Namespace: "default",
+					Labels: map[string]string{
+						capi.ClusterLabelName: "c1",
+						label.Cluster:         "c1",
+						label.Organization:    "acme",
+					},
+				},
+			}
+
+			ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, organization).Build()
+
+			selector, err := NewPoolSelector(PoolSelectorConfig{
+				CtrlClient:  ctrlClient,
+				Logger:      microloggertest.New(),
+				DefaultPool: defaultPool,
+				Pools:       pools,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pool, err := selector.Select(context.Background(), azureConfig)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if pool.Name != tc.expectedPool {
+				t.Fatalf("pool == %#q, want %#q", pool.Name, tc.expectedPool)
+			}
+		})
+	}
+}
+
+func Test_AllocatedAddresses(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		parent            string
+		networks          []string
+		expectedAllocated uint64
+	}{
+		{
+			name: "case 0 no networks",
+
+			parent:            "10.1.0.0/16",
+			expectedAllocated: 0,
+		},
+		{
+			name: "case 1 networks outside of parent are ignored",
+
+			parent:            "10.1.0.0/16",
+			networks:          []string{"10.1.0.0/24", "10.2.0.0/24"},
+			expectedAllocated: 256,
+		},
+		{
+			name: "case 2 duplicated and nested networks are counted once",
+
+			parent:            "10.1.0.0/16",
+			networks:          []string{"10.1.0.0/23", "10.1.0.0/23", "10.1.1.0/24", "10.1.4.0/24"},
+			expectedAllocated: 768,
+		},
+		{
+			name: "case 3 network containing parent",
+
+			parent:            "10.1.0.0/16",
+			networks:          []string{"10.0.0.0/8", "10.1.0.0/24"},
+			expectedAllocated: 65536,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var networks []net.IPNet
+			for _, n := range tc.networks {
+				networks = append(networks, mustParseCIDR(n))
+			}
+
+			allocated := AllocatedAddresses(mustParseCIDR(tc.parent), networks)
+
+			if allocated != tc.expectedAllocated {
+				t.Fatalf("allocated == %d, want %d", allocated, tc.expectedAllocated)
+			}
+		})
+	}
+}
@@ -16,8 +16,11 @@ const (
 type NetworkRangeType string
 
 type Config struct {
-	Checker            Checker
-	Collector          Collector
+	Checker   Checker
+	Collector Collector
+	// IPv6Persister is optional. When set, IPv6 ranges are persisted for
+	// dual-stack clusters.
+	IPv6Persister      IPv6Persister
 	Locker             locker.Interface
 	Logger             micrologger.Logger
 	NetworkRangeGetter NetworkRangeGetter
@@ -32,6 +35,7 @@ type Config struct {
 type Resource struct {
 	checker            Checker
 	collector          Collector
+	ipv6Persister      IPv6Persister
 	locker             locker.Interface
 	logger             micrologger.Logger
 	networkRangeGetter NetworkRangeGetter
@@ -69,6 +73,7 @@ func New(config Config) (*Resource, error) {
 	r := &Resource{
 		checker:            config.Checker,
 		collector:          config.Collector,
+		ipv6Persister:      config.IPv6Persister,
 		locker:             config.Locker,
 		logger:             config.Logger,
 		networkRangeGetter: config.NetworkRangeGetter,
@@ -23,12 +23,9 @@ type Collector interface {
 // IP range can be allocated.
 type NetworkRangeGetter interface {
 	// GetParentNetworkRange return the network range from which the VNet/subnet range
-	// will be allocated. It receives the CR that is being reconciled.
-	GetParentNetworkRange(ctx context.Context, obj interface{}) (net.IPNet, error)
-
-	// GetRequiredIPMask returns an IP mask that is required by the network range
-	// that will be allocated.
-	GetRequiredIPMask() net.IPMask
+	// will be allocated, together with the IP mask that is required by the network
+	// range that will be allocated. It receives the CR that is being reconciled.
+	GetParentNetworkRange(ctx context.Context, obj interface{}) (net.IPNet, net.IPMask, error)
 }
 
 // Persister must mutate shared persistent state so that on successful execution
@@ -37,6 +34,15 @@ type Persister interface {
 	Persist(ctx context.Context, subnet net.IPNet, namespace, name string) error
 }
 
+// IPv6Persister must persist the IPv6 range of dual-stack clusters which
+// corresponds to the given allocated IPv4 network range. It is called for
+// newly and already allocated network ranges, so that existing clusters get
+// their IPv6 ranges when dual-stack is enabled. Implementations must do
+// nothing for single-stack clusters and for already persisted IPv6 ranges.
+type IPv6Persister interface {
+	PersistIPv6(ctx context.Context, subnet net.IPNet, namespace, name string) error
+}
+
 // Releaser must mutate shared persistent state so that on successful execution
 // allocated subnet is released.
 type Releaser interface {
@@ -19,10 +19,6 @@ func NewTestNetworkRangeGetter(parentNetworkRange net.IPNet, requiredNetworkMask
 	return g
 }
 
-func (g *TestNetworkRangeGetter) GetParentNetworkRange(_ context.Context, _ interface{}) (net.IPNet, error) {
-	return g.parentNetworkRange, nil
-}
-
-func (g *TestNetworkRangeGetter) GetRequiredIPMask() net.IPMask {
-	return g.requiredNetworkMask
+func (g *TestNetworkRangeGetter) GetParentNetworkRange(_ context.Context, _ interface{}) (net.IPNet, net.IPMask, error) {
+	return g.parentNetworkRange, g.requiredNetworkMask, nil
 }
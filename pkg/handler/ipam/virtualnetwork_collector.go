@@ -4,11 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"reflect"
 	"sync"
 
 	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
-	"github.com/giantswarm/ipam"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
@@ -29,7 +27,6 @@ type VirtualNetworkCollectorConfig struct {
 	K8sClient             k8sclient.Interface
 	Logger                micrologger.Logger
 
-	NetworkRange  net.IPNet
 	ReservedCIDRs []net.IPNet
 }
 
@@ -40,7 +37,6 @@ type VirtualNetworkCollector struct {
 	k8sclient             k8sclient.Interface
 	logger                micrologger.Logger
 
-	networkRange  net.IPNet
 	reservedCIDRs []net.IPNet
 }
 
@@ -61,10 +57,6 @@ func NewVirtualNetworkCollector(config VirtualNetworkCollectorConfig) (*VirtualN
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
 
-	if reflect.DeepEqual(config.NetworkRange, net.IPNet{}) {
-		return nil, microerror.Maskf(invalidConfigError, "%T.NetworkRange must not be empty", config)
-	}
-
 	c := &VirtualNetworkCollector{
 		azureMetricsCollector: config.AzureMetricsCollector,
 		credentialProvider:    config.CredentialProvider,
@@ -72,13 +64,15 @@ func NewVirtualNetworkCollector(config VirtualNetworkCollectorConfig) (*VirtualN
 		installationName:      config.InstallationName,
 		logger:                config.Logger,
 
-		networkRange:  config.NetworkRange,
 		reservedCIDRs: config.ReservedCIDRs,
 	}
 
 	return c, nil
 }
 
+// Collect returns the reserved virtual network ranges and the ones allocated in
+// AzureConfig CRs or found in Azure. The IPAM resource filters them by the
+// network range of the IPAM pool of the tenant cluster.
 func (c *VirtualNetworkCollector) Collect(ctx context.Context, _ interface{}) ([]net.IPNet, error) {
 	var err error
 	var mutex sync.Mutex
@@ -90,12 +84,12 @@ func (c *VirtualNetworkCollector) Collect(ctx context.Context, _ interface{}) ([
 	g.Go(func() error {
 		c.logger.Debugf(ctx, "finding allocated virtual networks from AzureConfig CRs")
 
-		virtualNetworks, err := c.getVirtualNetworksFromAzureConfigs(ctx)
+		allocations, err := c.AzureConfigAllocations(ctx)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 		mutex.Lock()
-		reservedVirtualNetworks = append(reservedVirtualNetworks, virtualNetworks...)
+		reservedVirtualNetworks = append(reservedVirtualNetworks, allocatedNetworks(allocations)...)
 		mutex.Unlock()
 
 		c.logger.Debugf(ctx, "found allocated virtual networks from AzureConfig CRs")
@@ -106,12 +100,12 @@ func (c *VirtualNetworkCollector) Collect(ctx context.Context, _ interface{}) ([
 	g.Go(func() error {
 		c.logger.Debugf(ctx, "finding allocated virtual networks from all resource groups in the subscription")
 
-		virtualNetworks, err := c.getVirtualNetworksFromAllSubscriptions(ctx)
+		allocations, err := c.AzureAllocations(ctx)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 		mutex.Lock()
-		reservedVirtualNetworks = append(reservedVirtualNetworks, virtualNetworks...)
+		reservedVirtualNetworks = append(reservedVirtualNetworks, allocatedNetworks(allocations)...)
 		mutex.Unlock()
 
 		c.logger.Debugf(ctx, "found allocated virtual networks from all resource groups in the subscription")
@@ -124,19 +118,19 @@ func (c *VirtualNetworkCollector) Collect(ctx context.Context, _ interface{}) ([
 		return nil, microerror.Mask(err)
 	}
 
-	reservedVirtualNetworks = ipam.CanonicalizeSubnets(c.networkRange, reservedVirtualNetworks)
-
 	return reservedVirtualNetworks, nil
 }
 
-func (c *VirtualNetworkCollector) getVirtualNetworksFromAzureConfigs(ctx context.Context) ([]net.IPNet, error) {
+// AzureConfigAllocations returns the virtual network ranges set in AzureConfig
+// CRs, owned by their tenant clusters.
+func (c *VirtualNetworkCollector) AzureConfigAllocations(ctx context.Context) ([]Allocation, error) {
 	tenantClusterList, err := c.getAllTenantClusters(ctx)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	var results []net.IPNet
-	for _, ac := range tenantClusterList.Items {
+	var results []Allocation
+	for i, ac := range tenantClusterList.Items {
 		cidr := key.AzureConfigNetworkCIDR(ac)
 		if cidr == "" {
 			continue
@@ -147,20 +141,24 @@ func (c *VirtualNetworkCollector) getVirtualNetworksFromAzureConfigs(ctx context
 			return nil, microerror.Mask(err)
 		}
 
-		results = append(results, *n)
+		results = append(results, Allocation{Network: *n, Owner: key.ClusterID(&tenantClusterList.Items[i])})
 	}
 
 	return results, nil
 }
 
-func (c *VirtualNetworkCollector) getVirtualNetworksFromAllSubscriptions(ctx context.Context) ([]net.IPNet, error) {
+// AzureAllocations returns the ranges of the virtual networks found in the
+// resource groups of the installation, in all subscriptions used by tenant
+// clusters. Tenant cluster virtual networks are owned by the tenant cluster
+// the resource group is named after.
+func (c *VirtualNetworkCollector) AzureAllocations(ctx context.Context) ([]Allocation, error) {
 	tenantClusterList, err := c.getAllTenantClusters(ctx)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
 	var doneSubscriptions []string
-	var ret []net.IPNet
+	var ret []Allocation
 	for _, cluster := range tenantClusterList.Items {
 		organizationAzureClientCredentialsConfig, subscriptionID, partnerID, err := c.credentialProvider.GetOrganizationAzureCredentials(ctx, key.CredentialNamespace(cluster), key.CredentialName(cluster))
 		if err != nil {
@@ -192,7 +190,7 @@ func (c *VirtualNetworkCollector) getVirtualNetworksFromAllSubscriptions(ctx con
 	return ret, nil
 }
 
-func (c *VirtualNetworkCollector) getVirtualNetworksFromSubscription(ctx context.Context, clientSet *client.AzureClientSet) ([]net.IPNet, error) {
+func (c *VirtualNetworkCollector) getVirtualNetworksFromSubscription(ctx context.Context, clientSet *client.AzureClientSet) ([]Allocation, error) {
 	groupsClient := clientSet.GroupsClient
 	vnetClient := clientSet.VirtualNetworkClient
 
@@ -202,7 +200,7 @@ func (c *VirtualNetworkCollector) getVirtualNetworksFromSubscription(ctx context
 		return nil, microerror.Mask(err)
 	}
 
-	var ret []net.IPNet
+	var ret []Allocation
 
 	for iterator.NotDone() {
 		group := iterator.Value()
@@ -211,12 +209,18 @@ func (c *VirtualNetworkCollector) getVirtualNetworksFromSubscription(ctx context
 		// Note: One we move to fully utilize CAPI/CAPZ types only (without AzureConfig), we should
 		// not expect that tenant cluster virtual networks follow any specific naming convention.
 		// We could list VNets by tags (we currently don't set tags to VNets).
+		tenantClusterVnetName := fmt.Sprintf("%s-VirtualNetwork", *group.Name)
 		vnetCandidates := []string{
-			fmt.Sprintf("%s-VirtualNetwork", *group.Name),
+			tenantClusterVnetName,
 			c.installationName,
 		}
 
 		for _, vnetName := range vnetCandidates {
+			var owner string
+			if vnetName == tenantClusterVnetName {
+				owner = *group.Name
+			}
+
 			vnet, err := vnetClient.Get(ctx, *group.Name, vnetName, "")
 			if IsNotFound(err) {
 				// VNET with desired name not found, ignore this resource group.
@@ -229,7 +233,7 @@ func (c *VirtualNetworkCollector) getVirtualNetworksFromSubscription(ctx context
 						return nil, microerror.Mask(err)
 					}
 
-					ret = append(ret, *n)
+					ret = append(ret, Allocation{Network: *n, Owner: owner})
 				}
 			}
 		}
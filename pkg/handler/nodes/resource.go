@@ -22,7 +22,7 @@ type Config struct {
 	Logger     micrologger.Logger
 
 	Azure         setting.Azure
-	ClientFactory client.OrganizationFactory
+	ClientFactory client.Interface
 	Name          string
 }
 
@@ -33,7 +33,7 @@ type Resource struct {
 	StateMachine state.Machine
 
 	Azure         setting.Azure
-	ClientFactory client.OrganizationFactory
+	ClientFactory client.Interface
 	name          string
 }
 
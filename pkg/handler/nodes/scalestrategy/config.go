@@ -0,0 +1,107 @@
+package scalestrategy
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+const (
+	NameIncremental = "incremental"
+	NameQuick       = "quick"
+	NameStaircase   = "staircase"
+	NameTimeBased   = "timebased"
+
+	defaultInterval = 5 * time.Minute
+)
+
+// Config holds the settings of the scale strategy of a node pool.
+type Config struct {
+	Name     string
+	Step     int64
+	Interval time.Duration
+
+	// LastScale and Now are only used by the time based strategy.
+	LastScale time.Time
+	Now       time.Time
+}
+
+// ConfigFromAnnotations parses the scale strategy settings from the given
+// annotations of an AzureMachinePool CR. Missing settings are defaulted.
+func ConfigFromAnnotations(annotations map[string]string) (Config, error) {
+	config := Config{
+		Name:     NameStaircase,
+		Step:     safeThreshold,
+		Interval: defaultInterval,
+	}
+
+	if v, ok := annotations[annotation.ScaleStrategy]; ok {
+		switch v {
+		case NameIncremental, NameQuick, NameStaircase, NameTimeBased:
+			config.Name = v
+		default:
+			return Config{}, microerror.Maskf(invalidConfigError, "unknown scale strategy %q in annotation %q", v, annotation.ScaleStrategy)
+		}
+	}
+
+	if v, ok := annotations[annotation.ScaleStrategyStep]; ok {
+		step, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || step <= 0 {
+			return Config{}, microerror.Maskf(invalidConfigError, "scale strategy step %q in annotation %q must be a positive integer", v, annotation.ScaleStrategyStep)
+		}
+		config.Step = step
+	}
+
+	if v, ok := annotations[annotation.ScaleStrategyInterval]; ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return Config{}, microerror.Maskf(invalidConfigError, "scale strategy interval %q in annotation %q must be a positive duration", v, annotation.ScaleStrategyInterval)
+		}
+		config.Interval = interval
+	}
+
+	if v, ok := annotations[annotation.ScaleStrategyLastScaleTimestamp]; ok {
+		// An unparseable timestamp is not a user error, we just act as if
+		// the node pool was never scaled before.
+		lastScale, err := time.Parse(time.RFC3339, v)
+		if err == nil {
+			config.LastScale = lastScale
+		}
+	}
+
+	return config, nil
+}
+
+// New returns the scale strategy described by the given config.
+func New(config Config) (Interface, error) {
+	switch config.Name {
+	case NameIncremental:
+		return Incremental{}, nil
+	case NameQuick:
+		return Quick{}, nil
+	case NameStaircase, "":
+		return Staircase{Step: config.Step}, nil
+	case NameTimeBased:
+		if config.Step <= 0 {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Step must be positive", config)
+		}
+		if config.Interval <= 0 {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Interval must be positive", config)
+		}
+		if config.Now.IsZero() {
+			return nil, microerror.Maskf(invalidConfigError, "%T.Now must not be empty", config)
+		}
+
+		return TimeBased{
+			Interval:  config.Interval,
+			LastScale: config.LastScale,
+			Now:       config.Now,
+			Step:      config.Step,
+		}, nil
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "unknown scale strategy %q", config.Name)
+	}
+}
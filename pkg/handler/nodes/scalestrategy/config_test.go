@@ -0,0 +1,90 @@
+package scalestrategy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_ConfigFromAnnotations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedConfig Config
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:        "case 0: defaults",
+			annotations: nil,
+			expectedConfig: Config{
+				Name:     NameStaircase,
+				Step:     safeThreshold,
+				Interval: defaultInterval,
+			},
+		},
+		{
+			name: "case 1: time based strategy",
+			annotations: map[string]string{
+				annotation.ScaleStrategy:                   NameTimeBased,
+				annotation.ScaleStrategyStep:               "2",
+				annotation.ScaleStrategyInterval:           "10m",
+				annotation.ScaleStrategyLastScaleTimestamp: "2021-01-01T12:00:00Z",
+			},
+			expectedConfig: Config{
+				Name:      NameTimeBased,
+				Step:      2,
+				Interval:  10 * time.Minute,
+				LastScale: time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "case 2: unknown strategy",
+			annotations: map[string]string{
+				annotation.ScaleStrategy: "fastest",
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name: "case 3: invalid step",
+			annotations: map[string]string{
+				annotation.ScaleStrategyStep: "0",
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name: "case 4: invalid interval",
+			annotations: map[string]string{
+				annotation.ScaleStrategy:         NameTimeBased,
+				annotation.ScaleStrategyInterval: "soon",
+			},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			config, err := ConfigFromAnnotations(tc.annotations)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(config, tc.expectedConfig) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedConfig, config))
+			}
+		})
+	}
+}
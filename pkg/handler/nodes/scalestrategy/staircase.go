@@ -1,6 +1,9 @@
 package scalestrategy
 
+// Staircase scales up by at most Step instances at a time and scales down
+// immediately.
 type Staircase struct {
+	Step int64
 }
 
 const (
@@ -13,8 +16,13 @@ func (i Staircase) GetNodeCount(currentCount int64, desiredCount int64) int64 {
 		return desiredCount
 	}
 
-	if desiredCount-currentCount > safeThreshold {
-		return currentCount + safeThreshold
+	step := i.Step
+	if step <= 0 {
+		step = safeThreshold
+	}
+
+	if desiredCount-currentCount > step {
+		return currentCount + step
 	}
 
 	return desiredCount
@@ -0,0 +1,31 @@
+package scalestrategy
+
+import "time"
+
+// TimeBased scales up by at most Step instances every Interval and scales
+// down immediately. LastScale is the time the node count was last increased
+// and Now is the time the strategy is evaluated at.
+type TimeBased struct {
+	Interval  time.Duration
+	LastScale time.Time
+	Now       time.Time
+	Step      int64
+}
+
+func (i TimeBased) GetNodeCount(currentCount int64, desiredCount int64) int64 {
+	// Cluster size decreased or unchanged.
+	if currentCount >= desiredCount {
+		return desiredCount
+	}
+
+	// Interval not elapsed yet since the last scaling, keep the current size.
+	if !i.LastScale.IsZero() && i.Now.Sub(i.LastScale) < i.Interval {
+		return currentCount
+	}
+
+	if desiredCount-currentCount > i.Step {
+		return currentCount + i.Step
+	}
+
+	return desiredCount
+}
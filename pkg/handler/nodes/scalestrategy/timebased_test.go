@@ -0,0 +1,66 @@
+package scalestrategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBased_GetNodeCount(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		lastScale    time.Time
+		currentCount int64
+		desiredCount int64
+		want         int64
+	}{
+		{
+			name:         "Never scaled before",
+			currentCount: 10,
+			desiredCount: 20,
+			want:         13,
+		},
+		{
+			name:         "Interval elapsed",
+			lastScale:    now.Add(-10 * time.Minute),
+			currentCount: 13,
+			desiredCount: 20,
+			want:         16,
+		},
+		{
+			name:         "Interval not elapsed",
+			lastScale:    now.Add(-2 * time.Minute),
+			currentCount: 13,
+			desiredCount: 20,
+			want:         13,
+		},
+		{
+			name:         "Last step",
+			lastScale:    now.Add(-10 * time.Minute),
+			currentCount: 19,
+			desiredCount: 20,
+			want:         20,
+		},
+		{
+			name:         "Size decreased within interval",
+			lastScale:    now.Add(-2 * time.Minute),
+			currentCount: 20,
+			desiredCount: 10,
+			want:         10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := TimeBased{
+				Interval:  5 * time.Minute,
+				LastScale: tt.lastScale,
+				Now:       now,
+				Step:      3,
+			}
+			if got := i.GetNodeCount(tt.currentCount, tt.desiredCount); got != tt.want {
+				t.Errorf("GetNodeCount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
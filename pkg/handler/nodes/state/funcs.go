@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"time"
 
 	"github.com/giantswarm/microerror"
 )
@@ -25,3 +26,43 @@ func (m Machine) Execute(ctx context.Context, obj interface{}, currentState Stat
 	m.Logger.LogCtx(ctx, "resource", m.ResourceName, "message", "state changed", "oldState", currentState, "newState", newState)
 	return newState, nil
 }
+
+// ExecuteWithDeadline executes the transition of the current state like
+// Execute. It additionally returns true when the state machine stays in the
+// current state longer than the deadline configured for it. This is also the
+// case when the transition keeps failing, so that errors can't hold the state
+// machine in a state past its deadline. The transition error is returned as is
+// so that callers can apply their deadline policy and still report it.
+// enteredAt is the time the state machine entered the current state, the
+// deadline is not checked when it is zero.
+func (m Machine) ExecuteWithDeadline(ctx context.Context, obj interface{}, currentState State, enteredAt time.Time) (State, bool, error) {
+	newState, err := m.Execute(ctx, obj, currentState)
+	if err != nil {
+		return newState, m.DeadlineExceeded(currentState, enteredAt), microerror.Mask(err)
+	}
+
+	if newState == currentState && m.DeadlineExceeded(currentState, enteredAt) {
+		return newState, true, nil
+	}
+
+	return newState, false, nil
+}
+
+// DeadlineExceeded returns true when the state machine entered the given state
+// at enteredAt and stayed in it longer than the deadline configured for it.
+func (m Machine) DeadlineExceeded(currentState State, enteredAt time.Time) bool {
+	deadline, exists := m.Deadlines[currentState]
+	if !exists || deadline <= 0 || enteredAt.IsZero() {
+		return false
+	}
+
+	return m.now().Sub(enteredAt) > deadline
+}
+
+func (m Machine) now() time.Time {
+	if m.Clock == nil {
+		return SystemClock{}.Now()
+	}
+
+	return m.Clock.Now()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
@@ -11,8 +12,9 @@ import (
 )
 
 const (
-	OpenState   = "open"
-	ClosedState = "closed"
+	OpenState    = "open"
+	ClosedState  = "closed"
+	FailingState = "failing"
 )
 
 func Test_StateMachine(t *testing.T) {
@@ -106,3 +108,125 @@ func Test_StateMachine(t *testing.T) {
 func IsExecutionFailedError(err error) bool {
 	return microerror.Cause(err) == executionFailedError
 }
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+func Test_StateMachine_ExecuteWithDeadline(t *testing.T) {
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	machine := Machine{
+		Logger:       logger,
+		ResourceName: "",
+		Transitions: TransitionMap{
+			OpenState: func(ctx context.Context, obj interface{}, currentState State) (State, error) {
+				return currentState, nil
+			},
+			ClosedState: func(ctx context.Context, obj interface{}, currentState State) (State, error) { return OpenState, nil },
+			FailingState: func(ctx context.Context, obj interface{}, currentState State) (State, error) {
+				return currentState, microerror.Mask(executionFailedError)
+			},
+		},
+		Deadlines: DeadlineMap{
+			OpenState:    10 * time.Minute,
+			ClosedState:  10 * time.Minute,
+			FailingState: 10 * time.Minute,
+		},
+		Clock: fakeClock{now: now},
+	}
+
+	testCases := []struct {
+		name                     string
+		currentState             State
+		enteredAt                time.Time
+		expectedNewState         State
+		expectedDeadlineExceeded bool
+		errorMatcher             func(error) bool
+	}{
+		{
+			name:                     "case 0: state within deadline",
+			currentState:             OpenState,
+			enteredAt:                now.Add(-5 * time.Minute),
+			expectedNewState:         OpenState,
+			expectedDeadlineExceeded: false,
+			errorMatcher:             nil,
+		},
+		{
+			name:                     "case 1: state exceeded deadline",
+			currentState:             OpenState,
+			enteredAt:                now.Add(-11 * time.Minute),
+			expectedNewState:         OpenState,
+			expectedDeadlineExceeded: true,
+			errorMatcher:             nil,
+		},
+		{
+			name:                     "case 2: state changed after deadline",
+			currentState:             ClosedState,
+			enteredAt:                now.Add(-11 * time.Minute),
+			expectedNewState:         OpenState,
+			expectedDeadlineExceeded: false,
+			errorMatcher:             nil,
+		},
+		{
+			name:                     "case 3: unknown entry time",
+			currentState:             OpenState,
+			enteredAt:                time.Time{},
+			expectedNewState:         OpenState,
+			expectedDeadlineExceeded: false,
+			errorMatcher:             nil,
+		},
+		{
+			name:                     "case 4: failing state within deadline",
+			currentState:             FailingState,
+			enteredAt:                now.Add(-5 * time.Minute),
+			expectedNewState:         FailingState,
+			expectedDeadlineExceeded: false,
+			errorMatcher:             IsExecutionFailedError,
+		},
+		{
+			name:                     "case 5: failing state exceeded deadline returns the transition error",
+			currentState:             FailingState,
+			enteredAt:                now.Add(-11 * time.Minute),
+			expectedNewState:         FailingState,
+			expectedDeadlineExceeded: true,
+			errorMatcher:             IsExecutionFailedError,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			newState, deadlineExceeded, err := machine.ExecuteWithDeadline(context.Background(), nil, tc.currentState, tc.enteredAt)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if deadlineExceeded != tc.expectedDeadlineExceeded {
+				t.Fatalf("deadlineExceeded == %t, want %t", deadlineExceeded, tc.expectedDeadlineExceeded)
+			}
+
+			if !cmp.Equal(newState, tc.expectedNewState) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedNewState, newState))
+			}
+		})
+	}
+}
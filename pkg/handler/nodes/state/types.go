@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"time"
 
 	"github.com/giantswarm/micrologger"
 )
@@ -11,10 +12,30 @@ type Machine struct {
 	Logger       micrologger.Logger
 	ResourceName string
 	Transitions  TransitionMap
+
+	// Deadlines optionally limits the time the state machine can stay in a
+	// given state. See ExecuteWithDeadline.
+	Deadlines DeadlineMap
+	// Clock is used to check deadlines. It defaults to the system clock and
+	// is only meant to be overridden in tests.
+	Clock Clock
 }
 
 type State string
 type TransitionMap map[State]TransitionFunc
+type DeadlineMap map[State]time.Duration
 
 // TransitionFunc defines state transition function signature.
 type TransitionFunc func(ctx context.Context, obj interface{}, currentState State) (State, error)
+
+// Clock abstracts the current time so that deadlines can be tested.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock returning the system time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
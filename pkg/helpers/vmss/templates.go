@@ -10,13 +10,35 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/templates"
 )
 
-func RenderCloudConfig(blobURL string, encryptionKey string, initialVector string, instanceRole string) (string, error) {
+// RenderMasterCloudConfig renders the master cloud config mounting the Docker
+// and kubelet data disks attached at the master LUNs.
+func RenderMasterCloudConfig(blobURL string, encryptionKey string, initialVector string) (string, error) {
 	smallCloudconfigConfig := SmallCloudconfigConfig{
 		BlobURL:       blobURL,
 		EncryptionKey: encryptionKey,
 		InitialVector: initialVector,
-		InstanceRole:  instanceRole,
+		InstanceRole:  key.PrefixMaster(),
 	}
+
+	return renderCloudConfig(smallCloudconfigConfig)
+}
+
+// RenderWorkerCloudConfig renders the worker cloud config mounting the Docker
+// and kubelet data disks attached at the given LUNs.
+func RenderWorkerCloudConfig(blobURL string, encryptionKey string, initialVector string, dockerDiskLun int32, kubeletDiskLun int32) (string, error) {
+	smallCloudconfigConfig := SmallCloudconfigConfig{
+		BlobURL:        blobURL,
+		DockerDiskLun:  dockerDiskLun,
+		EncryptionKey:  encryptionKey,
+		InitialVector:  initialVector,
+		InstanceRole:   key.PrefixWorker(),
+		KubeletDiskLun: kubeletDiskLun,
+	}
+
+	return renderCloudConfig(smallCloudconfigConfig)
+}
+
+func renderCloudConfig(smallCloudconfigConfig SmallCloudconfigConfig) (string, error) {
 	cloudConfig, err := templates.Render(key.CloudConfigSmallTemplates(), smallCloudconfigConfig)
 	if err != nil {
 		return "", microerror.Mask(err)
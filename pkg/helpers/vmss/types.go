@@ -51,9 +51,13 @@ func newNodeOSImageCoreOS(distroVersion string) NodeOSImage {
 // SmallCloudconfigConfig represents the data structure required for executing
 // the small cloudconfig template.
 type SmallCloudconfigConfig struct {
-	BlobURL       string
-	CertsFiles    []certs.File
-	EncryptionKey string
-	InitialVector string
-	InstanceRole  string
+	BlobURL    string
+	CertsFiles []certs.File
+	// DockerDiskLun and KubeletDiskLun are the LUNs of the worker data
+	// disks. Masters always use the same LUNs.
+	DockerDiskLun  int32
+	EncryptionKey  string
+	InitialVector  string
+	InstanceRole   string
+	KubeletDiskLun int32
 }
@@ -15,6 +15,7 @@ const (
 	OperatorVersion        = "azure-operator.giantswarm.io/version"
 	ClusterOperatorVersion = "cluster-operator.giantswarm.io/version"
 	CGroupVersion          = "cgroups.giantswarm.io/version"
+	IPAMPool               = "azure-operator.giantswarm.io/ipam-pool"
 	ReleaseVersion         = "release.giantswarm.io/version"
 	SingleTenantSP         = "giantswarm.io/single-tenant-service-principal"
 
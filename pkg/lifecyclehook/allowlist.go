@@ -0,0 +1,87 @@
+package lifecyclehook
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+// WebhookAllowlist lists the URL prefixes webhooks may be called at. Hook
+// annotations are editable by workload cluster owners, so webhook URLs are
+// restricted to endpoints configured by the operator. An empty allowlist
+// rejects all webhooks.
+type WebhookAllowlist []url.URL
+
+// ParseWebhookAllowlist parses a comma separated list of absolute http or
+// https URL prefixes, e.g.
+// `https://lb.example.com/hooks/,https://snapshots.example.com`.
+func ParseWebhookAllowlist(v string) (WebhookAllowlist, error) {
+	var allowlist WebhookAllowlist
+
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		u, err := url.Parse(entry)
+		if err != nil || !isHTTPURL(u) || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			return nil, microerror.Maskf(invalidConfigError, "webhook allowlist entry %q must be an absolute http or https URL without user info, query or fragment", entry)
+		}
+
+		allowlist = append(allowlist, *u)
+	}
+
+	return allowlist, nil
+}
+
+// Allows returns true when the URL has the scheme and host of an allowlist
+// entry and its path is below the path of the entry. URLs with "." or ".."
+// path segments, also percent-encoded, are rejected because the webhook
+// server may resolve them to a path outside of the entry.
+func (a WebhookAllowlist) Allows(u *url.URL) bool {
+	if u == nil || !isHTTPURL(u) || u.User != nil || hasDotSegment(u.Path) {
+		return false
+	}
+
+	for _, allowed := range a {
+		if u.Scheme != allowed.Scheme || !strings.EqualFold(u.Host, allowed.Host) {
+			continue
+		}
+
+		prefix := cleanPath(allowed.Path)
+		if prefix == "/" {
+			return true
+		}
+
+		p := cleanPath(u.Path)
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanPath returns the absolute, cleaned form of the given URL path.
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// hasDotSegment returns true when the given decoded URL path has a "." or ".."
+// segment.
+func hasDotSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHTTPURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
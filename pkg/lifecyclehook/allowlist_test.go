@@ -0,0 +1,157 @@
+package lifecyclehook
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func Test_WebhookAllowlist_Allows(t *testing.T) {
+	testCases := []struct {
+		name            string
+		allowlist       string
+		url             string
+		expectedAllowed bool
+	}{
+		{
+			name:            "case 0: empty allowlist",
+			allowlist:       "",
+			url:             "https://lb.example.com/deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 1: allowed host",
+			allowlist:       "https://lb.example.com",
+			url:             "https://LB.example.com/deregister?node=worker-0",
+			expectedAllowed: true,
+		},
+		{
+			name:            "case 2: other host",
+			allowlist:       "https://lb.example.com",
+			url:             "https://lb.example.com.evil.io/deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 3: other scheme",
+			allowlist:       "https://lb.example.com",
+			url:             "http://lb.example.com/deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 4: other port",
+			allowlist:       "https://lb.example.com",
+			url:             "https://lb.example.com:8443/deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 5: path below allowed path",
+			allowlist:       "https://hooks.example.com, https://lb.example.com/hooks",
+			url:             "https://lb.example.com/hooks/deregister",
+			expectedAllowed: true,
+		},
+		{
+			name:            "case 6: path outside of allowed path",
+			allowlist:       "https://lb.example.com/hooks/",
+			url:             "https://lb.example.com/hooksevil",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 7: user info",
+			allowlist:       "https://lb.example.com",
+			url:             "https://admin@lb.example.com/deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 8: dot segments leaving the allowed path",
+			allowlist:       "https://lb.example.com/hooks",
+			url:             "https://lb.example.com/hooks/../admin",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 9: encoded dot segments leaving the allowed path",
+			allowlist:       "https://lb.example.com/hooks",
+			url:             "https://lb.example.com/hooks/%2e%2e/admin",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 10: single dot segment",
+			allowlist:       "https://lb.example.com/hooks",
+			url:             "https://lb.example.com/hooks/./deregister",
+			expectedAllowed: false,
+		},
+		{
+			name:            "case 11: repeated slashes below allowed path",
+			allowlist:       "https://lb.example.com/hooks/",
+			url:             "https://lb.example.com//hooks//deregister",
+			expectedAllowed: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			allowlist, err := ParseWebhookAllowlist(tc.allowlist)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			allowed := allowlist.Allows(u)
+			if allowed != tc.expectedAllowed {
+				t.Fatalf("allowed == %t, want %t", allowed, tc.expectedAllowed)
+			}
+		})
+	}
+}
+
+func Test_ParseWebhookAllowlist(t *testing.T) {
+	testCases := []struct {
+		name         string
+		allowlist    string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:      "case 0: valid entries",
+			allowlist: "https://lb.example.com/hooks/,http://10.0.0.4:8080",
+		},
+		{
+			name:         "case 1: relative URL",
+			allowlist:    "/hooks",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 2: other scheme",
+			allowlist:    "ftp://lb.example.com",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 3: query",
+			allowlist:    "https://lb.example.com/hooks?token=x",
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			_, err := ParseWebhookAllowlist(tc.allowlist)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
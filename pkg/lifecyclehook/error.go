@@ -0,0 +1,30 @@
+package lifecyclehook
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidHookError = &microerror.Error{
+	Kind: "invalidHookError",
+}
+
+// IsInvalidHook asserts invalidHookError.
+func IsInvalidHook(err error) bool {
+	return microerror.Cause(err) == invalidHookError
+}
+
+var webhookFailedError = &microerror.Error{
+	Kind: "webhookFailedError",
+}
+
+// IsWebhookFailed asserts webhookFailedError.
+func IsWebhookFailed(err error) bool {
+	return microerror.Cause(err) == webhookFailedError
+}
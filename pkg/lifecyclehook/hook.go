@@ -0,0 +1,117 @@
+// Package lifecyclehook runs the actions declared on node pools around the
+// replacement of their nodes, e.g. deregistering nodes from an external load
+// balancer before they are drained.
+package lifecyclehook
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// Type is the point of the node replacement a hook runs at.
+type Type string
+
+const (
+	// PreDrain hooks run once a node is cordoned and before it is drained.
+	PreDrain Type = "pre-drain"
+	// PostDrain hooks run once a node is drained and before it is
+	// terminated.
+	PostDrain Type = "post-drain"
+)
+
+const (
+	defaultJobNamespace = "kube-system"
+	defaultTimeout      = 15 * time.Minute
+)
+
+// Hook is a lifecycle hook declared on a node pool. Exactly one of Webhook and
+// Job must be set.
+type Hook struct {
+	// Webhook is called for every node. The hook is completed once the
+	// webhook responds with 200 OK. 202 Accepted means the hook is still in
+	// progress, the webhook is called again in the next reconciliation loop.
+	Webhook *Webhook `json:"webhook,omitempty"`
+	// Job is created for every node in the workload cluster. The hook is
+	// completed once the job succeeded.
+	Job *Job `json:"job,omitempty"`
+	// Timeout is the time after which the node replacement carries on
+	// without the hook being completed, e.g. "10m". Defaults to 15 minutes.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type Webhook struct {
+	URL string `json:"url"`
+}
+
+type Job struct {
+	// Namespace of the job in the workload cluster. Defaults to kube-system.
+	Namespace string                  `json:"namespace,omitempty"`
+	Template  batchv1.JobTemplateSpec `json:"template"`
+}
+
+// Request describes the node a hook runs for. It is the body of webhook
+// requests and is passed to job containers as environment variables.
+type Request struct {
+	Type      Type   `json:"type"`
+	ClusterID string `json:"clusterID"`
+	NodePool  string `json:"nodePool"`
+	Node      string `json:"node"`
+}
+
+// Parse parses a hook declared in a node pool annotation. Webhook URLs must be
+// allowed by the given allowlist.
+func Parse(value string, allowlist WebhookAllowlist) (Hook, error) {
+	var hook Hook
+	err := json.Unmarshal([]byte(value), &hook)
+	if err != nil {
+		return Hook{}, microerror.Maskf(invalidHookError, "%s", err)
+	}
+
+	if (hook.Webhook == nil) == (hook.Job == nil) {
+		return Hook{}, microerror.Maskf(invalidHookError, "exactly one of webhook and job must be set")
+	}
+
+	if hook.Webhook != nil {
+		u, err := url.Parse(hook.Webhook.URL)
+		if err != nil || !isHTTPURL(u) {
+			return Hook{}, microerror.Maskf(invalidHookError, "webhook URL %q must be an absolute http or https URL", hook.Webhook.URL)
+		}
+		if !allowlist.Allows(u) {
+			return Hook{}, microerror.Maskf(invalidHookError, "webhook URL %q is not allowed by the webhook allowlist of the operator", hook.Webhook.URL)
+		}
+	}
+
+	if hook.Job != nil && len(hook.Job.Template.Spec.Template.Spec.Containers) == 0 {
+		return Hook{}, microerror.Maskf(invalidHookError, "job template must have at least one container")
+	}
+
+	if hook.Timeout != "" {
+		d, err := time.ParseDuration(hook.Timeout)
+		if err != nil || d <= 0 {
+			return Hook{}, microerror.Maskf(invalidHookError, "timeout %q must be a positive duration", hook.Timeout)
+		}
+	}
+
+	return hook, nil
+}
+
+func (h Hook) timeout() time.Duration {
+	d, err := time.ParseDuration(h.Timeout)
+	if err != nil || d <= 0 {
+		return defaultTimeout
+	}
+
+	return d
+}
+
+func (j Job) namespace() string {
+	if j.Namespace == "" {
+		return defaultJobNamespace
+	}
+
+	return j.Namespace
+}
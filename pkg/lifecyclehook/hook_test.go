@@ -0,0 +1,90 @@
+package lifecyclehook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Parse(t *testing.T) {
+	testCases := []struct {
+		name            string
+		value           string
+		expectedTimeout time.Duration
+		errorMatcher    func(err error) bool
+	}{
+		{
+			name:            "case 0: webhook with default timeout",
+			value:           `{"webhook":{"url":"https://lb.example.com/deregister"}}`,
+			expectedTimeout: 15 * time.Minute,
+		},
+		{
+			name:            "case 1: job with timeout",
+			value:           `{"job":{"template":{"spec":{"template":{"spec":{"containers":[{"name":"snapshot","image":"snapshot:1.0.0"}]}}}}},"timeout":"5m"}`,
+			expectedTimeout: 5 * time.Minute,
+		},
+		{
+			name:         "case 2: malformed JSON",
+			value:        `webhook`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 3: webhook and job",
+			value:        `{"webhook":{"url":"https://lb.example.com"},"job":{"template":{}}}`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 4: relative webhook URL",
+			value:        `{"webhook":{"url":"/deregister"}}`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 5: job without containers",
+			value:        `{"job":{"template":{}}}`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 6: invalid timeout",
+			value:        `{"webhook":{"url":"https://lb.example.com"},"timeout":"-1m"}`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 7: webhook host not allowed",
+			value:        `{"webhook":{"url":"http://169.254.169.254/metadata/instance"}}`,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:         "case 8: webhook scheme not allowed",
+			value:        `{"webhook":{"url":"http://lb.example.com/deregister"}}`,
+			errorMatcher: IsInvalidHook,
+		},
+	}
+
+	allowlist, err := ParseWebhookAllowlist("https://lb.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			hook, err := Parse(tc.value, allowlist)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && hook.timeout() != tc.expectedTimeout {
+				t.Fatalf("timeout == %v, want %v", hook.timeout(), tc.expectedTimeout)
+			}
+		})
+	}
+}
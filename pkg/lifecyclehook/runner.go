@@ -0,0 +1,336 @@
+package lifecyclehook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Status is the status of a hook for a node.
+type Status string
+
+const (
+	StatusInProgress Status = "InProgress"
+	StatusCompleted  Status = "Completed"
+	StatusTimedOut   Status = "TimedOut"
+)
+
+// Result is the outcome of running a hook for a node.
+type Result struct {
+	Status Status
+	// Finished is true when the hook completed or timed out in this run, so
+	// that callers can report it once.
+	Finished bool
+}
+
+const (
+	hookLabel = "giantswarm.io/lifecycle-hook"
+	nodeLabel = "giantswarm.io/lifecycle-hook-node"
+
+	defaultWebhookTimeout = 30 * time.Second
+	// jobTTL is the time finished hook jobs are kept in the workload cluster
+	// when the job template does not define it.
+	jobTTL int32 = 3600
+)
+
+type Config struct {
+	Logger micrologger.Logger
+
+	// HTTPClient is used to call webhooks. Defaults to a client with a 30
+	// seconds timeout that does not follow redirects.
+	HTTPClient *http.Client
+	// WebhookAllowlist lists the URL prefixes webhooks may be called at.
+	// Webhooks are rejected when it is empty.
+	WebhookAllowlist WebhookAllowlist
+}
+
+// Runner starts lifecycle hooks and tracks their status in annotations of the
+// workload cluster nodes they run for.
+type Runner struct {
+	httpClient       *http.Client
+	logger           micrologger.Logger
+	webhookAllowlist WebhookAllowlist
+}
+
+func New(config Config) (*Runner, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{
+			// Redirects are not followed so that webhooks cannot send
+			// requests to URLs outside of the allowlist.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Timeout: defaultWebhookTimeout,
+		}
+	}
+
+	r := &Runner{
+		httpClient:       config.HTTPClient,
+		logger:           config.Logger,
+		webhookAllowlist: config.WebhookAllowlist,
+	}
+
+	return r, nil
+}
+
+// Parse parses a hook declared in a node pool annotation and checks its
+// webhook URL against the allowlist of the runner.
+func (r *Runner) Parse(value string) (Hook, error) {
+	hook, err := Parse(value, r.webhookAllowlist)
+	if err != nil {
+		return Hook{}, microerror.Mask(err)
+	}
+
+	return hook, nil
+}
+
+// Run starts the hook for the node of the request unless it was started
+// already and returns its status. Hooks of nodes that do not exist anymore are
+// completed.
+func (r *Runner) Run(ctx context.Context, ctrlClient client.Client, hook Hook, request Request) (Result, error) {
+	node := corev1.Node{}
+	err := ctrlClient.Get(ctx, client.ObjectKey{Name: request.Node}, &node)
+	if apierrors.IsNotFound(err) {
+		r.logger.Debugf(ctx, "node %#q was not found, skipping %s hook", request.Node, request.Type)
+		return Result{Status: StatusCompleted}, nil
+	} else if err != nil {
+		return Result{}, microerror.Mask(err)
+	}
+
+	switch s := Status(node.Annotations[statusAnnotation(request.Type)]); s {
+	case StatusCompleted, StatusTimedOut:
+		return Result{Status: s}, nil
+	}
+
+	now := time.Now().UTC()
+	started, err := time.Parse(time.RFC3339, node.Annotations[startedAnnotation(request.Type)])
+	if err != nil {
+		started = now
+		err = r.annotate(ctx, ctrlClient, node, startedAnnotation(request.Type), started.Format(time.RFC3339))
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	}
+
+	var status Status
+	if hook.Webhook != nil {
+		status, err = r.callWebhook(ctx, *hook.Webhook, request)
+		if IsWebhookFailed(err) {
+			// Webhooks are called again until they succeed or time out.
+			r.logger.Debugf(ctx, "%s webhook for node %#q failed: %s", request.Type, request.Node, err)
+			status = StatusInProgress
+		} else if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	} else {
+		status, err = r.ensureJob(ctx, ctrlClient, *hook.Job, request)
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	}
+
+	if status == StatusInProgress && now.Sub(started) > hook.timeout() {
+		r.logger.Debugf(ctx, "%s hook for node %#q did not complete within %s", request.Type, request.Node, hook.timeout())
+		status = StatusTimedOut
+	}
+
+	if status != StatusInProgress {
+		// Nodes are fetched again because annotating them above changed
+		// their resource version.
+		err = ctrlClient.Get(ctx, client.ObjectKey{Name: request.Node}, &node)
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+
+		err = r.annotate(ctx, ctrlClient, node, statusAnnotation(request.Type), string(status))
+		if err != nil {
+			return Result{}, microerror.Mask(err)
+		}
+	}
+
+	return Result{Status: status, Finished: status != StatusInProgress}, nil
+}
+
+// Reset removes the status of all hooks of the node and their jobs, so that
+// hooks run again when the node is replaced later, e.g. after an upgrade was
+// rolled back.
+func (r *Runner) Reset(ctx context.Context, ctrlClient client.Client, nodeName string) error {
+	node := corev1.Node{}
+	err := ctrlClient.Get(ctx, client.ObjectKey{Name: nodeName}, &node)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p := client.MergeFrom(node.DeepCopy())
+	for _, t := range []Type{PreDrain, PostDrain} {
+		delete(node.Annotations, startedAnnotation(t))
+		delete(node.Annotations, statusAnnotation(t))
+	}
+	err = ctrlClient.Patch(ctx, &node, p)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var jobs batchv1.JobList
+	err = ctrlClient.List(ctx, &jobs, client.MatchingLabels{nodeLabel: nodeName})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for i := range jobs.Items {
+		err = ctrlClient.Delete(ctx, &jobs.Items[i], client.PropagationPolicy("Background"))
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) callWebhook(ctx context.Context, webhook Webhook, request Request) (Status, error) {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || !r.webhookAllowlist.Allows(u) {
+		return "", microerror.Maskf(invalidHookError, "webhook URL %q is not allowed by the webhook allowlist of the operator", webhook.URL)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", microerror.Maskf(webhookFailedError, "%s", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return StatusCompleted, nil
+	case http.StatusAccepted:
+		return StatusInProgress, nil
+	default:
+		return "", microerror.Maskf(webhookFailedError, "unexpected status code %d", res.StatusCode)
+	}
+}
+
+func (r *Runner) ensureJob(ctx context.Context, ctrlClient client.Client, job Job, request Request) (Status, error) {
+	current := batchv1.Job{}
+	err := ctrlClient.Get(ctx, client.ObjectKey{Namespace: job.namespace(), Name: jobName(request)}, &current)
+	if apierrors.IsNotFound(err) {
+		desired := desiredJob(job, request)
+
+		r.logger.Debugf(ctx, "creating %s hook job %#q for node %#q", request.Type, desired.Namespace+"/"+desired.Name, request.Node)
+
+		err = ctrlClient.Create(ctx, &desired)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		return StatusInProgress, nil
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if current.Status.Succeeded > 0 {
+		return StatusCompleted, nil
+	}
+
+	return StatusInProgress, nil
+}
+
+func desiredJob(job Job, request Request) batchv1.Job {
+	template := job.Template.DeepCopy()
+
+	j := batchv1.Job{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+	j.Name = jobName(request)
+	j.Namespace = job.namespace()
+
+	if j.Labels == nil {
+		j.Labels = map[string]string{}
+	}
+	j.Labels[hookLabel] = string(request.Type)
+	j.Labels[nodeLabel] = request.Node
+
+	if j.Spec.TTLSecondsAfterFinished == nil {
+		ttl := jobTTL
+		j.Spec.TTLSecondsAfterFinished = &ttl
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "HOOK_TYPE", Value: string(request.Type)},
+		{Name: "CLUSTER_ID", Value: request.ClusterID},
+		{Name: "NODE_POOL", Value: request.NodePool},
+		{Name: "NODE_NAME", Value: request.Node},
+	}
+	for i := range j.Spec.Template.Spec.Containers {
+		j.Spec.Template.Spec.Containers[i].Env = append(j.Spec.Template.Spec.Containers[i].Env, env...)
+	}
+
+	return j
+}
+
+func (r *Runner) annotate(ctx context.Context, ctrlClient client.Client, node corev1.Node, key, value string) error {
+	p := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[key] = value
+
+	err := ctrlClient.Patch(ctx, &node, p)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// jobName returns the name of the hook job of the node. Names are limited to
+// 63 characters so that they can be used as label values by the job
+// controller.
+func jobName(request Request) string {
+	name := fmt.Sprintf("%s-hook-%s", request.Type, request.Node)
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-.")
+	}
+
+	return name
+}
+
+func startedAnnotation(t Type) string {
+	return fmt.Sprintf("giantswarm.io/%s-hook-started-ts", t)
+}
+
+func statusAnnotation(t Type) string {
+	return fmt.Sprintf("giantswarm.io/%s-hook-status", t)
+}
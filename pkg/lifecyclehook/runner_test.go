@@ -0,0 +1,261 @@
+package lifecyclehook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_Runner_Run_Webhook(t *testing.T) {
+	testCases := []struct {
+		name           string
+		statusCode     int
+		started        time.Time
+		expectedResult Result
+	}{
+		{
+			name:           "case 0: webhook completed",
+			statusCode:     http.StatusOK,
+			expectedResult: Result{Status: StatusCompleted, Finished: true},
+		},
+		{
+			name:           "case 1: webhook in progress",
+			statusCode:     http.StatusAccepted,
+			expectedResult: Result{Status: StatusInProgress},
+		},
+		{
+			name:           "case 2: failing webhook is retried",
+			statusCode:     http.StatusInternalServerError,
+			expectedResult: Result{Status: StatusInProgress},
+		},
+		{
+			name:           "case 3: webhook timed out",
+			statusCode:     http.StatusAccepted,
+			started:        time.Now().Add(-time.Hour),
+			expectedResult: Result{Status: StatusTimedOut, Finished: true},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var received Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewDecoder(r.Body).Decode(&received)
+				if err != nil {
+					t.Errorf("unexpected error %#v", err)
+				}
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			node := newNode("worker-0")
+			if !tc.started.IsZero() {
+				node.Annotations[startedAnnotation(PreDrain)] = tc.started.UTC().Format(time.RFC3339)
+			}
+			ctrlClient := fake.NewClientBuilder().WithObjects(node).Build()
+
+			request := Request{Type: PreDrain, ClusterID: "c0001", NodePool: "np001", Node: node.Name}
+			hook := Hook{Webhook: &Webhook{URL: server.URL}}
+
+			result, err := newTestRunner(t, server.URL).Run(context.Background(), ctrlClient, hook, request)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if !cmp.Equal(result, tc.expectedResult) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, result))
+			}
+			if !cmp.Equal(received, request) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(request, received))
+			}
+
+			// Finished hooks are not run again.
+			result, err = newTestRunner(t, server.URL).Run(context.Background(), ctrlClient, hook, request)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			if result.Finished {
+				t.Fatalf("hook finished twice")
+			}
+		})
+	}
+}
+
+func Test_Runner_Run_WebhookNotAllowed(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirecting.Close()
+
+	testCases := []struct {
+		name           string
+		url            string
+		expectedResult Result
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:         "case 0: webhook outside of the allowlist is not called",
+			url:          server.URL,
+			errorMatcher: IsInvalidHook,
+		},
+		{
+			name:           "case 1: redirects are not followed",
+			url:            redirecting.URL,
+			expectedResult: Result{Status: StatusInProgress},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			called = false
+
+			node := newNode("worker-0")
+			ctrlClient := fake.NewClientBuilder().WithObjects(node).Build()
+
+			request := Request{Type: PreDrain, ClusterID: "c0001", NodePool: "np001", Node: node.Name}
+			hook := Hook{Webhook: &Webhook{URL: tc.url}}
+
+			result, err := newTestRunner(t, redirecting.URL).Run(context.Background(), ctrlClient, hook, request)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if called {
+				t.Fatalf("webhook outside of the allowlist was called")
+			}
+			if !cmp.Equal(result, tc.expectedResult) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedResult, result))
+			}
+		})
+	}
+}
+
+func Test_Runner_Run_Job(t *testing.T) {
+	node := newNode("worker-0")
+	ctrlClient := fake.NewClientBuilder().WithObjects(node).Build()
+
+	request := Request{Type: PostDrain, ClusterID: "c0001", NodePool: "np001", Node: node.Name}
+	hook := Hook{
+		Job: &Job{
+			Template: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "snapshot", Image: "snapshot:1.0.0"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r := newTestRunner(t, "")
+
+	result, err := r.Run(context.Background(), ctrlClient, hook, request)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if result.Status != StatusInProgress {
+		t.Fatalf("status == %q, want %q", result.Status, StatusInProgress)
+	}
+
+	job := batchv1.Job{}
+	err = ctrlClient.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "post-drain-hook-worker-0"}, &job)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	env := job.Spec.Template.Spec.Containers[0].Env
+	expectedEnv := []corev1.EnvVar{
+		{Name: "HOOK_TYPE", Value: "post-drain"},
+		{Name: "CLUSTER_ID", Value: "c0001"},
+		{Name: "NODE_POOL", Value: "np001"},
+		{Name: "NODE_NAME", Value: "worker-0"},
+	}
+	if !cmp.Equal(env, expectedEnv) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expectedEnv, env))
+	}
+
+	job.Status.Succeeded = 1
+	err = ctrlClient.Status().Update(context.Background(), &job)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	result, err = r.Run(context.Background(), ctrlClient, hook, request)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	expected := Result{Status: StatusCompleted, Finished: true}
+	if !cmp.Equal(result, expected) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expected, result))
+	}
+
+	// Resetting the node removes the job so that the hook runs again.
+	err = r.Reset(context.Background(), ctrlClient, node.Name)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	result, err = r.Run(context.Background(), ctrlClient, hook, request)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if result.Status != StatusInProgress {
+		t.Fatalf("status == %q, want %q", result.Status, StatusInProgress)
+	}
+}
+
+func newTestRunner(t *testing.T, webhookAllowlist string) *Runner {
+	allowlist, err := ParseWebhookAllowlist(webhookAllowlist)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	r, err := New(Config{Logger: microloggertest.New(), WebhookAllowlist: allowlist})
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	return r
+}
+
+func newNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{},
+		},
+	}
+}
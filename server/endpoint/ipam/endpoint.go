@@ -0,0 +1,96 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	ipamhandler "github.com/giantswarm/azure-operator/v8/pkg/handler/ipam"
+)
+
+const (
+	// Method is the HTTP method this endpoint is registered for.
+	Method = "GET"
+	// Name identifies the endpoint. It is aligned to the package path.
+	Name = "ipam"
+	// Path is the HTTP request path this endpoint is registered for.
+	Path = "/ipam/"
+)
+
+// Config represents the configuration used to create an IPAM endpoint.
+type Config struct {
+	Inspector *ipamhandler.Inspector
+	Logger    micrologger.Logger
+}
+
+// Endpoint is a read-only endpoint listing the network ranges allocated by
+// IPAM, their owners and the free space left in their parent network ranges.
+// It serves the report cached by the inspector, so requests don't trigger
+// Azure API calls.
+type Endpoint struct {
+	inspector *ipamhandler.Inspector
+	logger    micrologger.Logger
+}
+
+// New creates a new configured IPAM endpoint.
+func New(config Config) (*Endpoint, error) {
+	if config.Inspector == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Inspector must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	e := &Endpoint{
+		inspector: config.Inspector,
+		logger:    config.Logger,
+	}
+
+	return e, nil
+}
+
+func (e *Endpoint) Decoder() kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return nil, nil
+	}
+}
+
+func (e *Endpoint) Encoder() kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (e *Endpoint) Endpoint() kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		report, err := e.inspector.Report()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return report, nil
+	}
+}
+
+func (e *Endpoint) Method() string {
+	return Method
+}
+
+func (e *Endpoint) Middlewares() []kitendpoint.Middleware {
+	return []kitendpoint.Middleware{}
+}
+
+func (e *Endpoint) Name() string {
+	return Name
+}
+
+func (e *Endpoint) Path() string {
+	return Path
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	microserver "github.com/giantswarm/microkit/server"
+	"github.com/giantswarm/micrologger"
+
+	ipamhandler "github.com/giantswarm/azure-operator/v8/pkg/handler/ipam"
+	ipamendpoint "github.com/giantswarm/azure-operator/v8/server/endpoint/ipam"
+	"github.com/giantswarm/azure-operator/v8/service"
+)
+
+type IPAMConfig struct {
+	Logger  micrologger.Logger
+	Service *service.Service
+
+	ListenAddress string
+	ProjectName   string
+}
+
+// NewIPAM creates the server of the IPAM inspection endpoint. It listens on its
+// own address, which is not exposed by the operator Service, because the
+// endpoint reports the network layout of all tenant clusters.
+func NewIPAM(config IPAMConfig) (microserver.Server, error) {
+	var err error
+
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Service == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
+	}
+
+	if config.ListenAddress == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ListenAddress must not be empty", config)
+	}
+	if config.ProjectName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ProjectName must not be empty", config)
+	}
+
+	var ipamEndpoint *ipamendpoint.Endpoint
+	{
+		c := ipamendpoint.Config{
+			Inspector: config.Service.IPAMInspector,
+			Logger:    config.Logger,
+		}
+
+		ipamEndpoint, err = ipamendpoint.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var ipamServer microserver.Server
+	{
+		c := microserver.Config{
+			Logger:        config.Logger,
+			ListenAddress: config.ListenAddress,
+			ServiceName:   config.ProjectName,
+
+			Endpoints: []microserver.Endpoint{
+				ipamEndpoint,
+			},
+			ErrorEncoder: encodeIPAMError,
+		}
+
+		ipamServer, err = microserver.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return ipamServer, nil
+}
+
+func encodeIPAMError(ctx context.Context, err error, w http.ResponseWriter) {
+	rErr := err.(microserver.ResponseError)
+	uErr := rErr.Underlying()
+
+	if ipamhandler.IsReportNotReady(uErr) {
+		rErr.SetCode(microserver.CodeNotYetAvailable)
+		rErr.SetMessage(uErr.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	encodeError(ctx, err, w)
+}
@@ -2,7 +2,6 @@ package azurecluster
 
 import (
 	"context"
-	"time"
 
 	"github.com/giantswarm/certs/v4/pkg/certs"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
@@ -29,6 +28,7 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/azurecluster/handler/azureclusteridentity"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azurecluster/handler/azureclusterupgrade"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azurecluster/handler/azureconfig"
+	"github.com/giantswarm/azure-operator/v8/service/controller/azurecluster/handler/existingvnet"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azurecluster/handler/subnet"
 	"github.com/giantswarm/azure-operator/v8/service/controller/controllercontext"
 	"github.com/giantswarm/azure-operator/v8/service/controller/debugger"
@@ -36,6 +36,7 @@ import (
 )
 
 type ControllerConfig struct {
+	ClientFactory      *client.Factory
 	CredentialProvider credential.Provider
 	InstallationName   string
 	K8sClient          k8sclient.Interface
@@ -115,26 +116,11 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 func newAzureClusterResources(config ControllerConfig, certsSearcher certs.Interface) ([]resource.Interface, error) {
 	var err error
 
-	var clientFactory *client.Factory
-	{
-		c := client.FactoryConfig{
-			AzureAPIMetrics:    config.AzureMetricsCollector,
-			CacheDuration:      30 * time.Minute,
-			CredentialProvider: config.CredentialProvider,
-			Logger:             config.Logger,
-		}
-
-		clientFactory, err = client.NewFactory(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-	}
-
 	var organizationClientFactory client.OrganizationFactory
 	{
 		c := client.OrganizationFactoryConfig{
 			CtrlClient: config.K8sClient.CtrlClient(),
-			Factory:    clientFactory,
+			Factory:    config.ClientFactory,
 			Logger:     config.Logger,
 		}
 		organizationClientFactory = client.NewOrganizationFactory(c)
@@ -205,6 +191,20 @@ func newAzureClusterResources(config ControllerConfig, certsSearcher certs.Inter
 		}
 	}
 
+	var existingVnetResource resource.Interface
+	{
+		c := existingvnet.Config{
+			AzureClientsFactory: organizationClientFactory,
+			CtrlClient:          config.K8sClient.CtrlClient(),
+			Logger:              config.Logger,
+		}
+
+		existingVnetResource, err = existingvnet.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var releaseResource resource.Interface
 	{
 		c := release.Config{
@@ -265,6 +265,7 @@ func newAzureClusterResources(config ControllerConfig, certsSearcher certs.Inter
 		releaseResource,
 		azureclusteridentityResource,
 		azureClusterConfigResource,
+		existingVnetResource,
 		azureConfigResource,
 		subnetResource,
 	}
@@ -25,6 +25,12 @@ func (r *Resource) ensureVNetPeeringReadyCondition(ctx context.Context, azureClu
 	r.logger.Debugf(ctx, "ensuring condition %s", VNetPeeringReadyCondition)
 	var err error
 
+	// Peerings of existing VNets are managed by their owners.
+	if key.UsesExistingVnet(azureCluster) {
+		capiconditions.MarkTrue(azureCluster, VNetPeeringReadyCondition)
+		return nil
+	}
+
 	// Get Azure Deployments client
 	vnetPeeringsClient, err := r.azureClientsFactory.GetVnetPeeringsClient(ctx, azureCluster.ObjectMeta)
 	if err != nil {
@@ -159,6 +159,12 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		}
 		presentAzureConfig.Annotations[localannotation.WorkersEgressExternalPublicIP] = mappedAzureConfig.Annotations[localannotation.WorkersEgressExternalPublicIP]
 
+		// Dual-stack can't be disabled once the IPv6 ranges are allocated.
+		if key.DualStackEnabled(&mappedAzureConfig) && !key.DualStackEnabled(&presentAzureConfig) {
+			presentAzureConfig.Annotations[localannotation.DualStack] = mappedAzureConfig.Annotations[localannotation.DualStack]
+			changed = true
+		}
+
 		if changed {
 			r.logger.Debugf(ctx, "existing azureconfig needs update")
 
@@ -255,6 +261,12 @@ func (r *Resource) buildAzureConfig(ctx context.Context, cluster capi.Cluster, a
 		if azureCluster.Annotations[localannotation.WorkersEgressExternalPublicIP] != "" {
 			azureConfig.Annotations[localannotation.WorkersEgressExternalPublicIP] = azureCluster.Annotations[localannotation.WorkersEgressExternalPublicIP]
 		}
+		if key.DualStackEnabled(&azureCluster) {
+			azureConfig.Annotations[localannotation.DualStack] = azureCluster.Annotations[localannotation.DualStack]
+		}
+		if key.UsesExistingVnet(&azureCluster) {
+			azureConfig.Annotations[localannotation.ExistingVirtualNetworkID] = azureCluster.Spec.NetworkSpec.Vnet.ID
+		}
 	}
 
 	{
@@ -0,0 +1,191 @@
+package existingvnet
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/operatorkit/v7/pkg/controller/context/reconciliationcanceledcontext"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/helpers"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// EnsureCreated validates the existing virtual network referenced by the
+// AzureCluster and the existing subnets used by its node pools. The range
+// reserved for the master and worker subnets must be part of the virtual
+// network and must not overlap with other subnets, and the node pool subnets
+// must be large enough for the node pools at their maximum size.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	azureCluster, err := key.ToAzureCluster(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !key.UsesExistingVnet(&azureCluster) {
+		r.logger.Debugf(ctx, "cluster does not use an existing virtual network")
+		r.logger.Debugf(ctx, "canceling resource")
+		return nil
+	}
+
+	r.logger.Debugf(ctx, "ensuring existing virtual network %#q is valid", azureCluster.Spec.NetworkSpec.Vnet.ID)
+
+	desired, err := r.desiredNetworkSpec(ctx, azureCluster)
+	if IsInvalidExistingVnet(err) {
+		r.logger.Errorf(ctx, err, "existing virtual network %#q is not valid", azureCluster.Spec.NetworkSpec.Vnet.ID)
+		r.logger.Debugf(ctx, "canceling reconciliation")
+		reconciliationcanceledcontext.SetCanceled(ctx)
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !reflect.DeepEqual(azureCluster.Spec.NetworkSpec, desired) {
+		r.logger.Debugf(ctx, "updating network spec of AzureCluster")
+
+		azureCluster.Spec.NetworkSpec = desired
+		err = r.ctrlClient.Update(ctx, &azureCluster)
+		if apierrors.IsConflict(err) {
+			r.logger.Debugf(ctx, "conflict trying to save object in k8s API concurrently")
+			r.logger.Debugf(ctx, "canceling reconciliation")
+			reconciliationcanceledcontext.SetCanceled(ctx)
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "updated network spec of AzureCluster")
+	}
+
+	r.logger.Debugf(ctx, "ensured existing virtual network %#q is valid", azureCluster.Spec.NetworkSpec.Vnet.ID)
+
+	return nil
+}
+
+// desiredNetworkSpec returns the network spec of the AzureCluster with the
+// name and resource group of the existing virtual network, and the existing
+// subnets used by the node pools of the cluster merged into its subnets.
+func (r *Resource) desiredNetworkSpec(ctx context.Context, azureCluster capz.AzureCluster) (capz.NetworkSpec, error) {
+	networkSpec := *azureCluster.Spec.NetworkSpec.DeepCopy()
+
+	vnetID, err := azure.ParseResourceID(networkSpec.Vnet.ID)
+	if err != nil {
+		return capz.NetworkSpec{}, microerror.Maskf(invalidExistingVnetError, "virtual network ID %#q is not valid", networkSpec.Vnet.ID)
+	}
+	if len(networkSpec.Vnet.CIDRBlocks) == 0 || networkSpec.Vnet.CIDRBlocks[0] == "" {
+		return capz.NetworkSpec{}, microerror.Maskf(invalidExistingVnetError, "the range of the master and worker subnets must be set in the first CIDR block of the virtual network")
+	}
+
+	virtualNetworksClient, err := r.azureClientsFactory.GetVirtualNetworksClient(ctx, azureCluster.ObjectMeta)
+	if err != nil {
+		return capz.NetworkSpec{}, microerror.Mask(err)
+	}
+
+	if vnetID.SubscriptionID != virtualNetworksClient.SubscriptionID {
+		return capz.NetworkSpec{}, microerror.Maskf(invalidExistingVnetError, "virtual network %#q must be in subscription %#q of the cluster", networkSpec.Vnet.ID, virtualNetworksClient.SubscriptionID)
+	}
+
+	vnet, err := virtualNetworksClient.Get(ctx, vnetID.ResourceGroup, vnetID.ResourceName, "")
+	if IsNotFound(err) {
+		return capz.NetworkSpec{}, microerror.Maskf(invalidExistingVnetError, "virtual network %#q not found", networkSpec.Vnet.ID)
+	} else if err != nil {
+		return capz.NetworkSpec{}, microerror.Mask(err)
+	}
+
+	clusterSubnetNames := []string{
+		key.MasterSubnetNameFromClusterAPIObject(&azureCluster),
+		key.WorkerSubnetNameFromClusterAPIObject(&azureCluster),
+	}
+	err = validateClusterRange(vnet, networkSpec.Vnet.CIDRBlocks[0], clusterSubnetNames)
+	if err != nil {
+		return capz.NetworkSpec{}, microerror.Mask(err)
+	}
+
+	nodes, err := r.nodesPerSubnet(ctx, azureCluster)
+	if err != nil {
+		return capz.NetworkSpec{}, microerror.Mask(err)
+	}
+
+	var subnetNames []string
+	for name := range nodes {
+		subnetNames = append(subnetNames, name)
+	}
+	sort.Strings(subnetNames)
+
+	var subnets capz.Subnets
+	for _, name := range subnetNames {
+		subnet, err := nodeSubnet(vnet, name)
+		if err != nil {
+			return capz.NetworkSpec{}, microerror.Mask(err)
+		}
+
+		err = validateSubnetCapacity(subnet, nodes[name])
+		if err != nil {
+			return capz.NetworkSpec{}, microerror.Mask(err)
+		}
+
+		subnets = append(subnets, subnet)
+	}
+
+	networkSpec.Vnet.Name = vnetID.ResourceName
+	networkSpec.Vnet.ResourceGroup = vnetID.ResourceGroup
+	networkSpec.Subnets = mergeSubnets(networkSpec.Subnets, subnets)
+
+	return networkSpec, nil
+}
+
+// mergeSubnets returns the current subnets with the given node pool subnets
+// replacing the ones of the same name, in place, and the others appended.
+// Subnets set by other controllers are kept.
+func mergeSubnets(current, nodeSubnets capz.Subnets) capz.Subnets {
+	merged := make(capz.Subnets, 0, len(current)+len(nodeSubnets))
+	replaced := map[string]bool{}
+	for _, subnet := range current {
+		for _, nodeSubnet := range nodeSubnets {
+			if subnet.Name == nodeSubnet.Name {
+				subnet.CIDRBlocks = nodeSubnet.CIDRBlocks
+				subnet.ID = nodeSubnet.ID
+				subnet.Role = nodeSubnet.Role
+				replaced[nodeSubnet.Name] = true
+			}
+		}
+		merged = append(merged, subnet)
+	}
+	for _, nodeSubnet := range nodeSubnets {
+		if !replaced[nodeSubnet.Name] {
+			merged = append(merged, nodeSubnet)
+		}
+	}
+
+	return merged
+}
+
+// nodesPerSubnet returns the maximum number of nodes of the node pools of the
+// cluster, per subnet. Several node pools can share the same subnet.
+func (r *Resource) nodesPerSubnet(ctx context.Context, azureCluster capz.AzureCluster) (map[string]int, error) {
+	azureMachinePools, err := helpers.GetAzureMachinePoolsByClusterID(ctx, r.ctrlClient, azureCluster.Namespace, key.ClusterID(&azureCluster))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	machinePools, err := helpers.GetMachinePoolsByClusterID(ctx, r.ctrlClient, azureCluster.Namespace, key.ClusterID(&azureCluster))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	maxReplicas := map[string]int{}
+	for i := range machinePools.Items {
+		maxReplicas[machinePools.Items[i].Name] = int(key.NodePoolMaxReplicas(&machinePools.Items[i]))
+	}
+
+	nodes := map[string]int{}
+	for i := range azureMachinePools.Items {
+		nodes[key.NodePoolSubnetName(&azureMachinePools.Items[i])] += maxReplicas[azureMachinePools.Items[i].Name]
+	}
+
+	return nodes, nil
+}
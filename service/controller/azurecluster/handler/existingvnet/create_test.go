@@ -0,0 +1,80 @@
+package existingvnet
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+func Test_mergeSubnets(t *testing.T) {
+	masterSubnet := capz.SubnetSpec{
+		Name:       "c1-VirtualNetwork-MasterSubnet",
+		Role:       capz.SubnetControlPlane,
+		CIDRBlocks: []string{"10.1.16.0/24"},
+		SecurityGroup: capz.SecurityGroup{
+			Name: "c1-MasterSecurityGroup",
+		},
+	}
+	nodeSubnet := capz.SubnetSpec{
+		Name:       "customer-nodes",
+		Role:       capz.SubnetNode,
+		ID:         "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/customer-nodes",
+		CIDRBlocks: []string{"10.1.0.0/24"},
+	}
+
+	testCases := []struct {
+		name            string
+		current         capz.Subnets
+		nodeSubnets     capz.Subnets
+		expectedSubnets capz.Subnets
+	}{
+		{
+			name:            "case 0: node pool subnet is added to the other subnets",
+			current:         capz.Subnets{masterSubnet},
+			nodeSubnets:     capz.Subnets{nodeSubnet},
+			expectedSubnets: capz.Subnets{masterSubnet, nodeSubnet},
+		},
+		{
+			name: "case 1: node pool subnet is updated in place keeping fields set by other controllers",
+			current: capz.Subnets{
+				{
+					Name:       "customer-nodes",
+					Role:       capz.SubnetNode,
+					CIDRBlocks: []string{"10.1.0.0/25"},
+					RouteTable: capz.RouteTable{Name: "c1-RouteTable"},
+				},
+				masterSubnet,
+			},
+			nodeSubnets: capz.Subnets{nodeSubnet},
+			expectedSubnets: capz.Subnets{
+				{
+					Name:       "customer-nodes",
+					Role:       capz.SubnetNode,
+					ID:         nodeSubnet.ID,
+					CIDRBlocks: []string{"10.1.0.0/24"},
+					RouteTable: capz.RouteTable{Name: "c1-RouteTable"},
+				},
+				masterSubnet,
+			},
+		},
+		{
+			name:            "case 2: subnets are kept without node pools",
+			current:         capz.Subnets{masterSubnet},
+			expectedSubnets: capz.Subnets{masterSubnet},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			subnets := mergeSubnets(tc.current, tc.nodeSubnets)
+
+			if !cmp.Equal(subnets, tc.expectedSubnets) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedSubnets, subnets))
+			}
+		})
+	}
+}
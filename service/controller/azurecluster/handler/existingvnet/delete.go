@@ -0,0 +1,11 @@
+package existingvnet
+
+import (
+	"context"
+)
+
+// EnsureDeleted is a noop since existing virtual networks and subnets are
+// owned by the customer and must survive the deletion of the cluster.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package existingvnet
+
+import (
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidExistingVnetError = &microerror.Error{
+	Kind: "invalidExistingVnetError",
+}
+
+// IsInvalidExistingVnet asserts invalidExistingVnetError.
+func IsInvalidExistingVnet(err error) bool {
+	return microerror.Cause(err) == invalidExistingVnetError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	c := microerror.Cause(err)
+
+	if c == notFoundError {
+		return true
+	}
+
+	{
+		dErr, ok := c.(autorest.DetailedError)
+		if ok {
+			if dErr.StatusCode == 404 {
+				return true
+			}
+		}
+	}
+
+	return strings.Contains(c.Error(), "ResourceNotFound")
+}
@@ -0,0 +1,51 @@
+package existingvnet
+
+import (
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/client"
+)
+
+const (
+	// Name is the identifier of the resource.
+	Name = "existingvnet"
+)
+
+type Config struct {
+	AzureClientsFactory client.OrganizationFactory
+	CtrlClient          ctrlclient.Client
+	Logger              micrologger.Logger
+}
+
+// Resource validates the existing virtual networks and subnets referenced by
+// AzureClusters and fills the AzureCluster network spec with their details.
+// It never creates nor deletes anything in Azure.
+type Resource struct {
+	azureClientsFactory client.OrganizationFactory
+	ctrlClient          ctrlclient.Client
+	logger              micrologger.Logger
+}
+
+func New(config Config) (*Resource, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	r := &Resource{
+		azureClientsFactory: config.AzureClientsFactory,
+		ctrlClient:          config.CtrlClient,
+		logger:              config.Logger,
+	}
+
+	return r, nil
+}
+
+// Name returns the resource name.
+func (r *Resource) Name() string {
+	return Name
+}
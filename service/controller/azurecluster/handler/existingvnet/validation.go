@@ -0,0 +1,177 @@
+package existingvnet
+
+import (
+	"math/big"
+	"net"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
+	"github.com/giantswarm/microerror"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+const (
+	// azureReservedAddresses is the number of addresses Azure reserves in
+	// every subnet.
+	azureReservedAddresses = 5
+
+	storageServiceEndpoint = "Microsoft.Storage"
+)
+
+// validateClusterRange ensures the range the master and worker subnets are
+// allocated from is part of the address space of the virtual network and
+// doesn't overlap with any of its subnets, but the ones created for the
+// cluster.
+func validateClusterRange(vnet network.VirtualNetwork, clusterRange string, clusterSubnetNames []string) error {
+	_, clusterNet, err := net.ParseCIDR(clusterRange)
+	if err != nil {
+		return microerror.Maskf(invalidExistingVnetError, "cluster range %#q is not a valid CIDR", clusterRange)
+	}
+
+	var inAddressSpace bool
+	if vnet.AddressSpace != nil && vnet.AddressSpace.AddressPrefixes != nil {
+		for _, prefix := range *vnet.AddressSpace.AddressPrefixes {
+			_, addressSpace, err := net.ParseCIDR(prefix)
+			if err != nil {
+				continue
+			}
+
+			if contains(*addressSpace, *clusterNet) {
+				inAddressSpace = true
+				break
+			}
+		}
+	}
+	if !inAddressSpace {
+		return microerror.Maskf(invalidExistingVnetError, "cluster range %#q is not part of the address space of virtual network %#q", clusterRange, toString(vnet.Name))
+	}
+
+	if vnet.Subnets == nil {
+		return nil
+	}
+
+	for _, subnet := range *vnet.Subnets {
+		if isClusterSubnet(toString(subnet.Name), clusterSubnetNames) {
+			continue
+		}
+
+		for _, prefix := range subnetPrefixes(subnet) {
+			_, subnetNet, err := net.ParseCIDR(prefix)
+			if err != nil {
+				continue
+			}
+
+			if overlaps(*clusterNet, *subnetNet) {
+				return microerror.Maskf(invalidExistingVnetError, "cluster range %#q overlaps with range %#q of subnet %#q", clusterRange, prefix, toString(subnet.Name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// nodeSubnet returns the spec of the existing subnet with the given name. The
+// subnet must have the storage service endpoint enabled, because the nodes
+// need access to the storage account of the cluster.
+func nodeSubnet(vnet network.VirtualNetwork, name string) (capz.SubnetSpec, error) {
+	if vnet.Subnets != nil {
+		for _, subnet := range *vnet.Subnets {
+			if toString(subnet.Name) != name {
+				continue
+			}
+
+			if !hasServiceEndpoint(subnet, storageServiceEndpoint) {
+				return capz.SubnetSpec{}, microerror.Maskf(invalidExistingVnetError, "subnet %#q does not have service endpoint %#q enabled", name, storageServiceEndpoint)
+			}
+
+			return capz.SubnetSpec{
+				CIDRBlocks: subnetPrefixes(subnet),
+				ID:         toString(subnet.ID),
+				Name:       name,
+				Role:       capz.SubnetNode,
+			}, nil
+		}
+	}
+
+	return capz.SubnetSpec{}, microerror.Maskf(invalidExistingVnetError, "subnet %#q not found in virtual network %#q", name, toString(vnet.Name))
+}
+
+// validateSubnetCapacity ensures the IPv4 range of the subnet is large enough
+// to hold the given number of nodes.
+func validateSubnetCapacity(subnet capz.SubnetSpec, nodes int) error {
+	for _, cidr := range subnet.CIDRBlocks {
+		_, subnetNet, err := net.ParseCIDR(cidr)
+		if err != nil || subnetNet.IP.To4() == nil {
+			continue
+		}
+
+		ones, bits := subnetNet.Mask.Size()
+		size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)) // nolint:gosec
+		available := new(big.Int).Sub(size, big.NewInt(azureReservedAddresses))
+		if available.Cmp(big.NewInt(int64(nodes))) < 0 {
+			return microerror.Maskf(invalidExistingVnetError, "subnet %#q has %s available addresses, but %d nodes are required", subnet.Name, available.String(), nodes)
+		}
+
+		return nil
+	}
+
+	return microerror.Maskf(invalidExistingVnetError, "subnet %#q has no IPv4 range", subnet.Name)
+}
+
+func contains(parent, child net.IPNet) bool {
+	parentOnes, _ := parent.Mask.Size()
+	childOnes, _ := child.Mask.Size()
+
+	return parent.Contains(child.IP) && parentOnes <= childOnes
+}
+
+func overlaps(a, b net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func hasServiceEndpoint(subnet network.Subnet, service string) bool {
+	if subnet.SubnetPropertiesFormat == nil || subnet.ServiceEndpoints == nil {
+		return false
+	}
+
+	for _, endpoint := range *subnet.ServiceEndpoints {
+		if toString(endpoint.Service) == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isClusterSubnet(name string, clusterSubnetNames []string) bool {
+	for _, n := range clusterSubnetNames {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func subnetPrefixes(subnet network.Subnet) []string {
+	if subnet.SubnetPropertiesFormat == nil {
+		return nil
+	}
+
+	if subnet.AddressPrefixes != nil && len(*subnet.AddressPrefixes) > 0 {
+		return *subnet.AddressPrefixes
+	}
+
+	if subnet.AddressPrefix != nil {
+		return []string{*subnet.AddressPrefix}
+	}
+
+	return nil
+}
+
+func toString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,204 @@
+package existingvnet
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+func Test_validateClusterRange(t *testing.T) {
+	testCases := []struct {
+		name         string
+		clusterRange string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: range in address space without overlaps",
+			clusterRange: "10.1.16.0/20",
+		},
+		{
+			name:         "case 1: range overlapping with the cluster subnets",
+			clusterRange: "10.1.32.0/20",
+		},
+		{
+			name:         "case 2: range outside of address space",
+			clusterRange: "10.2.0.0/20",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+		{
+			name:         "case 3: range larger than the address space",
+			clusterRange: "10.0.0.0/8",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+		{
+			name:         "case 4: range overlapping with a node subnet",
+			clusterRange: "10.1.0.0/20",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+		{
+			name:         "case 5: invalid range",
+			clusterRange: "10.1.0.0",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			err := validateClusterRange(newVnet(), tc.clusterRange, []string{"c1-VirtualNetwork-MasterSubnet", "c1-VirtualNetwork-WorkerSubnet"})
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func Test_nodeSubnet(t *testing.T) {
+	testCases := []struct {
+		name           string
+		subnetName     string
+		expectedSubnet capz.SubnetSpec
+		errorMatcher   func(error) bool
+	}{
+		{
+			name:       "case 0: existing subnet",
+			subnetName: "nodes",
+			expectedSubnet: capz.SubnetSpec{
+				CIDRBlocks: []string{"10.1.0.0/24"},
+				ID:         "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/hub/subnets/nodes",
+				Name:       "nodes",
+				Role:       capz.SubnetNode,
+			},
+		},
+		{
+			name:         "case 1: subnet without storage service endpoint",
+			subnetName:   "private",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+		{
+			name:         "case 2: missing subnet",
+			subnetName:   "missing",
+			errorMatcher: IsInvalidExistingVnet,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			subnet, err := nodeSubnet(newVnet(), tc.subnetName)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(subnet, tc.expectedSubnet) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedSubnet, subnet))
+			}
+		})
+	}
+}
+
+func Test_validateSubnetCapacity(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cidrBlocks   []string
+		nodes        int
+		errorMatcher func(error) bool
+	}{
+		{
+			name:       "case 0: subnet large enough",
+			cidrBlocks: []string{"10.1.0.0/24"},
+			nodes:      251,
+		},
+		{
+			name:         "case 1: subnet too small",
+			cidrBlocks:   []string{"10.1.0.0/24"},
+			nodes:        252,
+			errorMatcher: IsInvalidExistingVnet,
+		},
+		{
+			name:       "case 2: dual-stack subnet",
+			cidrBlocks: []string{"fd00::/64", "10.1.0.0/28"},
+			nodes:      11,
+		},
+		{
+			name:         "case 3: subnet without IPv4 range",
+			cidrBlocks:   []string{"fd00::/64"},
+			nodes:        1,
+			errorMatcher: IsInvalidExistingVnet,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			err := validateSubnetCapacity(capz.SubnetSpec{Name: "nodes", CIDRBlocks: tc.cidrBlocks}, tc.nodes)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func newVnet() network.VirtualNetwork {
+	newSubnet := func(name, cidr string, serviceEndpoints ...string) network.Subnet {
+		var endpoints []network.ServiceEndpointPropertiesFormat
+		for _, s := range serviceEndpoints {
+			endpoints = append(endpoints, network.ServiceEndpointPropertiesFormat{Service: to.StringPtr(s)})
+		}
+
+		return network.Subnet{
+			ID:   to.StringPtr("/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/hub/subnets/" + name),
+			Name: to.StringPtr(name),
+			SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+				AddressPrefix:    to.StringPtr(cidr),
+				ServiceEndpoints: &endpoints,
+			},
+		}
+	}
+
+	return network.VirtualNetwork{
+		Name: to.StringPtr("hub"),
+		VirtualNetworkPropertiesFormat: &network.VirtualNetworkPropertiesFormat{
+			AddressSpace: &network.AddressSpace{
+				AddressPrefixes: &[]string{"10.1.0.0/16"},
+			},
+			Subnets: &[]network.Subnet{
+				newSubnet("nodes", "10.1.0.0/24", storageServiceEndpoint),
+				newSubnet("private", "10.1.1.0/24"),
+				newSubnet("c1-VirtualNetwork-MasterSubnet", "10.1.32.0/24"),
+				newSubnet("c1-VirtualNetwork-WorkerSubnet", "10.1.33.0/24"),
+			},
+		},
+	}
+}
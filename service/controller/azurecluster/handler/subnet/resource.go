@@ -97,6 +97,27 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	// Subnets of existing virtual networks are neither created nor deleted by
+	// the operator, we only allow them to access the storage account.
+	if key.UsesExistingVnet(&azureCluster) {
+		for _, existingSubnet := range azureCluster.Spec.NetworkSpec.Subnets {
+			if existingSubnet.ID == "" {
+				continue
+			}
+
+			err = r.ensureSubnetIsAllowedToStorageAccount(ctx, storageAccountsClient, &azureCluster, existingSubnet)
+			if IsStorageAccountNotFound(err) || IsNotFound(err) {
+				r.logger.LogCtx(ctx, "level", "warning", "message", "Storage Account needs to be in state 'Succeeded' before subnets can be allowed")
+				r.logger.LogCtx(ctx, "message", "cancelling resource")
+				return nil
+			} else if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		return nil
+	}
+
 	err = r.garbageCollectSubnets(ctx, deploymentsClient, subnetsClient, azureCluster)
 	if IsNotFound(err) {
 		r.logger.LogCtx(ctx, "message", "resources not ready")
@@ -386,7 +407,7 @@ func isSubnetAllowedToStorageAccount(storageAccount storage.Account, subnetID st
 }
 
 // This functions decides whether or not the ARM deployment is out of date.
-// We only take into consideration the subnet's name and CIDR.
+// We only take into consideration the subnet's name and CIDRs.
 func (r *Resource) isDeploymentOutOfDate(ctx context.Context, allocatedSubnet capz.SubnetSpec, currentDeployment azureresource.DeploymentExtended) (bool, error) {
 	currentParams, ok := currentDeployment.Properties.Parameters.(map[string]interface{})
 	if !ok {
@@ -403,9 +424,16 @@ func (r *Resource) isDeploymentOutOfDate(ctx context.Context, allocatedSubnet ca
 		return false, microerror.Maskf(wrongTypeError, "expected 'string', got '%T'", currentParams["nodepoolName"].(map[string]interface{})["value"])
 	}
 
-	r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("Checking if deployment is out of date for %#q", nodepoolName), "desiredSubnetName", allocatedSubnet.Name, "deploymentSubnetName", nodepoolName, "desiredSubnetCidr", allocatedSubnet.CIDRBlocks[0], "deploymentSubnetCidr", subnetCidr)
+	// Deployments of single-stack clusters and deployments created before
+	// dual-stack support don't have the IPv6 range.
+	var subnetIPv6Cidr string
+	if p, ok := currentParams["subnetIPv6Cidr"].(map[string]interface{}); ok {
+		subnetIPv6Cidr, _ = p["value"].(string)
+	}
+
+	r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("Checking if deployment is out of date for %#q", nodepoolName), "desiredSubnetName", allocatedSubnet.Name, "deploymentSubnetName", nodepoolName, "desiredSubnetCidr", allocatedSubnet.CIDRBlocks[0], "deploymentSubnetCidr", subnetCidr, "desiredSubnetIPv6Cidr", key.SubnetIPv6CIDR(allocatedSubnet), "deploymentSubnetIPv6Cidr", subnetIPv6Cidr)
 
-	return allocatedSubnet.Name != nodepoolName || allocatedSubnet.CIDRBlocks[0] != subnetCidr, nil
+	return allocatedSubnet.Name != nodepoolName || allocatedSubnet.CIDRBlocks[0] != subnetCidr || key.SubnetIPv6CIDR(allocatedSubnet) != subnetIPv6Cidr, nil
 }
 
 func (r *Resource) getDeploymentParameters(azureCluster *capz.AzureCluster, natGatewayId string, allocatedSubnet capz.SubnetSpec) (map[string]interface{}, error) {
@@ -416,6 +444,7 @@ func (r *Resource) getDeploymentParameters(azureCluster *capz.AzureCluster, natG
 		"routeTableName":     fmt.Sprintf("%s-%s", key.ClusterID(azureCluster), "RouteTable"),
 		"securityGroupName":  fmt.Sprintf("%s-%s", key.ClusterID(azureCluster), "WorkerSecurityGroup"),
 		"subnetCidr":         allocatedSubnet.CIDRBlocks[0],
+		"subnetIPv6Cidr":     key.SubnetIPv6CIDR(allocatedSubnet),
 		"virtualNetworkName": azureCluster.Spec.NetworkSpec.Vnet.Name,
 	}, nil
 }
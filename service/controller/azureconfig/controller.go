@@ -54,6 +54,7 @@ import (
 )
 
 type ControllerConfig struct {
+	ClientFactory      *client.Factory
 	CredentialProvider credential.Provider
 	InstallationName   string
 	K8sClient          k8sclient.Interface
@@ -66,15 +67,14 @@ type ControllerConfig struct {
 	CPAzureClientSet *client.AzureClientSet
 	ProjectName      string
 
-	ClusterVNetMaskBits int
-
-	Ignition          setting.Ignition
-	IPAMNetworkRange  net.IPNet
-	IPAMReservedCIDRs []net.IPNet
-	OIDC              setting.OIDC
-	SSHUserList       employees.SSHUserList
-	SSOPublicKey      string
-	TemplateVersion   string
+	Ignition             setting.Ignition
+	IPAMIPv6NetworkRange net.IPNet
+	IPAMPoolSelector     *ipam.PoolSelector
+	IPAMReservedCIDRs    []net.IPNet
+	OIDC                 setting.OIDC
+	SSHUserList          employees.SSHUserList
+	SSOPublicKey         string
+	TemplateVersion      string
 
 	DockerhubToken  string
 	RegistryDomain  string
@@ -89,6 +89,9 @@ type Controller struct {
 }
 
 func NewController(config ControllerConfig) (*controller.Controller, error) {
+	if config.ClientFactory == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClientFactory must not be empty", config)
+	}
 	if config.K8sClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
 	}
@@ -208,26 +211,11 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 		}
 	}
 
-	var clientFactory *client.Factory
-	{
-		c := client.FactoryConfig{
-			AzureAPIMetrics:    config.AzureMetricsCollector,
-			CacheDuration:      30 * time.Minute,
-			CredentialProvider: config.CredentialProvider,
-			Logger:             config.Logger,
-		}
-
-		clientFactory, err = client.NewFactory(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-	}
-
 	var organizationClientFactory client.OrganizationFactory
 	{
 		c := client.OrganizationFactoryConfig{
 			CtrlClient: config.K8sClient.CtrlClient(),
-			Factory:    clientFactory,
+			Factory:    config.ClientFactory,
 			Logger:     config.Logger,
 		}
 		organizationClientFactory = client.NewOrganizationFactory(c)
@@ -438,7 +426,7 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 		Logger:     config.Logger,
 
 		Azure:         config.Azure,
-		ClientFactory: organizationClientFactory,
+		ClientFactory: &organizationClientFactory,
 	}
 
 	var mastersResource resource.Interface
@@ -460,7 +448,7 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 	{
 		c := workermigration.Config{
 			CertsSearcher:             certsSearcher,
-			ClientFactory:             clientFactory,
+			ClientFactory:             config.ClientFactory,
 			CPPublicIPAddressesClient: config.CPAzureClientSet.PublicIpAddressesClient,
 			CtrlClient:                config.K8sClient.CtrlClient(),
 			Logger:                    config.Logger,
@@ -493,6 +481,8 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 		c := ipam.AzureConfigPersisterConfig{
 			CtrlClient: config.K8sClient.CtrlClient(),
 			Logger:     config.Logger,
+
+			IPv6NetworkRange: config.IPAMIPv6NetworkRange,
 		}
 
 		azureConfigPersister, err = ipam.NewAzureConfigPersister(c)
@@ -501,6 +491,19 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 		}
 	}
 
+	var azureConfigReleaser *ipam.AzureConfigReleaser
+	{
+		c := ipam.AzureConfigReleaserConfig{
+			CtrlClient: config.K8sClient.CtrlClient(),
+			Logger:     config.Logger,
+		}
+
+		azureConfigReleaser, err = ipam.NewAzureConfigReleaser(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var virtualNetworkCollector *ipam.VirtualNetworkCollector
 	{
 		c := ipam.VirtualNetworkCollectorConfig{
@@ -510,7 +513,6 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 			InstallationName:      config.InstallationName,
 			Logger:                config.Logger,
 
-			NetworkRange:  config.IPAMNetworkRange,
 			ReservedCIDRs: config.IPAMReservedCIDRs,
 		}
 
@@ -523,8 +525,7 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 	var networkRangeGetter *ipam.AzureConfigNetworkRangeGetter
 	{
 		c := ipam.AzureConfigNetworkRangeGetterConfig{
-			InstallationNetworkRange:            config.IPAMNetworkRange,
-			TenantClusterVirtualNetworkMaskBits: config.ClusterVNetMaskBits,
+			PoolSelector: config.IPAMPoolSelector,
 		}
 
 		networkRangeGetter, err = ipam.NewAzureConfigNetworkRangeGetter(c)
@@ -538,12 +539,13 @@ func newAzureConfigResources(config ControllerConfig, certsSearcher certs.Interf
 		c := ipam.Config{
 			Checker:            azureConfigChecker,
 			Collector:          virtualNetworkCollector,
+			IPv6Persister:      azureConfigPersister,
 			Locker:             config.Locker,
 			Logger:             config.Logger,
 			NetworkRangeGetter: networkRangeGetter,
 			NetworkRangeType:   ipam.VirtualNetworkRange,
 			Persister:          azureConfigPersister,
-			Releaser:           ipam.NewNOPReleaser(),
+			Releaser:           azureConfigReleaser,
 		}
 
 		ipamResource, err = ipam.New(c)
@@ -176,9 +176,9 @@ func (r *Resource) mapAzureConfigToAzureCluster(ctx context.Context, cr provider
 			ResourceGroup: key.ClusterID(&cr),
 			NetworkSpec: capz.NetworkSpec{
 				Vnet: capz.VnetSpec{
-					CIDRBlocks:    []string{key.VnetCIDR(cr)},
+					CIDRBlocks:    key.VnetCIDRBlocks(cr),
 					Name:          key.VnetName(cr),
-					ResourceGroup: key.ResourceGroupName(cr),
+					ResourceGroup: key.VnetResourceGroupName(cr),
 				},
 			},
 		},
@@ -345,8 +345,7 @@ func detectAzureClusterUpdate(orig, desired runtime.Object) (bool, error) {
 	o := orig.(*capz.AzureCluster)
 	d := desired.(*capz.AzureCluster)
 
-	return len(o.Spec.NetworkSpec.Vnet.CIDRBlocks) != len(d.Spec.NetworkSpec.Vnet.CIDRBlocks) ||
-		o.Spec.NetworkSpec.Vnet.CIDRBlocks[0] != d.Spec.NetworkSpec.Vnet.CIDRBlocks[0] ||
+	return !reflect.DeepEqual(o.Spec.NetworkSpec.Vnet.CIDRBlocks, d.Spec.NetworkSpec.Vnet.CIDRBlocks) ||
 		o.Spec.NetworkSpec.Vnet.Name != d.Spec.NetworkSpec.Vnet.Name ||
 		o.Spec.NetworkSpec.Vnet.ResourceGroup != d.Spec.NetworkSpec.Vnet.ResourceGroup ||
 		o.Spec.ResourceGroup != d.Spec.ResourceGroup ||
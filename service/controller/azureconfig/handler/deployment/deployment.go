@@ -36,6 +36,14 @@ func (r Resource) newDeployment(ctx context.Context, customObject providerv1alph
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
 
+	// The cluster subnets are created in the resource group of an existing
+	// virtual network, an empty value means the cluster owns its virtual
+	// network.
+	var vnetResourceGroup string
+	if key.ExistingVnetID(customObject) != "" {
+		vnetResourceGroup = key.VnetResourceGroupName(customObject)
+	}
+
 	defaultParams := map[string]interface{}{
 		"blobContainerName":             key.BlobContainerName(),
 		"calicoSubnetCidr":              key.CalicoCIDR(customObject),
@@ -49,7 +57,9 @@ func (r Resource) newDeployment(ctx context.Context, customObject providerv1alph
 		"masterSubnetCidr":              key.MastersSubnetCIDR(customObject),
 		"storageAccountName":            key.StorageAccountName(&customObject),
 		"virtualNetworkCidr":            key.VnetCIDR(customObject),
+		"virtualNetworkIPv6Cidr":        key.VnetIPv6CIDR(customObject),
 		"virtualNetworkName":            key.VnetName(customObject),
+		"virtualNetworkResourceGroup":   vnetResourceGroup,
 		"vnetGatewaySubnetName":         key.VNetGatewaySubnetName(),
 		"vpnSubnetCidr":                 vpnSubnet.String(),
 		"workerSubnetCidr":              key.WorkersSubnetCIDR(customObject),
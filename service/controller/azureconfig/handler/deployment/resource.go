@@ -287,7 +287,7 @@ func (r *Resource) enrichControllerContext(ctx context.Context, customObject pro
 	}
 
 	{
-		v, err := r.getDeploymentOutputValue(ctx, deploymentsClient, resourceGroupName, "virtual_network_setup", "masterSubnetID")
+		v, err := r.getDeploymentOutputValue(ctx, deploymentsClient, key.VnetResourceGroupName(customObject), key.VnetSetupDeploymentName(customObject), "masterSubnetID")
 		if IsNotFound(err) {
 			// fall through
 		} else if err != nil {
@@ -298,7 +298,7 @@ func (r *Resource) enrichControllerContext(ctx context.Context, customObject pro
 	}
 
 	{
-		v, err := r.getDeploymentOutputValue(ctx, deploymentsClient, resourceGroupName, "virtual_network_setup", "workerSubnetID")
+		v, err := r.getDeploymentOutputValue(ctx, deploymentsClient, key.VnetResourceGroupName(customObject), key.VnetSetupDeploymentName(customObject), "workerSubnetID")
 		if IsNotFound(err) {
 			// fall through
 		} else if err != nil {
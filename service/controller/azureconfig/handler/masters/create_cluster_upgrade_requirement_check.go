@@ -58,14 +58,20 @@ func (r *Resource) clusterUpgradeRequirementCheckTransition(ctx context.Context,
 		return "", microerror.Mask(err)
 	}
 
+	anyResize, err := r.anyMasterInstanceNeedsResize(ctx, cr)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
 	cluster, err := r.getCluster(ctx, &cr)
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
 
-	if conditions.IsCreatingFalse(cluster) && anyOldNodes {
+	if conditions.IsCreatingFalse(cluster) && (anyOldNodes || anyResize) {
 		// Only continue rolling nodes when cluster is not creating and there
-		// are old nodes in tenant cluster.
+		// are old nodes in tenant cluster or master instances with an
+		// outdated VM size.
 		return MasterInstancesUpgrading, nil
 	}
 
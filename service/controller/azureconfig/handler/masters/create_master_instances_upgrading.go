@@ -2,8 +2,10 @@ package masters
 
 import (
 	"context"
+	"strings"
 
 	"github.com/giantswarm/errors/tenant"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/tenantcluster/v6/pkg/tenantcluster"
 
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
@@ -17,8 +19,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/etcdhealth"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmssinstance"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
@@ -37,8 +41,9 @@ func (r *Resource) masterInstancesUpgradingTransition(ctx context.Context, obj i
 	}
 
 	var tenantClusterK8sClient client.Client
+	var tenantClusterClients k8sclient.Interface
 	{
-		tenantClusterK8sClient, err = r.getTenantClusterClient(ctx, &cr)
+		tenantClusterClients, err = r.getTenantClusterK8sClient(ctx, &cr)
 		if tenant.IsAPINotAvailable(err) || tenantcluster.IsTimeout(err) {
 			// The kubernetes API is not reachable. This usually happens when a new cluster is being created.
 			// This makes the whole controller to fail and stops next handlers from being executed even if they are
@@ -50,6 +55,8 @@ func (r *Resource) masterInstancesUpgradingTransition(ctx context.Context, obj i
 		} else if err != nil {
 			return "", microerror.Mask(err)
 		}
+
+		tenantClusterK8sClient = tenantClusterClients.CtrlClient()
 	}
 
 	r.Logger.Debugf(ctx, "finding out if all tenant cluster master nodes are Ready")
@@ -93,38 +100,35 @@ func (r *Resource) masterInstancesUpgradingTransition(ctx context.Context, obj i
 				}
 			}
 
-			desiredVersion := key.ReleaseVersion(&cr)
-			for i, vm := range allMasterInstances {
-				instanceName := key.MasterInstanceName(cr, *vm.InstanceID)
-				instanceVersion, ok := versionValue[instanceName]
-				if !ok {
-					continue
-				}
-				if desiredVersion == instanceVersion {
-					continue
-				}
-
+			i, action := nextMasterInstanceAction(cr, allMasterInstances, versionValue)
+			if action != noInstanceAction {
 				masterUpgradeInProgress = true
-
-				// Ensure that VM has latest VMSS configuration (includes ignition template etc.).
-				if !*vm.VirtualMachineScaleSetVMProperties.LatestModelApplied {
+				instanceName := key.MasterInstanceName(cr, *allMasterInstances[i].InstanceID)
+
+				// Taking a master down is only safe while etcd is healthy,
+				// so that the remaining masters keep quorum.
+				err = etcdhealth.Check(ctx, tenantClusterClients)
+				if etcdhealth.IsUnhealthy(err) {
+					r.Logger.Debugf(ctx, "etcd is not healthy, not rolling master instance %#q: %s", instanceName, err.Error())
+				} else if err != nil {
+					return "", microerror.Mask(err)
+				} else if action == updateInstanceAction {
+					// Ensure that VM has latest VMSS configuration (includes ignition template, VM size etc.).
+					// Update only one instance at at time.
 					err = r.updateInstance(ctx, cr, &allMasterInstances[i], key.MasterVMSSName, key.MasterInstanceName)
 					if err != nil {
 						return "", microerror.Mask(err)
 					}
-
-					// Update only one instance at at time.
-					break
-				}
-
-				// Once the VM instance configuration has been updated, it can be reimaged.
-				err = r.reimageInstance(ctx, cr, &allMasterInstances[i], key.MasterVMSSName, key.MasterInstanceName)
-				if err != nil {
-					return "", microerror.Mask(err)
+				} else {
+					// Once the VM instance configuration has been updated, it can be reimaged.
+					// Reimage only one instance at a time.
+					err = r.reimageInstance(ctx, cr, &allMasterInstances[i], key.MasterVMSSName, key.MasterInstanceName)
+					if err != nil {
+						return "", microerror.Mask(err)
+					}
 				}
-
-				// Reimage only one instance at a time.
-				break
+			} else if !anyInstanceNeedsResize(allMasterInstances, key.MasterVMSize(cr)) {
+				r.masterVMSizes.set(key.ClusterID(&cr), key.MasterVMSize(cr))
 			}
 
 			r.Logger.Debugf(ctx, "processed master VMSSs")
@@ -141,7 +145,7 @@ func (r *Resource) masterInstancesUpgradingTransition(ctx context.Context, obj i
 }
 
 // isMastersVmssUpToDate checks whether or not the masters VMSS has been updated. We rely on the tag containing the az-op
-// version, as we are mostly interested on the cloudconfig blob, which depends on the az-op version.
+// version, as we are mostly interested on the cloudconfig blob, which depends on the az-op version, and on the VM size.
 func (r *Resource) isMastersVmssUpToDate(ctx context.Context, azureConfig *providerv1alpha1.AzureConfig) (bool, error) {
 	virtualMachineScaleSetsClient, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureConfig.ObjectMeta)
 	if err != nil {
@@ -158,6 +162,12 @@ func (r *Resource) isMastersVmssUpToDate(ctx context.Context, azureConfig *provi
 		return false, nil
 	}
 
+	// The VM size of the VMSS model must be updated before resizing instances.
+	desiredVMSize := key.MasterVMSize(*azureConfig)
+	if desiredVMSize != "" && (mastersVMSS.Sku == nil || mastersVMSS.Sku.Name == nil || !strings.EqualFold(*mastersVMSS.Sku.Name, desiredVMSize)) {
+		return false, nil
+	}
+
 	return true, nil
 }
 
@@ -202,18 +212,7 @@ func (r *Resource) reimageInstance(ctx context.Context, customObject providerv1a
 		return microerror.Mask(err)
 	}
 
-	resourceGroupName := key.ResourceGroupName(customObject)
-	vmssName := deploymentNameFunc(customObject)
-	ids := &compute.VirtualMachineScaleSetReimageParameters{
-		InstanceIds: to.StringSlicePtr([]string{
-			*instance.InstanceID,
-		}),
-	}
-	res, err := c.Reimage(ctx, resourceGroupName, vmssName, ids)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-	_, err = c.ReimageResponder(res.Response())
+	err = vmssinstance.Reimage(ctx, c, key.ResourceGroupName(customObject), deploymentNameFunc(customObject), *instance.InstanceID)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -40,5 +40,7 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		}
 	}
 
+	r.masterVMSizes.forget(key.ClusterID(m))
+
 	return nil
 }
@@ -88,7 +88,7 @@ func (r Resource) newDeployment(ctx context.Context, obj providerv1alpha1.AzureC
 	if err != nil {
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
-	masterCloudConfig, err := vmss.RenderCloudConfig(masterBlobURL, encryptionKey, initialVector, prefixMaster)
+	masterCloudConfig, err := vmss.RenderMasterCloudConfig(masterBlobURL, encryptionKey, initialVector)
 	if err != nil {
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
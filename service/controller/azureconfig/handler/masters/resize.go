@@ -0,0 +1,147 @@
+package masters
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+type masterInstanceAction int
+
+const (
+	// noInstanceAction means all master instances are up to date.
+	noInstanceAction masterInstanceAction = iota
+	// updateInstanceAction applies the latest VMSS model, including the VM
+	// size, to the instance.
+	updateInstanceAction
+	// reimageInstanceAction reimages the instance running the latest VMSS
+	// model with the latest ignition.
+	reimageInstanceAction
+)
+
+// anyMasterInstanceNeedsResize returns true if any master instance runs with
+// a VM size other than the one defined in the AzureConfig. The masters VMSS
+// instances are only listed when the desired VM size differs from the one all
+// master instances were last found running with.
+func (r *Resource) anyMasterInstanceNeedsResize(ctx context.Context, cr providerv1alpha1.AzureConfig) (bool, error) {
+	desiredVMSize := key.MasterVMSize(cr)
+	if desiredVMSize == "" || r.masterVMSizes.upToDate(key.ClusterID(&cr), desiredVMSize) {
+		return false, nil
+	}
+
+	instances, err := r.allInstances(ctx, cr, key.MasterVMSSName)
+	if nodes.IsScaleSetNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if anyInstanceNeedsResize(instances, desiredVMSize) {
+		return true, nil
+	}
+
+	r.masterVMSizes.set(key.ClusterID(&cr), desiredVMSize)
+
+	return false, nil
+}
+
+func anyInstanceNeedsResize(instances []compute.VirtualMachineScaleSetVM, desiredVMSize string) bool {
+	for _, instance := range instances {
+		if instanceNeedsResize(instance, desiredVMSize) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// instanceNeedsResize returns true if the VMSS instance runs with a VM size
+// other than the desired one. Applying the latest VMSS model to the instance
+// resizes it.
+func instanceNeedsResize(instance compute.VirtualMachineScaleSetVM, desiredVMSize string) bool {
+	if desiredVMSize == "" || instance.Sku == nil || instance.Sku.Name == nil {
+		return false
+	}
+
+	return !strings.EqualFold(*instance.Sku.Name, desiredVMSize)
+}
+
+// nextMasterInstanceAction returns the index of the master instance to roll
+// next and how to roll it. Instances whose node is not registered are
+// skipped. Instances with an outdated release version or VM size are updated
+// to the latest VMSS model first, and instances with an outdated release
+// version running the latest VMSS model are reimaged.
+func nextMasterInstanceAction(cr providerv1alpha1.AzureConfig, instances []compute.VirtualMachineScaleSetVM, nodeVersions map[string]string) (int, masterInstanceAction) {
+	desiredVersion := key.ReleaseVersion(&cr)
+	desiredVMSize := key.MasterVMSize(cr)
+
+	for i, vm := range instances {
+		instanceVersion, ok := nodeVersions[key.MasterInstanceName(cr, *vm.InstanceID)]
+		if !ok {
+			continue
+		}
+
+		outdated := desiredVersion != instanceVersion
+		resize := instanceNeedsResize(vm, desiredVMSize)
+		if !outdated && !resize {
+			continue
+		}
+
+		latestModelApplied := vm.VirtualMachineScaleSetVMProperties != nil &&
+			vm.LatestModelApplied != nil &&
+			*vm.LatestModelApplied
+		if !latestModelApplied || resize {
+			return i, updateInstanceAction
+		}
+
+		return i, reimageInstanceAction
+	}
+
+	return -1, noInstanceAction
+}
+
+// masterVMSizes remembers the VM size all master instances of each cluster
+// were last found running with, so that the masters VMSS instances are not
+// listed in every upgrade requirement check.
+type masterVMSizes struct {
+	mutex sync.Mutex
+	sizes map[string]string
+}
+
+func newMasterVMSizes() *masterVMSizes {
+	return &masterVMSizes{
+		sizes: map[string]string{},
+	}
+}
+
+// upToDate returns true when all master instances of the cluster were last
+// found running with the given VM size.
+func (m *masterVMSizes) upToDate(clusterID, vmSize string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	size, ok := m.sizes[clusterID]
+
+	return ok && strings.EqualFold(size, vmSize)
+}
+
+func (m *masterVMSizes) set(clusterID, vmSize string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.sizes[clusterID] = vmSize
+}
+
+func (m *masterVMSizes) forget(clusterID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.sizes, clusterID)
+}
@@ -0,0 +1,281 @@
+package masters
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	azureclient "github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+)
+
+var errVMSSNotAvailable = errors.New("vmss not available")
+
+func Test_instanceNeedsResize(t *testing.T) {
+	testCases := []struct {
+		name           string
+		instance       compute.VirtualMachineScaleSetVM
+		desiredVMSize  string
+		expectedResize bool
+	}{
+		{
+			name:          "case 0: same VM size",
+			instance:      compute.VirtualMachineScaleSetVM{Sku: &compute.Sku{Name: to.StringPtr("Standard_D4s_v3")}},
+			desiredVMSize: "Standard_D4s_v3",
+		},
+		{
+			name:          "case 1: VM size differs in case only",
+			instance:      compute.VirtualMachineScaleSetVM{Sku: &compute.Sku{Name: to.StringPtr("standard_d4s_v3")}},
+			desiredVMSize: "Standard_D4s_v3",
+		},
+		{
+			name:           "case 2: different VM size",
+			instance:       compute.VirtualMachineScaleSetVM{Sku: &compute.Sku{Name: to.StringPtr("Standard_D4s_v3")}},
+			desiredVMSize:  "Standard_D8s_v3",
+			expectedResize: true,
+		},
+		{
+			name:          "case 3: unknown VM size",
+			instance:      compute.VirtualMachineScaleSetVM{},
+			desiredVMSize: "Standard_D8s_v3",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			resize := instanceNeedsResize(tc.instance, tc.desiredVMSize)
+
+			if resize != tc.expectedResize {
+				t.Fatalf("expected %t, got %t", tc.expectedResize, resize)
+			}
+		})
+	}
+}
+
+func Test_nextMasterInstanceAction(t *testing.T) {
+	instance := func(id, vmSize string, latestModelApplied bool) compute.VirtualMachineScaleSetVM {
+		return compute.VirtualMachineScaleSetVM{
+			InstanceID: to.StringPtr(id),
+			Sku:        &compute.Sku{Name: to.StringPtr(vmSize)},
+			VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+				LatestModelApplied: to.BoolPtr(latestModelApplied),
+			},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		instances      []compute.VirtualMachineScaleSetVM
+		nodeVersions   map[string]string
+		expectedIndex  int
+		expectedAction masterInstanceAction
+	}{
+		{
+			name: "case 0: all instances up to date",
+			instances: []compute.VirtualMachineScaleSetVM{
+				instance("0", "Standard_D8s_v3", true),
+				instance("1", "Standard_D8s_v3", true),
+			},
+			nodeVersions: map[string]string{
+				"c1a2b-master-c1a2b-000000": "20.0.0",
+				"c1a2b-master-c1a2b-000001": "20.0.0",
+			},
+			expectedIndex:  -1,
+			expectedAction: noInstanceAction,
+		},
+		{
+			name: "case 1: instance with outdated VM size is updated although it runs the latest model",
+			instances: []compute.VirtualMachineScaleSetVM{
+				instance("0", "Standard_D8s_v3", true),
+				instance("1", "Standard_D4s_v3", true),
+			},
+			nodeVersions: map[string]string{
+				"c1a2b-master-c1a2b-000000": "20.0.0",
+				"c1a2b-master-c1a2b-000001": "20.0.0",
+			},
+			expectedIndex:  1,
+			expectedAction: updateInstanceAction,
+		},
+		{
+			name: "case 2: outdated instance without the latest model is updated",
+			instances: []compute.VirtualMachineScaleSetVM{
+				instance("0", "Standard_D8s_v3", false),
+			},
+			nodeVersions: map[string]string{
+				"c1a2b-master-c1a2b-000000": "19.0.0",
+			},
+			expectedIndex:  0,
+			expectedAction: updateInstanceAction,
+		},
+		{
+			name: "case 3: outdated instance running the latest model is reimaged",
+			instances: []compute.VirtualMachineScaleSetVM{
+				instance("0", "Standard_D8s_v3", true),
+			},
+			nodeVersions: map[string]string{
+				"c1a2b-master-c1a2b-000000": "19.0.0",
+			},
+			expectedIndex:  0,
+			expectedAction: reimageInstanceAction,
+		},
+		{
+			name: "case 4: instances without a node are skipped",
+			instances: []compute.VirtualMachineScaleSetVM{
+				instance("0", "Standard_D4s_v3", true),
+				instance("1", "Standard_D4s_v3", true),
+			},
+			nodeVersions: map[string]string{
+				"c1a2b-master-c1a2b-000001": "20.0.0",
+			},
+			expectedIndex:  1,
+			expectedAction: updateInstanceAction,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			index, action := nextMasterInstanceAction(newAzureConfig("Standard_D8s_v3"), tc.instances, tc.nodeVersions)
+
+			if index != tc.expectedIndex {
+				t.Fatalf("expected index %d, got %d", tc.expectedIndex, index)
+			}
+			if action != tc.expectedAction {
+				t.Fatalf("expected action %d, got %d", tc.expectedAction, action)
+			}
+		})
+	}
+}
+
+func Test_Resource_anyMasterInstanceNeedsResize(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		knownVMSize           string
+		desiredVMSize         string
+		expectedVMSSVMsCalls  int
+		expectedErrorOccurred bool
+	}{
+		{
+			name:          "case 0: masters known to run with the desired VM size are not listed",
+			knownVMSize:   "Standard_D8s_v3",
+			desiredVMSize: "Standard_D8s_v3",
+		},
+		{
+			name:          "case 1: known VM size differing in case only",
+			knownVMSize:   "standard_d8s_v3",
+			desiredVMSize: "Standard_D8s_v3",
+		},
+		{
+			name: "case 2: no desired VM size",
+		},
+		{
+			name:                  "case 3: masters are listed when the desired VM size changes",
+			knownVMSize:           "Standard_D4s_v3",
+			desiredVMSize:         "Standard_D8s_v3",
+			expectedVMSSVMsCalls:  1,
+			expectedErrorOccurred: true,
+		},
+		{
+			name:                  "case 4: masters are listed when their VM size is unknown",
+			desiredVMSize:         "Standard_D8s_v3",
+			expectedVMSSVMsCalls:  1,
+			expectedErrorOccurred: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			clientFactory := &fakeClientFactory{}
+			r := &Resource{
+				Resource: nodes.Resource{
+					ClientFactory: clientFactory,
+					Logger:        microloggertest.New(),
+				},
+				masterVMSizes: newMasterVMSizes(),
+			}
+			if tc.knownVMSize != "" {
+				r.masterVMSizes.set("c1a2b", tc.knownVMSize)
+			}
+
+			resize, err := r.anyMasterInstanceNeedsResize(context.Background(), newAzureConfig(tc.desiredVMSize))
+			if (err != nil) != tc.expectedErrorOccurred {
+				t.Fatalf("error == %#v, want error %t", err, tc.expectedErrorOccurred)
+			}
+			if resize {
+				t.Fatalf("expected no resize")
+			}
+			if clientFactory.vmssVMsCalls != tc.expectedVMSSVMsCalls {
+				t.Fatalf("VMSS VMs client requested %d times, want %d", clientFactory.vmssVMsCalls, tc.expectedVMSSVMsCalls)
+			}
+		})
+	}
+}
+
+func Test_masterVMSizes(t *testing.T) {
+	sizes := newMasterVMSizes()
+
+	if sizes.upToDate("c1a2b", "Standard_D8s_v3") {
+		t.Fatalf("expected unknown cluster not to be up to date")
+	}
+
+	sizes.set("c1a2b", "Standard_D8s_v3")
+	if !sizes.upToDate("c1a2b", "Standard_D8s_v3") {
+		t.Fatalf("expected cluster to be up to date")
+	}
+	if sizes.upToDate("c1a2b", "Standard_D16s_v3") {
+		t.Fatalf("expected cluster not to be up to date for another VM size")
+	}
+	if sizes.upToDate("d3e4f", "Standard_D8s_v3") {
+		t.Fatalf("expected other cluster not to be up to date")
+	}
+
+	sizes.forget("c1a2b")
+	if sizes.upToDate("c1a2b", "Standard_D8s_v3") {
+		t.Fatalf("expected forgotten cluster not to be up to date")
+	}
+}
+
+func newAzureConfig(masterVMSize string) providerv1alpha1.AzureConfig {
+	cr := providerv1alpha1.AzureConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "c1a2b",
+			Labels: map[string]string{
+				label.Cluster:        "c1a2b",
+				label.ReleaseVersion: "20.0.0",
+			},
+		},
+	}
+	if masterVMSize != "" {
+		cr.Spec.Azure.Masters = []providerv1alpha1.AzureConfigSpecAzureNode{
+			{VMSize: masterVMSize},
+		}
+	}
+
+	return cr
+}
+
+// fakeClientFactory fails all VMSS VMs requests and counts them. Any other
+// Azure client request panics.
+type fakeClientFactory struct {
+	azureclient.Interface
+
+	vmssVMsCalls int
+}
+
+func (f *fakeClientFactory) GetVirtualMachineScaleSetVMsClient(ctx context.Context, objectMeta metav1.ObjectMeta) (*compute.VirtualMachineScaleSetVMsClient, error) {
+	f.vmssVMsCalls++
+	return nil, errVMSSNotAvailable
+}
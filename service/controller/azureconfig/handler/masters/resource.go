@@ -29,6 +29,7 @@ type Config struct {
 type Resource struct {
 	nodes.Resource
 	ctrlClient               client.Client
+	masterVMSizes            *masterVMSizes
 	tenantRestConfigProvider *tenantcluster.TenantCluster
 	vmSku                    *vmsku.VMSKUs
 }
@@ -53,6 +54,7 @@ func New(config Config) (*Resource, error) {
 	r := &Resource{
 		Resource:                 *nodes,
 		ctrlClient:               config.CtrlClient,
+		masterVMSizes:            newMasterVMSizes(),
 		tenantRestConfigProvider: config.TenantRestConfigProvider,
 		vmSku:                    config.VMSKU,
 	}
@@ -67,21 +69,27 @@ func (r *Resource) Name() string {
 }
 
 func (r *Resource) getTenantClusterClient(ctx context.Context, azureConfig *providerv1alpha1.AzureConfig) (client.Client, error) {
-	var k8sClient k8sclient.Interface
-	{
-		restConfig, err := r.tenantRestConfigProvider.NewRestConfig(ctx, key.ClusterID(azureConfig), key.ClusterAPIEndpoint(*azureConfig))
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-
-		k8sClient, err = k8sclient.NewClients(k8sclient.ClientsConfig{
-			Logger:     r.Logger,
-			RestConfig: rest.CopyConfig(restConfig),
-		})
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
+	k8sClient, err := r.getTenantClusterK8sClient(ctx, azureConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
 	return k8sClient.CtrlClient(), nil
 }
+
+func (r *Resource) getTenantClusterK8sClient(ctx context.Context, azureConfig *providerv1alpha1.AzureConfig) (k8sclient.Interface, error) {
+	restConfig, err := r.tenantRestConfigProvider.NewRestConfig(ctx, key.ClusterID(azureConfig), key.ClusterAPIEndpoint(*azureConfig))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	k8sClient, err := k8sclient.NewClients(k8sclient.ClientsConfig{
+		Logger:     r.Logger,
+		RestConfig: rest.CopyConfig(restConfig),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return k8sClient, nil
+}
@@ -0,0 +1,93 @@
+package resourcegroup
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
+	azureresource "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-05-01/resources"
+	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// detachClusterSubnets removes the network security groups, the route table
+// and the NAT gateways of the resource group from the master and worker
+// subnets created in an existing virtual network. Otherwise Azure refuses to
+// delete them together with the resource group. It returns true while subnets
+// are being updated. Azure doesn't allow concurrent operations on the subnets
+// of a virtual network, so subnets are updated one by one.
+func (r *Resource) detachClusterSubnets(ctx context.Context, cr v1alpha1.AzureConfig, subnetsClient *network.SubnetsClient) (bool, error) {
+	for _, subnetName := range []string{key.MasterSubnetName(cr), key.WorkerSubnetName(cr)} {
+		subnet, err := subnetsClient.Get(ctx, key.VnetResourceGroupName(cr), key.VnetName(cr), subnetName, "")
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		if subnet.ProvisioningState != network.Succeeded {
+			r.logger.Debugf(ctx, "subnet %#q is in state %#q", subnetName, subnet.ProvisioningState)
+			return true, nil
+		}
+
+		if subnet.NetworkSecurityGroup == nil && subnet.RouteTable == nil && subnet.NatGateway == nil {
+			continue
+		}
+
+		r.logger.Debugf(ctx, "detaching subnet %#q from resource group resources", subnetName)
+
+		subnet.NetworkSecurityGroup = nil
+		subnet.RouteTable = nil
+		subnet.NatGateway = nil
+
+		_, err = subnetsClient.CreateOrUpdate(ctx, key.VnetResourceGroupName(cr), key.VnetName(cr), subnetName, subnet)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "detached subnet %#q from resource group resources", subnetName)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// deleteClusterSubnets deletes the master and worker subnets created in an
+// existing virtual network, together with the deployment that created them.
+// The virtual network and the node pool subnets are owned by the customer and
+// are never deleted. It returns true while subnets are being deleted, one by
+// one.
+func (r *Resource) deleteClusterSubnets(ctx context.Context, cr v1alpha1.AzureConfig, subnetsClient *network.SubnetsClient, deploymentsClient *azureresource.DeploymentsClient) (bool, error) {
+	for _, subnetName := range []string{key.MasterSubnetName(cr), key.WorkerSubnetName(cr)} {
+		_, err := subnetsClient.Get(ctx, key.VnetResourceGroupName(cr), key.VnetName(cr), subnetName, "")
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "deleting subnet %#q", subnetName)
+
+		_, err = subnetsClient.Delete(ctx, key.VnetResourceGroupName(cr), key.VnetName(cr), subnetName)
+		if IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "subnet %#q deletion in progress", subnetName)
+
+		return true, nil
+	}
+
+	_, err := deploymentsClient.Delete(ctx, key.VnetResourceGroupName(cr), key.VnetSetupDeploymentName(cr))
+	if IsNotFound(err) {
+		// fall through
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return false, nil
+}
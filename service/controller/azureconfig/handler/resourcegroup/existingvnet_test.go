@@ -0,0 +1,204 @@
+package resourcegroup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-05-01/resources"
+	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+	"github.com/giantswarm/azure-operator/v8/service/controller/controllercontext"
+)
+
+const (
+	testClusterID      = "c1a2b"
+	testSubscriptionID = "subscription-id"
+	testVnetGroup      = "customer-network"
+	testVnetName       = "customer-vnet"
+	testNodeSubnetName = "customer-nodes"
+)
+
+func Test_Resource_EnsureDeleted_ExistingVnet(t *testing.T) {
+	masterSubnet := testClusterID + "-VirtualNetwork-MasterSubnet"
+	workerSubnet := testClusterID + "-VirtualNetwork-WorkerSubnet"
+	subnetPath := func(name string) string {
+		return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s", testSubscriptionID, testVnetGroup, testVnetName, name)
+	}
+
+	testCases := []struct {
+		name           string
+		groupExists    bool
+		subnets        map[string]bool
+		expectedWrites []string
+	}{
+		{
+			name:        "case 0: cluster subnets are detached from the resource group first",
+			groupExists: true,
+			subnets: map[string]bool{
+				masterSubnet:       true,
+				workerSubnet:       true,
+				testNodeSubnetName: true,
+			},
+			expectedWrites: []string{
+				"PUT " + subnetPath(masterSubnet),
+			},
+		},
+		{
+			name:        "case 1: resource group is deleted once cluster subnets are detached",
+			groupExists: true,
+			subnets: map[string]bool{
+				masterSubnet:       false,
+				workerSubnet:       false,
+				testNodeSubnetName: true,
+			},
+			expectedWrites: []string{
+				fmt.Sprintf("DELETE /subscriptions/%s/resourcegroups/%s", testSubscriptionID, testClusterID),
+			},
+		},
+		{
+			name: "case 2: cluster subnets are deleted after the resource group",
+			subnets: map[string]bool{
+				masterSubnet:       false,
+				workerSubnet:       false,
+				testNodeSubnetName: true,
+			},
+			expectedWrites: []string{
+				"DELETE " + subnetPath(masterSubnet),
+			},
+		},
+		{
+			name: "case 3: subnet deployment is deleted last",
+			subnets: map[string]bool{
+				testNodeSubnetName: true,
+			},
+			expectedWrites: []string{
+				fmt.Sprintf("DELETE /subscriptions/%s/resourcegroups/%s/providers/Microsoft.Resources/deployments/%s-virtual_network_setup", testSubscriptionID, testVnetGroup, testClusterID),
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			api := &fakeNetworkAPI{
+				groupExists: tc.groupExists,
+				subnets:     tc.subnets,
+			}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			groupsClient := resources.NewGroupsClientWithBaseURI(server.URL, testSubscriptionID)
+			subnetsClient := network.NewSubnetsClientWithBaseURI(server.URL, testSubscriptionID)
+			deploymentsClient := resources.NewDeploymentsClientWithBaseURI(server.URL, testSubscriptionID)
+			ctx := controllercontext.NewContext(context.Background(), controllercontext.Context{
+				AzureClientSet: &client.AzureClientSet{
+					DeploymentsClient: &deploymentsClient,
+					GroupsClient:      &groupsClient,
+					SubnetsClient:     &subnetsClient,
+				},
+			})
+
+			r := &Resource{
+				logger: microloggertest.New(),
+			}
+
+			cr := v1alpha1.AzureConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: testClusterID,
+					Labels: map[string]string{
+						label.Cluster: testClusterID,
+					},
+					Annotations: map[string]string{
+						annotation.ExistingVirtualNetworkID: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s", testSubscriptionID, testVnetGroup, testVnetName),
+					},
+				},
+			}
+
+			err := r.EnsureDeleted(ctx, &cr)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if !cmp.Equal(api.writes, tc.expectedWrites) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedWrites, api.writes))
+			}
+			for _, request := range api.requests {
+				if strings.Contains(request, "/subnets/"+testNodeSubnetName) {
+					t.Fatalf("node pool subnet requested: %s", request)
+				}
+				if strings.HasSuffix(request, "/virtualNetworks/"+testVnetName) && !strings.HasPrefix(request, http.MethodGet) {
+					t.Fatalf("virtual network written: %s", request)
+				}
+			}
+		})
+	}
+}
+
+// fakeNetworkAPI serves the resource group, subnet and deployment requests of
+// the Azure API. Subnets are attached to the resource group when their value
+// is true.
+type fakeNetworkAPI struct {
+	groupExists bool
+	subnets     map[string]bool
+
+	requests []string
+	writes   []string
+}
+
+func (a *fakeNetworkAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := r.Method + " " + r.URL.Path
+	a.requests = append(a.requests, request)
+	if r.Method != http.MethodGet {
+		a.writes = append(a.writes, request)
+	}
+
+	var response interface{}
+	switch {
+	case strings.Contains(r.URL.Path, "/subnets/"):
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		attached, ok := a.subnets[name]
+		if !ok && r.Method == http.MethodGet {
+			http.Error(w, `{"error": {"code": "NotFound"}}`, http.StatusNotFound)
+			return
+		}
+
+		properties := map[string]interface{}{
+			"provisioningState": "Succeeded",
+		}
+		if attached && r.Method == http.MethodGet {
+			properties["routeTable"] = map[string]interface{}{"id": "route-table"}
+		}
+		response = map[string]interface{}{
+			"name":       name,
+			"properties": properties,
+		}
+	case strings.Contains(r.URL.Path, "/deployments/"):
+		response = map[string]interface{}{}
+	case strings.HasSuffix(r.URL.Path, "/resourcegroups/"+testClusterID):
+		if !a.groupExists && r.Method == http.MethodGet {
+			http.Error(w, `{"error": {"code": "ResourceGroupNotFound"}}`, http.StatusNotFound)
+			return
+		}
+		response = map[string]interface{}{"name": testClusterID}
+	default:
+		http.Error(w, fmt.Sprintf("unexpected request %s", request), http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
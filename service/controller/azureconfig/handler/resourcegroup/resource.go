@@ -119,6 +119,11 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	cc, err := controllercontext.FromContext(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	r.logger.Debugf(ctx, "ensuring resource group deletion")
 
 	_, err = groupsClient.Get(ctx, key.ClusterID(&cr))
@@ -127,6 +132,19 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 	} else if err != nil {
 		return microerror.Mask(err)
 	} else {
+		if key.ExistingVnetID(cr) != "" {
+			updating, err := r.detachClusterSubnets(ctx, cr, cc.AzureClientSet.SubnetsClient)
+			if err != nil {
+				return microerror.Mask(err)
+			} else if updating {
+				finalizerskeptcontext.SetKept(ctx)
+				reconciliationcanceledcontext.SetCanceled(ctx)
+				r.logger.Debugf(ctx, "canceling reconciliation")
+
+				return nil
+			}
+		}
+
 		res, err := groupsClient.Delete(ctx, key.ClusterID(&cr))
 		if IsNotFound(err) {
 			// fall through
@@ -147,6 +165,19 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		}
 	}
 
+	if key.ExistingVnetID(cr) != "" {
+		deleting, err := r.deleteClusterSubnets(ctx, cr, cc.AzureClientSet.SubnetsClient, cc.AzureClientSet.DeploymentsClient)
+		if err != nil {
+			return microerror.Mask(err)
+		} else if deleting {
+			finalizerskeptcontext.SetKept(ctx)
+			reconciliationcanceledcontext.SetCanceled(ctx)
+			r.logger.Debugf(ctx, "canceling reconciliation")
+
+			return nil
+		}
+	}
+
 	r.logger.Debugf(ctx, "ensured resource group deletion")
 
 	return nil
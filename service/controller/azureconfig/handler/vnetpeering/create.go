@@ -23,6 +23,13 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	// Peerings of existing VNets are managed by their owners.
+	if key.ExistingVnetID(cr) != "" {
+		r.logger.Debugf(ctx, "cluster uses existing virtual network %#q", key.ExistingVnetID(cr))
+		r.logger.Debugf(ctx, "canceling resource")
+		return nil
+	}
+
 	var tcVnet network.VirtualNetwork
 	{
 		r.logger.LogCtx(ctx, "level", "debug", "message", fmt.Sprintf("Checking if TC virtual network %#q exists in resource group %#q", key.VnetName(cr), key.ResourceGroupName(cr)))
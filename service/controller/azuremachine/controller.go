@@ -2,7 +2,6 @@ package azuremachine
 
 import (
 	"context"
-	"time"
 
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
@@ -24,6 +23,7 @@ import (
 
 type ControllerConfig struct {
 	AzureMetricsCollector collector.AzureAPIMetrics
+	ClientFactory         *client.Factory
 	CredentialProvider    credential.Provider
 	K8sClient             k8sclient.Interface
 	Logger                micrologger.Logger
@@ -34,6 +34,9 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
+	if config.ClientFactory == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClientFactory must not be empty", config)
+	}
 	if config.K8sClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.K8sClient must not be empty", config)
 	}
@@ -79,26 +82,11 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 func NewAzureMachineResourceSet(config ControllerConfig) ([]resource.Interface, error) {
 	var err error
 
-	var clientFactory *client.Factory
-	{
-		c := client.FactoryConfig{
-			AzureAPIMetrics:    config.AzureMetricsCollector,
-			CacheDuration:      30 * time.Minute,
-			CredentialProvider: config.CredentialProvider,
-			Logger:             config.Logger,
-		}
-
-		clientFactory, err = client.NewFactory(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-	}
-
 	var organizationClientFactory client.OrganizationFactory
 	{
 		c := client.OrganizationFactoryConfig{
 			CtrlClient: config.K8sClient.CtrlClient(),
-			Factory:    clientFactory,
+			Factory:    config.ClientFactory,
 			Logger:     config.Logger,
 		}
 		organizationClientFactory = client.NewOrganizationFactory(c)
@@ -27,19 +27,27 @@ func (r *Resource) ensureSubnetReadyCondition(ctx context.Context, azureMachine
 		return microerror.Mask(err)
 	}
 
+	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, r.ctrlClient, azureMachine.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	// Now let's first check ARM deployment state (master subnet is currently
 	// deployed as a part of VNet deployment, we can remove this once we have
 	// a separate VirtualNetworkReady conditions, or separate master subnet
-	// deployment).
-	vnetDeploymentName := "virtual_network_setup"
-	isSubnetDeploymentSuccessful, err := r.deploymentChecker.CheckIfDeploymentIsSuccessful(ctx, deploymentsClient, azureMachine, vnetDeploymentName, azureconditions.SubnetReadyCondition)
-	if err != nil {
-		return microerror.Mask(err)
-	} else if !isSubnetDeploymentSuccessful {
-		// Function CheckIfDeploymentIsSuccessful that is called above, if it
-		// sees that the deployment is not succeeded, for whatever reason, it
-		// will also set appropriate condition value, so our job here is done.
-		return nil
+	// deployment). The VNet deployment of clusters using an existing VNet is
+	// created in the resource group of the VNet, so we only check the subnet.
+	if !key.UsesExistingVnet(azureCluster) {
+		vnetDeploymentName := "virtual_network_setup"
+		isSubnetDeploymentSuccessful, err := r.deploymentChecker.CheckIfDeploymentIsSuccessful(ctx, deploymentsClient, azureMachine, vnetDeploymentName, azureconditions.SubnetReadyCondition)
+		if err != nil {
+			return microerror.Mask(err)
+		} else if !isSubnetDeploymentSuccessful {
+			// Function CheckIfDeploymentIsSuccessful that is called above, if it
+			// sees that the deployment is not succeeded, for whatever reason, it
+			// will also set appropriate condition value, so our job here is done.
+			return nil
+		}
 	}
 
 	// Deployment is successful, we proceed with checking the actual Azure
@@ -49,13 +57,8 @@ func (r *Resource) ensureSubnetReadyCondition(ctx context.Context, azureMachine
 		return microerror.Mask(err)
 	}
 
-	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, r.ctrlClient, azureMachine.ObjectMeta)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
 	subnetName := key.MasterSubnetNameFromClusterAPIObject(azureMachine)
-	subnet, err := subnetsClient.Get(ctx, azureCluster.Name, azureCluster.Spec.NetworkSpec.Vnet.Name, subnetName, "")
+	subnet, err := subnetsClient.Get(ctx, key.AzureClusterVnetResourceGroup(azureCluster), azureCluster.Spec.NetworkSpec.Vnet.Name, subnetName, "")
 	if IsNotFound(err) {
 		r.setSubnetNotFound(ctx, azureMachine, subnetName, azureconditions.SubnetReadyCondition)
 		return nil
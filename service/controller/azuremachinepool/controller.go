@@ -18,10 +18,14 @@ import (
 
 	"github.com/giantswarm/azure-operator/v8/client"
 	"github.com/giantswarm/azure-operator/v8/pkg/credential"
+	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
 	"github.com/giantswarm/azure-operator/v8/pkg/employees"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/ipam"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
 	"github.com/giantswarm/azure-operator/v8/pkg/locker"
 	"github.com/giantswarm/azure-operator/v8/pkg/project"
 	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
@@ -30,34 +34,39 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/cloudconfigblob"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/nodepool"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/spark"
+	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/spotinstances"
 	"github.com/giantswarm/azure-operator/v8/service/controller/debugger"
 	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmsku"
 	"github.com/giantswarm/azure-operator/v8/service/controller/setting"
 )
 
 type ControllerConfig struct {
-	APIServerSecurePort   int
-	Azure                 setting.Azure
-	AzureMetricsCollector collector.AzureAPIMetrics
-	CalicoCIDRSize        int
-	CalicoMTU             int
-	CalicoSubnet          string
-	ClusterIPRange        string
-	CPAzureClientSet      *client.AzureClientSet
-	CredentialProvider    credential.Provider
-	DockerhubToken        string
-	EtcdPrefix            string
-	Ignition              setting.Ignition
-	InstallationName      string
-	K8sClient             k8sclient.Interface
-	Locker                locker.Interface
-	Logger                micrologger.Logger
-	OIDC                  setting.OIDC
-	RegistryDomain        string
-	SentryDSN             string
-	SSHUserList           employees.SSHUserList
-	SSOPublicKey          string
-	VMSSMSIEnabled        bool
+	APIServerSecurePort           int
+	Azure                         setting.Azure
+	AzureMetricsCollector         collector.AzureAPIMetrics
+	CalicoCIDRSize                int
+	CalicoMTU                     int
+	CalicoSubnet                  string
+	ClientFactory                 *client.Factory
+	ClusterIPRange                string
+	CPAzureClientSet              *client.AzureClientSet
+	CredentialProvider            credential.Provider
+	DockerhubToken                string
+	DrainRules                    drainer.Rules
+	EtcdPrefix                    string
+	Ignition                      setting.Ignition
+	InstallationName              string
+	K8sClient                     k8sclient.Interface
+	LifecycleHookWebhookAllowlist lifecyclehook.WebhookAllowlist
+	Locker                        locker.Interface
+	Logger                        micrologger.Logger
+	NodePoolStateDeadlines        state.DeadlineMap
+	OIDC                          setting.OIDC
+	RegistryDomain                string
+	SentryDSN                     string
+	SSHUserList                   employees.SSHUserList
+	SSOPublicKey                  string
+	VMSSMSIEnabled                bool
 }
 
 func NewController(config ControllerConfig) (*controller.Controller, error) {
@@ -65,6 +74,10 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
 
+	if config.ClientFactory == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClientFactory must not be empty", config)
+	}
+
 	if config.CPAzureClientSet == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.cpAzureClientSet must not be empty", config)
 	}
@@ -114,26 +127,11 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interface, error) {
 	var err error
 
-	var clientFactory *client.Factory
-	{
-		c := client.FactoryConfig{
-			AzureAPIMetrics:    config.AzureMetricsCollector,
-			CacheDuration:      30 * time.Minute,
-			CredentialProvider: config.CredentialProvider,
-			Logger:             config.Logger,
-		}
-
-		clientFactory, err = client.NewFactory(c)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-	}
-
 	var organizationClientFactory client.OrganizationFactory
 	{
 		c := client.OrganizationFactoryConfig{
 			CtrlClient: config.K8sClient.CtrlClient(),
-			Factory:    clientFactory,
+			Factory:    config.ClientFactory,
 			Logger:     config.Logger,
 		}
 		organizationClientFactory = client.NewOrganizationFactory(c)
@@ -199,7 +197,7 @@ func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interfa
 		Logger:     config.Logger,
 
 		Azure:         config.Azure,
-		ClientFactory: organizationClientFactory,
+		ClientFactory: &organizationClientFactory,
 	}
 
 	var vmSKU *vmsku.VMSKUs
@@ -214,13 +212,30 @@ func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interfa
 		}
 	}
 
+	var eventRecorder *event.Recorder
+	{
+		c := event.Config{
+			CtrlClient: config.K8sClient.CtrlClient(),
+			Logger:     config.Logger,
+		}
+
+		eventRecorder, err = event.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var nodepoolResource resource.Interface
 	{
 		c := nodepool.Config{
-			Config:              nodesConfig,
-			CredentialProvider:  config.CredentialProvider,
-			TenantClientFactory: cachedTenantClientFactory,
-			VMSKU:               vmSKU,
+			Config:                        nodesConfig,
+			CredentialProvider:            config.CredentialProvider,
+			DrainRules:                    config.DrainRules,
+			EventRecorder:                 eventRecorder,
+			LifecycleHookWebhookAllowlist: config.LifecycleHookWebhookAllowlist,
+			StateDeadlines:                config.NodePoolStateDeadlines,
+			TenantClientFactory:           cachedTenantClientFactory,
+			VMSKU:                         vmSKU,
 		}
 
 		nodepoolResource, err = nodepool.New(c)
@@ -229,6 +244,22 @@ func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interfa
 		}
 	}
 
+	var spotInstancesResource resource.Interface
+	{
+		c := spotinstances.Config{
+			ClientFactory:       organizationClientFactory,
+			CtrlClient:          config.K8sClient.CtrlClient(),
+			EventRecorder:       eventRecorder,
+			Logger:              config.Logger,
+			TenantClientFactory: cachedTenantClientFactory,
+		}
+
+		spotInstancesResource, err = spotinstances.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var subnetChecker *ipam.AzureMachinePoolSubnetChecker
 	{
 		c := ipam.AzureMachinePoolSubnetCheckerConfig{
@@ -300,6 +331,7 @@ func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interfa
 		c := ipam.Config{
 			Checker:            subnetChecker,
 			Collector:          subnetCollector,
+			IPv6Persister:      subnetPersister,
 			Locker:             config.Locker,
 			Logger:             config.Logger,
 			NetworkRangeGetter: networkRangeGetter,
@@ -362,6 +394,7 @@ func NewAzureMachinePoolResourceSet(config ControllerConfig) ([]resource.Interfa
 		ipamResource,
 		sparkResource,
 		cloudconfigblobResource,
+		spotInstancesResource,
 		nodepoolResource,
 	}
 
@@ -0,0 +1,43 @@
+package azuremachinepoolconditions
+
+import (
+	"context"
+
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// DisksValidCondition reports if the OS and data disks of the node pool
+	// can be deployed.
+	DisksValidCondition capi.ConditionType = "DisksValid"
+
+	InvalidDiskConfigReason = "InvalidDiskConfig"
+)
+
+func (r *Resource) ensureDisksValidCondition(ctx context.Context, azureMachinePool *capzexp.AzureMachinePool) {
+	r.logger.Debugf(ctx, "ensuring condition %s", DisksValidCondition)
+
+	err := key.ValidateNodePoolDisks(azureMachinePool)
+	if key.IsInvalidDiskConfig(err) {
+		message := "Node pool disks are invalid, the node pool is not updated: %s"
+		messageArgs := err.Error()
+		capiconditions.MarkFalse(
+			azureMachinePool,
+			DisksValidCondition,
+			InvalidDiskConfigReason,
+			capi.ConditionSeverityError,
+			message,
+			messageArgs)
+
+		r.logger.Debugf(ctx, message, messageArgs)
+	} else {
+		capiconditions.MarkTrue(azureMachinePool, DisksValidCondition)
+	}
+
+	r.logConditionStatus(ctx, azureMachinePool, DisksValidCondition)
+	r.logger.Debugf(ctx, "ensured condition %s", DisksValidCondition)
+}
@@ -0,0 +1,47 @@
+package azuremachinepoolconditions
+
+import (
+	"context"
+
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/scalestrategy"
+)
+
+const (
+	// ScaleStrategyValidCondition reports if the scale strategy configured
+	// through AzureMachinePool annotations is valid.
+	ScaleStrategyValidCondition capi.ConditionType = "ScaleStrategyValid"
+
+	InvalidScaleStrategyReason = "InvalidScaleStrategy"
+)
+
+func (r *Resource) ensureScaleStrategyValidCondition(ctx context.Context, azureMachinePool *capzexp.AzureMachinePool) {
+	r.logger.Debugf(ctx, "ensuring condition %s", ScaleStrategyValidCondition)
+
+	_, err := scalestrategy.ConfigFromAnnotations(azureMachinePool.Annotations)
+	if scalestrategy.IsInvalidConfig(err) {
+		r.setInvalidScaleStrategy(ctx, azureMachinePool, err.Error(), ScaleStrategyValidCondition)
+	} else {
+		capiconditions.MarkTrue(azureMachinePool, ScaleStrategyValidCondition)
+	}
+
+	r.logConditionStatus(ctx, azureMachinePool, ScaleStrategyValidCondition)
+	r.logger.Debugf(ctx, "ensured condition %s", ScaleStrategyValidCondition)
+}
+
+func (r *Resource) setInvalidScaleStrategy(ctx context.Context, cr capiconditions.Setter, reason string, condition capi.ConditionType) {
+	message := "Scale strategy settings are invalid, the default strategy is used: %s"
+	messageArgs := reason
+	capiconditions.MarkFalse(
+		cr,
+		condition,
+		InvalidScaleStrategyReason,
+		capi.ConditionSeverityError,
+		message,
+		messageArgs)
+
+	r.logger.Debugf(ctx, message, messageArgs)
+}
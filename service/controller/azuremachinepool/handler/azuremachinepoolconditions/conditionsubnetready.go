@@ -22,21 +22,29 @@ const (
 func (r *Resource) ensureSubnetReadyCondition(ctx context.Context, azureMachinePool *capzexp.AzureMachinePool) error {
 	r.logger.Debugf(ctx, "ensuring condition %s", azureconditions.SubnetReadyCondition)
 
-	deploymentsClient, err := r.azureClientsFactory.GetDeploymentsClient(ctx, azureMachinePool.ObjectMeta)
+	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, r.ctrlClient, azureMachinePool.ObjectMeta)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	// Now let's first check ARM deployment state
-	subnetDeploymentName := key.SubnetDeploymentName(azureMachinePool.Name)
-	isSubnetDeploymentSuccessful, err := r.deploymentChecker.CheckIfDeploymentIsSuccessful(ctx, deploymentsClient, azureMachinePool, subnetDeploymentName, azureconditions.SubnetReadyCondition)
-	if err != nil {
-		return microerror.Mask(err)
-	} else if !isSubnetDeploymentSuccessful {
-		// Function CheckIfDeploymentIsSuccessful that is called above, if it
-		// sees that the deployment is not succeeded, for whatever reason, it
-		// will also set appropriate condition value, so our job here is done.
-		return nil
+	// Subnets of existing VNets are not created with ARM deployments.
+	if !key.UsesExistingVnet(azureCluster) {
+		deploymentsClient, err := r.azureClientsFactory.GetDeploymentsClient(ctx, azureMachinePool.ObjectMeta)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		// Now let's first check ARM deployment state
+		subnetDeploymentName := key.SubnetDeploymentName(azureMachinePool.Name)
+		isSubnetDeploymentSuccessful, err := r.deploymentChecker.CheckIfDeploymentIsSuccessful(ctx, deploymentsClient, azureMachinePool, subnetDeploymentName, azureconditions.SubnetReadyCondition)
+		if err != nil {
+			return microerror.Mask(err)
+		} else if !isSubnetDeploymentSuccessful {
+			// Function CheckIfDeploymentIsSuccessful that is called above, if it
+			// sees that the deployment is not succeeded, for whatever reason, it
+			// will also set appropriate condition value, so our job here is done.
+			return nil
+		}
 	}
 
 	// Deployment is successful, we proceed with checking the actual Azure
@@ -46,13 +54,8 @@ func (r *Resource) ensureSubnetReadyCondition(ctx context.Context, azureMachineP
 		return microerror.Mask(err)
 	}
 
-	azureCluster, err := helpers.GetAzureClusterFromMetadata(ctx, r.ctrlClient, azureMachinePool.ObjectMeta)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	subnetName := azureMachinePool.Name
-	subnet, err := subnetsClient.Get(ctx, azureCluster.Name, azureCluster.Spec.NetworkSpec.Vnet.Name, subnetName, "")
+	subnetName := key.NodePoolSubnetName(azureMachinePool)
+	subnet, err := subnetsClient.Get(ctx, key.AzureClusterVnetResourceGroup(azureCluster), azureCluster.Spec.NetworkSpec.Vnet.Name, subnetName, "")
 	if IsNotFound(err) {
 		r.setSubnetNotFound(ctx, azureMachinePool, subnetName, azureconditions.SubnetReadyCondition)
 		return nil
@@ -22,6 +22,12 @@ func (r *Resource) EnsureCreated(ctx context.Context, cr interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	// ensure ScaleStrategyValid condition
+	r.ensureScaleStrategyValidCondition(ctx, &azureMachinePool)
+
+	// ensure DisksValid condition
+	r.ensureDisksValidCondition(ctx, &azureMachinePool)
+
 	err = r.ctrlClient.Status().Update(ctx, &azureMachinePool)
 	if apierrors.IsConflict(err) {
 		r.logger.Debugf(ctx, "conflict trying to save object in k8s API concurrently")
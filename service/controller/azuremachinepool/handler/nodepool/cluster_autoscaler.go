@@ -3,18 +3,42 @@ package nodepool
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
+	apiextensionslabels "github.com/giantswarm/apiextensions/v6/pkg/label"
 	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
 const (
+	ClusterAutoscalerTagsDriftedReason = "ClusterAutoscalerTagsDrifted"
+
 	clusterAutoscalerEnabledTagName = "cluster-autoscaler-enabled"
+	clusterAutoscalerNameTagName    = "cluster-autoscaler-name"
+	clusterAutoscalerMinTagName     = "min"
+	clusterAutoscalerMaxTagName     = "max"
+
+	// clusterAutoscalerTagsCheckInterval is how often the cluster autoscaler
+	// tags of a node pool are checked for drift while its sizes do not change.
+	clusterAutoscalerTagsCheckInterval = 30 * time.Minute
+
+	// scaleDownDisabledAnnotation stops the cluster autoscaler from removing
+	// the annotated node.
+	scaleDownDisabledAnnotation = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
 )
 
 func (r *Resource) disableClusterAutoscaler(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) error {
@@ -38,18 +62,20 @@ func (r *Resource) disableClusterAutoscaler(ctx context.Context, azureMachinePoo
 	return nil
 }
 
+// enableClusterAutoscaler hands the node pool back to the cluster autoscaler
+// once it is not upgraded anymore. The autoscaler is only enabled for node
+// pools whose minimum and maximum size differ.
 func (r *Resource) enableClusterAutoscaler(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) error {
-	resourceGroup := key.ClusterID(&azureMachinePool)
 	vmssName := key.NodePoolVMSSName(&azureMachinePool)
 
 	r.Logger.LogCtx(ctx, "level", "info", "message", fmt.Sprintf("Enabling cluster autoscaler for nodepool %s", vmssName))
 
-	virtualMachineScaleSetsClient, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	desired, err := r.getDesiredClusterAutoscalerTags(ctx, azureMachinePool)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	err = setClusterAutoscalerEnabled(ctx, virtualMachineScaleSetsClient, resourceGroup, vmssName, true)
+	_, err = r.ensureClusterAutoscalerTags(ctx, azureMachinePool, desired)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -59,6 +85,194 @@ func (r *Resource) enableClusterAutoscaler(ctx context.Context, azureMachinePool
 	return nil
 }
 
+// reconcileClusterAutoscalerTags repairs the cluster autoscaler tags of the
+// node pool VMSS while the node pool is not upgraded, e.g. tags left behind
+// by an aborted upgrade or min and max sizes changed in the MachinePool, and
+// re-enables scale down of nodes left protected by an upgrade. The VMSS and
+// nodes are only checked when the MachinePool sizes changed or the last check
+// is older than clusterAutoscalerTagsCheckInterval.
+func (r *Resource) reconcileClusterAutoscalerTags(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, wcClient ctrlclient.Client) error {
+	desired, err := r.getDesiredClusterAutoscalerTags(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	checkKey := string(azureMachinePool.UID)
+	fingerprint := formatTags(desired)
+	now := r.clock.Now()
+	if !r.clusterAutoscalerChecks.due(checkKey, fingerprint, now) {
+		r.Logger.Debugf(ctx, "cluster autoscaler tags of VMSS %#q were checked recently", key.NodePoolVMSSName(&azureMachinePool))
+		return nil
+	}
+
+	drifted, err := r.ensureClusterAutoscalerTags(ctx, azureMachinePool, desired)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if len(drifted) > 0 {
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, ClusterAutoscalerTagsDriftedReason, "Cluster autoscaler tags %v of VMSS %s drifted from the MachinePool and were repaired", drifted, key.NodePoolVMSSName(&azureMachinePool))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err = r.enableLeftoverNodesScaleDown(ctx, wcClient, azureMachinePool.Name)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.clusterAutoscalerChecks.done(checkKey, fingerprint, now)
+
+	return nil
+}
+
+// getDesiredClusterAutoscalerTags returns the cluster autoscaler tags of the
+// node pool VMSS defined by the owner MachinePool.
+func (r *Resource) getDesiredClusterAutoscalerTags(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) (map[string]string, error) {
+	machinePool, err := r.getOwnerMachinePool(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if machinePool == nil {
+		return nil, microerror.Mask(ownerReferenceNotSet)
+	}
+
+	return desiredClusterAutoscalerTags(azureMachinePool, *machinePool), nil
+}
+
+// ensureClusterAutoscalerTags updates the cluster autoscaler tags of the node
+// pool VMSS to the desired tags and returns the names of the tags which were
+// updated.
+func (r *Resource) ensureClusterAutoscalerTags(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, desired map[string]string) ([]string, error) {
+	resourceGroup := key.ClusterID(&azureMachinePool)
+	vmssName := key.NodePoolVMSSName(&azureMachinePool)
+
+	virtualMachineScaleSetsClient, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	vmss, err := virtualMachineScaleSetsClient.Get(ctx, resourceGroup, vmssName)
+	if IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	drifted := driftedTags(vmss.Tags, desired)
+	if len(drifted) == 0 {
+		return nil, nil
+	}
+
+	r.Logger.Debugf(ctx, "updating cluster autoscaler tags %v of VMSS %#q", drifted, vmssName)
+
+	tags := vmss.Tags
+	if tags == nil {
+		tags = map[string]*string{}
+	}
+	for name, value := range desired {
+		tags[name] = to.StringPtr(value)
+	}
+
+	params := compute.VirtualMachineScaleSetUpdate{
+		Tags: tags,
+	}
+
+	_, err = virtualMachineScaleSetsClient.Update(ctx, resourceGroup, vmssName, params)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "updated cluster autoscaler tags %v of VMSS %#q", drifted, vmssName)
+
+	return drifted, nil
+}
+
+// desiredClusterAutoscalerTags returns the VMSS tags the cluster autoscaler
+// uses to discover the node pool and its size limits. The limits include the
+// instances added while spot node pools lack capacity.
+func desiredClusterAutoscalerTags(azureMachinePool capzexp.AzureMachinePool, machinePool capiexp.MachinePool) map[string]string {
+	minReplicas := key.NodePoolScalingMinReplicas(&machinePool)
+	maxReplicas := key.NodePoolScalingMaxReplicas(&machinePool)
+
+	return map[string]string{
+		clusterAutoscalerEnabledTagName: strconv.FormatBool(minReplicas != maxReplicas),
+		clusterAutoscalerNameTagName:    key.ClusterID(&azureMachinePool),
+		clusterAutoscalerMinTagName:     strconv.Itoa(int(minReplicas)),
+		clusterAutoscalerMaxTagName:     strconv.Itoa(int(maxReplicas)),
+	}
+}
+
+// driftedTags returns the sorted names of the desired tags which are missing
+// or have a different value in the current tags.
+func driftedTags(current map[string]*string, desired map[string]string) []string {
+	var drifted []string
+	for name, value := range desired {
+		v, ok := current[name]
+		if !ok || v == nil || *v != value {
+			drifted = append(drifted, name)
+		}
+	}
+	sort.Strings(drifted)
+
+	return drifted
+}
+
+func formatTags(tags map[string]string) string {
+	var values []string
+	for name, value := range tags {
+		values = append(values, name+"="+value)
+	}
+	sort.Strings(values)
+
+	return strings.Join(values, ",")
+}
+
+// clusterAutoscalerChecks remembers when the cluster autoscaler tags of each
+// node pool were last checked and for which desired tags, so that the VMSS is
+// not read in every reconciliation loop.
+type clusterAutoscalerChecks struct {
+	mutex  sync.Mutex
+	checks map[string]clusterAutoscalerCheck
+}
+
+type clusterAutoscalerCheck struct {
+	checked time.Time
+	desired string
+}
+
+func newClusterAutoscalerChecks() *clusterAutoscalerChecks {
+	return &clusterAutoscalerChecks{
+		checks: map[string]clusterAutoscalerCheck{},
+	}
+}
+
+// due returns true when the node pool was not checked for the given desired
+// tags yet or its last check is older than the check interval.
+func (c *clusterAutoscalerChecks) due(key, desired string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	check, ok := c.checks[key]
+
+	return !ok || check.desired != desired || now.Sub(check.checked) >= clusterAutoscalerTagsCheckInterval
+}
+
+func (c *clusterAutoscalerChecks) done(key, desired string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[key] = clusterAutoscalerCheck{checked: now, desired: desired}
+}
+
+func (c *clusterAutoscalerChecks) forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.checks, key)
+}
+
 func setClusterAutoscalerEnabled(ctx context.Context, virtualMachineScaleSetsClient *compute.VirtualMachineScaleSetsClient, resourceGroup, vmssName string, enabled bool) error {
 	vmss, err := virtualMachineScaleSetsClient.Get(ctx, resourceGroup, vmssName)
 	if err != nil {
@@ -79,3 +293,68 @@ func setClusterAutoscalerEnabled(ctx context.Context, virtualMachineScaleSetsCli
 
 	return nil
 }
+
+// setNodeScaleDownDisabled stops or allows the cluster autoscaler to remove
+// the given node while it is replaced. Only annotations set by the node pool
+// upgrade are removed again. Nodes which do not exist are ignored.
+func setNodeScaleDownDisabled(ctx context.Context, wcClient ctrlclient.Client, nodeName string, disabled bool) error {
+	node := corev1.Node{}
+	err := wcClient.Get(ctx, ctrlclient.ObjectKey{Name: nodeName}, &node)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p := ctrlclient.MergeFrom(node.DeepCopy())
+	if disabled {
+		if node.Annotations[scaleDownDisabledAnnotation] == "true" {
+			return nil
+		}
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[scaleDownDisabledAnnotation] = "true"
+		node.Annotations[annotation.NodeScaleDownDisabledByUpgrade] = "true"
+	} else {
+		if _, ok := node.Annotations[annotation.NodeScaleDownDisabledByUpgrade]; !ok {
+			return nil
+		}
+		delete(node.Annotations, scaleDownDisabledAnnotation)
+		delete(node.Annotations, annotation.NodeScaleDownDisabledByUpgrade)
+	}
+
+	err = wcClient.Patch(ctx, &node, p)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// enableLeftoverNodesScaleDown allows the cluster autoscaler to remove nodes of
+// the node pool again which an upgrade protected but did not replace.
+func (r *Resource) enableLeftoverNodesScaleDown(ctx context.Context, wcClient ctrlclient.Client, nodePoolID string) error {
+	nodeList := corev1.NodeList{}
+	err := wcClient.List(ctx, &nodeList, ctrlclient.MatchingLabels{apiextensionslabels.MachinePool: nodePoolID})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, node := range nodeList.Items {
+		if _, ok := node.Annotations[annotation.NodeScaleDownDisabledByUpgrade]; !ok {
+			continue
+		}
+
+		r.Logger.Debugf(ctx, "enabling cluster autoscaler scale down of node %#q left behind by an upgrade", node.Name)
+
+		err = setNodeScaleDownDisabled(ctx, wcClient, node.Name, false)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
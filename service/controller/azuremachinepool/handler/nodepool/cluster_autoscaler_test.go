@@ -0,0 +1,275 @@
+package nodepool
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	apiextensionsannotations "github.com/giantswarm/apiextensions/v6/pkg/annotation"
+	"github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_desiredClusterAutoscalerTags(t *testing.T) {
+	azureMachinePool := capzexp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "np001",
+			Labels: map[string]string{label.Cluster: "c0001"},
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		annotations  map[string]string
+		replicas     int32
+		expectedTags map[string]string
+	}{
+		{
+			name: "case 0: autoscaled node pool",
+			annotations: map[string]string{
+				apiextensionsannotations.NodePoolMinSize: "3",
+				apiextensionsannotations.NodePoolMaxSize: "10",
+			},
+			replicas: 5,
+			expectedTags: map[string]string{
+				"cluster-autoscaler-enabled": "true",
+				"cluster-autoscaler-name":    "c0001",
+				"min":                        "3",
+				"max":                        "10",
+			},
+		},
+		{
+			name:     "case 1: fixed size node pool",
+			replicas: 4,
+			expectedTags: map[string]string{
+				"cluster-autoscaler-enabled": "false",
+				"cluster-autoscaler-name":    "c0001",
+				"min":                        "4",
+				"max":                        "4",
+			},
+		},
+		{
+			name: "case 2: autoscaled node pool with spot fallback instances",
+			annotations: map[string]string{
+				apiextensionsannotations.NodePoolMinSize:        "3",
+				apiextensionsannotations.NodePoolMaxSize:        "10",
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+			},
+			replicas: 5,
+			expectedTags: map[string]string{
+				"cluster-autoscaler-enabled": "true",
+				"cluster-autoscaler-name":    "c0001",
+				"min":                        "5",
+				"max":                        "10",
+			},
+		},
+		{
+			name:     "case 3: fixed size node pool with spot fallback instances",
+			replicas: 4,
+			annotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+				annotation.SpotFallbackReplicasPrefix + "spot2": "1",
+			},
+			expectedTags: map[string]string{
+				"cluster-autoscaler-enabled": "false",
+				"cluster-autoscaler-name":    "c0001",
+				"min":                        "7",
+				"max":                        "7",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			replicas := tc.replicas
+			machinePool := capiexp.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+				Spec:       capiexp.MachinePoolSpec{Replicas: &replicas},
+			}
+
+			tags := desiredClusterAutoscalerTags(azureMachinePool, machinePool)
+
+			if !cmp.Equal(tags, tc.expectedTags) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedTags, tags))
+			}
+		})
+	}
+}
+
+func Test_driftedTags(t *testing.T) {
+	desired := map[string]string{
+		"cluster-autoscaler-enabled": "true",
+		"cluster-autoscaler-name":    "c0001",
+		"min":                        "3",
+		"max":                        "10",
+	}
+
+	testCases := []struct {
+		name            string
+		current         map[string]*string
+		expectedDrifted []string
+	}{
+		{
+			name: "case 0: in sync",
+			current: map[string]*string{
+				"cluster-autoscaler-enabled": to.StringPtr("true"),
+				"cluster-autoscaler-name":    to.StringPtr("c0001"),
+				"min":                        to.StringPtr("3"),
+				"max":                        to.StringPtr("10"),
+				"other":                      to.StringPtr("value"),
+			},
+		},
+		{
+			name: "case 1: left disabled by an aborted upgrade and max changed",
+			current: map[string]*string{
+				"cluster-autoscaler-enabled": to.StringPtr("false"),
+				"cluster-autoscaler-name":    to.StringPtr("c0001"),
+				"min":                        to.StringPtr("3"),
+				"max":                        to.StringPtr("5"),
+			},
+			expectedDrifted: []string{"cluster-autoscaler-enabled", "max"},
+		},
+		{
+			name:            "case 2: no tags",
+			expectedDrifted: []string{"cluster-autoscaler-enabled", "cluster-autoscaler-name", "max", "min"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			drifted := driftedTags(tc.current, desired)
+
+			if !cmp.Equal(drifted, tc.expectedDrifted) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedDrifted, drifted))
+			}
+		})
+	}
+}
+
+func Test_clusterAutoscalerChecks(t *testing.T) {
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	desired := formatTags(map[string]string{"min": "3", "max": "10"})
+	if desired != "max=10,min=3" {
+		t.Fatalf("desired == %q, want %q", desired, "max=10,min=3")
+	}
+
+	c := newClusterAutoscalerChecks()
+	if !c.due("uid", desired, now) {
+		t.Fatalf("expected unchecked node pool to be due")
+	}
+
+	c.done("uid", desired, now)
+	if c.due("uid", desired, now.Add(time.Minute)) {
+		t.Fatalf("expected recently checked node pool not to be due")
+	}
+	if !c.due("uid", "max=10,min=5", now.Add(time.Minute)) {
+		t.Fatalf("expected node pool with changed sizes to be due")
+	}
+	if !c.due("uid", desired, now.Add(clusterAutoscalerTagsCheckInterval)) {
+		t.Fatalf("expected node pool checked long ago to be due")
+	}
+
+	c.forget("uid")
+	if !c.due("uid", desired, now.Add(time.Minute)) {
+		t.Fatalf("expected forgotten node pool to be due")
+	}
+}
+
+func Test_setNodeScaleDownDisabled(t *testing.T) {
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		disabled            bool
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:     "case 0: disable scale down",
+			disabled: true,
+			expectedAnnotations: map[string]string{
+				scaleDownDisabledAnnotation:               "true",
+				annotation.NodeScaleDownDisabledByUpgrade: "true",
+			},
+		},
+		{
+			name: "case 1: enable scale down disabled by upgrade",
+			annotations: map[string]string{
+				scaleDownDisabledAnnotation:               "true",
+				annotation.NodeScaleDownDisabledByUpgrade: "true",
+			},
+			disabled:            false,
+			expectedAnnotations: nil,
+		},
+		{
+			name: "case 2: scale down disabled by the user is kept",
+			annotations: map[string]string{
+				scaleDownDisabledAnnotation: "true",
+			},
+			disabled: false,
+			expectedAnnotations: map[string]string{
+				scaleDownDisabledAnnotation: "true",
+			},
+		},
+		{
+			name: "case 3: scale down disabled by the user is not claimed",
+			annotations: map[string]string{
+				scaleDownDisabledAnnotation: "true",
+			},
+			disabled: true,
+			expectedAnnotations: map[string]string{
+				scaleDownDisabledAnnotation: "true",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "np001-000000",
+					Annotations: tc.annotations,
+				},
+			}
+			wcClient := fake.NewClientBuilder().WithObjects(node).Build()
+
+			err := setNodeScaleDownDisabled(context.Background(), wcClient, node.Name, tc.disabled)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			updated := corev1.Node{}
+			err = wcClient.Get(context.Background(), client.ObjectKey{Name: node.Name}, &updated)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if len(updated.Annotations) == 0 {
+				updated.Annotations = nil
+			}
+			if !cmp.Equal(updated.Annotations, tc.expectedAnnotations) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedAnnotations, updated.Annotations))
+			}
+		})
+	}
+
+	// Missing nodes are ignored.
+	err := setNodeScaleDownDisabled(context.Background(), fake.NewClientBuilder().Build(), "np001-000001", true)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"github.com/giantswarm/microerror"
 	"sigs.k8s.io/cluster-api/util"
 
-	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
 	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 
@@ -35,7 +36,7 @@ func (r *Resource) cordonOldWorkerInstances(ctx context.Context, obj interface{}
 		return TerminateOldWorkerInstances, nil
 	}
 
-	oldInstances, _, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
+	oldInstances, newInstances, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
 	if err != nil {
 		return currentState, microerror.Mask(err)
 	}
@@ -43,7 +44,40 @@ func (r *Resource) cordonOldWorkerInstances(ctx context.Context, obj interface{}
 	if len(oldInstances) > 0 {
 		r.Logger.Debugf(ctx, "There are still %d workers from the previous release running", len(oldInstances))
 
-		tenantClusterK8sClients, err := r.tenantClientFactory.GetAllClients(ctx, cluster)
+		// The batch is saved before it is cordoned, so that the following
+		// states drain and terminate the same instances even when the zones
+		// covered by new instances change in the meantime.
+		batch, found := savedRollingUpdateBatch(azureMachinePool, oldInstances)
+		if !found || len(batch) == 0 {
+			var zones []string
+			batch, zones, err = r.getRollingUpdateBatch(ctx, azureMachinePool, oldInstances, newInstances)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
+			// Old instances in zones without new instances are only cordoned
+			// once all other old instances are replaced and the zones stayed
+			// uncovered for a while.
+			if len(zones) > 0 {
+				entered, ok := stateEnteredTimestamp(azureMachinePool)
+				if !ok || r.clock.Now().Sub(entered) < zoneCoverageTimeout {
+					r.Logger.Debugf(ctx, "waiting for new worker instances in zones %v before cordoning old worker instances there", zones)
+					return currentState, nil
+				}
+
+				err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, ZoneNotCoveredReason, "No new instances in zones %v after %s, replacing old instances there anyway", zones, zoneCoverageTimeout)
+				if err != nil {
+					return currentState, microerror.Mask(err)
+				}
+			}
+
+			err = r.saveRollingUpdateBatch(ctx, azureMachinePool, batch)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+		}
+
+		nodeDrainer, err := r.getNodeDrainer(ctx, cluster, azureMachinePool)
 		if tenantcluster.IsAPINotAvailableError(err) {
 			r.Logger.Debugf(ctx, "tenant API not available yet")
 			r.Logger.Debugf(ctx, "canceling resource")
@@ -53,16 +87,26 @@ func (r *Resource) cordonOldWorkerInstances(ctx context.Context, obj interface{}
 			return currentState, microerror.Mask(err)
 		}
 
-		nodeDrainer, err := drainer.New(drainer.Config{
-			Logger:    r.Logger,
-			WCClients: tenantClusterK8sClients,
-		})
-		if err != nil {
+		tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
 			return currentState, microerror.Mask(err)
 		}
 
-		for _, instance := range oldInstances {
+		for _, instance := range batch {
 			nodeName := strings.ToLower(*instance.OsProfile.ComputerName)
+
+			// The cluster autoscaler must not remove nodes while they are
+			// replaced.
+			err = setNodeScaleDownDisabled(ctx, tenantClusterK8sClient, nodeName, true)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
 			r.Logger.Debugf(ctx, "Cordoning node %q (instance name %q)", nodeName, *instance.Name)
 			err = nodeDrainer.CordonNode(ctx, nodeName)
 			if err != nil {
@@ -72,6 +116,18 @@ func (r *Resource) cordonOldWorkerInstances(ctx context.Context, obj interface{}
 			r.Logger.Debugf(ctx, "Cordoned node %q", nodeName)
 		}
 
+		// Pre-drain hooks are started right away, draining waits for them
+		// to complete.
+		_, err = r.ensureLifecycleHooks(ctx, azureMachinePool, cluster, lifecyclehook.PreDrain, batch)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
 		return DrainOldWorkerInstances, nil
 	}
 
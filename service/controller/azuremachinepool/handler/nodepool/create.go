@@ -30,7 +30,14 @@ func (r *Resource) createStateMachine() state.Machine {
 			CordonOldWorkerInstances:    r.cordonOldWorkerInstances,
 			DrainOldWorkerInstances:     r.drainOldWorkerInstances,
 			TerminateOldWorkerInstances: r.terminateOldWorkersTransition,
+
+			InPlaceCordonWorkerInstance:  r.inPlaceCordonWorkerInstanceTransition,
+			InPlaceDrainWorkerInstance:   r.inPlaceDrainWorkerInstanceTransition,
+			InPlaceUpgradeWorkerInstance: r.inPlaceUpgradeWorkerInstanceTransition,
+			InPlaceWaitForWorkerInstance: r.inPlaceWaitForWorkerInstanceTransition,
 		},
+		Deadlines: r.stateDeadlines,
+		Clock:     r.clock,
 	}
 
 	return sm
@@ -43,6 +50,10 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	// The instance counts found by the state machine are reported as upgrade
+	// progress.
+	ctx = withInstanceCounts(ctx)
+
 	upgrading, err := r.isMasterUpgrading(ctx, &azureMachinePool)
 	if err != nil {
 		return microerror.Mask(err)
@@ -64,8 +75,43 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		}
 		currentState = state.State(s)
 
+		stop, err := r.ensureUpgradeControl(ctx, azureMachinePool, currentState)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if stop {
+			err = r.reportUpgradeProgress(ctx, azureMachinePool, currentState)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			r.Logger.Debugf(ctx, "canceling resource")
+			return nil
+		}
+
+		enteredAt, ok := stateEnteredTimestamp(azureMachinePool)
+		if !ok && currentState != DeploymentUninitialized {
+			// The state was entered before its entry time was recorded, the
+			// deadline is checked from now on.
+			err = r.saveStateEnteredTimestamp(ctx, azureMachinePool)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
 		r.Logger.Debugf(ctx, "current state: %s", currentState)
-		newState, err = r.StateMachine.Execute(ctx, obj, currentState)
+		var deadlineExceeded bool
+		newState, deadlineExceeded, err = r.StateMachine.ExecuteWithDeadline(ctx, obj, currentState, enteredAt)
+		if deadlineExceeded {
+			// The timeout policy is applied even when the transition failed,
+			// the transition error is returned afterwards so that it is
+			// still retried and reported.
+			handleErr := r.handleStateDeadlineExceeded(ctx, azureMachinePool, currentState, enteredAt)
+			if handleErr != nil {
+				return microerror.Mask(handleErr)
+			}
+		}
+
 		if state.IsUnkownStateError(err) {
 			// This can happen if there is a race condition with a previous version of the azure operator
 			// or if the node pool at upgrade time was in a state that doesn't exists any more in this azure
@@ -93,12 +139,23 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 			return microerror.Mask(err)
 		}
 		r.Logger.Debugf(ctx, "set resource status to %#q", newState)
+
+		err = r.ensureUpgradeWithinDeadlineCondition(ctx, azureMachinePool)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
 		r.Logger.Debugf(ctx, "canceling reconciliation")
 		reconciliationcanceledcontext.SetCanceled(ctx)
 	} else {
 		r.Logger.Debugf(ctx, "no state change")
 	}
 
+	err = r.reportUpgradeProgress(ctx, azureMachinePool, newState)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
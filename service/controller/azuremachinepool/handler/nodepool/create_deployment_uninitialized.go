@@ -15,11 +15,17 @@ import (
 	"sigs.k8s.io/cluster-api/util"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/nodepool/template"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
+const (
+	InvalidDiskConfigReason = "InvalidDiskConfig"
+)
+
 func (r *Resource) deploymentUninitializedTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
 	azureMachinePool, err := key.ToAzureMachinePool(obj)
 	if err != nil {
@@ -101,6 +107,14 @@ func (r *Resource) deploymentUninitializedTransition(ctx context.Context, obj in
 	} else if IsSubnetNotReadyError(err) {
 		r.Logger.Debugf(ctx, "subnet is not Ready, it's probably still being created")
 		r.Logger.Debugf(ctx, microerror.JSON(err))
+		r.Logger.Debugf(ctx, "canceling resource")
+		return currentState, nil
+	} else if key.IsInvalidDiskConfig(err) || IsEphemeralOSDiskNotSupported(err) {
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, InvalidDiskConfigReason, "Node pool disks can't be deployed: %s", err.Error())
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
 		r.Logger.Debugf(ctx, "canceling resource")
 		return currentState, nil
 	} else if err != nil {
@@ -138,17 +152,21 @@ func (r *Resource) deploymentUninitializedTransition(ctx context.Context, obj in
 			}
 		}
 
-		// When customer is only scaling the cluster,
-		// we don't need to move to the next state of the state machine which will rollout all the nodes.
-		numberOfChangedParameters := len(changes)
-		deploymentNeedsToBeSubmitted = numberOfChangedParameters > 0
-		nodesNeedToBeRolled = numberOfChangedParameters > 1 || numberOfChangedParameters == 1 && !contains(changes, "scaling")
+		// When customer is only scaling the cluster or the cluster autoscaler
+		// node template changed, we don't need to move to the next state of
+		// the state machine which will rollout all the nodes.
+		deploymentNeedsToBeSubmitted = len(changes) > 0
+		nodesNeedToBeRolled = changesRequireRollout(changes)
 		r.Logger.Debugf(ctx, "Checking if deployment is out of date and needs to be re-submitted", "deploymentNeedsToBeSubmitted", deploymentNeedsToBeSubmitted, "nodesNeedToBeRolled", nodesNeedToBeRolled, "changedParameters", changes)
 	}
 
 	if deploymentNeedsToBeSubmitted {
 		r.Logger.Debugf(ctx, "template or parameters changed")
 
+		// The deployment sets the VMSS tags, so they are checked again once
+		// it succeeded.
+		r.clusterAutoscalerChecks.forget(string(azureMachinePool.UID))
+
 		_, err = r.ensureDeployment(ctx, deploymentsClient, desiredDeployment, &azureMachinePool)
 		if err != nil {
 			return currentState, microerror.Mask(err)
@@ -201,6 +219,22 @@ func (r *Resource) deploymentUninitializedTransition(ctx context.Context, obj in
 			return currentState, microerror.Mask(err)
 		}
 
+		if *currentDeployment.Properties.ProvisioningState == "Succeeded" {
+			tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+			if tenantcluster.IsAPINotAvailableError(err) {
+				r.Logger.Debugf(ctx, "tenant API not available yet")
+				r.Logger.Debugf(ctx, "canceling resource")
+				return currentState, nil
+			} else if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
+			err = r.reconcileClusterAutoscalerTags(ctx, azureMachinePool, tenantClusterK8sClient)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+		}
+
 		r.Logger.Debugf(ctx, "canceling resource")
 		return currentState, nil
 	}
@@ -250,6 +284,21 @@ func (r *Resource) saveAzureIDsInCR(ctx context.Context, virtualMachineScaleSetV
 	return nil
 }
 
+// changesRequireRollout returns true if any of the given deployment changes
+// only applies to new instances.
+func changesRequireRollout(changes []string) bool {
+	for _, c := range changes {
+		switch c {
+		case "scaling", "clusterAutoscalerNodeTemplate":
+			// VMSS tags and capacity apply to existing instances.
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
 func contains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -23,9 +23,14 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
-// The goal of scaleUpWorkerVMSSTransition is to double the desired number
-// of nodes in worker VMSS in order to provide 1:1 mapping between new
-// up-to-date nodes when draining and terminating old nodes.
+const (
+	provisioningStateDeleting = "Deleting"
+)
+
+// The goal of scaleUpWorkerVMSSTransition is to add the surge of the current
+// rolling update batch to the worker VMSS in order to provide new up-to-date
+// nodes before draining and terminating old nodes. With the default settings
+// the number of nodes is doubled and all old nodes are replaced at once.
 // This will be done in subsequent reconciliation loops to avoid hitting the
 // VMSS api too hard.
 func (r *Resource) scaleUpWorkerVMSSTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
@@ -70,7 +75,7 @@ func (r *Resource) scaleUpWorkerVMSSTransition(ctx context.Context, obj interfac
 		}
 	}
 
-	strategy := scalestrategy.Staircase{}
+	strategy, strategyName := r.getScaleStrategy(ctx, azureMachinePool)
 
 	// Ensure the deployment is successful before we move on with scaling.
 	currentDeployment, err := deploymentsClient.Get(ctx, key.ClusterID(&azureMachinePool), key.NodePoolDeploymentName(&azureMachinePool))
@@ -114,6 +119,11 @@ func (r *Resource) scaleUpWorkerVMSSTransition(ctx context.Context, obj interfac
 		return DeploymentUninitialized, nil
 	}
 
+	if key.NodePoolInPlaceUpgrade(&azureMachinePool) {
+		r.Logger.Debugf(ctx, "Node Pool is upgraded in place, no need to scale up the VMSS")
+		return InPlaceCordonWorkerInstance, nil
+	}
+
 	oldInstances, newInstances, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
 	if tenantcluster.IsAPINotAvailableError(err) {
 		r.Logger.Debugf(ctx, "tenant API not available yet")
@@ -124,15 +134,45 @@ func (r *Resource) scaleUpWorkerVMSSTransition(ctx context.Context, obj interfac
 		return currentState, microerror.Mask(err)
 	}
 
-	desiredWorkerCount := int64(len(oldInstances) * 2)
-	r.Logger.Debugf(ctx, "The desired number of workers is: %d", desiredWorkerCount)
-
-	if desiredWorkerCount == 0 {
+	if len(oldInstances) == 0 {
 		// The node pool is empty, the upgrade process can stop here.
 		r.Logger.Debugf(ctx, "No outdated instances found: no need to roll out nodes")
+
+		err = r.removeRollingUpdateBatch(ctx, azureMachinePool)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		err = r.removeRollingUpdateDesiredReplicas(ctx, azureMachinePool)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
 		return DeploymentUninitialized, nil
 	}
 
+	// The node pool size is recorded when the rolling update starts, so that
+	// every batch is computed against the same size.
+	desiredReplicas, found := rollingUpdateDesiredReplicas(azureMachinePool)
+	if !found {
+		desiredReplicas = len(oldInstances) + len(newInstances)
+
+		err = r.saveRollingUpdateDesiredReplicas(ctx, azureMachinePool, desiredReplicas)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+	}
+
+	batch, err := computeRollingUpdateBatch(desiredReplicas, len(oldInstances), key.NodePoolMaxSurge(machinePool, &azureMachinePool), key.NodePoolMaxUnavailable(machinePool, &azureMachinePool))
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "Rolling update batch: %d outdated instances out of %d, surge of %d instances", batch.Size, len(oldInstances), batch.Surge)
+
+	desiredWorkerCount := int64(desiredReplicas + batch.Surge)
+	r.Logger.Debugf(ctx, "The desired number of workers is: %d", desiredWorkerCount)
+
 	if desiredWorkerCount > int64(len(oldInstances)+len(newInstances)) {
 		// Disable cluster autoscaler for this nodepool.
 		err = r.disableClusterAutoscaler(ctx, azureMachinePool)
@@ -145,7 +185,14 @@ func (r *Resource) scaleUpWorkerVMSSTransition(ctx context.Context, obj interfac
 			return DeploymentUninitialized, microerror.Mask(err)
 		}
 
-		r.Logger.Debugf(ctx, "scaled worker VMSS to %d nodes (desired count is %d)", newCount, desiredWorkerCount)
+		r.Logger.Debugf(ctx, "scaled worker VMSS to %d nodes using %s strategy (desired count is %d)", newCount, strategyName, desiredWorkerCount)
+
+		if strategyName == scalestrategy.NameTimeBased && newCount > int64(len(oldInstances)+len(newInstances)) {
+			err = r.saveScaleStrategyLastScaleTimestamp(ctx, azureMachinePool, r.clock.Now())
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+		}
 
 		// Let's stay in the current state.
 		return currentState, nil
@@ -196,6 +243,11 @@ func (r *Resource) splitInstancesByUpdatedStatus(ctx context.Context, azureMachi
 	var newInstances []compute.VirtualMachineScaleSetVM
 	{
 		for _, i := range allWorkerInstances {
+			if i.ProvisioningState != nil && *i.ProvisioningState == provisioningStateDeleting {
+				// Instance is being terminated already, it is neither old nor new.
+				continue
+			}
+
 			old, err := r.isWorkerInstanceFromPreviousRelease(ctx, cluster, azureMachinePool, i)
 			if err != nil {
 				return nil, nil, microerror.Mask(err)
@@ -212,6 +264,8 @@ func (r *Resource) splitInstancesByUpdatedStatus(ctx context.Context, azureMachi
 		}
 	}
 
+	recordInstanceCounts(ctx, len(oldInstances), len(newInstances))
+
 	return oldInstances, newInstances, nil
 }
 
@@ -306,6 +360,12 @@ func (r *Resource) scaleVMSS(ctx context.Context, azureMachinePool capzexp.Azure
 	}
 
 	computedCount := scaleStrategy.GetNodeCount(*vmss.Sku.Capacity, desiredNodeCount)
+	if computedCount == *vmss.Sku.Capacity {
+		// Nothing to do, e.g. the scale strategy is waiting before adding
+		// more instances.
+		return computedCount, nil
+	}
+
 	*vmss.Sku.Capacity = computedCount
 	res, err := virtualMachineScaleSetsClient.CreateOrUpdate(ctx, resourceGroup, vmssName, vmss)
 	if err != nil {
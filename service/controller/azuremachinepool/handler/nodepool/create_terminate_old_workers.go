@@ -6,9 +6,14 @@ import (
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/giantswarm/microerror"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/scalestrategy"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmsscheck"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
@@ -28,7 +33,7 @@ func (r *Resource) terminateOldWorkersTransition(ctx context.Context, obj interf
 		return currentState, nil
 	}
 
-	oldInstances, _, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
+	oldInstances, newInstances, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
 	if err != nil {
 		return currentState, microerror.Mask(err)
 	}
@@ -36,35 +41,62 @@ func (r *Resource) terminateOldWorkersTransition(ctx context.Context, obj interf
 	if len(oldInstances) > 0 {
 		r.Logger.Debugf(ctx, "There are still %d workers from the previous release running", len(oldInstances))
 
-		r.Logger.Debugf(ctx, "terminating %d old worker instances", len(oldInstances))
+		batch, found := savedRollingUpdateBatch(azureMachinePool, oldInstances)
+		if !found {
+			if azureMachinePool.Spec.Template.SpotVMOptions == nil {
+				// The batch is only known once it is cordoned and drained.
+				r.Logger.Debugf(ctx, "rolling update batch was not saved, cordoning it first")
+				return CordonOldWorkerInstances, nil
+			}
 
-		var ids compute.VirtualMachineScaleSetVMInstanceRequiredIDs
-		{
-			var strIds []string
-			for _, i := range oldInstances {
-				strIds = append(strIds, *i.InstanceID)
+			// Spot instances are neither cordoned nor drained, the batch is
+			// taken when terminating them.
+			batch, _, err = r.getRollingUpdateBatch(ctx, azureMachinePool, oldInstances, newInstances)
+			if err != nil {
+				return currentState, microerror.Mask(err)
 			}
 
-			ids = compute.VirtualMachineScaleSetVMInstanceRequiredIDs{
-				InstanceIds: to.StringSlicePtr(strIds),
+			err = r.saveRollingUpdateBatch(ctx, azureMachinePool, batch)
+			if err != nil {
+				return currentState, microerror.Mask(err)
 			}
 		}
 
-		virtualMachineScaleSetsClient, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
-		if err != nil {
-			return currentState, microerror.Mask(err)
+		if len(batch) == 0 {
+			// All instances of the batch are being deleted, the iteration
+			// is completed.
+			err = r.removeRollingUpdateBatch(ctx, azureMachinePool)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
+			r.Logger.Debugf(ctx, "%d old worker instances left, starting next rolling update batch", len(oldInstances))
+			return ScaleUpWorkerVMSS, nil
 		}
 
-		res, err := virtualMachineScaleSetsClient.DeleteInstances(ctx, key.ClusterID(&azureMachinePool), key.NodePoolVMSSName(&azureMachinePool), ids)
-		if err != nil {
+		hooksCompleted, err := r.ensureLifecycleHooks(ctx, azureMachinePool, cluster, lifecyclehook.PostDrain, batch)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
 			return currentState, microerror.Mask(err)
 		}
-		_, err = virtualMachineScaleSetsClient.DeleteInstancesResponder(res.Response())
+
+		if !hooksCompleted {
+			r.Logger.Debugf(ctx, "waiting for post-drain hooks to complete")
+			return currentState, nil
+		}
+
+		r.Logger.Debugf(ctx, "terminating %d old worker instances", len(batch))
+
+		err = r.deleteInstances(ctx, azureMachinePool, batch)
 		if err != nil {
 			return currentState, microerror.Mask(err)
 		}
 
-		r.Logger.Debugf(ctx, "terminated %d old worker instances", len(oldInstances))
+		r.Logger.Debugf(ctx, "terminated %d old worker instances", len(batch))
 
 		if azureMachinePool.Spec.Template.SpotVMOptions != nil {
 			r.Logger.Debugf(ctx, "node pool is using spot instances, deleting nodes from kubernetes API to speed up upgrade process")
@@ -73,11 +105,14 @@ func (r *Resource) terminateOldWorkersTransition(ctx context.Context, obj interf
 				return currentState, microerror.Mask(err)
 			}
 
-			for _, i := range oldInstances {
+			for _, i := range batch {
 				n, err := r.getK8sWorkerNodeForInstance(ctx, tenantClusterK8sClient, azureMachinePool.Name, i)
 				if err != nil {
 					return currentState, microerror.Mask(err)
 				}
+				if n == nil {
+					continue
+				}
 
 				err = tenantClusterK8sClient.Delete(ctx, n)
 				if err != nil {
@@ -87,12 +122,54 @@ func (r *Resource) terminateOldWorkersTransition(ctx context.Context, obj interf
 			r.Logger.Debugf(ctx, "deleted nodes from kubernetes API to speed up upgrade process")
 		}
 
+		// The batch is removed once its instances are being deleted, which
+		// is checked in the next reconciliation loop.
 		return currentState, nil
 	}
 
 	// All old nodes are terminated.
 	r.Logger.Debugf(ctx, "no old workers were found")
 
+	// When instances were allowed to be unavailable during the rolling update,
+	// the node pool can be smaller than before the rolling update started.
+	desiredReplicas, found := rollingUpdateDesiredReplicas(azureMachinePool)
+	if found && len(newInstances) < desiredReplicas {
+		virtualMachineScaleSetVMsClient, err := r.ClientFactory.GetVirtualMachineScaleSetVMsClient(ctx, azureMachinePool.ObjectMeta)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		// Changing the capacity while old instances are still being deleted
+		// could remove new instances, so we wait for the deletion to finish.
+		allRunning, err := vmsscheck.InstancesAreRunning(ctx, r.Logger, virtualMachineScaleSetVMsClient, key.ClusterID(&azureMachinePool), key.NodePoolVMSSName(&azureMachinePool))
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+		if !allRunning {
+			r.Logger.Debugf(ctx, "waiting for old worker instances to be deleted before restoring node pool size")
+			return currentState, nil
+		}
+
+		r.Logger.Debugf(ctx, "restoring node pool size to %d instances", desiredReplicas)
+
+		_, err = r.scaleVMSS(ctx, azureMachinePool, int64(desiredReplicas), scalestrategy.Quick{})
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		r.Logger.Debugf(ctx, "restored node pool size to %d instances", desiredReplicas)
+	}
+
+	err = r.removeRollingUpdateBatch(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	err = r.removeRollingUpdateDesiredReplicas(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
 	// Enable cluster autoscaler for this nodepool.
 	err = r.enableClusterAutoscaler(ctx, azureMachinePool)
 	if err != nil {
@@ -101,3 +178,34 @@ func (r *Resource) terminateOldWorkersTransition(ctx context.Context, obj interf
 
 	return DeploymentUninitialized, nil
 }
+
+// deleteInstances deletes the given instances from the node pool VMSS.
+func (r *Resource) deleteInstances(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, instances []compute.VirtualMachineScaleSetVM) error {
+	var ids compute.VirtualMachineScaleSetVMInstanceRequiredIDs
+	{
+		var strIds []string
+		for _, i := range instances {
+			strIds = append(strIds, *i.InstanceID)
+		}
+
+		ids = compute.VirtualMachineScaleSetVMInstanceRequiredIDs{
+			InstanceIds: to.StringSlicePtr(strIds),
+		}
+	}
+
+	virtualMachineScaleSetsClient, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	res, err := virtualMachineScaleSetsClient.DeleteInstances(ctx, key.ClusterID(&azureMachinePool), key.NodePoolVMSSName(&azureMachinePool), ids)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = virtualMachineScaleSetsClient.DeleteInstancesResponder(res.Response())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package nodepool
 import (
 	"context"
 
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
 	apiextensionslabels "github.com/giantswarm/apiextensions/v6/pkg/label"
 	"github.com/giantswarm/microerror"
 	corev1 "k8s.io/api/core/v1"
@@ -36,6 +37,16 @@ func (r *Resource) waitForWorkersToBecomeReadyTransition(ctx context.Context, ob
 		return TerminateOldWorkerInstances, nil
 	}
 
+	// A node pool scaled to zero has no nodes to wait for.
+	instances, err := r.GetVMSSInstances(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+	if countActiveInstances(instances) == 0 {
+		r.Logger.Debugf(ctx, "Node Pool VMSS has no instances, no worker nodes to wait for")
+		return ScaleUpWorkerVMSS, nil
+	}
+
 	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
 	if tenantcluster.IsAPINotAvailableError(err) {
 		r.Logger.Debugf(ctx, "tenant API not available yet")
@@ -66,6 +77,20 @@ func (r *Resource) waitForWorkersToBecomeReadyTransition(ctx context.Context, ob
 	return CordonOldWorkerInstances, nil
 }
 
+// countActiveInstances returns the number of VMSS instances which are not
+// being deleted.
+func countActiveInstances(instances []compute.VirtualMachineScaleSetVM) int {
+	var count int
+	for _, i := range instances {
+		if i.ProvisioningState != nil && *i.ProvisioningState == provisioningStateDeleting {
+			continue
+		}
+		count++
+	}
+
+	return count
+}
+
 func countReadyNodes(ctx context.Context, tenantClusterK8sClient ctrlclient.Client, azureMachinePool *capzexp.AzureMachinePool, nodeRoleMatchFunc func(corev1.Node) bool) (int, error) {
 	nodeList := &corev1.NodeList{}
 	labelSelector := ctrlclient.MatchingLabels{apiextensionslabels.MachinePool: azureMachinePool.Name}
@@ -0,0 +1,231 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// UpgradeWithinDeadlineCondition reports if the node pool state machine
+	// left its last states before their deadlines.
+	UpgradeWithinDeadlineCondition capi.ConditionType = "UpgradeWithinDeadline"
+
+	StateDeadlineExceededReason = "StateDeadlineExceeded"
+)
+
+// defaultStateDeadlines limits the time the node pool state machine can stay
+// in a given state before the upgrade timeout policy is applied. They can be
+// overridden per state with the --service.nodePool.stateDeadlines flag.
+var defaultStateDeadlines = state.DeadlineMap{
+	ScaleUpWorkerVMSS:           30 * time.Minute,
+	WaitForWorkersToBecomeReady: 30 * time.Minute,
+	CordonOldWorkerInstances:    15 * time.Minute,
+	DrainOldWorkerInstances:     time.Hour,
+	TerminateOldWorkerInstances: 30 * time.Minute,
+
+	InPlaceCordonWorkerInstance:  15 * time.Minute,
+	InPlaceDrainWorkerInstance:   time.Hour,
+	InPlaceUpgradeWorkerInstance: 30 * time.Minute,
+	InPlaceWaitForWorkerInstance: 30 * time.Minute,
+}
+
+// ParseStateDeadlines parses a comma separated list of node pool state
+// deadlines in the format `state=duration`, e.g.
+// `DrainOldWorkerInstances=2h,ScaleUpWorkerVMSS=45m`.
+func ParseStateDeadlines(v string) (state.DeadlineMap, error) {
+	deadlines := state.DeadlineMap{}
+
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, microerror.Maskf(invalidConfigError, "state deadline %#q must have the format `state=duration`", entry)
+		}
+
+		s := state.State(parts[0])
+		if _, ok := defaultStateDeadlines[s]; !ok {
+			return nil, microerror.Maskf(invalidConfigError, "state deadline %#q has unknown state %#q", entry, s)
+		}
+
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "state deadline %#q has invalid duration: %s", entry, err)
+		}
+		if d <= 0 {
+			return nil, microerror.Maskf(invalidConfigError, "state deadline %#q must be positive", entry)
+		}
+
+		deadlines[s] = d
+	}
+
+	return deadlines, nil
+}
+
+// newStateDeadlines returns the default state deadlines with the given
+// deadlines taking precedence.
+func newStateDeadlines(overrides state.DeadlineMap) state.DeadlineMap {
+	deadlines := state.DeadlineMap{}
+	for s, d := range defaultStateDeadlines {
+		deadlines[s] = d
+	}
+	for s, d := range overrides {
+		deadlines[s] = d
+	}
+
+	return deadlines
+}
+
+// handleStateDeadlineExceeded applies the upgrade timeout policy once the
+// state machine stayed in the current state longer than its deadline. The
+// policy is applied once for every time the state is entered.
+func (r *Resource) handleStateDeadlineExceeded(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, currentState state.State, enteredAt time.Time) error {
+	message := stateDeadlineExceededMessage(currentState, enteredAt, r.StateMachine.Deadlines[currentState])
+	if capiconditions.IsFalse(&azureMachinePool, UpgradeWithinDeadlineCondition) && capiconditions.GetMessage(&azureMachinePool, UpgradeWithinDeadlineCondition) == message {
+		r.Logger.Debugf(ctx, "deadline of state %#q was already handled", currentState)
+		return nil
+	}
+
+	policy := key.NodePoolUpgradeTimeoutPolicy(&azureMachinePool)
+	r.Logger.Debugf(ctx, "state %#q exceeded its deadline, applying %#q timeout policy", currentState, policy)
+
+	err := r.setUpgradeWithinDeadlineCondition(ctx, azureMachinePool, message)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, StateDeadlineExceededReason, "%s, applying %q timeout policy", message, policy)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch policy {
+	case key.NodePoolUpgradeTimeoutPolicyRetry:
+		// Scaling up validates and, when needed, re-applies the ARM
+		// deployment before the rolling update carries on with the
+		// remaining old instances.
+		err = r.saveCurrentState(ctx, azureMachinePool, ScaleUpWorkerVMSS)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = r.saveStateEnteredTimestamp(ctx, azureMachinePool)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case key.NodePoolUpgradeTimeoutPolicyRollback:
+		err = r.saveUpgradeAbort(ctx, azureMachinePool)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// ensureUpgradeWithinDeadlineCondition marks the UpgradeWithinDeadline
+// condition true again once the state machine moved on.
+func (r *Resource) ensureUpgradeWithinDeadlineCondition(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	if !capiconditions.IsFalse(&customObject, UpgradeWithinDeadlineCondition) {
+		return nil
+	}
+
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	capiconditions.MarkTrue(azureMachinePool, UpgradeWithinDeadlineCondition)
+
+	err = r.CtrlClient.Status().Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) setUpgradeWithinDeadlineCondition(ctx context.Context, customObject capzexp.AzureMachinePool, message string) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	capiconditions.MarkFalse(azureMachinePool, UpgradeWithinDeadlineCondition, StateDeadlineExceededReason, capi.ConditionSeverityWarning, "%s", message)
+
+	err = r.CtrlClient.Status().Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// saveStateEnteredTimestamp records the current time as the time the state
+// machine entered its current state.
+func (r *Resource) saveStateEnteredTimestamp(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.StateMachineStateEnteredTimestamp] = r.clock.Now().UTC().Format(time.RFC3339)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) saveUpgradeAbort(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.UpgradeAbort] = "true"
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// stateDeadlineExceededMessage returns the UpgradeWithinDeadline condition
+// message. It identifies the state entry so that the timeout policy is
+// applied once per entry.
+func stateDeadlineExceededMessage(currentState state.State, enteredAt time.Time, deadline time.Duration) string {
+	return fmt.Sprintf("State %s entered at %s exceeded its deadline of %s", currentState, enteredAt.UTC().Format(time.RFC3339), deadline)
+}
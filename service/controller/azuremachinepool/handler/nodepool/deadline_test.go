@@ -0,0 +1,151 @@
+package nodepool
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+func Test_StateMachine_Deadlines(t *testing.T) {
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+	r := &Resource{clock: fakeClock{now: now}, stateDeadlines: newStateDeadlines(nil)}
+	sm := r.createStateMachine()
+
+	for s := range sm.Transitions {
+		if s == DeploymentUninitialized {
+			continue
+		}
+		if _, ok := sm.Deadlines[s]; !ok {
+			t.Fatalf("state %q has no deadline", s)
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		currentState     state.State
+		enteredAt        time.Time
+		expectedExceeded bool
+	}{
+		{
+			name:             "case 0: waiting for workers within deadline",
+			currentState:     WaitForWorkersToBecomeReady,
+			enteredAt:        now.Add(-29 * time.Minute),
+			expectedExceeded: false,
+		},
+		{
+			name:             "case 1: waiting for workers exceeded deadline",
+			currentState:     WaitForWorkersToBecomeReady,
+			enteredAt:        now.Add(-31 * time.Minute),
+			expectedExceeded: true,
+		},
+		{
+			name:             "case 2: uninitialized deployment has no deadline",
+			currentState:     DeploymentUninitialized,
+			enteredAt:        now.Add(-24 * time.Hour),
+			expectedExceeded: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			exceeded := sm.DeadlineExceeded(tc.currentState, tc.enteredAt)
+			if exceeded != tc.expectedExceeded {
+				t.Fatalf("exceeded == %t, want %t", exceeded, tc.expectedExceeded)
+			}
+		})
+	}
+}
+
+func Test_ParseStateDeadlines(t *testing.T) {
+	testCases := []struct {
+		name              string
+		stateDeadlines    string
+		expectedDeadlines state.DeadlineMap
+		errorMatcher      func(error) bool
+	}{
+		{
+			name:              "case 0: no overrides",
+			stateDeadlines:    "",
+			expectedDeadlines: state.DeadlineMap{},
+		},
+		{
+			name:           "case 1: two overrides",
+			stateDeadlines: "DrainOldWorkerInstances=2h, ScaleUpWorkerVMSS=45m",
+			expectedDeadlines: state.DeadlineMap{
+				DrainOldWorkerInstances: 2 * time.Hour,
+				ScaleUpWorkerVMSS:       45 * time.Minute,
+			},
+		},
+		{
+			name:           "case 2: unknown state",
+			stateDeadlines: "Foo=1h",
+			errorMatcher:   IsInvalidConfig,
+		},
+		{
+			name:           "case 3: invalid duration",
+			stateDeadlines: "DrainOldWorkerInstances=2",
+			errorMatcher:   IsInvalidConfig,
+		},
+		{
+			name:           "case 4: negative duration",
+			stateDeadlines: "DrainOldWorkerInstances=-1h",
+			errorMatcher:   IsInvalidConfig,
+		},
+		{
+			name:           "case 5: missing duration",
+			stateDeadlines: "DrainOldWorkerInstances",
+			errorMatcher:   IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			deadlines, err := ParseStateDeadlines(tc.stateDeadlines)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(deadlines, tc.expectedDeadlines) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedDeadlines, deadlines))
+			}
+		})
+	}
+}
+
+func Test_newStateDeadlines(t *testing.T) {
+	deadlines := newStateDeadlines(state.DeadlineMap{DrainOldWorkerInstances: 2 * time.Hour})
+
+	if deadlines[DrainOldWorkerInstances] != 2*time.Hour {
+		t.Fatalf("deadline == %s, want %s", deadlines[DrainOldWorkerInstances], 2*time.Hour)
+	}
+	if deadlines[ScaleUpWorkerVMSS] != defaultStateDeadlines[ScaleUpWorkerVMSS] {
+		t.Fatalf("deadline == %s, want %s", deadlines[ScaleUpWorkerVMSS], defaultStateDeadlines[ScaleUpWorkerVMSS])
+	}
+	if defaultStateDeadlines[DrainOldWorkerInstances] == 2*time.Hour {
+		t.Fatalf("default deadlines must not be modified")
+	}
+}
@@ -24,6 +24,8 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
+	r.clusterAutoscalerChecks.forget(string(azureMachinePool.UID))
+
 	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
 	if err != nil {
 		return microerror.Mask(err)
@@ -54,11 +56,17 @@ func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
 		return microerror.Mask(err)
 	}
 
-	err = r.removeSubnetFromAzureCluster(ctx, azureCluster, azureMachinePool.Name)
-	if err != nil {
-		return microerror.Mask(err)
+	// Existing subnets are kept in AzureCluster, they can be used by other
+	// node pools and are never deleted.
+	if !key.UsesExistingVnet(azureCluster) {
+		err = r.removeSubnetFromAzureCluster(ctx, azureCluster, azureMachinePool.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
 	}
 
+	resetUpgradeProgressMetrics(key.ClusterID(&azureMachinePool), azureMachinePool.Name)
+
 	return nil
 }
 
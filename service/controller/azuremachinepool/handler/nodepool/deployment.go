@@ -32,13 +32,28 @@ import (
 )
 
 func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClient *storage.AccountsClient, release *releasev1alpha1.Release, machinePool *capiexp.MachinePool, azureMachinePool *capzexp.AzureMachinePool, azureCluster *capz.AzureCluster, vmss compute.VirtualMachineScaleSet) (azureresource.Deployment, error) {
+	err := key.ValidateNodePoolDisks(azureMachinePool)
+	if err != nil {
+		return azureresource.Deployment{}, microerror.Mask(err)
+	}
+
+	if key.NodePoolEphemeralOSDisk(azureMachinePool) {
+		supported, err := r.vmsku.HasCapability(ctx, azureMachinePool.Spec.Template.VMSize, vmsku.CapabilityEphemeralOSDisk)
+		if err != nil {
+			return azureresource.Deployment{}, microerror.Mask(err)
+		}
+		if !supported {
+			return azureresource.Deployment{}, microerror.Maskf(ephemeralOSDiskNotSupportedError, "VM size %#q does not support ephemeral OS disks", azureMachinePool.Spec.Template.VMSize)
+		}
+	}
+
 	encrypterObject, err := r.getEncrypterObject(ctx, key.CertificateEncryptionSecretName(azureCluster))
 	if err != nil {
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
 
 	storageAccountName := strings.Replace(fmt.Sprintf("%s%s", "gssa", azureCluster.GetName()), "-", "", -1)
-	workerCloudConfig, err := r.getWorkerCloudConfig(ctx, storageAccountsClient, azureCluster.GetName(), storageAccountName, key.BlobContainerName(), key.BootstrapBlobName(*azureMachinePool), encrypterObject)
+	workerCloudConfig, err := r.getWorkerCloudConfig(ctx, storageAccountsClient, azureCluster.GetName(), storageAccountName, key.BlobContainerName(), azureMachinePool, encrypterObject)
 	if err != nil {
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
@@ -52,13 +67,15 @@ func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClien
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
 
-	vnetName, subnetName, err := r.getSubnetName(azureMachinePool, azureCluster)
+	vnetName, subnetName, ipv6Enabled, err := r.getSubnet(azureMachinePool, azureCluster)
 	if err != nil {
 		return azureresource.Deployment{}, microerror.Mask(err)
 	}
 
-	currentReplicas := key.NodePoolMinReplicas(machinePool)
-	if key.NodePoolMinReplicas(machinePool) != key.NodePoolMaxReplicas(machinePool) {
+	minReplicas := key.NodePoolScalingMinReplicas(machinePool)
+	maxReplicas := key.NodePoolScalingMaxReplicas(machinePool)
+	currentReplicas := minReplicas
+	if minReplicas != maxReplicas {
 		// Autoscaler is enabled. Will need to use the current number of replicas from the VMSS if it exists.
 		if !vmss.IsHTTPStatus(404) {
 			// Update VMSS desired number of nodes in case existing desired
@@ -98,14 +115,26 @@ func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClien
 		}
 	}
 
+	nodeTemplateTags, err := r.getClusterAutoscalerNodeTemplateTags(ctx, machinePool, azureMachinePool)
+	if err != nil {
+		return azureresource.Deployment{}, microerror.Mask(err)
+	}
+
 	templateParameters := template.Parameters{
-		AzureOperatorVersion:        project.Version(),
-		CGroupsVersion:              key.CGroupVersion(machinePool),
-		ClusterID:                   azureCluster.GetName(),
-		DataDisks:                   azureMachinePool.Spec.Template.DataDisks,
-		EnableAcceleratedNetworking: enableAcceleratedNetworking,
-		NodepoolName:                key.NodePoolVMSSName(azureMachinePool),
-		KubernetesVersion:           kubernetesVersion,
+		AzureOperatorVersion:              project.Version(),
+		CGroupsVersion:                    key.CGroupVersion(machinePool),
+		ClusterAutoscalerNodeTemplateTags: nodeTemplateTags,
+		ClusterID:                         azureCluster.GetName(),
+		DataDisks:                         key.NodePoolDataDisks(azureMachinePool),
+		EnableAcceleratedNetworking:       enableAcceleratedNetworking,
+		IPv6Enabled:                       ipv6Enabled,
+		NodepoolName:                      key.NodePoolVMSSName(azureMachinePool),
+		KubernetesVersion:                 kubernetesVersion,
+		OSDisk: template.OSDisk{
+			CachingType:         azureMachinePool.Spec.Template.OSDisk.CachingType,
+			DiskEncryptionSetID: key.NodePoolOSDiskEncryptionSetID(azureMachinePool),
+			Ephemeral:           key.NodePoolEphemeralOSDisk(azureMachinePool),
+		},
 		OSImage: template.OSImage{
 			Publisher: "kinvolk",
 			Offer:     "flatcar-container-linux-free",
@@ -113,8 +142,8 @@ func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClien
 			Version:   distroVersion,
 		},
 		Scaling: template.Scaling{
-			MinReplicas:     key.NodePoolMinReplicas(machinePool),
-			MaxReplicas:     key.NodePoolMaxReplicas(machinePool),
+			MinReplicas:     minReplicas,
+			MaxReplicas:     maxReplicas,
 			CurrentReplicas: currentReplicas,
 		},
 		SpotInstanceConfig: template.SpotInstanceConfig{
@@ -126,6 +155,7 @@ func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClien
 		VMCustomData:       workerCloudConfig,
 		VMSize:             azureMachinePool.Spec.Template.VMSize,
 		VnetName:           vnetName,
+		VnetResourceGroup:  key.AzureClusterVnetResourceGroup(azureCluster),
 		Zones:              machinePool.Spec.FailureDomains,
 	}
 
@@ -137,21 +167,23 @@ func (r Resource) getDesiredDeployment(ctx context.Context, storageAccountsClien
 	return deployment, nil
 }
 
-func (r Resource) getSubnetName(azureMachinePool *capzexp.AzureMachinePool, azureCluster *capz.AzureCluster) (string, string, error) {
+func (r Resource) getSubnet(azureMachinePool *capzexp.AzureMachinePool, azureCluster *capz.AzureCluster) (string, string, bool, error) {
+	subnetName := key.NodePoolSubnetName(azureMachinePool)
 	for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
-		if azureMachinePool.Name == subnet.Name {
+		if subnetName == subnet.Name {
 			if subnet.ID == "" {
-				return "", "", microerror.Maskf(subnetNotReadyError, fmt.Sprintf("Subnet %#q ID field is empty, which means the Subnet is not Ready", subnet.Name))
+				return "", "", false, microerror.Maskf(subnetNotReadyError, fmt.Sprintf("Subnet %#q ID field is empty, which means the Subnet is not Ready", subnet.Name))
 			}
 
-			return azureCluster.Spec.NetworkSpec.Vnet.Name, subnet.Name, nil
+			return azureCluster.Spec.NetworkSpec.Vnet.Name, subnet.Name, key.SubnetIPv6CIDR(subnet) != "", nil
 		}
 	}
 
-	return "", "", microerror.Maskf(notFoundError, "there is no allocated subnet for nodepool %#q in virtual network called %#q", azureMachinePool.Name, azureCluster.Spec.NetworkSpec.Vnet.ID)
+	return "", "", false, microerror.Maskf(notFoundError, "there is no allocated subnet %#q for nodepool %#q in virtual network called %#q", subnetName, azureMachinePool.Name, azureCluster.Spec.NetworkSpec.Vnet.Name)
 }
 
-func (r *Resource) getWorkerCloudConfig(ctx context.Context, storageAccountsClient *storage.AccountsClient, resourceGroupName, storageAccountName, containerName, workerBlobName string, encrypterObject encrypter.Interface) (string, error) {
+func (r *Resource) getWorkerCloudConfig(ctx context.Context, storageAccountsClient *storage.AccountsClient, resourceGroupName, storageAccountName, containerName string, azureMachinePool *capzexp.AzureMachinePool, encrypterObject encrypter.Interface) (string, error) {
+	workerBlobName := key.BootstrapBlobName(*azureMachinePool)
 	encryptionKey := encrypterObject.GetEncryptionKey()
 	initialVector := encrypterObject.GetInitialVector()
 
@@ -187,7 +219,7 @@ func (r *Resource) getWorkerCloudConfig(ctx context.Context, storageAccountsClie
 	if err != nil {
 		return "", microerror.Mask(err)
 	}
-	return vmss.RenderCloudConfig(workerBlobURL, encryptionKey, initialVector, key.PrefixWorker())
+	return vmss.RenderWorkerCloudConfig(workerBlobURL, encryptionKey, initialVector, *key.NodePoolDockerDisk(azureMachinePool).Lun, *key.NodePoolKubeletDisk(azureMachinePool).Lun)
 }
 
 func (r *Resource) getEncrypterObject(ctx context.Context, secretName string) (encrypter.Interface, error) {
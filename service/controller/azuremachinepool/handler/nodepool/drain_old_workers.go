@@ -3,12 +3,12 @@ package nodepool
 import (
 	"context"
 	"strings"
-	"time"
 
 	"github.com/giantswarm/microerror"
 	"sigs.k8s.io/cluster-api/util"
 
 	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
 	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 
@@ -44,7 +44,14 @@ func (r *Resource) drainOldWorkerInstances(ctx context.Context, obj interface{},
 	if len(oldInstances) > 0 {
 		r.Logger.Debugf(ctx, "There are still %d workers from the previous release running", len(oldInstances))
 
-		tenantClusterK8sClients, err := r.tenantClientFactory.GetAllClients(ctx, cluster)
+		batch, found := savedRollingUpdateBatch(azureMachinePool, oldInstances)
+		if !found {
+			// The batch is only known once it is cordoned.
+			r.Logger.Debugf(ctx, "rolling update batch was not saved, cordoning it first")
+			return CordonOldWorkerInstances, nil
+		}
+
+		hooksCompleted, err := r.ensureLifecycleHooks(ctx, azureMachinePool, cluster, lifecyclehook.PreDrain, batch)
 		if tenantcluster.IsAPINotAvailableError(err) {
 			r.Logger.Debugf(ctx, "tenant API not available yet")
 			r.Logger.Debugf(ctx, "canceling resource")
@@ -54,19 +61,27 @@ func (r *Resource) drainOldWorkerInstances(ctx context.Context, obj interface{},
 			return currentState, microerror.Mask(err)
 		}
 
-		nodeDrainer, err := drainer.New(drainer.Config{
-			Logger:    r.Logger,
-			WCClients: tenantClusterK8sClients,
-		})
-		if err != nil {
+		if !hooksCompleted {
+			r.Logger.Debugf(ctx, "waiting for pre-drain hooks to complete")
+			return currentState, nil
+		}
+
+		nodeDrainer, err := r.getNodeDrainer(ctx, cluster, azureMachinePool)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
 			return currentState, microerror.Mask(err)
 		}
 
 		completed := true
-		for _, instance := range oldInstances {
+		drained := 0
+		for _, instance := range batch {
 			nodeName := strings.ToLower(*instance.OsProfile.ComputerName)
 			r.Logger.Debugf(ctx, "Draining node %q (instance name %q)", nodeName, *instance.Name)
-			err = nodeDrainer.DrainNode(ctx, nodeName, 15*time.Minute)
+			err = nodeDrainer.DrainNode(ctx, nodeName, key.NodePoolDrainTimeout)
 			if drainer.IsEvictionInProgress(err) {
 				// Node still draining.
 				r.Logger.Debugf(ctx, "Node %q is still draining: %s", nodeName, err)
@@ -74,19 +89,38 @@ func (r *Resource) drainOldWorkerInstances(ctx context.Context, obj interface{},
 			} else if drainer.IsDrainTimeout(err) {
 				// Node drain timed out.
 				r.Logger.Debugf(ctx, "Timeout while draining node %q", nodeName)
+				drained++
 			} else if err != nil {
 				r.Logger.Debugf(ctx, "Error draining node %q: %s", nodeName, err)
 				return currentState, microerror.Mask(err)
 			} else {
 				r.Logger.Debugf(ctx, "Node %q drained successfully", nodeName)
+				drained++
 			}
 		}
 
 		if completed {
+			// Post-drain hooks are started right away, terminating waits for
+			// them to complete.
+			_, err = r.ensureLifecycleHooks(ctx, azureMachinePool, cluster, lifecyclehook.PostDrain, batch)
+			if tenantcluster.IsAPINotAvailableError(err) {
+				r.Logger.Debugf(ctx, "tenant API not available yet")
+				r.Logger.Debugf(ctx, "canceling resource")
+
+				return currentState, nil
+			} else if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
 			return TerminateOldWorkerInstances, nil
 		}
 
 		// Nodes still to be drained.
+		err = r.saveDrainProgress(ctx, azureMachinePool, drained, len(batch))
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
 		return currentState, nil
 	}
 
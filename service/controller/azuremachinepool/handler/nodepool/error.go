@@ -25,6 +25,15 @@ var executionFailedError = &microerror.Error{
 	Kind: "executionFailedError",
 }
 
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
 var deploymentNotFoundError = &microerror.Error{
 	Kind: "deploymentNotFoundError",
 }
@@ -126,6 +135,15 @@ func IsMissingReleaseVersionLabel(err error) bool {
 	return microerror.Cause(err) == missingReleaseVersionLabel
 }
 
+var invalidRollingUpdateError = &microerror.Error{
+	Kind: "invalidRollingUpdateError",
+}
+
+// IsInvalidRollingUpdate asserts invalidRollingUpdateError.
+func IsInvalidRollingUpdate(err error) bool {
+	return microerror.Cause(err) == invalidRollingUpdateError
+}
+
 var notAvailableFailureDomain = &microerror.Error{
 	Kind: "notAvailableFailureDomain",
 }
@@ -161,3 +179,12 @@ var unexpectedUpstreamResponseError = &microerror.Error{
 func IsUnexpectedUpstreamResponse(err error) bool {
 	return microerror.Cause(err) == unexpectedUpstreamResponseError
 }
+
+var ephemeralOSDiskNotSupportedError = &microerror.Error{
+	Kind: "ephemeralOSDiskNotSupportedError",
+}
+
+// IsEphemeralOSDiskNotSupported asserts ephemeralOSDiskNotSupportedError.
+func IsEphemeralOSDiskNotSupported(err error) bool {
+	return microerror.Cause(err) == ephemeralOSDiskNotSupportedError
+}
@@ -0,0 +1,530 @@
+package nodepool
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmssinstance"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	InvalidDrainConfigReason = "InvalidDrainConfig"
+)
+
+// inPlaceCordonWorkerInstanceTransition picks the next outdated instance of a
+// node pool upgraded in place and cordons its node. When no outdated instances
+// are left the upgrade is completed.
+func (r *Resource) inPlaceCordonWorkerInstanceTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.Logger.Debugf(ctx, "Cluster is being deleted, skipping reconciling node pool")
+		return currentState, nil
+	}
+
+	instance, err := r.getInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	if instance == nil {
+		oldInstances, _, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		if len(oldInstances) == 0 {
+			// All old nodes are upgraded.
+			r.Logger.Debugf(ctx, "no old workers were found")
+
+			// Enable cluster autoscaler for this nodepool.
+			err = r.enableClusterAutoscaler(ctx, azureMachinePool)
+			if err != nil {
+				return currentState, microerror.Mask(err)
+			}
+
+			return DeploymentUninitialized, nil
+		}
+
+		r.Logger.Debugf(ctx, "There are still %d workers from the previous release running", len(oldInstances))
+
+		// Disable cluster autoscaler for this nodepool, it must not remove
+		// the instances we are working on.
+		err = r.disableClusterAutoscaler(ctx, azureMachinePool)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		instance = nextInPlaceUpgradeInstance(oldInstances)
+
+		err = r.saveInPlaceUpgradeInstance(ctx, azureMachinePool, *instance.InstanceID)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+	}
+
+	nodeDrainer, err := r.getNodeDrainer(ctx, cluster, azureMachinePool)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.Logger.Debugf(ctx, "tenant API not available yet")
+		r.Logger.Debugf(ctx, "canceling resource")
+
+		return currentState, nil
+	} else if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.Logger.Debugf(ctx, "tenant API not available yet")
+		r.Logger.Debugf(ctx, "canceling resource")
+
+		return currentState, nil
+	} else if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	nodeName := strings.ToLower(*instance.OsProfile.ComputerName)
+
+	// The cluster autoscaler must not remove the node while it is upgraded.
+	err = setNodeScaleDownDisabled(ctx, tenantClusterK8sClient, nodeName, true)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "Cordoning node %q (instance name %q)", nodeName, *instance.Name)
+	err = nodeDrainer.CordonNode(ctx, nodeName)
+	if drainer.IsAlreadyCordoned(err) {
+		// Fall through.
+	} else if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+	r.Logger.Debugf(ctx, "Cordoned node %q", nodeName)
+
+	return InPlaceDrainWorkerInstance, nil
+}
+
+// inPlaceDrainWorkerInstanceTransition drains the node of the instance being
+// upgraded in place.
+func (r *Resource) inPlaceDrainWorkerInstanceTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.Logger.Debugf(ctx, "Cluster is being deleted, skipping reconciling node pool")
+		return currentState, nil
+	}
+
+	instance, err := r.getInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	if instance == nil {
+		r.Logger.Debugf(ctx, "instance being upgraded in place was not found, picking the next one")
+		return InPlaceCordonWorkerInstance, nil
+	}
+
+	nodeDrainer, err := r.getNodeDrainer(ctx, cluster, azureMachinePool)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.Logger.Debugf(ctx, "tenant API not available yet")
+		r.Logger.Debugf(ctx, "canceling resource")
+
+		return currentState, nil
+	} else if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	nodeName := strings.ToLower(*instance.OsProfile.ComputerName)
+	r.Logger.Debugf(ctx, "Draining node %q (instance name %q)", nodeName, *instance.Name)
+	err = nodeDrainer.DrainNode(ctx, nodeName, key.NodePoolDrainTimeout)
+	if drainer.IsEvictionInProgress(err) {
+		// Node still draining.
+		r.Logger.Debugf(ctx, "Node %q is still draining: %s", nodeName, err)
+
+		err = r.saveDrainProgress(ctx, azureMachinePool, 0, 1)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		return currentState, nil
+	} else if drainer.IsDrainTimeout(err) {
+		// Node drain timed out.
+		r.Logger.Debugf(ctx, "Timeout while draining node %q", nodeName)
+	} else if err != nil {
+		r.Logger.Debugf(ctx, "Error draining node %q: %s", nodeName, err)
+		return currentState, microerror.Mask(err)
+	} else {
+		r.Logger.Debugf(ctx, "Node %q drained successfully", nodeName)
+	}
+
+	return InPlaceUpgradeWorkerInstance, nil
+}
+
+// inPlaceUpgradeWorkerInstanceTransition applies the latest VMSS model to the
+// instance being upgraded in place and reimages it.
+func (r *Resource) inPlaceUpgradeWorkerInstanceTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.Logger.Debugf(ctx, "Cluster is being deleted, skipping reconciling node pool")
+		return currentState, nil
+	}
+
+	instance, err := r.getInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	if instance == nil {
+		r.Logger.Debugf(ctx, "instance being upgraded in place was not found, picking the next one")
+		return InPlaceCordonWorkerInstance, nil
+	}
+
+	if instance.ProvisioningState != nil && !key.IsSucceededProvisioningState(*instance.ProvisioningState) {
+		r.Logger.Debugf(ctx, "instance %#q is not in successful provisioning state: %#q", *instance.Name, *instance.ProvisioningState)
+		return currentState, nil
+	}
+
+	// Ensure that VM has latest VMSS configuration (includes ignition template etc.).
+	if instance.LatestModelApplied != nil && !*instance.LatestModelApplied {
+		err = r.updateInstance(ctx, azureMachinePool, instance)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		// Reimage the instance in the next reconciliation loop, once the
+		// update is completed.
+		return currentState, nil
+	}
+
+	// Once the VM instance configuration has been updated, it can be reimaged.
+	err = r.reimageInstance(ctx, azureMachinePool, instance)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	// The node is deleted from the kubernetes API so that the reimaged
+	// instance registers again with up to date labels.
+	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.Logger.Debugf(ctx, "tenant API not available yet")
+		return InPlaceWaitForWorkerInstance, nil
+	} else if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	n, err := r.getK8sWorkerNodeForInstance(ctx, tenantClusterK8sClient, azureMachinePool.Name, *instance)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	if n != nil {
+		err = tenantClusterK8sClient.Delete(ctx, n)
+		if apierrors.IsNotFound(err) {
+			// Fall through.
+		} else if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+		r.Logger.Debugf(ctx, "deleted node %q from kubernetes API", n.Name)
+	}
+
+	return InPlaceWaitForWorkerInstance, nil
+}
+
+// inPlaceWaitForWorkerInstanceTransition waits for the instance upgraded in
+// place to join the cluster again before moving on to the next instance.
+func (r *Resource) inPlaceWaitForWorkerInstanceTransition(ctx context.Context, obj interface{}, currentState state.State) (state.State, error) {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return DeploymentUninitialized, microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.Logger.Debugf(ctx, "Cluster is being deleted, skipping reconciling node pool")
+		return currentState, nil
+	}
+
+	instance, err := r.getInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	if instance != nil {
+		ready, err := r.isInPlaceUpgradedInstanceReady(ctx, cluster, azureMachinePool, *instance)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		if !ready {
+			r.Logger.Debugf(ctx, "instance %#q is not upgraded and ready yet", *instance.Name)
+			return currentState, nil
+		}
+
+		r.Logger.Debugf(ctx, "instance %#q is upgraded and ready", *instance.Name)
+
+		tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			r.Logger.Debugf(ctx, "canceling resource")
+
+			return currentState, nil
+		} else if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+
+		err = setNodeScaleDownDisabled(ctx, tenantClusterK8sClient, strings.ToLower(*instance.OsProfile.ComputerName), false)
+		if err != nil {
+			return currentState, microerror.Mask(err)
+		}
+	}
+
+	err = r.removeInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return currentState, microerror.Mask(err)
+	}
+
+	return InPlaceCordonWorkerInstance, nil
+}
+
+func (r *Resource) isInPlaceUpgradedInstanceReady(ctx context.Context, cluster *capi.Cluster, azureMachinePool capzexp.AzureMachinePool, instance compute.VirtualMachineScaleSetVM) (bool, error) {
+	if instance.ProvisioningState != nil && !key.IsSucceededProvisioningState(*instance.ProvisioningState) {
+		return false, nil
+	}
+
+	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	n, err := r.getK8sWorkerNodeForInstance(ctx, tenantClusterK8sClient, azureMachinePool.Name, instance)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if n == nil || !isReady(*n) {
+		return false, nil
+	}
+
+	old, err := r.isWorkerInstanceFromPreviousRelease(ctx, cluster, azureMachinePool, instance)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return !old, nil
+}
+
+// getInPlaceUpgradeInstance returns the instance being upgraded in place or
+// nil when there is none.
+func (r *Resource) getInPlaceUpgradeInstance(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) (*compute.VirtualMachineScaleSetVM, error) {
+	instanceID, exists := azureMachinePool.Annotations[annotation.InPlaceUpgradeInstance]
+	if !exists {
+		return nil, nil
+	}
+
+	instances, err := r.GetVMSSInstances(ctx, azureMachinePool)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range instances {
+		if *instances[i].InstanceID == instanceID {
+			return &instances[i], nil
+		}
+	}
+
+	// The instance is gone, e.g. it was deleted by the cluster autoscaler
+	// before it was disabled.
+	r.Logger.Debugf(ctx, "instance %#q being upgraded in place was not found", instanceID)
+
+	err = r.removeInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return nil, nil
+}
+
+func (r *Resource) saveInPlaceUpgradeInstance(ctx context.Context, customObject capzexp.AzureMachinePool, instanceID string) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.InPlaceUpgradeInstance] = instanceID
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) removeInPlaceUpgradeInstance(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if _, exists := azureMachinePool.Annotations[annotation.InPlaceUpgradeInstance]; !exists {
+		return nil
+	}
+
+	delete(azureMachinePool.Annotations, annotation.InPlaceUpgradeInstance)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) getNodeDrainer(ctx context.Context, cluster *capi.Cluster, azureMachinePool capzexp.AzureMachinePool) (*drainer.Drainer, error) {
+	tenantClusterK8sClients, err := r.tenantClientFactory.GetAllClients(ctx, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	pdbDeletionGracePeriod, err := key.NodePoolDrainPDBDeletionGracePeriod(&azureMachinePool)
+	if key.IsInvalidDrainConfig(err) {
+		// Blocked pods are not deleted rather than blocking the upgrade.
+		r.Logger.LogCtx(ctx, "level", "warning", "message", "invalid drain settings, pods blocked by PodDisruptionBudgets are not deleted", "stack", microerror.JSON(err))
+
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, InvalidDrainConfigReason, "Pods blocked by PodDisruptionBudgets are not deleted: %s", err.Error())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	nodeDrainer, err := drainer.New(drainer.Config{
+		Logger:    r.Logger,
+		WCClients: tenantClusterK8sClients,
+
+		PDBDeletionGracePeriod: pdbDeletionGracePeriod,
+		Rules:                  r.drainRules.WithOverrides(cluster.Annotations),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return nodeDrainer, nil
+}
+
+func (r *Resource) reimageInstance(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, instance *compute.VirtualMachineScaleSetVM) error {
+	r.Logger.Debugf(ctx, "ensuring instance '%s' to be reimaged", *instance.Name)
+
+	c, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = vmssinstance.Reimage(ctx, c, key.ClusterID(&azureMachinePool), key.NodePoolVMSSName(&azureMachinePool), *instance.InstanceID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "ensured instance '%s' to be reimaged", *instance.Name)
+
+	return nil
+}
+
+func (r *Resource) updateInstance(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, instance *compute.VirtualMachineScaleSetVM) error {
+	r.Logger.Debugf(ctx, "ensuring instance '%s' to be updated", *instance.Name)
+
+	c, err := r.ClientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	ids := compute.VirtualMachineScaleSetVMInstanceRequiredIDs{
+		InstanceIds: to.StringSlicePtr([]string{
+			*instance.InstanceID,
+		}),
+	}
+	res, err := c.UpdateInstances(ctx, key.ClusterID(&azureMachinePool), key.NodePoolVMSSName(&azureMachinePool), ids)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = c.UpdateInstancesResponder(res.Response())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "ensured instance '%s' to be updated", *instance.Name)
+
+	return nil
+}
+
+// nextInPlaceUpgradeInstance returns the next outdated instance of the zone
+// with the most outdated instances, see zoneBalancedOrder.
+func nextInPlaceUpgradeInstance(oldInstances []compute.VirtualMachineScaleSetVM) *compute.VirtualMachineScaleSetVM {
+	if len(oldInstances) == 0 {
+		return nil
+	}
+
+	sorted := zoneBalancedOrder(oldInstances)
+
+	return &sorted[0]
+}
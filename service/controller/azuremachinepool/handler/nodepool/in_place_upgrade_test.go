@@ -0,0 +1,578 @@
+package nodepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	apiextensionsannotations "github.com/giantswarm/apiextensions/v6/pkg/annotation"
+	apiextensionslabels "github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	azureclient "github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/label"
+	"github.com/giantswarm/azure-operator/v8/pkg/mock/mock_tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/pkg/project"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+func Test_nextInPlaceUpgradeInstance(t *testing.T) {
+	testCases := []struct {
+		name               string
+		oldInstances       []compute.VirtualMachineScaleSetVM
+		expectedInstanceID string
+	}{
+		{
+			name:               "case 0: no outdated instances",
+			oldInstances:       nil,
+			expectedInstanceID: "",
+		},
+		{
+			name: "case 1: single outdated instance",
+			oldInstances: []compute.VirtualMachineScaleSetVM{
+				{InstanceID: to.StringPtr("3")},
+			},
+			expectedInstanceID: "3",
+		},
+		{
+			name: "case 2: instance IDs are compared numerically",
+			oldInstances: []compute.VirtualMachineScaleSetVM{
+				{InstanceID: to.StringPtr("10")},
+				{InstanceID: to.StringPtr("9")},
+				{InstanceID: to.StringPtr("11")},
+			},
+			expectedInstanceID: "9",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			instance := nextInPlaceUpgradeInstance(tc.oldInstances)
+
+			var instanceID string
+			if instance != nil {
+				instanceID = *instance.InstanceID
+			}
+
+			if instanceID != tc.expectedInstanceID {
+				t.Fatalf("instanceID == %q, want %q", instanceID, tc.expectedInstanceID)
+			}
+		})
+	}
+}
+
+func Test_InPlaceUpgradeTransitions(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		transition      func(*Resource, context.Context, interface{}, state.State) (state.State, error)
+		currentState    state.State
+		upgradeInstance string
+		instances       []testInstance
+		nodes           []string
+
+		expectedState           state.State
+		expectedUpgradeInstance string
+		expectedUpdated         []string
+		expectedReimaged        []string
+		expectedNodes           []string
+		expectedUnschedulable   []string
+		expectedScaleDownLocked []string
+		// expectedAutoscaler is the value of the cluster autoscaler enabled
+		// VMSS tag, empty when the tag is not set.
+		expectedAutoscaler string
+	}{
+		{
+			name: "case 0: cordon picks the outdated instance",
+
+			transition:   (*Resource).inPlaceCordonWorkerInstanceTransition,
+			currentState: InPlaceCordonWorkerInstance,
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+				{id: "1", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0", "1"},
+
+			expectedState:           InPlaceDrainWorkerInstance,
+			expectedUpgradeInstance: "0",
+			expectedNodes:           []string{"0", "1"},
+			expectedUnschedulable:   []string{"0"},
+			expectedScaleDownLocked: []string{"0"},
+			expectedAutoscaler:      "false",
+		},
+		{
+			name: "case 1: cordon completes the upgrade without outdated instances",
+
+			transition:   (*Resource).inPlaceCordonWorkerInstanceTransition,
+			currentState: InPlaceCordonWorkerInstance,
+			instances: []testInstance{
+				{id: "0", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0"},
+
+			expectedState:      DeploymentUninitialized,
+			expectedNodes:      []string{"0"},
+			expectedAutoscaler: "true",
+		},
+		{
+			name: "case 2: drain moves on once the node is drained",
+
+			transition:      (*Resource).inPlaceDrainWorkerInstanceTransition,
+			currentState:    InPlaceDrainWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0"},
+
+			expectedState:           InPlaceUpgradeWorkerInstance,
+			expectedUpgradeInstance: "0",
+			expectedNodes:           []string{"0"},
+		},
+		{
+			name: "case 3: drain picks the next instance when the instance is gone",
+
+			transition:      (*Resource).inPlaceDrainWorkerInstanceTransition,
+			currentState:    InPlaceDrainWorkerInstance,
+			upgradeInstance: "5",
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0"},
+
+			expectedState: InPlaceCordonWorkerInstance,
+			expectedNodes: []string{"0"},
+		},
+		{
+			name: "case 4: upgrade waits for the instance provisioning",
+
+			transition:      (*Resource).inPlaceUpgradeWorkerInstanceTransition,
+			currentState:    InPlaceUpgradeWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: false, provisioningState: "Updating"},
+			},
+			nodes: []string{"0"},
+
+			expectedState:           InPlaceUpgradeWorkerInstance,
+			expectedUpgradeInstance: "0",
+			expectedNodes:           []string{"0"},
+		},
+		{
+			name: "case 5: upgrade applies the latest model before reimaging",
+
+			transition:      (*Resource).inPlaceUpgradeWorkerInstanceTransition,
+			currentState:    InPlaceUpgradeWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: false, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0"},
+
+			expectedState:           InPlaceUpgradeWorkerInstance,
+			expectedUpgradeInstance: "0",
+			expectedUpdated:         []string{"0"},
+			expectedNodes:           []string{"0"},
+		},
+		{
+			name: "case 6: upgrade reimages the instance and deletes its node",
+
+			transition:      (*Resource).inPlaceUpgradeWorkerInstanceTransition,
+			currentState:    InPlaceUpgradeWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+				{id: "1", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0", "1"},
+
+			expectedState:           InPlaceWaitForWorkerInstance,
+			expectedUpgradeInstance: "0",
+			expectedReimaged:        []string{"0"},
+			expectedNodes:           []string{"1"},
+		},
+		{
+			name: "case 7: wait until the reimaged instance joins the cluster",
+
+			transition:      (*Resource).inPlaceWaitForWorkerInstanceTransition,
+			currentState:    InPlaceWaitForWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+
+			expectedState:           InPlaceWaitForWorkerInstance,
+			expectedUpgradeInstance: "0",
+		},
+		{
+			name: "case 8: wait moves on to the next instance once the node is ready",
+
+			transition:      (*Resource).inPlaceWaitForWorkerInstanceTransition,
+			currentState:    InPlaceWaitForWorkerInstance,
+			upgradeInstance: "0",
+			instances: []testInstance{
+				{id: "0", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []string{"0"},
+
+			expectedState: InPlaceCordonWorkerInstance,
+			expectedNodes: []string{"0"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			azureMachinePool := newTestAzureMachinePool()
+			if tc.upgradeInstance != "" {
+				azureMachinePool.Annotations[annotation.InPlaceUpgradeInstance] = tc.upgradeInstance
+			}
+
+			ctrlClient := unittest.FakeK8sClient(newTestCluster(), newTestMachinePool(), azureMachinePool).CtrlClient()
+
+			var wcObjects []ctrlclient.Object
+			for _, id := range tc.nodes {
+				wcObjects = append(wcObjects, newTestNode(id))
+			}
+			wcCtrlClient := fake.NewClientBuilder().WithObjects(wcObjects...).Build()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+			tenantClientFactory.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(wcCtrlClient, nil).AnyTimes()
+			tenantClientFactory.EXPECT().GetAllClients(gomock.Any(), gomock.Any()).Return(k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: wcCtrlClient,
+				K8sClient:  kubernetesfake.NewSimpleClientset(),
+			}), nil).AnyTimes()
+
+			vmssAPI := &fakeVMSSAPI{
+				vmss: compute.VirtualMachineScaleSet{
+					Sku: &compute.Sku{Name: to.StringPtr(testSKU), Capacity: to.Int64Ptr(int64(len(tc.instances)))},
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+							StorageProfile: &compute.VirtualMachineScaleSetStorageProfile{
+								ImageReference: &compute.ImageReference{Version: to.StringPtr(testImageVersion)},
+							},
+						},
+					},
+					Tags: map[string]*string{},
+				},
+				instances: tc.instances,
+			}
+			server := httptest.NewServer(vmssAPI)
+			defer server.Close()
+
+			r := &Resource{
+				Resource: nodes.Resource{
+					CtrlClient:    ctrlClient,
+					Logger:        microloggertest.New(),
+					ClientFactory: &fakeClientFactory{baseURI: server.URL},
+				},
+				clock:               state.SystemClock{},
+				tenantClientFactory: tenantClientFactory,
+			}
+
+			newState, err := tc.transition(r, ctx, azureMachinePool, tc.currentState)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if newState != tc.expectedState {
+				t.Fatalf("state == %q, want %q", newState, tc.expectedState)
+			}
+
+			err = ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if upgradeInstance := azureMachinePool.Annotations[annotation.InPlaceUpgradeInstance]; upgradeInstance != tc.expectedUpgradeInstance {
+				t.Fatalf("upgrade instance == %q, want %q", upgradeInstance, tc.expectedUpgradeInstance)
+			}
+
+			if !cmp.Equal(vmssAPI.updated, tc.expectedUpdated) {
+				t.Fatalf("updated instances\n\n%s\n", cmp.Diff(tc.expectedUpdated, vmssAPI.updated))
+			}
+			if !cmp.Equal(vmssAPI.reimaged, tc.expectedReimaged) {
+				t.Fatalf("reimaged instances\n\n%s\n", cmp.Diff(tc.expectedReimaged, vmssAPI.reimaged))
+			}
+
+			var autoscaler string
+			if v := vmssAPI.vmss.Tags[clusterAutoscalerEnabledTagName]; v != nil {
+				autoscaler = *v
+			}
+			if autoscaler != tc.expectedAutoscaler {
+				t.Fatalf("cluster autoscaler enabled tag == %q, want %q", autoscaler, tc.expectedAutoscaler)
+			}
+
+			nodeList := &corev1.NodeList{}
+			err = wcCtrlClient.List(ctx, nodeList)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var nodeNames, unschedulable, scaleDownLocked []string
+			for _, n := range nodeList.Items {
+				nodeNames = append(nodeNames, n.Name)
+				if n.Spec.Unschedulable {
+					unschedulable = append(unschedulable, n.Name)
+				}
+				if n.Annotations[scaleDownDisabledAnnotation] == "true" {
+					scaleDownLocked = append(scaleDownLocked, n.Name)
+				}
+			}
+
+			if !cmp.Equal(nodeNames, testNodeNames(tc.expectedNodes)) {
+				t.Fatalf("nodes\n\n%s\n", cmp.Diff(testNodeNames(tc.expectedNodes), nodeNames))
+			}
+			if !cmp.Equal(unschedulable, testNodeNames(tc.expectedUnschedulable)) {
+				t.Fatalf("unschedulable nodes\n\n%s\n", cmp.Diff(testNodeNames(tc.expectedUnschedulable), unschedulable))
+			}
+			if !cmp.Equal(scaleDownLocked, testNodeNames(tc.expectedScaleDownLocked)) {
+				t.Fatalf("nodes protected from scale down\n\n%s\n", cmp.Diff(testNodeNames(tc.expectedScaleDownLocked), scaleDownLocked))
+			}
+		})
+	}
+}
+
+const (
+	testClusterID    = "c1"
+	testNamespace    = "org-acme"
+	testNodePoolID   = "np1"
+	testSKU          = "Standard_D4s_v3"
+	oldTestSKU       = "Standard_D2s_v3"
+	testImageVersion = "2905.2.0"
+)
+
+// testInstance is a node pool VMSS instance served by fakeVMSSAPI.
+type testInstance struct {
+	id                 string
+	sku                string
+	zone               string
+	latestModelApplied bool
+	provisioningState  string
+}
+
+// fakeVMSSAPI serves the Azure VMSS API of a single node pool VMSS and records
+// the instances updated to the latest model, reimaged and deleted.
+type fakeVMSSAPI struct {
+	mutex sync.Mutex
+
+	vmss      compute.VirtualMachineScaleSet
+	instances []testInstance
+
+	updated  []string
+	reimaged []string
+	deleted  []string
+}
+
+func (a *fakeVMSSAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var response interface{}
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/virtualMachines"):
+		var values []interface{}
+		for _, i := range a.instances {
+			values = append(values, i.toJSON())
+		}
+		response = map[string]interface{}{"value": values}
+	case r.Method == http.MethodGet:
+		response = a.vmss
+	case r.Method == http.MethodPatch:
+		update := compute.VirtualMachineScaleSetUpdate{}
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.vmss.Tags = update.Tags
+		response = a.vmss
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/manualupgrade"):
+		ids := compute.VirtualMachineScaleSetVMInstanceRequiredIDs{}
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.updated = append(a.updated, *ids.InstanceIds...)
+		response = map[string]interface{}{}
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/reimage"):
+		params := compute.VirtualMachineScaleSetReimageParameters{}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.reimaged = append(a.reimaged, *params.InstanceIds...)
+		response = map[string]interface{}{}
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/delete"):
+		ids := compute.VirtualMachineScaleSetVMInstanceRequiredIDs{}
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.deleted = append(a.deleted, *ids.InstanceIds...)
+		response = map[string]interface{}{}
+	default:
+		http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.Path), http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// toJSON renders the instance the way the Azure API does. The SDK types can't
+// be marshalled directly because they omit read-only fields.
+func (i testInstance) toJSON() map[string]interface{} {
+	var zones []string
+	if i.zone != "" {
+		zones = append(zones, i.zone)
+	}
+
+	return map[string]interface{}{
+		"instanceId": i.id,
+		"zones":      zones,
+		"name":       fmt.Sprintf("nodepool-%s_%s", testNodePoolID, i.id),
+		"sku":        map[string]interface{}{"name": i.sku},
+		"properties": map[string]interface{}{
+			"latestModelApplied": i.latestModelApplied,
+			"provisioningState":  i.provisioningState,
+			"osProfile":          map[string]interface{}{"computerName": key.NodePoolInstanceName(testNodePoolID, i.id)},
+			"storageProfile": map[string]interface{}{
+				"imageReference": map[string]interface{}{"version": testImageVersion},
+			},
+		},
+	}
+}
+
+// fakeClientFactory returns Azure VMSS clients talking to a fake API. Other
+// clients are not implemented.
+type fakeClientFactory struct {
+	azureclient.Interface
+
+	baseURI string
+}
+
+func (f *fakeClientFactory) GetVirtualMachineScaleSetsClient(ctx context.Context, objectMeta metav1.ObjectMeta) (*compute.VirtualMachineScaleSetsClient, error) {
+	c := compute.NewVirtualMachineScaleSetsClientWithBaseURI(f.baseURI, "subscription-id")
+	return &c, nil
+}
+
+func (f *fakeClientFactory) GetVirtualMachineScaleSetVMsClient(ctx context.Context, objectMeta metav1.ObjectMeta) (*compute.VirtualMachineScaleSetVMsClient, error) {
+	c := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(f.baseURI, "subscription-id")
+	return &c, nil
+}
+
+func newTestCluster() *capi.Cluster {
+	return &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testClusterID,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				label.Cluster: testClusterID,
+			},
+		},
+	}
+}
+
+func newTestMachinePool() *capiexp.MachinePool {
+	return &capiexp.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testNodePoolID,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				capi.ClusterLabelName: testClusterID,
+				label.Cluster:         testClusterID,
+			},
+			Annotations: map[string]string{
+				apiextensionsannotations.NodePoolMinSize: "1",
+				apiextensionsannotations.NodePoolMaxSize: "3",
+			},
+		},
+		Spec: capiexp.MachinePoolSpec{
+			Replicas: to.Int32Ptr(2),
+		},
+	}
+}
+
+func newTestAzureMachinePool() *capzexp.AzureMachinePool {
+	return &capzexp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testNodePoolID,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				capi.ClusterLabelName: testClusterID,
+				label.Cluster:         testClusterID,
+			},
+			Annotations: map[string]string{},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: capiexp.GroupVersion.String(),
+					Kind:       "MachinePool",
+					Name:       testNodePoolID,
+				},
+			},
+		},
+	}
+}
+
+// newTestNode returns the ready and up to date node of the node pool instance
+// with the given ID.
+func newTestNode(instanceID string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: key.NodePoolInstanceName(testNodePoolID, instanceID),
+			Labels: map[string]string{
+				apiextensionslabels.MachinePool: testNodePoolID,
+				label.CGroupVersion:             "v2",
+				label.OperatorVersion:           project.Version(),
+			},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue, Reason: "KubeletReady"},
+			},
+		},
+	}
+}
+
+func testNodeNames(instanceIDs []string) []string {
+	var names []string
+	for _, id := range instanceIDs {
+		names = append(names, key.NodePoolInstanceName(testNodePoolID, id))
+	}
+
+	return names
+}
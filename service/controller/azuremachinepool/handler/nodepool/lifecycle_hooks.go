@@ -0,0 +1,141 @@
+package nodepool
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/giantswarm/microerror"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	InvalidLifecycleHookReason  = "InvalidLifecycleHook"
+	LifecycleHookTimedOutReason = "LifecycleHookTimedOut"
+)
+
+var lifecycleHookAnnotations = map[lifecyclehook.Type]string{
+	lifecyclehook.PreDrain:  annotation.NodePoolPreDrainHook,
+	lifecyclehook.PostDrain: annotation.NodePoolPostDrainHook,
+}
+
+// ensureLifecycleHooks runs the hook of the given type declared on the
+// MachinePool for the nodes of the given instances. It returns true once the
+// hook completed or timed out for all of them, or when no hook is declared.
+// Invalid hooks are reported and block the upgrade until they are fixed.
+func (r *Resource) ensureLifecycleHooks(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, cluster *capi.Cluster, hookType lifecyclehook.Type, instances []compute.VirtualMachineScaleSetVM) (bool, error) {
+	if len(instances) == 0 {
+		return true, nil
+	}
+
+	machinePool, err := r.getOwnerMachinePool(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if machinePool == nil {
+		return true, nil
+	}
+
+	value, exists := machinePool.Annotations[lifecycleHookAnnotations[hookType]]
+	if !exists {
+		return true, nil
+	}
+
+	invalidHookKey := string(machinePool.UID) + "/" + string(hookType)
+	hook, err := r.lifecycleHooks.Parse(value)
+	if lifecyclehook.IsInvalidHook(err) {
+		r.Logger.Debugf(ctx, "%s hook of MachinePool %#q is invalid: %s", hookType, machinePool.Name, err)
+
+		if r.invalidLifecycleHooks.changed(invalidHookKey, value) {
+			err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, InvalidLifecycleHookReason, "Invalid %s hook in annotation %s of MachinePool %s, waiting for it to be fixed: %s", hookType, lifecycleHookAnnotations[hookType], machinePool.Name, err)
+			if err != nil {
+				return false, microerror.Mask(err)
+			}
+		}
+
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+	r.invalidLifecycleHooks.forget(invalidHookKey)
+
+	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	completed := true
+	var timedOut []string
+	for _, instance := range instances {
+		request := lifecyclehook.Request{
+			Type:      hookType,
+			ClusterID: key.ClusterID(&azureMachinePool),
+			NodePool:  azureMachinePool.Name,
+			Node:      strings.ToLower(*instance.OsProfile.ComputerName),
+		}
+
+		result, err := r.lifecycleHooks.Run(ctx, tenantClusterK8sClient, hook, request)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		switch result.Status {
+		case lifecyclehook.StatusInProgress:
+			r.Logger.Debugf(ctx, "%s hook for node %#q is still in progress", hookType, request.Node)
+			completed = false
+		case lifecyclehook.StatusTimedOut:
+			if result.Finished {
+				timedOut = append(timedOut, request.Node)
+			}
+		}
+	}
+
+	if len(timedOut) > 0 {
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, LifecycleHookTimedOutReason, "%s hook did not complete for nodes %s, carrying on with the upgrade", hookType, strings.Join(timedOut, ", "))
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+	}
+
+	return completed, nil
+}
+
+// invalidHooks remembers the invalid hook annotation values reported for each
+// MachinePool, so that they are reported once and not in every
+// reconciliation loop until they are fixed.
+type invalidHooks struct {
+	mutex  sync.Mutex
+	values map[string]string
+}
+
+func newInvalidHooks() *invalidHooks {
+	return &invalidHooks{
+		values: map[string]string{},
+	}
+}
+
+// changed records the invalid annotation value and returns true when it
+// differs from the value recorded last.
+func (h *invalidHooks) changed(key, value string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	previous, exists := h.values[key]
+	h.values[key] = value
+
+	return !exists || previous != value
+}
+
+func (h *invalidHooks) forget(key string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.values, key)
+}
@@ -0,0 +1,37 @@
+package nodepool
+
+import (
+	"testing"
+)
+
+func Test_invalidHooks_changed(t *testing.T) {
+	h := newInvalidHooks()
+
+	steps := []struct {
+		value           string
+		valid           bool
+		expectedChanged bool
+	}{
+		{value: "webhook", expectedChanged: true},
+		{value: "webhook", expectedChanged: false},
+		{value: `{"webhook":{"url":"http://169.254.169.254"}}`, expectedChanged: true},
+		{value: `{"webhook":{"url":"https://lb.example.com"}}`, valid: true},
+		{value: `{"webhook":{"url":"http://169.254.169.254"}}`, expectedChanged: true},
+	}
+
+	for i, s := range steps {
+		if s.valid {
+			h.forget("uid/pre-drain")
+			continue
+		}
+
+		changed := h.changed("uid/pre-drain", s.value)
+		if changed != s.expectedChanged {
+			t.Fatalf("step %d: changed == %t, want %t", i, changed, s.expectedChanged)
+		}
+	}
+
+	if !h.changed("uid/post-drain", "webhook") {
+		t.Fatalf("hooks of other types must be tracked separately")
+	}
+}
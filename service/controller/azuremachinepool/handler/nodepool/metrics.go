@@ -0,0 +1,74 @@
+package nodepool
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelClusterID  = "cluster_id"
+	labelGeneration = "generation"
+	labelNodePoolID = "node_pool_id"
+	labelState      = "state"
+	labelStatus     = "status"
+)
+
+var (
+	upgradeStateDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_node_pool_upgrade_state_duration_seconds",
+			Help: "Gauge representing the time the node pool upgrade has spent in its current state.",
+		},
+		[]string{labelClusterID, labelNodePoolID, labelState},
+	)
+
+	upgradeInstances = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_node_pool_upgrade_instances",
+			Help: "Gauge representing the number of instances of the node pool being upgraded by generation, old or new.",
+		},
+		[]string{labelClusterID, labelNodePoolID, labelState, labelGeneration},
+	)
+
+	upgradeDrainNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_node_pool_upgrade_drain_nodes",
+			Help: "Gauge representing the number of drained nodes and nodes to drain of the node pool being upgraded.",
+		},
+		[]string{labelClusterID, labelNodePoolID, labelState, labelStatus},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(upgradeStateDuration)
+	prometheus.MustRegister(upgradeInstances)
+	prometheus.MustRegister(upgradeDrainNodes)
+}
+
+// resetUpgradeProgressMetrics removes the upgrade progress series of the
+// given node pool, e.g. because its state changed or the upgrade completed.
+func resetUpgradeProgressMetrics(clusterID, nodePoolID string) {
+	labels := prometheus.Labels{
+		labelClusterID:  clusterID,
+		labelNodePoolID: nodePoolID,
+	}
+
+	upgradeStateDuration.DeletePartialMatch(labels)
+	upgradeInstances.DeletePartialMatch(labels)
+	upgradeDrainNodes.DeletePartialMatch(labels)
+}
+
+func reportStateDuration(clusterID, nodePoolID, state string, d time.Duration) {
+	upgradeStateDuration.WithLabelValues(clusterID, nodePoolID, state).Set(d.Seconds())
+}
+
+func reportInstances(clusterID, nodePoolID, state string, oldInstances, newInstances int) {
+	upgradeInstances.WithLabelValues(clusterID, nodePoolID, state, "old").Set(float64(oldInstances))
+	upgradeInstances.WithLabelValues(clusterID, nodePoolID, state, "new").Set(float64(newInstances))
+}
+
+func reportDrainProgress(clusterID, nodePoolID, state string, drained, total int) {
+	upgradeDrainNodes.WithLabelValues(clusterID, nodePoolID, state, "drained").Set(float64(drained))
+	upgradeDrainNodes.WithLabelValues(clusterID, nodePoolID, state, "total").Set(float64(total))
+}
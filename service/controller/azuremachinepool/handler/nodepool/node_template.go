@@ -0,0 +1,111 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmsku"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// The cluster autoscaler builds a node of an empty VMSS from the tags
+	// with these prefixes, see
+	// https://github.com/kubernetes/autoscaler/blob/master/cluster-autoscaler/cloudprovider/azure/README.md.
+	nodeTemplateLabelTagPrefix    = "k8s.io_cluster-autoscaler_node-template_label_"
+	nodeTemplateTaintTagPrefix    = "k8s.io_cluster-autoscaler_node-template_taint_"
+	nodeTemplateResourceTagPrefix = "k8s.io_cluster-autoscaler_node-template_resources_"
+
+	gpuResourceName = "nvidia.com/gpu"
+)
+
+// nodeTemplateResources are the allocatable resources of a node pool node.
+type nodeTemplateResources struct {
+	CPU      string
+	MemoryGB string
+	GPUs     string
+}
+
+// getClusterAutoscalerNodeTemplateTags returns the VMSS tags describing the
+// resources, labels and taints of the node pool nodes, which allow the cluster
+// autoscaler to scale up the node pool from zero instances.
+func (r *Resource) getClusterAutoscalerNodeTemplateTags(ctx context.Context, machinePool *capiexp.MachinePool, azureMachinePool *capzexp.AzureMachinePool) (map[string]string, error) {
+	var resources nodeTemplateResources
+	{
+		capabilities := map[string]*string{
+			vmsku.CapabilityVCPUs:    &resources.CPU,
+			vmsku.CapabilityMemoryGB: &resources.MemoryGB,
+			vmsku.CapabilityGPUs:     &resources.GPUs,
+		}
+		for name, value := range capabilities {
+			v, err := r.vmsku.Capability(ctx, azureMachinePool.Spec.Template.VMSize, name)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			*value = v
+		}
+	}
+
+	// Invalid labels are reported when rendering the cloud config.
+	labels, _ := key.NodePoolKubeletLabels(machinePool)
+
+	taints, err := key.NodePoolTaints(machinePool)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return clusterAutoscalerNodeTemplateTags(resources, labels, taints), nil
+}
+
+// clusterAutoscalerNodeTemplateTags returns the cluster autoscaler node
+// template tags for the given resources, comma-separated kubelet labels and
+// taints.
+func clusterAutoscalerNodeTemplateTags(resources nodeTemplateResources, labels string, taints []corev1.Taint) map[string]string {
+	tags := map[string]string{}
+
+	if resources.CPU != "" {
+		tags[nodeTemplateResourceTagPrefix+"cpu"] = resources.CPU
+	}
+	if resources.MemoryGB != "" {
+		// Azure reports the memory in GB with decimals, e.g. 3.5.
+		memoryGB, err := strconv.ParseFloat(resources.MemoryGB, 64)
+		if err == nil {
+			tags[nodeTemplateResourceTagPrefix+"memory"] = fmt.Sprintf("%dMi", int64(memoryGB*1024))
+		}
+	}
+	if resources.GPUs != "" && resources.GPUs != "0" {
+		tags[nodeTemplateResourceTagPrefix+nodeTemplateTagName(gpuResourceName)] = resources.GPUs
+	}
+
+	for _, l := range strings.Split(labels, ",") {
+		name, value, found := strings.Cut(l, "=")
+		if !found || name == "" {
+			continue
+		}
+
+		tags[nodeTemplateLabelTagPrefix+nodeTemplateTagName(name)] = value
+	}
+
+	for _, t := range taints {
+		tags[nodeTemplateTaintTagPrefix+nodeTemplateTagName(t.Key)] = fmt.Sprintf("%s:%s", t.Value, t.Effect)
+	}
+
+	return tags
+}
+
+// nodeTemplateTagName encodes a label, taint or resource name the way the
+// cluster autoscaler decodes it, because Azure tag names must not contain
+// slashes.
+func nodeTemplateTagName(name string) string {
+	name = strings.ReplaceAll(name, "_", "~2")
+	name = strings.ReplaceAll(name, "/", "_")
+
+	return name
+}
@@ -0,0 +1,105 @@
+package nodepool
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_clusterAutoscalerNodeTemplateTags(t *testing.T) {
+	testCases := []struct {
+		name         string
+		resources    nodeTemplateResources
+		labels       string
+		taints       []corev1.Taint
+		expectedTags map[string]string
+	}{
+		{
+			name:         "case 0: nothing known about the nodes",
+			expectedTags: map[string]string{},
+		},
+		{
+			name: "case 1: CPU only node pool",
+			resources: nodeTemplateResources{
+				CPU:      "4",
+				MemoryGB: "16",
+				GPUs:     "0",
+			},
+			labels: "giantswarm.io/provider=azure,giantswarm.io/machine-pool=np001",
+			expectedTags: map[string]string{
+				"k8s.io_cluster-autoscaler_node-template_resources_cpu":                    "4",
+				"k8s.io_cluster-autoscaler_node-template_resources_memory":                 "16384Mi",
+				"k8s.io_cluster-autoscaler_node-template_label_giantswarm.io_provider":     "azure",
+				"k8s.io_cluster-autoscaler_node-template_label_giantswarm.io_machine-pool": "np001",
+			},
+		},
+		{
+			name: "case 2: tainted GPU node pool",
+			resources: nodeTemplateResources{
+				CPU:      "6",
+				MemoryGB: "3.5",
+				GPUs:     "1",
+			},
+			labels: "node_type=gpu",
+			taints: []corev1.Taint{
+				{Key: "nvidia.com/gpu", Value: "present", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Effect: corev1.TaintEffectNoExecute},
+			},
+			expectedTags: map[string]string{
+				"k8s.io_cluster-autoscaler_node-template_resources_cpu":            "6",
+				"k8s.io_cluster-autoscaler_node-template_resources_memory":         "3584Mi",
+				"k8s.io_cluster-autoscaler_node-template_resources_nvidia.com_gpu": "1",
+				"k8s.io_cluster-autoscaler_node-template_label_node~2type":         "gpu",
+				"k8s.io_cluster-autoscaler_node-template_taint_nvidia.com_gpu":     "present:NoSchedule",
+				"k8s.io_cluster-autoscaler_node-template_taint_dedicated":          ":NoExecute",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			tags := clusterAutoscalerNodeTemplateTags(tc.resources, tc.labels, tc.taints)
+
+			if !cmp.Equal(tags, tc.expectedTags) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedTags, tags))
+			}
+		})
+	}
+}
+
+func Test_changesRequireRollout(t *testing.T) {
+	testCases := []struct {
+		name            string
+		changes         []string
+		expectedRollout bool
+	}{
+		{
+			name: "case 0: no changes",
+		},
+		{
+			name:    "case 1: scaling and node template only",
+			changes: []string{"scaling", "clusterAutoscalerNodeTemplate"},
+		},
+		{
+			name:            "case 2: vm size changed",
+			changes:         []string{"scaling", "vmSize"},
+			expectedRollout: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			rollout := changesRequireRollout(tc.changes)
+
+			if rollout != tc.expectedRollout {
+				t.Fatalf("expected %t, got %t", tc.expectedRollout, rollout)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package nodepool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// UpgradeProgressCondition reports the progress of the node pool upgrade.
+	// It is True while an upgrade is in progress, with the current state as
+	// reason and an upgradeProgress JSON document as message.
+	UpgradeProgressCondition capi.ConditionType = "UpgradeProgress"
+
+	UpgradeCompletedReason = "UpgradeCompleted"
+)
+
+// upgradeProgress is the structured progress of a node pool upgrade published
+// in the UpgradeProgress condition message. It only changes when the upgrade
+// makes progress, the time spent in each state is reported in the
+// azure_operator_node_pool_upgrade_state_duration_seconds metric.
+type upgradeProgress struct {
+	State        string `json:"state"`
+	OldInstances int    `json:"old_instances"`
+	NewInstances int    `json:"new_instances"`
+	DrainedNodes int    `json:"drained_nodes"`
+	NodesToDrain int    `json:"nodes_to_drain"`
+}
+
+type instanceCountsKey struct{}
+
+// instanceCounts are the numbers of old and new instances the state machine
+// found in the current reconciliation loop. They are reported as upgrade
+// progress without listing the VMSS instances again.
+type instanceCounts struct {
+	known        bool
+	oldInstances int
+	newInstances int
+}
+
+func withInstanceCounts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, instanceCountsKey{}, &instanceCounts{})
+}
+
+func recordInstanceCounts(ctx context.Context, oldInstances, newInstances int) {
+	c, ok := ctx.Value(instanceCountsKey{}).(*instanceCounts)
+	if !ok {
+		return
+	}
+
+	c.known = true
+	c.oldInstances = oldInstances
+	c.newInstances = newInstances
+}
+
+// instanceCountsFromContext returns the instance counts recorded in the
+// current reconciliation loop, if any.
+func instanceCountsFromContext(ctx context.Context) (int, int, bool) {
+	c, ok := ctx.Value(instanceCountsKey{}).(*instanceCounts)
+	if !ok || !c.known {
+		return 0, 0, false
+	}
+
+	return c.oldInstances, c.newInstances, true
+}
+
+// reportUpgradeProgress publishes the progress of the node pool upgrade in
+// the UpgradeProgress condition and in Prometheus metrics. Instance counts are
+// taken from the instances the state machine fetched in this reconciliation
+// loop, or kept from the previous report when it did not fetch any. The
+// condition is only written when the state or the counts change.
+func (r *Resource) reportUpgradeProgress(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, currentState state.State) error {
+	clusterID := key.ClusterID(&azureMachinePool)
+
+	resetUpgradeProgressMetrics(clusterID, azureMachinePool.Name)
+
+	var desired *capi.Condition
+	if currentState == DeploymentUninitialized {
+		if capiconditions.Has(&azureMachinePool, UpgradeProgressCondition) && !capiconditions.IsTrue(&azureMachinePool, UpgradeProgressCondition) {
+			// Nothing changed since the last upgrade completed.
+			return nil
+		}
+
+		desired = capiconditions.FalseCondition(UpgradeProgressCondition, UpgradeCompletedReason, capi.ConditionSeverityInfo, "%s", durationsMessage(&azureMachinePool))
+	} else {
+		var timeInState time.Duration
+		if entered, ok := stateEnteredTimestamp(azureMachinePool); ok && azureMachinePool.Annotations[annotation.StateMachineCurrentState] == string(currentState) {
+			timeInState = r.clock.Now().Sub(entered).Round(time.Second)
+		}
+		reportStateDuration(clusterID, azureMachinePool.Name, string(currentState), timeInState)
+
+		progress := previousUpgradeProgress(azureMachinePool)
+		progress.State = string(currentState)
+
+		if oldInstances, newInstances, ok := instanceCountsFromContext(ctx); ok {
+			progress.OldInstances = oldInstances
+			progress.NewInstances = newInstances
+		}
+		reportInstances(clusterID, azureMachinePool.Name, progress.State, progress.OldInstances, progress.NewInstances)
+
+		progress.DrainedNodes, progress.NodesToDrain = drainProgress(azureMachinePool)
+		reportDrainProgress(clusterID, azureMachinePool.Name, progress.State, progress.DrainedNodes, progress.NodesToDrain)
+
+		message, err := json.Marshal(progress)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		desired = &capi.Condition{
+			Type:    UpgradeProgressCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  progress.State,
+			Message: string(message),
+		}
+	}
+
+	current := capiconditions.Get(&azureMachinePool, UpgradeProgressCondition)
+	if current != nil && current.Status == desired.Status && current.Reason == desired.Reason && current.Message == desired.Message {
+		return nil
+	}
+
+	latest := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: azureMachinePool.Namespace, Name: azureMachinePool.Name}, latest)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	capiconditions.Set(latest, desired)
+
+	err = r.CtrlClient.Status().Update(ctx, latest)
+	if apierrors.IsConflict(err) {
+		r.Logger.Debugf(ctx, "conflict trying to save object in k8s API concurrently, upgrade progress is reported in the next reconciliation loop")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// previousUpgradeProgress returns the progress reported for the upgrade in
+// progress, or an empty progress when there is none.
+func previousUpgradeProgress(azureMachinePool capzexp.AzureMachinePool) upgradeProgress {
+	var progress upgradeProgress
+	if !capiconditions.IsTrue(&azureMachinePool, UpgradeProgressCondition) {
+		return progress
+	}
+
+	// Malformed messages are overwritten with the current progress.
+	_ = json.Unmarshal([]byte(capiconditions.GetMessage(&azureMachinePool, UpgradeProgressCondition)), &progress)
+
+	return progress
+}
+
+func (r *Resource) saveDrainProgress(ctx context.Context, customObject capzexp.AzureMachinePool, drained, total int) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	value := fmt.Sprintf("%d/%d", drained, total)
+	if azureMachinePool.Annotations[annotation.DrainProgress] == value {
+		return nil
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.DrainProgress] = value
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// drainProgress returns the number of drained nodes and the number of nodes
+// being drained in the current state.
+func drainProgress(azureMachinePool capzexp.AzureMachinePool) (int, int) {
+	v, exists := azureMachinePool.Annotations[annotation.DrainProgress]
+	if !exists {
+		return 0, 0
+	}
+
+	parts := strings.SplitN(v, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+
+	drained, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0
+	}
+	total, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0
+	}
+
+	return drained, total
+}
+
+func stateEnteredTimestamp(azureMachinePool capzexp.AzureMachinePool) (time.Time, bool) {
+	v, exists := azureMachinePool.Annotations[annotation.StateMachineStateEnteredTimestamp]
+	if !exists {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func durationsMessage(azureMachinePool *capzexp.AzureMachinePool) string {
+	v, exists := azureMachinePool.Annotations[annotation.StateMachineStateDurations]
+	if !exists {
+		return "No upgrade in progress"
+	}
+
+	return fmt.Sprintf("No upgrade in progress, last upgrade spent %s", v)
+}
+
+// parseStateDurations parses the value of the state durations annotation.
+// Malformed entries are ignored.
+func parseStateDurations(v string) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	if v == "" {
+		return durations
+	}
+
+	for _, entry := range strings.Split(v, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			continue
+		}
+
+		durations[parts[0]] = d
+	}
+
+	return durations
+}
+
+// formatStateDurations formats the value of the state durations annotation
+// with states sorted by name.
+func formatStateDurations(durations map[string]time.Duration) string {
+	var states []string
+	for s := range durations {
+		states = append(states, s)
+	}
+	sort.Strings(states)
+
+	var entries []string
+	for _, s := range states {
+		entries = append(entries, fmt.Sprintf("%s=%s", s, durations[s].Round(time.Second)))
+	}
+
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,189 @@
+package nodepool
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+func Test_parseStateDurations(t *testing.T) {
+	testCases := []struct {
+		name              string
+		value             string
+		expectedDurations map[string]time.Duration
+	}{
+		{
+			name:              "case 0: empty annotation",
+			value:             "",
+			expectedDurations: map[string]time.Duration{},
+		},
+		{
+			name:  "case 1: multiple states",
+			value: "DrainOldWorkerInstances=10m0s,ScaleUpWorkerVMSS=2m30s",
+			expectedDurations: map[string]time.Duration{
+				"DrainOldWorkerInstances": 10 * time.Minute,
+				"ScaleUpWorkerVMSS":       150 * time.Second,
+			},
+		},
+		{
+			name:  "case 2: malformed entries are ignored",
+			value: "DrainOldWorkerInstances=soon,ScaleUpWorkerVMSS,WaitForWorkersToBecomeReady=1s",
+			expectedDurations: map[string]time.Duration{
+				"WaitForWorkersToBecomeReady": time.Second,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			durations := parseStateDurations(tc.value)
+
+			if !cmp.Equal(durations, tc.expectedDurations) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedDurations, durations))
+			}
+
+			// Formatting the parsed durations must be stable.
+			if len(durations) > 0 {
+				formatted := formatStateDurations(durations)
+				if !cmp.Equal(parseStateDurations(formatted), durations) {
+					t.Fatalf("durations %q do not round-trip", formatted)
+				}
+			}
+		})
+	}
+}
+
+func Test_formatStateDurations(t *testing.T) {
+	durations := map[string]time.Duration{
+		"WaitForWorkersToBecomeReady": 90*time.Second + 400*time.Millisecond,
+		"CordonOldWorkerInstances":    time.Second,
+	}
+
+	expected := "CordonOldWorkerInstances=1s,WaitForWorkersToBecomeReady=1m30s"
+	formatted := formatStateDurations(durations)
+	if formatted != expected {
+		t.Fatalf("formatted == %q, want %q", formatted, expected)
+	}
+}
+
+func Test_reportUpgradeProgress(t *testing.T) {
+	const progressMessage = `{"state":"DrainOldWorkerInstances","old_instances":3,"new_instances":3,"drained_nodes":1,"nodes_to_drain":3}`
+
+	testCases := []struct {
+		name string
+
+		currentState    state.State
+		condition       *capi.Condition
+		instanceCounts  []int
+		expectedWrite   bool
+		expectedMessage string
+	}{
+		{
+			name: "case 0: unchanged progress is not written",
+
+			currentState:    DrainOldWorkerInstances,
+			condition:       &capi.Condition{Type: UpgradeProgressCondition, Status: corev1.ConditionTrue, Reason: string(DrainOldWorkerInstances), Message: progressMessage},
+			instanceCounts:  []int{3, 3},
+			expectedWrite:   false,
+			expectedMessage: progressMessage,
+		},
+		{
+			name: "case 1: counts are kept when the state machine did not fetch instances",
+
+			currentState:    DrainOldWorkerInstances,
+			condition:       &capi.Condition{Type: UpgradeProgressCondition, Status: corev1.ConditionTrue, Reason: string(DrainOldWorkerInstances), Message: progressMessage},
+			expectedWrite:   false,
+			expectedMessage: progressMessage,
+		},
+		{
+			name: "case 2: changed counts are written",
+
+			currentState:    DrainOldWorkerInstances,
+			condition:       &capi.Condition{Type: UpgradeProgressCondition, Status: corev1.ConditionTrue, Reason: string(DrainOldWorkerInstances), Message: progressMessage},
+			instanceCounts:  []int{2, 4},
+			expectedWrite:   true,
+			expectedMessage: `{"state":"DrainOldWorkerInstances","old_instances":2,"new_instances":4,"drained_nodes":1,"nodes_to_drain":3}`,
+		},
+		{
+			name: "case 3: changed state is written",
+
+			currentState:    TerminateOldWorkerInstances,
+			condition:       &capi.Condition{Type: UpgradeProgressCondition, Status: corev1.ConditionTrue, Reason: string(DrainOldWorkerInstances), Message: progressMessage},
+			expectedWrite:   true,
+			expectedMessage: `{"state":"TerminateOldWorkerInstances","old_instances":3,"new_instances":3,"drained_nodes":1,"nodes_to_drain":3}`,
+		},
+		{
+			name: "case 4: completed upgrade is written once",
+
+			currentState:    DeploymentUninitialized,
+			condition:       &capi.Condition{Type: UpgradeProgressCondition, Status: corev1.ConditionFalse, Severity: capi.ConditionSeverityInfo, Reason: UpgradeCompletedReason, Message: "No upgrade in progress"},
+			expectedWrite:   false,
+			expectedMessage: "No upgrade in progress",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := withInstanceCounts(context.Background())
+			if tc.instanceCounts != nil {
+				recordInstanceCounts(ctx, tc.instanceCounts[0], tc.instanceCounts[1])
+			}
+
+			azureMachinePool := newTestAzureMachinePool()
+			azureMachinePool.Annotations[annotation.StateMachineCurrentState] = string(DrainOldWorkerInstances)
+			azureMachinePool.Annotations[annotation.DrainProgress] = "1/3"
+			azureMachinePool.Status.Conditions = capi.Conditions{*tc.condition}
+
+			ctrlClient := unittest.FakeK8sClient(azureMachinePool).CtrlClient()
+			err := ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resourceVersion := azureMachinePool.ResourceVersion
+
+			r := &Resource{
+				Resource: nodes.Resource{
+					CtrlClient: ctrlClient,
+					Logger:     microloggertest.New(),
+				},
+				clock: state.SystemClock{},
+			}
+
+			err = r.reportUpgradeProgress(ctx, *azureMachinePool, tc.currentState)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if written := azureMachinePool.ResourceVersion != resourceVersion; written != tc.expectedWrite {
+				t.Fatalf("written == %t, want %t", written, tc.expectedWrite)
+			}
+
+			message := capiconditions.GetMessage(azureMachinePool, UpgradeProgressCondition)
+			if message != tc.expectedMessage {
+				t.Fatalf("message == %q, want %q", message, tc.expectedMessage)
+			}
+		})
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"github.com/giantswarm/microerror"
 
 	"github.com/giantswarm/azure-operator/v8/pkg/credential"
+	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
 	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
 	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmsku"
 )
@@ -17,31 +21,64 @@ const (
 type Config struct {
 	nodes.Config
 	CredentialProvider        credential.Provider
+	DrainRules                drainer.Rules
+	EventRecorder             *event.Recorder
 	GSClientCredentialsConfig auth.ClientCredentialsConfig
-	TenantClientFactory       tenantcluster.Factory
-	VMSKU                     *vmsku.VMSKUs
+	// LifecycleHookWebhookAllowlist lists the URL prefixes lifecycle hook
+	// webhooks may be called at.
+	LifecycleHookWebhookAllowlist lifecyclehook.WebhookAllowlist
+	// StateDeadlines overrides the default deadlines of the given states.
+	StateDeadlines      state.DeadlineMap
+	TenantClientFactory tenantcluster.Factory
+	VMSKU               *vmsku.VMSKUs
 }
 
 // Resource takes care of node pool life cycle.
 type Resource struct {
 	nodes.Resource
-	CredentialProvider  credential.Provider
-	tenantClientFactory tenantcluster.Factory
-	vmsku               *vmsku.VMSKUs
+	CredentialProvider      credential.Provider
+	clock                   state.Clock
+	clusterAutoscalerChecks *clusterAutoscalerChecks
+	drainRules              drainer.Rules
+	eventRecorder           *event.Recorder
+	invalidLifecycleHooks   *invalidHooks
+	lifecycleHooks          *lifecyclehook.Runner
+	stateDeadlines          state.DeadlineMap
+	tenantClientFactory     tenantcluster.Factory
+	vmsku                   *vmsku.VMSKUs
 }
 
 func New(config Config) (*Resource, error) {
+	if config.EventRecorder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.EventRecorder must not be empty", config)
+	}
+
 	config.Name = Name
 	nodesResource, err := nodes.New(config.Config)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
+	lifecycleHooks, err := lifecyclehook.New(lifecyclehook.Config{
+		Logger:           config.Logger,
+		WebhookAllowlist: config.LifecycleHookWebhookAllowlist,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
 	r := &Resource{
-		Resource:            *nodesResource,
-		CredentialProvider:  config.CredentialProvider,
-		tenantClientFactory: config.TenantClientFactory,
-		vmsku:               config.VMSKU,
+		Resource:                *nodesResource,
+		CredentialProvider:      config.CredentialProvider,
+		clock:                   state.SystemClock{},
+		clusterAutoscalerChecks: newClusterAutoscalerChecks(),
+		drainRules:              config.DrainRules,
+		eventRecorder:           config.EventRecorder,
+		invalidLifecycleHooks:   newInvalidHooks(),
+		lifecycleHooks:          lifecycleHooks,
+		stateDeadlines:          newStateDeadlines(config.StateDeadlines),
+		tenantClientFactory:     config.TenantClientFactory,
+		vmsku:                   config.VMSKU,
 	}
 	stateMachine := r.createStateMachine()
 	r.SetStateMachine(stateMachine)
@@ -0,0 +1,279 @@
+package nodepool
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// rollingUpdateBatch holds the numbers driving a single iteration of the
+// node pool rolling update.
+type rollingUpdateBatch struct {
+	// Surge is the number of instances created above the desired node pool
+	// size.
+	Surge int
+	// Size is the number of outdated instances replaced in this iteration.
+	Size int
+}
+
+// computeRollingUpdateBatch computes the next batch of the rolling update
+// given the desired node pool size and the number of outdated instances that
+// still have to be replaced. It follows the semantics of maxSurge and
+// maxUnavailable used by Deployments: percentages are rounded up for the
+// surge and down for the unavailable instances.
+func computeRollingUpdateBatch(desiredReplicas int, outdatedReplicas int, maxSurge intstr.IntOrString, maxUnavailable intstr.IntOrString) (rollingUpdateBatch, error) {
+	surge, err := intstr.GetScaledValueFromIntOrPercent(&maxSurge, desiredReplicas, true)
+	if err != nil {
+		return rollingUpdateBatch{}, microerror.Maskf(invalidRollingUpdateError, "maxSurge %q: %s", maxSurge.String(), err)
+	}
+	if surge < 0 {
+		return rollingUpdateBatch{}, microerror.Maskf(invalidRollingUpdateError, "maxSurge %q must not be negative", maxSurge.String())
+	}
+
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, desiredReplicas, false)
+	if err != nil {
+		return rollingUpdateBatch{}, microerror.Maskf(invalidRollingUpdateError, "maxUnavailable %q: %s", maxUnavailable.String(), err)
+	}
+	if unavailable < 0 {
+		return rollingUpdateBatch{}, microerror.Maskf(invalidRollingUpdateError, "maxUnavailable %q must not be negative", maxUnavailable.String())
+	}
+
+	// We always need to make progress, so we replace at least one instance
+	// at a time.
+	if surge == 0 && unavailable == 0 {
+		surge = 1
+	}
+
+	if surge > outdatedReplicas {
+		surge = outdatedReplicas
+	}
+
+	size := surge + unavailable
+	if size > outdatedReplicas {
+		size = outdatedReplicas
+	}
+
+	return rollingUpdateBatch{Surge: surge, Size: size}, nil
+}
+
+// getRollingUpdateBatch returns the outdated instances to be replaced during
+// the next iteration of the rolling update. Instances are taken across the
+// node pool zones so that the node pool stays balanced. The result depends on
+// the instances running, so it is only computed when cordoning and then saved
+// with saveRollingUpdateBatch for the following states of the iteration.
+//
+// When surge instances are created, instances in zones without up to date
+// instances are left out of the batch until new instances run there. Only
+// when all outdated instances are in such zones they are returned together
+// with the uncovered zones, so that callers can wait for the zones to be
+// covered before replacing them.
+func (r *Resource) getRollingUpdateBatch(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, oldInstances []compute.VirtualMachineScaleSetVM, newInstances []compute.VirtualMachineScaleSetVM) ([]compute.VirtualMachineScaleSetVM, []string, error) {
+	machinePool, err := r.getOwnerMachinePool(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	if machinePool == nil {
+		return nil, nil, microerror.Mask(ownerReferenceNotSet)
+	}
+
+	desiredReplicas, found := rollingUpdateDesiredReplicas(azureMachinePool)
+	if !found {
+		desiredReplicas = len(oldInstances) + len(newInstances)
+	}
+
+	batch, err := computeRollingUpdateBatch(desiredReplicas, len(oldInstances), key.NodePoolMaxSurge(machinePool, &azureMachinePool), key.NodePoolMaxUnavailable(machinePool, &azureMachinePool))
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	instances, uncovered := batchCandidates(machinePool.Spec.FailureDomains, oldInstances, newInstances, batch.Surge > 0)
+	if len(instances) < batch.Size {
+		batch.Size = len(instances)
+	}
+
+	r.Logger.Debugf(ctx, "rolling update batch contains %d of %d outdated instances", batch.Size, len(oldInstances))
+
+	return instances[:batch.Size], uncovered, nil
+}
+
+// batchCandidates returns the outdated instances the rolling update batch is
+// taken from, in zone balanced order. When zone coverage is enforced,
+// instances in zones without up to date instances are left out. Without surge
+// instances, new instances are only created once outdated ones are removed,
+// so zones can not be covered first. When all outdated instances are in
+// uncovered zones, all of them are returned together with the uncovered
+// zones.
+func batchCandidates(poolZones []string, oldInstances []compute.VirtualMachineScaleSetVM, newInstances []compute.VirtualMachineScaleSetVM, enforceZoneCoverage bool) ([]compute.VirtualMachineScaleSetVM, []string) {
+	ordered := zoneBalancedOrder(oldInstances)
+	if !enforceZoneCoverage {
+		return ordered, nil
+	}
+
+	uncovered := uncoveredZones(poolZones, oldInstances, newInstances)
+	isUncovered := map[string]bool{}
+	for _, zone := range uncovered {
+		isUncovered[zone] = true
+	}
+
+	var covered []compute.VirtualMachineScaleSetVM
+	for _, instance := range ordered {
+		if !isUncovered[instanceZone(instance)] {
+			covered = append(covered, instance)
+		}
+	}
+
+	if len(covered) == 0 {
+		return ordered, uncovered
+	}
+
+	return covered, nil
+}
+
+// savedRollingUpdateBatch returns the outdated instances saved as the batch
+// of the current rolling update iteration, in the order they were saved.
+// Instances of the batch which are not outdated anymore, e.g. because they are
+// being deleted, are left out. It returns false when no batch was saved.
+func savedRollingUpdateBatch(azureMachinePool capzexp.AzureMachinePool, oldInstances []compute.VirtualMachineScaleSetVM) ([]compute.VirtualMachineScaleSetVM, bool) {
+	v, exists := azureMachinePool.Annotations[annotation.RollingUpdateBatch]
+	if !exists {
+		return nil, false
+	}
+
+	byID := map[string]compute.VirtualMachineScaleSetVM{}
+	for _, instance := range oldInstances {
+		byID[*instance.InstanceID] = instance
+	}
+
+	var batch []compute.VirtualMachineScaleSetVM
+	for _, id := range strings.Split(v, ",") {
+		if instance, ok := byID[id]; ok {
+			batch = append(batch, instance)
+		}
+	}
+
+	return batch, true
+}
+
+func (r *Resource) saveRollingUpdateBatch(ctx context.Context, customObject capzexp.AzureMachinePool, batch []compute.VirtualMachineScaleSetVM) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	var ids []string
+	for _, instance := range batch {
+		ids = append(ids, *instance.InstanceID)
+	}
+	azureMachinePool.Annotations[annotation.RollingUpdateBatch] = strings.Join(ids, ",")
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) removeRollingUpdateBatch(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if _, exists := azureMachinePool.Annotations[annotation.RollingUpdateBatch]; !exists {
+		return nil
+	}
+
+	delete(azureMachinePool.Annotations, annotation.RollingUpdateBatch)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// rollingUpdateDesiredReplicas returns the node pool size recorded when the
+// rolling update started.
+func rollingUpdateDesiredReplicas(azureMachinePool capzexp.AzureMachinePool) (int, bool) {
+	v, exists := azureMachinePool.Annotations[annotation.RollingUpdateDesiredReplicas]
+	if !exists {
+		return 0, false
+	}
+
+	replicas, err := strconv.Atoi(v)
+	if err != nil || replicas < 0 {
+		return 0, false
+	}
+
+	return replicas, true
+}
+
+func (r *Resource) saveRollingUpdateDesiredReplicas(ctx context.Context, customObject capzexp.AzureMachinePool, replicas int) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.RollingUpdateDesiredReplicas] = strconv.Itoa(replicas)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *Resource) removeRollingUpdateDesiredReplicas(ctx context.Context, customObject capzexp.AzureMachinePool) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if _, exists := azureMachinePool.Annotations[annotation.RollingUpdateDesiredReplicas]; !exists {
+		return nil
+	}
+
+	delete(azureMachinePool.Annotations, annotation.RollingUpdateDesiredReplicas)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func instanceIDLess(a, b compute.VirtualMachineScaleSetVM) bool {
+	idA, errA := strconv.ParseUint(*a.InstanceID, 10, 64)
+	idB, errB := strconv.ParseUint(*b.InstanceID, 10, 64)
+	if errA != nil || errB != nil {
+		return *a.InstanceID < *b.InstanceID
+	}
+
+	return idA < idB
+}
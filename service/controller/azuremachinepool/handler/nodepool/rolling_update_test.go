@@ -0,0 +1,328 @@
+package nodepool
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/mock/mock_tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+func Test_computeRollingUpdateBatch(t *testing.T) {
+	testCases := []struct {
+		name             string
+		desiredReplicas  int
+		outdatedReplicas int
+		maxSurge         intstr.IntOrString
+		maxUnavailable   intstr.IntOrString
+		expectedBatch    rollingUpdateBatch
+		errorMatcher     func(error) bool
+	}{
+		{
+			name:             "case 0: default settings double the node pool",
+			desiredReplicas:  10,
+			outdatedReplicas: 10,
+			maxSurge:         intstr.FromString("100%"),
+			maxUnavailable:   intstr.FromInt(0),
+			expectedBatch:    rollingUpdateBatch{Surge: 10, Size: 10},
+		},
+		{
+			name:             "case 1: absolute surge",
+			desiredReplicas:  80,
+			outdatedReplicas: 80,
+			maxSurge:         intstr.FromInt(10),
+			maxUnavailable:   intstr.FromInt(0),
+			expectedBatch:    rollingUpdateBatch{Surge: 10, Size: 10},
+		},
+		{
+			name:             "case 2: surge and unavailable",
+			desiredReplicas:  80,
+			outdatedReplicas: 80,
+			maxSurge:         intstr.FromInt(10),
+			maxUnavailable:   intstr.FromInt(5),
+			expectedBatch:    rollingUpdateBatch{Surge: 10, Size: 15},
+		},
+		{
+			name:             "case 3: percentages are rounded up for surge and down for unavailable",
+			desiredReplicas:  15,
+			outdatedReplicas: 15,
+			maxSurge:         intstr.FromString("10%"),
+			maxUnavailable:   intstr.FromString("10%"),
+			expectedBatch:    rollingUpdateBatch{Surge: 2, Size: 3},
+		},
+		{
+			name:             "case 4: last batch is capped by the outdated instances",
+			desiredReplicas:  80,
+			outdatedReplicas: 3,
+			maxSurge:         intstr.FromInt(10),
+			maxUnavailable:   intstr.FromInt(5),
+			expectedBatch:    rollingUpdateBatch{Surge: 3, Size: 3},
+		},
+		{
+			name:             "case 5: only unavailable instances",
+			desiredReplicas:  10,
+			outdatedReplicas: 10,
+			maxSurge:         intstr.FromInt(0),
+			maxUnavailable:   intstr.FromInt(2),
+			expectedBatch:    rollingUpdateBatch{Surge: 0, Size: 2},
+		},
+		{
+			name:             "case 6: zero surge and zero unavailable replace one instance at a time",
+			desiredReplicas:  10,
+			outdatedReplicas: 10,
+			maxSurge:         intstr.FromInt(0),
+			maxUnavailable:   intstr.FromInt(0),
+			expectedBatch:    rollingUpdateBatch{Surge: 1, Size: 1},
+		},
+		{
+			name:             "case 7: no outdated instances",
+			desiredReplicas:  10,
+			outdatedReplicas: 0,
+			maxSurge:         intstr.FromString("100%"),
+			maxUnavailable:   intstr.FromInt(0),
+			expectedBatch:    rollingUpdateBatch{Surge: 0, Size: 0},
+		},
+		{
+			name:             "case 8: invalid surge",
+			desiredReplicas:  10,
+			outdatedReplicas: 10,
+			maxSurge:         intstr.FromString("many"),
+			maxUnavailable:   intstr.FromInt(0),
+			errorMatcher:     IsInvalidRollingUpdate,
+		},
+		{
+			name:             "case 9: negative unavailable",
+			desiredReplicas:  10,
+			outdatedReplicas: 10,
+			maxSurge:         intstr.FromInt(1),
+			maxUnavailable:   intstr.FromInt(-1),
+			errorMatcher:     IsInvalidRollingUpdate,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			batch, err := computeRollingUpdateBatch(tc.desiredReplicas, tc.outdatedReplicas, tc.maxSurge, tc.maxUnavailable)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(batch, tc.expectedBatch) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedBatch, batch))
+			}
+		})
+	}
+}
+
+func Test_savedRollingUpdateBatch(t *testing.T) {
+	oldInstances := []compute.VirtualMachineScaleSetVM{
+		{InstanceID: to.StringPtr("1")},
+		{InstanceID: to.StringPtr("2")},
+		{InstanceID: to.StringPtr("3")},
+	}
+
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		expectedFound       bool
+		expectedInstanceIDs []string
+	}{
+		{
+			name:          "case 0: no batch saved",
+			annotations:   map[string]string{},
+			expectedFound: false,
+		},
+		{
+			name:                "case 1: saved order is kept",
+			annotations:         map[string]string{annotation.RollingUpdateBatch: "3,1"},
+			expectedFound:       true,
+			expectedInstanceIDs: []string{"3", "1"},
+		},
+		{
+			name:                "case 2: instances not outdated anymore are left out",
+			annotations:         map[string]string{annotation.RollingUpdateBatch: "4,2"},
+			expectedFound:       true,
+			expectedInstanceIDs: []string{"2"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			azureMachinePool := capzexp.AzureMachinePool{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+			}
+
+			batch, found := savedRollingUpdateBatch(azureMachinePool, oldInstances)
+			if found != tc.expectedFound {
+				t.Fatalf("found == %t, want %t", found, tc.expectedFound)
+			}
+
+			var instanceIDs []string
+			for _, instance := range batch {
+				instanceIDs = append(instanceIDs, *instance.InstanceID)
+			}
+
+			if !cmp.Equal(instanceIDs, tc.expectedInstanceIDs) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedInstanceIDs, instanceIDs))
+			}
+		})
+	}
+}
+
+// Test_RollingUpdateBatchTransitions checks that the instances cordoned are
+// the ones drained and terminated, even when the zones covered by new
+// instances change between the states of the iteration.
+func Test_RollingUpdateBatchTransitions(t *testing.T) {
+	ctx := context.Background()
+
+	machinePool := newTestMachinePool()
+	machinePool.Spec.FailureDomains = []string{"1", "2"}
+
+	azureMachinePool := newTestAzureMachinePool()
+	azureMachinePool.Annotations[annotation.RollingUpdateDesiredReplicas] = "2"
+
+	ctrlClient := unittest.FakeK8sClient(newTestCluster(), machinePool, azureMachinePool).CtrlClient()
+	wcCtrlClient := fake.NewClientBuilder().WithObjects(newTestNode("0"), newTestNode("1"), newTestNode("2"), newTestNode("3")).Build()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+	tenantClientFactory.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(wcCtrlClient, nil).AnyTimes()
+	tenantClientFactory.EXPECT().GetAllClients(gomock.Any(), gomock.Any()).Return(k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+		CtrlClient: wcCtrlClient,
+		K8sClient:  kubernetesfake.NewSimpleClientset(),
+	}), nil).AnyTimes()
+
+	// Only zone 2 has a new instance, so the old instance in zone 1 is kept
+	// until its zone is covered.
+	vmssAPI := &fakeVMSSAPI{
+		vmss: compute.VirtualMachineScaleSet{
+			Sku: &compute.Sku{Name: to.StringPtr(testSKU), Capacity: to.Int64Ptr(3)},
+			VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+				VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+					StorageProfile: &compute.VirtualMachineScaleSetStorageProfile{
+						ImageReference: &compute.ImageReference{Version: to.StringPtr(testImageVersion)},
+					},
+				},
+			},
+			Tags: map[string]*string{},
+		},
+		instances: []testInstance{
+			{id: "0", sku: oldTestSKU, zone: "1", latestModelApplied: true, provisioningState: "Succeeded"},
+			{id: "1", sku: oldTestSKU, zone: "2", latestModelApplied: true, provisioningState: "Succeeded"},
+			{id: "2", sku: testSKU, zone: "2", latestModelApplied: true, provisioningState: "Succeeded"},
+		},
+	}
+	server := httptest.NewServer(vmssAPI)
+	defer server.Close()
+
+	r := &Resource{
+		Resource: nodes.Resource{
+			CtrlClient:    ctrlClient,
+			Logger:        microloggertest.New(),
+			ClientFactory: &fakeClientFactory{baseURI: server.URL},
+		},
+		clock:               state.SystemClock{},
+		tenantClientFactory: tenantClientFactory,
+	}
+
+	transition := func(f func(*Resource, context.Context, interface{}, state.State) (state.State, error), currentState state.State, expectedState state.State) {
+		t.Helper()
+
+		err := ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newState, err := f(r, ctx, azureMachinePool, currentState)
+		if err != nil {
+			t.Fatalf("unexpected error %#v", err)
+		}
+		if newState != expectedState {
+			t.Fatalf("state == %q, want %q", newState, expectedState)
+		}
+	}
+
+	transition((*Resource).cordonOldWorkerInstances, CordonOldWorkerInstances, DrainOldWorkerInstances)
+
+	nodeList := &corev1.NodeList{}
+	err := wcCtrlClient.List(ctx, nodeList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unschedulable []string
+	for _, n := range nodeList.Items {
+		if n.Spec.Unschedulable {
+			unschedulable = append(unschedulable, n.Name)
+		}
+	}
+	if !cmp.Equal(unschedulable, testNodeNames([]string{"1"})) {
+		t.Fatalf("unschedulable nodes\n\n%s\n", cmp.Diff(testNodeNames([]string{"1"}), unschedulable))
+	}
+
+	// The new instance moves from zone 2 to zone 1. Computing the batch
+	// again would now pick the old instance in zone 1, which was never
+	// cordoned.
+	vmssAPI.mutex.Lock()
+	vmssAPI.instances = []testInstance{
+		{id: "0", sku: oldTestSKU, zone: "1", latestModelApplied: true, provisioningState: "Succeeded"},
+		{id: "1", sku: oldTestSKU, zone: "2", latestModelApplied: true, provisioningState: "Succeeded"},
+		{id: "3", sku: testSKU, zone: "1", latestModelApplied: true, provisioningState: "Succeeded"},
+	}
+	vmssAPI.mutex.Unlock()
+
+	transition((*Resource).drainOldWorkerInstances, DrainOldWorkerInstances, TerminateOldWorkerInstances)
+	transition((*Resource).terminateOldWorkersTransition, TerminateOldWorkerInstances, TerminateOldWorkerInstances)
+
+	if !cmp.Equal(vmssAPI.deleted, []string{"1"}) {
+		t.Fatalf("deleted instances\n\n%s\n", cmp.Diff([]string{"1"}, vmssAPI.deleted))
+	}
+
+	// Once the instance is being deleted the iteration is completed and the
+	// batch is removed.
+	vmssAPI.mutex.Lock()
+	vmssAPI.instances[1].provisioningState = provisioningStateDeleting
+	vmssAPI.mutex.Unlock()
+
+	transition((*Resource).terminateOldWorkersTransition, TerminateOldWorkerInstances, ScaleUpWorkerVMSS)
+
+	err = ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := azureMachinePool.Annotations[annotation.RollingUpdateBatch]; ok {
+		t.Fatalf("rolling update batch == %q, want it removed", v)
+	}
+}
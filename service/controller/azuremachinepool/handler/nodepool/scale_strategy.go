@@ -0,0 +1,54 @@
+package nodepool
+
+import (
+	"context"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/scalestrategy"
+)
+
+// getScaleStrategy returns the scale strategy configured for the node pool.
+// Invalid settings are reported by the azuremachinepoolconditions handler,
+// here we fall back to the default strategy so that upgrades are not blocked.
+func (r *Resource) getScaleStrategy(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) (scalestrategy.Interface, string) {
+	config, err := scalestrategy.ConfigFromAnnotations(azureMachinePool.Annotations)
+	if err == nil {
+		config.Now = r.clock.Now()
+
+		var strategy scalestrategy.Interface
+		strategy, err = scalestrategy.New(config)
+		if err == nil {
+			return strategy, config.Name
+		}
+	}
+
+	r.Logger.LogCtx(ctx, "level", "warning", "message", "invalid scale strategy settings, falling back to default strategy", "stack", microerror.JSON(err))
+
+	return scalestrategy.Staircase{}, scalestrategy.NameStaircase
+}
+
+func (r *Resource) saveScaleStrategyLastScaleTimestamp(ctx context.Context, customObject capzexp.AzureMachinePool, t time.Time) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if azureMachinePool.Annotations == nil {
+		azureMachinePool.Annotations = map[string]string{}
+	}
+
+	azureMachinePool.Annotations[annotation.ScaleStrategyLastScaleTimestamp] = t.UTC().Format(time.RFC3339)
+
+	err = r.CtrlClient.Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
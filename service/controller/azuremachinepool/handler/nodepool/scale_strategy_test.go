@@ -0,0 +1,50 @@
+package nodepool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/scalestrategy"
+)
+
+func Test_getScaleStrategy_UsesResourceClock(t *testing.T) {
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+	r := &Resource{clock: fakeClock{now: now}}
+
+	azureMachinePool := capzexp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotation.ScaleStrategy:                   scalestrategy.NameTimeBased,
+				annotation.ScaleStrategyStep:               "2",
+				annotation.ScaleStrategyInterval:           "10m",
+				annotation.ScaleStrategyLastScaleTimestamp: now.Add(-5 * time.Minute).Format(time.RFC3339),
+			},
+		},
+	}
+
+	strategy, name := r.getScaleStrategy(context.Background(), azureMachinePool)
+	if name != scalestrategy.NameTimeBased {
+		t.Fatalf("name == %q, want %q", name, scalestrategy.NameTimeBased)
+	}
+
+	expected := scalestrategy.TimeBased{
+		Interval:  10 * time.Minute,
+		LastScale: now.Add(-5 * time.Minute),
+		Now:       now,
+		Step:      2,
+	}
+	if !cmp.Equal(strategy, expected) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expected, strategy))
+	}
+
+	// The interval has not elapsed yet according to the resource clock.
+	if count := strategy.GetNodeCount(3, 6); count != 3 {
+		t.Fatalf("count == %d, want %d", count, 3)
+	}
+}
@@ -2,6 +2,7 @@ package nodepool
 
 import (
 	"context"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
@@ -21,6 +22,16 @@ const (
 	DrainOldWorkerInstances     = "DrainOldWorkerInstances"
 	TerminateOldWorkerInstances = "TerminateOldWorkerInstances"
 	WaitForWorkersToBecomeReady = "WaitForWorkersToBecomeReady"
+
+	// States used when the node pool is upgraded in place.
+	InPlaceCordonWorkerInstance  = "InPlaceCordonWorkerInstance"
+	InPlaceDrainWorkerInstance   = "InPlaceDrainWorkerInstance"
+	InPlaceUpgradeWorkerInstance = "InPlaceUpgradeWorkerInstance"
+	InPlaceWaitForWorkerInstance = "InPlaceWaitForWorkerInstance"
+
+	// UpgradeRolledBack is set once an aborted upgrade was rolled back. The
+	// state machine is not executed in this state.
+	UpgradeRolledBack = "UpgradeRolledBack"
 )
 
 func (r *Resource) saveCurrentState(ctx context.Context, customObject capzexp.AzureMachinePool, state string) error {
@@ -39,6 +50,28 @@ func (r *Resource) saveCurrentState(ctx context.Context, customObject capzexp.Az
 		azureMachinePool.Annotations = map[string]string{}
 	}
 
+	previousState := azureMachinePool.Annotations[annotation.StateMachineCurrentState]
+	if previousState != state {
+		now := r.clock.Now().UTC()
+
+		durations := parseStateDurations(azureMachinePool.Annotations[annotation.StateMachineStateDurations])
+		if previousState == DeploymentUninitialized {
+			// A new upgrade starts, durations of the previous one are dropped.
+			durations = map[string]time.Duration{}
+		} else if entered, ok := stateEnteredTimestamp(*azureMachinePool); ok {
+			durations[previousState] += now.Sub(entered)
+		}
+
+		if len(durations) > 0 {
+			azureMachinePool.Annotations[annotation.StateMachineStateDurations] = formatStateDurations(durations)
+		} else {
+			delete(azureMachinePool.Annotations, annotation.StateMachineStateDurations)
+		}
+
+		azureMachinePool.Annotations[annotation.StateMachineStateEnteredTimestamp] = now.Format(time.RFC3339)
+		delete(azureMachinePool.Annotations, annotation.DrainProgress)
+	}
+
 	azureMachinePool.Annotations[annotation.StateMachineCurrentState] = state
 
 	err = r.CtrlClient.Update(ctx, azureMachinePool)
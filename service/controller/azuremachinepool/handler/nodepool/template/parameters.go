@@ -7,23 +7,35 @@ import (
 	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 )
 
+// defaultCachingType is the caching type of disks in deployments created by
+// older versions.
+const defaultCachingType = "ReadWrite"
+
 type Parameters struct {
-	AzureOperatorVersion        string
-	ClusterID                   string
-	CGroupsVersion              string
-	DataDisks                   []capz.DataDisk
-	EnableAcceleratedNetworking bool
-	KubernetesVersion           string
-	NodepoolName                string
-	OSImage                     OSImage
-	Scaling                     Scaling
-	SpotInstanceConfig          SpotInstanceConfig
-	StorageAccountType          string
-	SubnetName                  string
-	VMCustomData                string
-	VMSize                      string
-	VnetName                    string
-	Zones                       []string
+	AzureOperatorVersion string
+	ClusterID            string
+	// ClusterAutoscalerNodeTemplateTags are the VMSS tags the cluster
+	// autoscaler uses to build a node of the node pool when it is empty.
+	ClusterAutoscalerNodeTemplateTags map[string]string
+	CGroupsVersion                    string
+	DataDisks                         []capz.DataDisk
+	EnableAcceleratedNetworking       bool
+	// IPv6Enabled gives the VMs an IPv6 address from the dual-stack subnet
+	// of the node pool.
+	IPv6Enabled        bool
+	KubernetesVersion  string
+	NodepoolName       string
+	OSDisk             OSDisk
+	OSImage            OSImage
+	Scaling            Scaling
+	SpotInstanceConfig SpotInstanceConfig
+	StorageAccountType string
+	SubnetName         string
+	VMCustomData       string
+	VMSize             string
+	VnetName           string
+	VnetResourceGroup  string
+	Zones              []string
 }
 
 type Scaling struct {
@@ -37,6 +49,14 @@ type SpotInstanceConfig struct {
 	MaxPrice string
 }
 
+type OSDisk struct {
+	CachingType string
+	// DiskEncryptionSetID is the ID of the disk encryption set used to
+	// encrypt the disk with a customer-managed key.
+	DiskEncryptionSetID string
+	Ephemeral           bool
+}
+
 type OSImage struct {
 	Publisher string
 	Offer     string
@@ -67,7 +87,34 @@ func NewFromExtendedDeployment(deployment azureresource.DeploymentExtended) (Par
 func (p Parameters) ToDeployParams() map[string]interface{} {
 	var dataDisks []interface{}
 	for _, disk := range p.DataDisks {
-		dataDisks = append(dataDisks, map[string]interface{}{"nameSuffix": disk.NameSuffix, "lun": float64(*disk.Lun), "diskSizeGB": float64(disk.DiskSizeGB)})
+		cachingType := disk.CachingType
+		if cachingType == "" {
+			cachingType = defaultCachingType
+		}
+		storageAccountType := p.StorageAccountType
+		diskEncryptionSetID := ""
+		if disk.ManagedDisk != nil {
+			if disk.ManagedDisk.StorageAccountType != "" {
+				storageAccountType = disk.ManagedDisk.StorageAccountType
+			}
+			if disk.ManagedDisk.DiskEncryptionSet != nil {
+				diskEncryptionSetID = disk.ManagedDisk.DiskEncryptionSet.ID
+			}
+		}
+
+		dataDisks = append(dataDisks, map[string]interface{}{
+			"nameSuffix":          disk.NameSuffix,
+			"lun":                 float64(*disk.Lun),
+			"diskSizeGB":          float64(disk.DiskSizeGB),
+			"cachingType":         cachingType,
+			"storageAccountType":  storageAccountType,
+			"diskEncryptionSetID": diskEncryptionSetID,
+		})
+	}
+
+	osDiskCachingType := p.OSDisk.CachingType
+	if osDiskCachingType == "" {
+		osDiskCachingType = defaultCachingType
 	}
 
 	zones := []interface{}{}
@@ -75,14 +122,24 @@ func (p Parameters) ToDeployParams() map[string]interface{} {
 		zones = append(zones, zone)
 	}
 
+	nodeTemplateTags := map[string]interface{}{}
+	for name, value := range p.ClusterAutoscalerNodeTemplateTags {
+		nodeTemplateTags[name] = value
+	}
+
 	armDeploymentParameters := map[string]interface{}{}
 	armDeploymentParameters["azureOperatorVersion"] = toARMParam(p.AzureOperatorVersion)
 	armDeploymentParameters["clusterID"] = toARMParam(p.ClusterID)
+	armDeploymentParameters["clusterAutoscalerNodeTemplateTags"] = toARMParam(nodeTemplateTags)
 	armDeploymentParameters["cGroupsVersion"] = toARMParam(p.CGroupsVersion)
 	armDeploymentParameters["dataDisks"] = toARMParam(dataDisks)
 	armDeploymentParameters["enableAcceleratedNetworking"] = toARMParam(p.EnableAcceleratedNetworking)
+	armDeploymentParameters["ipv6Enabled"] = toARMParam(p.IPv6Enabled)
 	armDeploymentParameters["kubernetesVersion"] = toARMParam(p.KubernetesVersion)
 	armDeploymentParameters["nodepoolName"] = toARMParam(p.NodepoolName)
+	armDeploymentParameters["osDiskCachingType"] = toARMParam(osDiskCachingType)
+	armDeploymentParameters["osDiskEncryptionSetID"] = toARMParam(p.OSDisk.DiskEncryptionSetID)
+	armDeploymentParameters["osDiskEphemeral"] = toARMParam(p.OSDisk.Ephemeral)
 	armDeploymentParameters["osImagePublisher"] = toARMParam(p.OSImage.Publisher)
 	armDeploymentParameters["osImageOffer"] = toARMParam(p.OSImage.Offer)
 	armDeploymentParameters["osImageSKU"] = toARMParam(p.OSImage.SKU)
@@ -97,6 +154,7 @@ func (p Parameters) ToDeployParams() map[string]interface{} {
 	armDeploymentParameters["vmCustomData"] = toARMParam(p.VMCustomData)
 	armDeploymentParameters["vmSize"] = toARMParam(p.VMSize)
 	armDeploymentParameters["vnetName"] = toARMParam(p.VnetName)
+	armDeploymentParameters["vnetResourceGroup"] = toARMParam(p.VnetResourceGroup)
 	armDeploymentParameters["zones"] = toARMParam(zones)
 
 	return armDeploymentParameters
@@ -111,6 +169,8 @@ func toARMParam(v interface{}) interface{} {
 }
 
 func newParameters(parameters map[string]interface{}, cast func(param interface{}) interface{}) (Parameters, error) {
+	storageAccountType := cast(parameters["storageAccountType"]).(string)
+
 	// DataDisks is an untyped array so we need to work a little bit to get the right types.
 	var dataDisks []capz.DataDisk
 	disks, ok := cast(parameters["dataDisks"]).([]interface{})
@@ -123,13 +183,45 @@ func newParameters(parameters map[string]interface{}, cast func(param interface{
 		if !ok {
 			return Parameters{}, microerror.Maskf(wrongTypeError, "disk should be map[string]interface{}, got '%T'", disk)
 		}
+
+		// Deployments created by older versions only have the name, size and
+		// LUN of the disks.
+		cachingType := defaultCachingType
+		if d["cachingType"] != nil {
+			cachingType = d["cachingType"].(string)
+		}
+		managedDisk := &capz.ManagedDiskParameters{
+			StorageAccountType: storageAccountType,
+		}
+		if d["storageAccountType"] != nil {
+			managedDisk.StorageAccountType = d["storageAccountType"].(string)
+		}
+		if d["diskEncryptionSetID"] != nil && d["diskEncryptionSetID"].(string) != "" {
+			managedDisk.DiskEncryptionSet = &capz.DiskEncryptionSetParameters{ID: d["diskEncryptionSetID"].(string)}
+		}
+
 		dataDisks = append(dataDisks, capz.DataDisk{
-			NameSuffix: d["nameSuffix"].(string),
-			DiskSizeGB: int32(d["diskSizeGB"].(float64)),
-			Lun:        to.Int32Ptr(int32(d["lun"].(float64))),
+			NameSuffix:  d["nameSuffix"].(string),
+			DiskSizeGB:  int32(d["diskSizeGB"].(float64)),
+			Lun:         to.Int32Ptr(int32(d["lun"].(float64))),
+			CachingType: cachingType,
+			ManagedDisk: managedDisk,
 		})
 	}
 
+	osDisk := OSDisk{
+		CachingType: defaultCachingType,
+	}
+	if parameters["osDiskCachingType"] != nil {
+		osDisk.CachingType = cast(parameters["osDiskCachingType"]).(string)
+	}
+	if parameters["osDiskEncryptionSetID"] != nil {
+		osDisk.DiskEncryptionSetID = cast(parameters["osDiskEncryptionSetID"]).(string)
+	}
+	if parameters["osDiskEphemeral"] != nil {
+		osDisk.Ephemeral = cast(parameters["osDiskEphemeral"]).(bool)
+	}
+
 	// Zones is an untyped array so we need to work a little bit to get the right types.
 	var zones []string
 	rawZones, ok := cast(parameters["zones"]).([]interface{})
@@ -146,6 +238,27 @@ func newParameters(parameters map[string]interface{}, cast func(param interface{
 		zones = append(zones, zone)
 	}
 
+	// Deployments created by older versions don't have node template tags.
+	var nodeTemplateTags map[string]string
+	if parameters["clusterAutoscalerNodeTemplateTags"] != nil {
+		rawTags, ok := cast(parameters["clusterAutoscalerNodeTemplateTags"]).(map[string]interface{})
+		if !ok {
+			return Parameters{}, microerror.Maskf(wrongTypeError, "clusterAutoscalerNodeTemplateTags should be map[string]interface{}, got '%T'", cast(parameters["clusterAutoscalerNodeTemplateTags"]))
+		}
+
+		for name, rawValue := range rawTags {
+			value, ok := rawValue.(string)
+			if !ok {
+				return Parameters{}, microerror.Maskf(wrongTypeError, "tag %#q should be string, got '%T'", name, rawValue)
+			}
+
+			if nodeTemplateTags == nil {
+				nodeTemplateTags = map[string]string{}
+			}
+			nodeTemplateTags[name] = value
+		}
+	}
+
 	bidPrice := "-1"
 	if parameters["spotInstancesMaxPrice"] != nil {
 		bidPrice = cast(parameters["spotInstancesMaxPrice"]).(string)
@@ -156,6 +269,18 @@ func newParameters(parameters map[string]interface{}, cast func(param interface{
 		spotEnabled = cast(parameters["spotInstancesEnabled"]).(bool)
 	}
 
+	// Deployments created by older versions use the VNet of the cluster
+	// resource group.
+	vnetResourceGroup := ""
+	if parameters["vnetResourceGroup"] != nil {
+		vnetResourceGroup = cast(parameters["vnetResourceGroup"]).(string)
+	}
+
+	ipv6Enabled := false
+	if parameters["ipv6Enabled"] != nil {
+		ipv6Enabled = cast(parameters["ipv6Enabled"]).(bool)
+	}
+
 	cgroupsVersion := "v2"
 	if parameters["cGroupsVersion"] != nil {
 		cgroupsVersion = cast(parameters["cGroupsVersion"]).(string)
@@ -163,13 +288,16 @@ func newParameters(parameters map[string]interface{}, cast func(param interface{
 
 	// Finally return typed parameters.
 	return Parameters{
-		AzureOperatorVersion:        cast(parameters["azureOperatorVersion"]).(string),
-		CGroupsVersion:              cgroupsVersion,
-		ClusterID:                   cast(parameters["clusterID"]).(string),
-		DataDisks:                   dataDisks,
-		EnableAcceleratedNetworking: cast(parameters["enableAcceleratedNetworking"]).(bool),
-		KubernetesVersion:           cast(parameters["kubernetesVersion"]).(string),
-		NodepoolName:                cast(parameters["nodepoolName"]).(string),
+		AzureOperatorVersion:              cast(parameters["azureOperatorVersion"]).(string),
+		CGroupsVersion:                    cgroupsVersion,
+		ClusterID:                         cast(parameters["clusterID"]).(string),
+		ClusterAutoscalerNodeTemplateTags: nodeTemplateTags,
+		DataDisks:                         dataDisks,
+		EnableAcceleratedNetworking:       cast(parameters["enableAcceleratedNetworking"]).(bool),
+		IPv6Enabled:                       ipv6Enabled,
+		KubernetesVersion:                 cast(parameters["kubernetesVersion"]).(string),
+		NodepoolName:                      cast(parameters["nodepoolName"]).(string),
+		OSDisk:                            osDisk,
 		OSImage: OSImage{
 			Publisher: cast(parameters["osImagePublisher"]).(string),
 			Offer:     cast(parameters["osImageOffer"]).(string),
@@ -185,13 +313,14 @@ func newParameters(parameters map[string]interface{}, cast func(param interface{
 			Enabled:  spotEnabled,
 			MaxPrice: bidPrice,
 		},
-		StorageAccountType: cast(parameters["storageAccountType"]).(string),
+		StorageAccountType: storageAccountType,
 		SubnetName:         cast(parameters["subnetName"]).(string),
 		// It comes empty from Azure API.
-		VMCustomData: "",
-		VMSize:       cast(parameters["vmSize"]).(string),
-		VnetName:     cast(parameters["vnetName"]).(string),
-		Zones:        zones,
+		VMCustomData:      "",
+		VMSize:            cast(parameters["vmSize"]).(string),
+		VnetName:          cast(parameters["vnetName"]).(string),
+		VnetResourceGroup: vnetResourceGroup,
+		Zones:             zones,
 	}, nil
 }
 
@@ -61,6 +61,9 @@ func Diff(currentDeployment azureresource.DeploymentExtended, desiredDeployment
 	if currentParameters.ClusterID != desiredParameters.ClusterID {
 		changes = append(changes, "clusterID")
 	}
+	if currentParameters.IPv6Enabled != desiredParameters.IPv6Enabled {
+		changes = append(changes, "ipv6Enabled")
+	}
 	if currentParameters.KubernetesVersion != desiredParameters.KubernetesVersion {
 		changes = append(changes, "kubernetesVersion")
 	}
@@ -76,18 +79,27 @@ func Diff(currentDeployment azureresource.DeploymentExtended, desiredDeployment
 	if currentParameters.VnetName != desiredParameters.VnetName {
 		changes = append(changes, "vnetName")
 	}
+	if currentParameters.VnetResourceGroup != "" && currentParameters.VnetResourceGroup != desiredParameters.VnetResourceGroup {
+		changes = append(changes, "vnetResourceGroup")
+	}
 	if !reflect.DeepEqual(currentParameters.DataDisks, desiredParameters.DataDisks) {
 		changes = append(changes, "dataDisks")
 	}
 	if currentParameters.Scaling.MinReplicas != desiredParameters.Scaling.MinReplicas || currentParameters.Scaling.MaxReplicas != desiredParameters.Scaling.MaxReplicas {
 		changes = append(changes, "scaling")
 	}
+	if currentParameters.OSDisk != desiredParameters.OSDisk {
+		changes = append(changes, "osDisk")
+	}
 	if !reflect.DeepEqual(currentParameters.OSImage, desiredParameters.OSImage) {
 		changes = append(changes, "osImage")
 	}
 	if !reflect.DeepEqual(currentParameters.Zones, desiredParameters.Zones) {
 		changes = append(changes, "zones")
 	}
+	if !reflect.DeepEqual(currentParameters.ClusterAutoscalerNodeTemplateTags, desiredParameters.ClusterAutoscalerNodeTemplateTags) {
+		changes = append(changes, "clusterAutoscalerNodeTemplate")
+	}
 	if currentParameters.CGroupsVersion != desiredParameters.CGroupsVersion {
 		changes = append(changes, "cgroupsversion")
 	}
@@ -0,0 +1,129 @@
+package template
+
+import (
+	"strconv"
+	"testing"
+
+	azureresource "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-05-01/resources"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+func Test_Diff(t *testing.T) {
+	// currentParameters are the parameters of a deployment created by an
+	// older version, without the disk caching types, storage account types
+	// and disk encryption sets.
+	currentParameters := map[string]interface{}{
+		"azureOperatorVersion": "8.2.0",
+		"clusterID":            "c1",
+		"dataDisks": []interface{}{
+			map[string]interface{}{"nameSuffix": "docker", "lun": float64(21), "diskSizeGB": float64(50)},
+			map[string]interface{}{"nameSuffix": "kubelet", "lun": float64(22), "diskSizeGB": float64(100)},
+		},
+		"enableAcceleratedNetworking": false,
+		"kubernetesVersion":           "1.24.10",
+		"nodepoolName":                "nodepool-np1",
+		"osImagePublisher":            "kinvolk",
+		"osImageOffer":                "flatcar-container-linux-free",
+		"osImageSKU":                  "stable",
+		"osImageVersion":              "3374.2.0",
+		"minReplicas":                 float64(3),
+		"maxReplicas":                 float64(3),
+		"currentReplicas":             float64(3),
+		"storageAccountType":          "Premium_LRS",
+		"subnetName":                  "np1",
+		"vmSize":                      "Standard_D4s_v3",
+		"vnetName":                    "c1-VirtualNetwork",
+		"zones":                       []interface{}{},
+	}
+	current := map[string]interface{}{}
+	for name, value := range currentParameters {
+		current[name] = map[string]interface{}{"value": value}
+	}
+	currentDeployment := azureresource.DeploymentExtended{
+		Properties: &azureresource.DeploymentPropertiesExtended{Parameters: current},
+	}
+
+	desired := func(f func(p *Parameters)) Parameters {
+		p := Parameters{
+			AzureOperatorVersion: "8.2.0",
+			CGroupsVersion:       "v2",
+			ClusterID:            "c1",
+			DataDisks: []capz.DataDisk{
+				{NameSuffix: "docker", DiskSizeGB: 50, Lun: to.Int32Ptr(21)},
+				{NameSuffix: "kubelet", DiskSizeGB: 100, Lun: to.Int32Ptr(22)},
+			},
+			KubernetesVersion: "1.24.10",
+			NodepoolName:      "nodepool-np1",
+			OSImage: OSImage{
+				Publisher: "kinvolk",
+				Offer:     "flatcar-container-linux-free",
+				SKU:       "stable",
+				Version:   "3374.2.0",
+			},
+			Scaling:            Scaling{MinReplicas: 3, MaxReplicas: 3, CurrentReplicas: 3},
+			StorageAccountType: "Premium_LRS",
+			SubnetName:         "np1",
+			VMSize:             "Standard_D4s_v3",
+			VnetName:           "c1-VirtualNetwork",
+		}
+		f(&p)
+		return p
+	}
+
+	testCases := []struct {
+		name            string
+		parameters      Parameters
+		expectedChanges []string
+	}{
+		{
+			name:       "case 0: default disks are unchanged",
+			parameters: desired(func(p *Parameters) {}),
+		},
+		{
+			name: "case 1: ephemeral OS disk",
+			parameters: desired(func(p *Parameters) {
+				p.OSDisk.Ephemeral = true
+			}),
+			expectedChanges: []string{"osDisk"},
+		},
+		{
+			name: "case 2: data disk encryption set and caching type",
+			parameters: desired(func(p *Parameters) {
+				p.DataDisks[0].CachingType = "None"
+				p.DataDisks[0].ManagedDisk = &capz.ManagedDiskParameters{
+					DiskEncryptionSet: &capz.DiskEncryptionSetParameters{ID: "des"},
+				}
+			}),
+			expectedChanges: []string{"dataDisks"},
+		},
+		{
+			name: "case 3: dual-stack subnet",
+			parameters: desired(func(p *Parameters) {
+				p.IPv6Enabled = true
+			}),
+			expectedChanges: []string{"ipv6Enabled"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			desiredDeployment, err := NewDeployment(tc.parameters)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			changes, err := Diff(currentDeployment, desiredDeployment)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if !cmp.Equal(changes, tc.expectedChanges) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedChanges, changes))
+			}
+		})
+	}
+}
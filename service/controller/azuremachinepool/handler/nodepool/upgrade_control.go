@@ -0,0 +1,299 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	// UpgradeAllowedCondition reports if the node pool state machine is
+	// allowed to run, i.e. the upgrade was neither paused nor aborted through
+	// AzureMachinePool annotations.
+	UpgradeAllowedCondition capi.ConditionType = "UpgradeAllowed"
+
+	UpgradePausedReason     = "UpgradePaused"
+	UpgradeResumedReason    = "UpgradeResumed"
+	UpgradeAbortedReason    = "UpgradeAborted"
+	UpgradeRolledBackReason = "UpgradeRolledBack"
+)
+
+// ensureUpgradeControl honours the pause and abort annotations set on the
+// AzureMachinePool CR. It returns true when the state machine must not be
+// executed in this reconciliation loop.
+func (r *Resource) ensureUpgradeControl(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, currentState state.State) (bool, error) {
+	switch {
+	case key.NodePoolUpgradeAborted(&azureMachinePool):
+		if currentState == UpgradeRolledBack {
+			r.Logger.Debugf(ctx, "node pool upgrade was aborted and rolled back, remove annotation %#q to start it again", annotation.UpgradeAbort)
+			return true, nil
+		}
+
+		if !hasUpgradeAllowedReason(&azureMachinePool, UpgradeAbortedReason) {
+			err := r.setUpgradeAllowedCondition(ctx, azureMachinePool, corev1.ConditionFalse, UpgradeAbortedReason, capi.ConditionSeverityWarning, "Node pool upgrade was aborted, rolling back")
+			if err != nil {
+				return true, microerror.Mask(err)
+			}
+
+			err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, UpgradeAbortedReason, "Node pool upgrade was aborted in state %q, rolling back", currentState)
+			if err != nil {
+				return true, microerror.Mask(err)
+			}
+		}
+
+		rolledBack, err := r.rollbackUpgrade(ctx, azureMachinePool)
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+		if !rolledBack {
+			r.Logger.Debugf(ctx, "node pool upgrade rollback is still in progress")
+			return true, nil
+		}
+
+		err = r.saveCurrentState(ctx, azureMachinePool, UpgradeRolledBack)
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+		err = r.setUpgradeAllowedCondition(ctx, azureMachinePool, corev1.ConditionFalse, UpgradeRolledBackReason, capi.ConditionSeverityWarning, "Node pool upgrade was rolled back, remove annotation %s to start it again", annotation.UpgradeAbort)
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeNormal, UpgradeRolledBackReason, "Node pool upgrade was rolled back")
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+		return true, nil
+
+	case key.NodePoolUpgradePaused(&azureMachinePool):
+		if !hasUpgradeAllowedReason(&azureMachinePool, UpgradePausedReason) {
+			err := r.setUpgradeAllowedCondition(ctx, azureMachinePool, corev1.ConditionFalse, UpgradePausedReason, capi.ConditionSeverityInfo, "Node pool upgrade is paused, remove annotation %s to resume it", annotation.UpgradePaused)
+			if err != nil {
+				return true, microerror.Mask(err)
+			}
+
+			err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeNormal, UpgradePausedReason, "Node pool upgrade was paused in state %q", currentState)
+			if err != nil {
+				return true, microerror.Mask(err)
+			}
+		}
+
+		r.Logger.Debugf(ctx, "node pool upgrade is paused, remove annotation %#q to resume it", annotation.UpgradePaused)
+		return true, nil
+	}
+
+	if currentState == UpgradeRolledBack {
+		// The abort annotation was removed, we start the upgrade from scratch.
+		err := r.saveCurrentState(ctx, azureMachinePool, DeploymentUninitialized)
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+	}
+
+	if capiconditions.IsFalse(&azureMachinePool, UpgradeAllowedCondition) || currentState == UpgradeRolledBack {
+		// Time spent paused does not count against the deadline of the state.
+		err := r.saveStateEnteredTimestamp(ctx, azureMachinePool)
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+		err = r.setUpgradeAllowedCondition(ctx, azureMachinePool, corev1.ConditionTrue, UpgradeResumedReason, capi.ConditionSeverityNone, "Node pool upgrade was resumed")
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeNormal, UpgradeResumedReason, "Node pool upgrade was resumed")
+		if err != nil {
+			return true, microerror.Mask(err)
+		}
+
+	}
+
+	if currentState == UpgradeRolledBack {
+		// The state was reset, we continue in the next reconciliation loop.
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// rollbackUpgrade removes the instances added during the upgrade so that the
+// node pool goes back to its original size, and uncordons the old nodes. It
+// returns true once the rollback is completed.
+func (r *Resource) rollbackUpgrade(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) (bool, error) {
+	cluster, err := util.GetClusterFromMetadata(ctx, r.CtrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.Logger.Debugf(ctx, "Cluster is being deleted, skipping rollback of node pool upgrade")
+		return false, nil
+	}
+
+	oldInstances, newInstances, err := r.splitInstancesByUpdatedStatus(ctx, azureMachinePool)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.Logger.Debugf(ctx, "tenant API not available yet")
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	originalReplicas, found := rollingUpdateDesiredReplicas(azureMachinePool)
+	if !found {
+		originalReplicas = len(oldInstances) + len(newInstances)
+	}
+
+	surplus := rollbackSurplusInstances(newInstances, len(oldInstances)+len(newInstances)-originalReplicas)
+	if len(surplus) > 0 {
+		r.Logger.Debugf(ctx, "scaling node pool back to %d instances", originalReplicas)
+
+		err = r.deleteInstances(ctx, azureMachinePool, surplus)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		// Instances being deleted are ignored when splitting instances, so the
+		// next reconciliation loop carries on with the old nodes.
+		return false, nil
+	}
+
+	toUncordon := oldInstances
+	inPlaceInstance, err := r.getInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	if inPlaceInstance != nil {
+		toUncordon = append(toUncordon, *inPlaceInstance)
+	}
+
+	if len(toUncordon) > 0 {
+		nodeDrainer, err := r.getNodeDrainer(ctx, cluster, azureMachinePool)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			return false, nil
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+		if tenantcluster.IsAPINotAvailableError(err) {
+			r.Logger.Debugf(ctx, "tenant API not available yet")
+			return false, nil
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		for _, instance := range toUncordon {
+			nodeName := strings.ToLower(*instance.OsProfile.ComputerName)
+			r.Logger.Debugf(ctx, "Uncordoning node %q (instance name %q)", nodeName, *instance.Name)
+			err = nodeDrainer.UncordonNode(ctx, nodeName)
+			if err != nil {
+				return false, microerror.Mask(err)
+			}
+
+			// Hooks run again when the node is replaced by the next upgrade.
+			err = r.lifecycleHooks.Reset(ctx, tenantClusterK8sClient, nodeName)
+			if err != nil {
+				return false, microerror.Mask(err)
+			}
+
+			err = setNodeScaleDownDisabled(ctx, tenantClusterK8sClient, nodeName, false)
+			if err != nil {
+				return false, microerror.Mask(err)
+			}
+		}
+	}
+
+	err = r.removeRollingUpdateBatch(ctx, azureMachinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	err = r.removeRollingUpdateDesiredReplicas(ctx, azureMachinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	err = r.removeInPlaceUpgradeInstance(ctx, azureMachinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	err = r.enableClusterAutoscaler(ctx, azureMachinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return true, nil
+}
+
+func (r *Resource) setUpgradeAllowedCondition(ctx context.Context, customObject capzexp.AzureMachinePool, status corev1.ConditionStatus, reason string, severity capi.ConditionSeverity, messageFormat string, messageArgs ...interface{}) error {
+	azureMachinePool := &capzexp.AzureMachinePool{}
+	err := r.CtrlClient.Get(ctx, client.ObjectKey{Namespace: customObject.Namespace, Name: customObject.Name}, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if status == corev1.ConditionTrue {
+		severity = capi.ConditionSeverityNone
+	}
+
+	capiconditions.Set(azureMachinePool, &capi.Condition{
+		Type:     UpgradeAllowedCondition,
+		Status:   status,
+		Severity: severity,
+		Reason:   reason,
+		Message:  fmt.Sprintf(messageFormat, messageArgs...),
+	})
+
+	err = r.CtrlClient.Status().Update(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	r.Logger.Debugf(ctx, "set condition %s to %s with reason %s", UpgradeAllowedCondition, status, reason)
+
+	return nil
+}
+
+func hasUpgradeAllowedReason(azureMachinePool *capzexp.AzureMachinePool, reason string) bool {
+	return capiconditions.GetReason(azureMachinePool, UpgradeAllowedCondition) == reason
+}
+
+// rollbackSurplusInstances returns the newest count instances, which are the
+// ones removed when scaling the node pool back to its original size.
+func rollbackSurplusInstances(newInstances []compute.VirtualMachineScaleSetVM, count int) []compute.VirtualMachineScaleSetVM {
+	if count <= 0 {
+		return nil
+	}
+	if count > len(newInstances) {
+		count = len(newInstances)
+	}
+
+	sorted := make([]compute.VirtualMachineScaleSetVM, len(newInstances))
+	copy(sorted, newInstances)
+	sort.Slice(sorted, func(i, j int) bool {
+		return instanceIDLess(sorted[j], sorted[i])
+	})
+
+	return sorted[:count]
+}
@@ -0,0 +1,413 @@
+package nodepool
+
+import (
+	"context"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
+	"github.com/giantswarm/azure-operator/v8/pkg/mock/mock_tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+func Test_rollbackSurplusInstances(t *testing.T) {
+	newInstances := []compute.VirtualMachineScaleSetVM{
+		{InstanceID: to.StringPtr("9")},
+		{InstanceID: to.StringPtr("11")},
+		{InstanceID: to.StringPtr("10")},
+	}
+
+	testCases := []struct {
+		name                string
+		newInstances        []compute.VirtualMachineScaleSetVM
+		count               int
+		expectedInstanceIDs []string
+	}{
+		{
+			name:                "case 0: node pool already has its original size",
+			newInstances:        newInstances,
+			count:               0,
+			expectedInstanceIDs: nil,
+		},
+		{
+			name:                "case 1: newest instances are removed first",
+			newInstances:        newInstances,
+			count:               2,
+			expectedInstanceIDs: []string{"11", "10"},
+		},
+		{
+			name:                "case 2: count is capped by the new instances",
+			newInstances:        newInstances,
+			count:               5,
+			expectedInstanceIDs: []string{"11", "10", "9"},
+		},
+		{
+			name:                "case 3: negative count",
+			newInstances:        newInstances,
+			count:               -1,
+			expectedInstanceIDs: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var instanceIDs []string
+			for _, instance := range rollbackSurplusInstances(tc.newInstances, tc.count) {
+				instanceIDs = append(instanceIDs, *instance.InstanceID)
+			}
+
+			if !cmp.Equal(instanceIDs, tc.expectedInstanceIDs) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedInstanceIDs, instanceIDs))
+			}
+		})
+	}
+}
+
+func Test_ensureUpgradeControl(t *testing.T) {
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+
+		annotations map[string]string
+		// conditionReason is the reason of the UpgradeAllowed condition set
+		// before reconciling, the condition is not set when empty.
+		conditionReason string
+		currentState    state.State
+		instances       []testInstance
+		nodes           []*corev1.Node
+
+		expectedSkip            bool
+		expectedState           state.State
+		expectedConditionStatus corev1.ConditionStatus
+		expectedConditionReason string
+		expectedEvents          []string
+		expectedDeleted         []string
+		expectedNodes           []*corev1.Node
+		// expectedAutoscaler is the value of the cluster autoscaler enabled
+		// VMSS tag, empty when the tag is not set.
+		expectedAutoscaler string
+	}{
+		{
+			name: "case 0: upgrade is neither paused nor aborted",
+
+			currentState: CordonOldWorkerInstances,
+
+			expectedSkip:  false,
+			expectedState: CordonOldWorkerInstances,
+		},
+		{
+			name: "case 1: upgrade is paused",
+
+			annotations:  map[string]string{annotation.UpgradePaused: "true"},
+			currentState: CordonOldWorkerInstances,
+
+			expectedSkip:            true,
+			expectedState:           CordonOldWorkerInstances,
+			expectedConditionStatus: corev1.ConditionFalse,
+			expectedConditionReason: UpgradePausedReason,
+			expectedEvents:          []string{UpgradePausedReason},
+		},
+		{
+			name: "case 2: paused upgrade does not emit the event again",
+
+			annotations:     map[string]string{annotation.UpgradePaused: "true"},
+			conditionReason: UpgradePausedReason,
+			currentState:    CordonOldWorkerInstances,
+
+			expectedSkip:            true,
+			expectedState:           CordonOldWorkerInstances,
+			expectedConditionStatus: corev1.ConditionFalse,
+			expectedConditionReason: UpgradePausedReason,
+		},
+		{
+			name: "case 3: paused upgrade is resumed",
+
+			conditionReason: UpgradePausedReason,
+			currentState:    CordonOldWorkerInstances,
+
+			expectedSkip:            false,
+			expectedState:           CordonOldWorkerInstances,
+			expectedConditionStatus: corev1.ConditionTrue,
+			expectedConditionReason: UpgradeResumedReason,
+			expectedEvents:          []string{UpgradeResumedReason},
+		},
+		{
+			name: "case 4: aborted upgrade removes the surplus instances first",
+
+			annotations: map[string]string{
+				annotation.UpgradeAbort:                 "true",
+				annotation.RollingUpdateDesiredReplicas: "2",
+			},
+			currentState: DrainOldWorkerInstances,
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+				{id: "1", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+				{id: "2", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+				{id: "3", sku: testSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []*corev1.Node{newTestNode("0"), newTestNode("1"), newTestNode("2"), newTestNode("3")},
+
+			expectedSkip:            true,
+			expectedState:           DrainOldWorkerInstances,
+			expectedConditionStatus: corev1.ConditionFalse,
+			expectedConditionReason: UpgradeAbortedReason,
+			expectedEvents:          []string{UpgradeAbortedReason},
+			expectedDeleted:         []string{"3", "2"},
+			expectedNodes:           []*corev1.Node{newTestNode("0"), newTestNode("1"), newTestNode("2"), newTestNode("3")},
+		},
+		{
+			name: "case 5: aborted upgrade uncordons the old nodes and resets their hooks",
+
+			annotations: map[string]string{
+				annotation.UpgradeAbort:                 "true",
+				annotation.RollingUpdateDesiredReplicas: "1",
+			},
+			conditionReason: UpgradeAbortedReason,
+			currentState:    DrainOldWorkerInstances,
+			instances: []testInstance{
+				{id: "0", sku: oldTestSKU, latestModelApplied: true, provisioningState: "Succeeded"},
+			},
+			nodes: []*corev1.Node{newTestCordonedNode("0")},
+
+			expectedSkip:            true,
+			expectedState:           UpgradeRolledBack,
+			expectedConditionStatus: corev1.ConditionFalse,
+			expectedConditionReason: UpgradeRolledBackReason,
+			expectedEvents:          []string{UpgradeRolledBackReason},
+			expectedNodes:           []*corev1.Node{newTestNode("0")},
+			expectedAutoscaler:      "true",
+		},
+		{
+			name: "case 6: rolled back upgrade waits for the abort annotation to be removed",
+
+			annotations:     map[string]string{annotation.UpgradeAbort: "true"},
+			conditionReason: UpgradeRolledBackReason,
+			currentState:    UpgradeRolledBack,
+
+			expectedSkip:            true,
+			expectedState:           UpgradeRolledBack,
+			expectedConditionStatus: corev1.ConditionFalse,
+			expectedConditionReason: UpgradeRolledBackReason,
+		},
+		{
+			name: "case 7: rolled back upgrade starts again once the abort annotation is removed",
+
+			conditionReason: UpgradeRolledBackReason,
+			currentState:    UpgradeRolledBack,
+
+			expectedSkip:            true,
+			expectedState:           DeploymentUninitialized,
+			expectedConditionStatus: corev1.ConditionTrue,
+			expectedConditionReason: UpgradeResumedReason,
+			expectedEvents:          []string{UpgradeResumedReason},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			azureMachinePool := newTestAzureMachinePool()
+			for k, v := range tc.annotations {
+				azureMachinePool.Annotations[k] = v
+			}
+			azureMachinePool.Annotations[annotation.StateMachineCurrentState] = string(tc.currentState)
+			if tc.conditionReason != "" {
+				capiconditions.MarkFalse(azureMachinePool, UpgradeAllowedCondition, tc.conditionReason, capi.ConditionSeverityInfo, "")
+			}
+
+			ctrlClient := unittest.FakeK8sClient(newTestCluster(), newTestMachinePool(), azureMachinePool).CtrlClient()
+
+			var wcObjects []ctrlclient.Object
+			for _, n := range tc.nodes {
+				wcObjects = append(wcObjects, n)
+			}
+			wcCtrlClient := fake.NewClientBuilder().WithObjects(wcObjects...).Build()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+			tenantClientFactory.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(wcCtrlClient, nil).AnyTimes()
+			tenantClientFactory.EXPECT().GetAllClients(gomock.Any(), gomock.Any()).Return(k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: wcCtrlClient,
+				K8sClient:  kubernetesfake.NewSimpleClientset(),
+			}), nil).AnyTimes()
+
+			vmssAPI := &fakeVMSSAPI{
+				vmss: compute.VirtualMachineScaleSet{
+					Sku: &compute.Sku{Name: to.StringPtr(testSKU), Capacity: to.Int64Ptr(int64(len(tc.instances)))},
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+							StorageProfile: &compute.VirtualMachineScaleSetStorageProfile{
+								ImageReference: &compute.ImageReference{Version: to.StringPtr(testImageVersion)},
+							},
+						},
+					},
+					Tags: map[string]*string{},
+				},
+				instances: tc.instances,
+			}
+			server := httptest.NewServer(vmssAPI)
+			defer server.Close()
+
+			eventRecorder, err := event.New(event.Config{
+				CtrlClient: ctrlClient,
+				Logger:     microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lifecycleHooks, err := lifecyclehook.New(lifecyclehook.Config{
+				Logger: microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &Resource{
+				Resource: nodes.Resource{
+					CtrlClient:    ctrlClient,
+					Logger:        microloggertest.New(),
+					ClientFactory: &fakeClientFactory{baseURI: server.URL},
+				},
+				clock:               fakeClock{now: now},
+				eventRecorder:       eventRecorder,
+				lifecycleHooks:      lifecycleHooks,
+				tenantClientFactory: tenantClientFactory,
+			}
+
+			skip, err := r.ensureUpgradeControl(ctx, *azureMachinePool, tc.currentState)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if skip != tc.expectedSkip {
+				t.Fatalf("skip == %t, want %t", skip, tc.expectedSkip)
+			}
+
+			err = ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(azureMachinePool), azureMachinePool)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := azureMachinePool.Annotations[annotation.StateMachineCurrentState]; state.State(s) != tc.expectedState {
+				t.Fatalf("state == %q, want %q", s, tc.expectedState)
+			}
+
+			condition := capiconditions.Get(azureMachinePool, UpgradeAllowedCondition)
+			var conditionStatus corev1.ConditionStatus
+			var conditionReason string
+			if condition != nil {
+				conditionStatus = condition.Status
+				conditionReason = condition.Reason
+			}
+			if conditionStatus != tc.expectedConditionStatus {
+				t.Fatalf("condition status == %q, want %q", conditionStatus, tc.expectedConditionStatus)
+			}
+			if conditionReason != tc.expectedConditionReason {
+				t.Fatalf("condition reason == %q, want %q", conditionReason, tc.expectedConditionReason)
+			}
+
+			if tc.expectedConditionReason == UpgradeResumedReason {
+				// Time spent paused does not count against the deadline.
+				entered, ok := stateEnteredTimestamp(*azureMachinePool)
+				if !ok || !entered.Equal(now) {
+					t.Fatalf("state entered timestamp == %v, want %v", entered, now)
+				}
+			}
+
+			if tc.expectedState == UpgradeRolledBack {
+				for _, a := range []string{annotation.RollingUpdateDesiredReplicas, annotation.InPlaceUpgradeInstance} {
+					if _, ok := azureMachinePool.Annotations[a]; ok {
+						t.Fatalf("annotation %q was not removed", a)
+					}
+				}
+			}
+
+			eventList := &corev1.EventList{}
+			err = ctrlClient.List(ctx, eventList)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var events []string
+			for _, e := range eventList.Items {
+				events = append(events, e.Reason)
+			}
+			sort.Strings(events)
+			if !cmp.Equal(events, tc.expectedEvents) {
+				t.Fatalf("events\n\n%s\n", cmp.Diff(tc.expectedEvents, events))
+			}
+
+			if !cmp.Equal(vmssAPI.deleted, tc.expectedDeleted) {
+				t.Fatalf("deleted instances\n\n%s\n", cmp.Diff(tc.expectedDeleted, vmssAPI.deleted))
+			}
+
+			var autoscaler string
+			if v := vmssAPI.vmss.Tags[clusterAutoscalerEnabledTagName]; v != nil {
+				autoscaler = *v
+			}
+			if autoscaler != tc.expectedAutoscaler {
+				t.Fatalf("cluster autoscaler enabled tag == %q, want %q", autoscaler, tc.expectedAutoscaler)
+			}
+
+			for _, expected := range tc.expectedNodes {
+				node := &corev1.Node{}
+				err = wcCtrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(expected), node)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if node.Spec.Unschedulable != expected.Spec.Unschedulable {
+					t.Fatalf("node %q unschedulable == %t, want %t", node.Name, node.Spec.Unschedulable, expected.Spec.Unschedulable)
+				}
+				if !cmp.Equal(node.Annotations, expected.Annotations, cmpopts.EquateEmpty()) {
+					t.Fatalf("node %q annotations\n\n%s\n", node.Name, cmp.Diff(expected.Annotations, node.Annotations))
+				}
+			}
+		})
+	}
+}
+
+// newTestCordonedNode returns the node of the given instance the way it is
+// left behind by an interrupted upgrade: cordoned, with lifecycle hooks run
+// and protected from cluster autoscaler scale down.
+func newTestCordonedNode(instanceID string) *corev1.Node {
+	node := newTestNode(instanceID)
+	node.Spec.Unschedulable = true
+	node.Annotations = map[string]string{
+		"giantswarm.io/pre-drain-hook-started-ts": "2021-03-04T11:00:00Z",
+		"giantswarm.io/pre-drain-hook-status":     "Completed",
+		scaleDownDisabledAnnotation:               "true",
+		annotation.NodeScaleDownDisabledByUpgrade: "true",
+	}
+
+	return node
+}
@@ -0,0 +1,102 @@
+package nodepool
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+)
+
+const (
+	ZoneNotCoveredReason = "ZoneNotCovered"
+
+	// zoneCoverageTimeout is the time old instances in zones without new
+	// instances are kept before they are cordoned anyway. The node pool VMSS
+	// places new instances, so zones may stay uncovered.
+	zoneCoverageTimeout = 10 * time.Minute
+)
+
+// instanceZone returns the availability zone of the instance, or an empty
+// string for instances of node pools not spread across zones.
+func instanceZone(instance compute.VirtualMachineScaleSetVM) string {
+	if instance.Zones == nil || len(*instance.Zones) == 0 {
+		return ""
+	}
+
+	return (*instance.Zones)[0]
+}
+
+// zoneBalancedOrder orders outdated instances so that replacing them in order
+// keeps the node pool balanced across its zones. The next instance is always
+// taken from the zone with the most instances left, instances of the same
+// zone are ordered by their ID.
+func zoneBalancedOrder(instances []compute.VirtualMachineScaleSetVM) []compute.VirtualMachineScaleSetVM {
+	byZone := map[string][]compute.VirtualMachineScaleSetVM{}
+	var zones []string
+	for _, instance := range instances {
+		zone := instanceZone(instance)
+		if _, exists := byZone[zone]; !exists {
+			zones = append(zones, zone)
+		}
+		byZone[zone] = append(byZone[zone], instance)
+	}
+
+	sort.Strings(zones)
+	for _, zone := range zones {
+		zoneInstances := byZone[zone]
+		sort.Slice(zoneInstances, func(i, j int) bool {
+			return instanceIDLess(zoneInstances[i], zoneInstances[j])
+		})
+	}
+
+	ordered := make([]compute.VirtualMachineScaleSetVM, 0, len(instances))
+	for len(ordered) < len(instances) {
+		var next string
+		for _, zone := range zones {
+			if len(byZone[zone]) > len(byZone[next]) {
+				next = zone
+			}
+		}
+
+		ordered = append(ordered, byZone[next][0])
+		byZone[next] = byZone[next][1:]
+	}
+
+	return ordered
+}
+
+// uncoveredZones returns the node pool zones of the given outdated instances
+// no up to date instance runs in yet. Outdated instances in these zones must
+// not be removed, so that zonal workloads, e.g. using zonal persistent
+// volumes, can still be scheduled. Zones the node pool is not spread across
+// anymore are ignored.
+func uncoveredZones(poolZones []string, oldInstances []compute.VirtualMachineScaleSetVM, newInstances []compute.VirtualMachineScaleSetVM) []string {
+	if len(poolZones) < 2 {
+		return nil
+	}
+
+	covered := map[string]bool{}
+	for _, instance := range newInstances {
+		covered[instanceZone(instance)] = true
+	}
+
+	inPool := map[string]bool{}
+	for _, zone := range poolZones {
+		inPool[zone] = true
+	}
+
+	seen := map[string]bool{}
+	var uncovered []string
+	for _, instance := range oldInstances {
+		zone := instanceZone(instance)
+		if zone == "" || !inPool[zone] || covered[zone] || seen[zone] {
+			continue
+		}
+
+		seen[zone] = true
+		uncovered = append(uncovered, zone)
+	}
+	sort.Strings(uncovered)
+
+	return uncovered
+}
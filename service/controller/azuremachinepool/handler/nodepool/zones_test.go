@@ -0,0 +1,204 @@
+package nodepool
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_zoneBalancedOrder(t *testing.T) {
+	testCases := []struct {
+		name                string
+		instances           []compute.VirtualMachineScaleSetVM
+		expectedInstanceIDs []string
+	}{
+		{
+			name: "case 0: instances without zones are ordered by ID",
+			instances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("10", ""),
+				zonalInstance("9", ""),
+				zonalInstance("11", ""),
+			},
+			expectedInstanceIDs: []string{"9", "10", "11"},
+		},
+		{
+			name: "case 1: instances are taken across zones",
+			instances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("0", "1"),
+				zonalInstance("1", "1"),
+				zonalInstance("2", "2"),
+				zonalInstance("3", "2"),
+				zonalInstance("4", "3"),
+				zonalInstance("5", "3"),
+			},
+			expectedInstanceIDs: []string{"0", "2", "4", "1", "3", "5"},
+		},
+		{
+			name: "case 2: zones with the most instances go first",
+			instances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("0", "1"),
+				zonalInstance("1", "2"),
+				zonalInstance("2", "2"),
+				zonalInstance("3", "2"),
+				zonalInstance("4", "3"),
+			},
+			expectedInstanceIDs: []string{"1", "2", "0", "3", "4"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			instanceIDs := instanceIDs(zoneBalancedOrder(tc.instances))
+
+			if !cmp.Equal(instanceIDs, tc.expectedInstanceIDs) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedInstanceIDs, instanceIDs))
+			}
+		})
+	}
+}
+
+func Test_uncoveredZones(t *testing.T) {
+	testCases := []struct {
+		name          string
+		poolZones     []string
+		oldInstances  []compute.VirtualMachineScaleSetVM
+		newInstances  []compute.VirtualMachineScaleSetVM
+		expectedZones []string
+	}{
+		{
+			name:          "case 0: single zone node pool",
+			poolZones:     []string{"1"},
+			oldInstances:  []compute.VirtualMachineScaleSetVM{zonalInstance("0", "1")},
+			expectedZones: nil,
+		},
+		{
+			name:      "case 1: all zones covered",
+			poolZones: []string{"1", "2"},
+			oldInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("0", "1"),
+				zonalInstance("1", "2"),
+			},
+			newInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("2", "1"),
+				zonalInstance("3", "2"),
+			},
+			expectedZones: nil,
+		},
+		{
+			name:      "case 2: zones without new instances",
+			poolZones: []string{"1", "2", "3"},
+			oldInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("0", "3"),
+				zonalInstance("1", "2"),
+				zonalInstance("2", "3"),
+				zonalInstance("3", "1"),
+			},
+			newInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("4", "1"),
+			},
+			expectedZones: []string{"2", "3"},
+		},
+		{
+			name:      "case 3: zones removed from the node pool are ignored",
+			poolZones: []string{"1", "2"},
+			oldInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("0", "3"),
+			},
+			newInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("1", "1"),
+			},
+			expectedZones: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			zones := uncoveredZones(tc.poolZones, tc.oldInstances, tc.newInstances)
+
+			if !cmp.Equal(zones, tc.expectedZones) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedZones, zones))
+			}
+		})
+	}
+}
+
+func Test_batchCandidates(t *testing.T) {
+	poolZones := []string{"1", "2", "3"}
+	oldInstances := []compute.VirtualMachineScaleSetVM{
+		zonalInstance("0", "1"),
+		zonalInstance("1", "2"),
+		zonalInstance("2", "3"),
+		zonalInstance("3", "1"),
+	}
+
+	testCases := []struct {
+		name                string
+		newInstances        []compute.VirtualMachineScaleSetVM
+		enforceZoneCoverage bool
+		expectedInstanceIDs []string
+		expectedUncovered   []string
+	}{
+		{
+			name: "case 0: instances in uncovered zones are left out",
+			newInstances: []compute.VirtualMachineScaleSetVM{
+				zonalInstance("4", "2"),
+				zonalInstance("5", "3"),
+			},
+			enforceZoneCoverage: true,
+			expectedInstanceIDs: []string{"1", "2"},
+		},
+		{
+			name:                "case 1: all zones uncovered",
+			enforceZoneCoverage: true,
+			expectedInstanceIDs: []string{"0", "3", "1", "2"},
+			expectedUncovered:   []string{"1", "2", "3"},
+		},
+		{
+			name:                "case 2: zone coverage not enforced",
+			enforceZoneCoverage: false,
+			expectedInstanceIDs: []string{"0", "3", "1", "2"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			instances, uncovered := batchCandidates(poolZones, oldInstances, tc.newInstances, tc.enforceZoneCoverage)
+
+			if !cmp.Equal(instanceIDs(instances), tc.expectedInstanceIDs) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedInstanceIDs, instanceIDs(instances)))
+			}
+			if !cmp.Equal(uncovered, tc.expectedUncovered) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedUncovered, uncovered))
+			}
+		})
+	}
+}
+
+func zonalInstance(instanceID, zone string) compute.VirtualMachineScaleSetVM {
+	instance := compute.VirtualMachineScaleSetVM{
+		InstanceID: to.StringPtr(instanceID),
+	}
+	if zone != "" {
+		instance.Zones = &[]string{zone}
+	}
+
+	return instance
+}
+
+func instanceIDs(instances []compute.VirtualMachineScaleSetVM) []string {
+	var ids []string
+	for _, instance := range instances {
+		ids = append(ids, *instance.InstanceID)
+	}
+
+	return ids
+}
@@ -31,6 +31,7 @@ import (
 	capiutil "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
 	"github.com/giantswarm/azure-operator/v8/service/controller/cloudconfig"
 	"github.com/giantswarm/azure-operator/v8/service/controller/encrypter"
@@ -518,6 +519,12 @@ func (r *Resource) buildAzureConfig(cluster *capi.Cluster, azureCluster *capz.Az
 		}
 
 		azureConfig.Spec.Azure.VirtualNetwork.CIDR = azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks[0]
+
+		if key.UsesExistingVnet(azureCluster) {
+			azureConfig.Annotations = map[string]string{
+				annotation.ExistingVirtualNetworkID: azureCluster.Spec.NetworkSpec.Vnet.ID,
+			}
+		}
 	}
 
 	azureConfig.Spec.Azure.DNSZones.API.Name = hostDNSZone
@@ -541,8 +548,8 @@ func (r *Resource) buildAzureConfig(cluster *capi.Cluster, azureCluster *capz.Az
 		for i := int32(0); i < *machinePool.Spec.Replicas; i++ {
 			n := providerv1alpha1.AzureConfigSpecAzureNode{
 				VMSize:              azureMachinePool.Spec.Template.VMSize,
-				DockerVolumeSizeGB:  dockerVolumeSizeGB,
-				KubeletVolumeSizeGB: kubeletVolumeSizeGB,
+				DockerVolumeSizeGB:  int(key.NodePoolDockerDisk(azureMachinePool).DiskSizeGB),
+				KubeletVolumeSizeGB: int(key.NodePoolKubeletDisk(azureMachinePool).DiskSizeGB),
 			}
 			workerNodes = append(workerNodes, n)
 		}
@@ -627,7 +634,7 @@ func (r *Resource) newCluster(cluster *capi.Cluster, azureCluster *capz.AzureClu
 			return providerv1alpha1.Cluster{}, microerror.Mask(err)
 		}
 
-		kubeletLabels, err := key.KubeletLabelsNodePool(machinePool)
+		kubeletLabels, err := key.NodePoolKubeletLabels(machinePool)
 		if err != nil {
 			return providerv1alpha1.Cluster{}, microerror.Mask(err)
 		}
@@ -0,0 +1,56 @@
+package spotinstances
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/cluster-api/util"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// EnsureCreated reports spot instances which are about to be evicted and
+// scales the fallback on-demand node pool while spot capacity is
+// unavailable.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !key.NodePoolSpotInstancesEnabled(&azureMachinePool) {
+		return nil
+	}
+
+	machinePool, err := r.getOwnerMachinePool(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if machinePool == nil || !machinePool.GetDeletionTimestamp().IsZero() {
+		r.logger.Debugf(ctx, "MachinePool is missing or being deleted, skipping spot instances handling")
+		return nil
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.ctrlClient, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !cluster.GetDeletionTimestamp().IsZero() {
+		r.logger.Debugf(ctx, "Cluster is being deleted, skipping spot instances handling")
+		return nil
+	}
+
+	err = r.reportEvictionNotices(ctx, cluster, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.reconcileFallback(ctx, azureMachinePool, *machinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
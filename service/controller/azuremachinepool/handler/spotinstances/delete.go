@@ -0,0 +1,25 @@
+package spotinstances
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// EnsureDeleted scales the fallback on-demand node pool back when the spot
+// node pool is deleted.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	azureMachinePool, err := key.ToAzureMachinePool(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = r.removeFallback(ctx, azureMachinePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
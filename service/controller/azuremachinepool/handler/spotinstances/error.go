@@ -0,0 +1,29 @@
+package spotinstances
+
+import (
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+// IsNotFound asserts 404 responses.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	dErr, ok := microerror.Cause(err).(autorest.DetailedError)
+	if ok && dErr.StatusCode == 404 {
+		return true
+	}
+
+	return false
+}
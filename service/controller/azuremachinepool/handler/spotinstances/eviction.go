@@ -0,0 +1,110 @@
+package spotinstances
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	apiextensionslabels "github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	SpotEvictionNoticeReason = "SpotEvictionNotice"
+)
+
+// reportEvictionNotices reports the nodes of the spot node pool which received
+// an eviction notice. Azure evicts spot instances as early as 30 seconds after
+// the notice, so the nodes are cordoned and drained by the scheduled events
+// agent running on them, which also sets the eviction notice annotation.
+func (r *Resource) reportEvictionNotices(ctx context.Context, cluster *capi.Cluster, azureMachinePool capzexp.AzureMachinePool) error {
+	wcClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.logger.Debugf(ctx, "tenant API not available yet")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	nodeList := corev1.NodeList{}
+	err = wcClient.List(ctx, &nodeList, ctrlclient.MatchingLabels{apiextensionslabels.MachinePool: azureMachinePool.Name})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	notices := map[string]string{}
+	for _, node := range nodesWithEvictionNotice(nodeList.Items) {
+		notices[node.Name] = node.Annotations[annotation.NodeSpotEvictionNotice]
+	}
+
+	for _, name := range r.reportedNotices.update(string(azureMachinePool.UID), notices) {
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, SpotEvictionNoticeReason, "Spot instance of node %s is evicted at %s and was drained", name, notices[name])
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		reportEvictionNotice(key.ClusterID(&azureMachinePool), azureMachinePool.Name)
+	}
+
+	return nil
+}
+
+// nodesWithEvictionNotice returns the nodes which received a spot eviction
+// notice.
+func nodesWithEvictionNotice(nodes []corev1.Node) []corev1.Node {
+	var noticed []corev1.Node
+	for _, node := range nodes {
+		if _, ok := node.Annotations[annotation.NodeSpotEvictionNotice]; ok {
+			noticed = append(noticed, node)
+		}
+	}
+
+	return noticed
+}
+
+// reportedNotices remembers the eviction notices reported for the nodes of
+// each node pool, so that they are reported once and not in every
+// reconciliation loop until the nodes are gone.
+type reportedNotices struct {
+	mutex   sync.Mutex
+	notices map[string]map[string]string
+}
+
+func newReportedNotices() *reportedNotices {
+	return &reportedNotices{
+		notices: map[string]map[string]string{},
+	}
+}
+
+// update records the eviction notices of the node pool keyed by node name and
+// returns the sorted names of the nodes whose notice was not reported yet.
+// Nodes without notice are forgotten.
+func (n *reportedNotices) update(nodePool string, notices map[string]string) []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var names []string
+	for name, notice := range notices {
+		if previous, ok := n.notices[nodePool][name]; !ok || previous != notice {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(notices) == 0 {
+		delete(n.notices, nodePool)
+	} else {
+		n.notices[nodePool] = notices
+	}
+
+	return names
+}
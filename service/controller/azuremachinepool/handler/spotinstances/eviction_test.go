@@ -0,0 +1,28 @@
+package spotinstances
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_reportedNotices(t *testing.T) {
+	n := newReportedNotices()
+
+	names := n.update("np1", map[string]string{"b": "2023-07-20T12:00:00Z", "a": "2023-07-20T12:00:30Z"})
+	if !cmp.Equal(names, []string{"a", "b"}) {
+		t.Fatalf("\n\n%s\n", cmp.Diff([]string{"a", "b"}, names))
+	}
+
+	// Notices are reported once.
+	names = n.update("np1", map[string]string{"a": "2023-07-20T12:00:30Z"})
+	if len(names) != 0 {
+		t.Fatalf("expected no names, got %v", names)
+	}
+
+	// Nodes without notice are forgotten.
+	names = n.update("np1", map[string]string{"b": "2023-07-20T12:00:00Z"})
+	if !cmp.Equal(names, []string{"b"}) {
+		t.Fatalf("\n\n%s\n", cmp.Diff([]string{"b"}, names))
+	}
+}
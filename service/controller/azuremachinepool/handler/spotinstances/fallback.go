@@ -0,0 +1,253 @@
+package spotinstances
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+const (
+	SpotFallbackScaledReason = "SpotFallbackScaled"
+	InvalidSpotFallback      = "InvalidSpotFallback"
+
+	provisioningStateCreating  = "Creating"
+	provisioningStateSucceeded = "Succeeded"
+	provisioningStateUpdating  = "Updating"
+)
+
+// reconcileFallback scales up the fallback on-demand node pool by the number
+// of missing spot instances once spot capacity is unavailable for longer
+// than the configured time, and scales it back when spot capacity returns.
+func (r *Resource) reconcileFallback(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, machinePool capiexp.MachinePool) error {
+	fallbackName := azureMachinePool.Annotations[annotation.SpotFallbackMachinePool]
+	if fallbackName == "" {
+		return nil
+	}
+
+	fallbackAfter, err := key.NodePoolSpotFallbackAfter(&azureMachinePool)
+	if err != nil {
+		return r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, InvalidSpotFallback, "Spot fallback is not applied: %s", err.Error())
+	}
+
+	missing, err := r.missingInstances(ctx, azureMachinePool, machinePool)
+	if IsNotFound(err) {
+		r.logger.Debugf(ctx, "Node Pool VMSS was not found, spot capacity is not checked")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	now := time.Now()
+
+	since, err := r.saveCapacityUnavailableSince(ctx, azureMachinePool, missing, now)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	replicas := desiredFallbackReplicas(missing, since, fallbackAfter, now)
+
+	changed, err := r.setFallbackReplicas(ctx, azureMachinePool.Namespace, fallbackName, azureMachinePool.Name, replicas)
+	if apierrors.IsNotFound(microerror.Cause(err)) {
+		return r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeWarning, InvalidSpotFallback, "Spot fallback MachinePool %s was not found", fallbackName)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	reportFallbackReplicas(key.ClusterID(&azureMachinePool), azureMachinePool.Name, replicas)
+
+	if changed {
+		err = r.eventRecorder.Emit(ctx, &azureMachinePool, event.TypeNormal, SpotFallbackScaledReason, "Scaled fallback MachinePool %s to %d instances on behalf of %d missing spot instances", fallbackName, replicas, missing)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// removeFallback removes the instances added to the fallback node pool on
+// behalf of the spot node pool.
+func (r *Resource) removeFallback(ctx context.Context, azureMachinePool capzexp.AzureMachinePool) error {
+	fallbackName := azureMachinePool.Annotations[annotation.SpotFallbackMachinePool]
+	if fallbackName == "" {
+		return nil
+	}
+
+	_, err := r.setFallbackReplicas(ctx, azureMachinePool.Namespace, fallbackName, azureMachinePool.Name, 0)
+	if apierrors.IsNotFound(microerror.Cause(err)) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// missingInstances returns how many instances the spot node pool is missing
+// to reach its desired size.
+func (r *Resource) missingInstances(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, machinePool capiexp.MachinePool) (int, error) {
+	resourceGroup := key.ClusterID(&azureMachinePool)
+	vmssName := key.NodePoolVMSSName(&azureMachinePool)
+
+	virtualMachineScaleSetsClient, err := r.clientFactory.GetVirtualMachineScaleSetsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	vmss, err := virtualMachineScaleSetsClient.Get(ctx, resourceGroup, vmssName)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	virtualMachineScaleSetVMsClient, err := r.clientFactory.GetVirtualMachineScaleSetVMsClient(ctx, azureMachinePool.ObjectMeta)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	result, err := virtualMachineScaleSetVMsClient.List(ctx, resourceGroup, vmssName, "", "", "")
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	var instances []compute.VirtualMachineScaleSetVM
+	for result.NotDone() {
+		instances = append(instances, result.Values()...)
+
+		err := result.NextWithContext(ctx)
+		if err != nil {
+			return 0, microerror.Mask(err)
+		}
+	}
+
+	desired := int(key.NodePoolMinReplicas(&machinePool))
+	if vmss.Sku != nil && vmss.Sku.Capacity != nil && int(*vmss.Sku.Capacity) > desired {
+		desired = int(*vmss.Sku.Capacity)
+	}
+
+	return countMissingInstances(desired, instances), nil
+}
+
+// saveCapacityUnavailableSince records since when the spot node pool misses
+// instances and returns it. It returns nil when no instances are missing.
+func (r *Resource) saveCapacityUnavailableSince(ctx context.Context, azureMachinePool capzexp.AzureMachinePool, missing int, now time.Time) (*time.Time, error) {
+	v, found := azureMachinePool.Annotations[annotation.SpotCapacityUnavailableSince]
+	if found && missing > 0 {
+		since, err := time.Parse(time.RFC3339, v)
+		if err == nil {
+			return &since, nil
+		}
+	}
+	if !found && missing == 0 {
+		return nil, nil
+	}
+
+	// Fetch the latest version of the object to reduce write conflicts.
+	amp := capzexp.AzureMachinePool{}
+	err := r.ctrlClient.Get(ctx, ctrlclient.ObjectKeyFromObject(&azureMachinePool), &amp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if amp.Annotations == nil {
+		amp.Annotations = map[string]string{}
+	}
+
+	var since *time.Time
+	if missing > 0 {
+		r.logger.Debugf(ctx, "spot node pool misses %d instances", missing)
+		amp.Annotations[annotation.SpotCapacityUnavailableSince] = now.UTC().Format(time.RFC3339)
+		since = &now
+	} else {
+		r.logger.Debugf(ctx, "spot node pool capacity is available again")
+		delete(amp.Annotations, annotation.SpotCapacityUnavailableSince)
+	}
+
+	err = r.ctrlClient.Update(ctx, &amp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return since, nil
+}
+
+// setFallbackReplicas sets the number of instances added to the fallback
+// MachinePool on behalf of the spot node pool and returns true if it changed.
+func (r *Resource) setFallbackReplicas(ctx context.Context, namespace, fallbackName, spotName string, replicas int) (bool, error) {
+	machinePool := capiexp.MachinePool{}
+	err := r.ctrlClient.Get(ctx, ctrlclient.ObjectKey{Namespace: namespace, Name: fallbackName}, &machinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	annotationName := annotation.SpotFallbackReplicasPrefix + spotName
+
+	current, found := machinePool.Annotations[annotationName]
+	if (replicas == 0 && !found) || current == strconv.Itoa(replicas) {
+		return false, nil
+	}
+
+	if replicas == 0 {
+		delete(machinePool.Annotations, annotationName)
+	} else {
+		if machinePool.Annotations == nil {
+			machinePool.Annotations = map[string]string{}
+		}
+		machinePool.Annotations[annotationName] = strconv.Itoa(replicas)
+	}
+
+	err = r.ctrlClient.Update(ctx, &machinePool)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return true, nil
+}
+
+// countMissingInstances returns how many of the desired instances are not
+// running, e.g. because they were evicted or could not be allocated.
+// Instances which are being created or updated are not missing, so that scale
+// ups and upgrades of the spot node pool do not scale the fallback node pool.
+func countMissingInstances(desired int, instances []compute.VirtualMachineScaleSetVM) int {
+	running := 0
+	for _, i := range instances {
+		if i.ProvisioningState == nil {
+			continue
+		}
+
+		switch *i.ProvisioningState {
+		case provisioningStateCreating, provisioningStateSucceeded, provisioningStateUpdating:
+			running++
+		}
+	}
+
+	if running >= desired {
+		return 0
+	}
+
+	return desired - running
+}
+
+// desiredFallbackReplicas returns the number of instances to add to the
+// fallback node pool. Missing instances are only replaced once spot capacity
+// was unavailable for the given time.
+func desiredFallbackReplicas(missing int, unavailableSince *time.Time, after time.Duration, now time.Time) int {
+	if missing == 0 || unavailableSince == nil {
+		return 0
+	}
+	if now.Sub(*unavailableSince) < after {
+		return 0
+	}
+
+	return missing
+}
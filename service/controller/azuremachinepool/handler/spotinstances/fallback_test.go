@@ -0,0 +1,200 @@
+package spotinstances
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_countMissingInstances(t *testing.T) {
+	instance := func(state string) compute.VirtualMachineScaleSetVM {
+		return compute.VirtualMachineScaleSetVM{
+			VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+				ProvisioningState: to.StringPtr(state),
+			},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		desired         int
+		instances       []compute.VirtualMachineScaleSetVM
+		expectedMissing int
+	}{
+		{
+			name:      "case 0: all instances running",
+			desired:   2,
+			instances: []compute.VirtualMachineScaleSetVM{instance("Succeeded"), instance("Succeeded")},
+		},
+		{
+			name:            "case 1: instances failed to allocate",
+			desired:         3,
+			instances:       []compute.VirtualMachineScaleSetVM{instance("Succeeded"), instance("Failed"), instance("Deleting")},
+			expectedMissing: 2,
+		},
+		{
+			name:            "case 2: evicted instances were deleted",
+			desired:         2,
+			expectedMissing: 2,
+		},
+		{
+			name:      "case 3: more instances than desired",
+			desired:   1,
+			instances: []compute.VirtualMachineScaleSetVM{instance("Succeeded"), instance("Succeeded")},
+		},
+		{
+			name:      "case 4: instances being created or updated are not missing",
+			desired:   3,
+			instances: []compute.VirtualMachineScaleSetVM{instance("Succeeded"), instance("Creating"), instance("Updating")},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			missing := countMissingInstances(tc.desired, tc.instances)
+
+			if missing != tc.expectedMissing {
+				t.Fatalf("expected %d, got %d", tc.expectedMissing, missing)
+			}
+		})
+	}
+}
+
+func Test_desiredFallbackReplicas(t *testing.T) {
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	recently := now.Add(-5 * time.Minute)
+	longAgo := now.Add(-time.Hour)
+
+	testCases := []struct {
+		name             string
+		missing          int
+		unavailableSince *time.Time
+		expectedReplicas int
+	}{
+		{
+			name: "case 0: capacity available",
+		},
+		{
+			name:             "case 1: capacity unavailable for a short time",
+			missing:          2,
+			unavailableSince: &recently,
+		},
+		{
+			name:             "case 2: capacity unavailable for longer than the fallback time",
+			missing:          2,
+			unavailableSince: &longAgo,
+			expectedReplicas: 2,
+		},
+		{
+			name:             "case 3: capacity returned",
+			unavailableSince: &longAgo,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			replicas := desiredFallbackReplicas(tc.missing, tc.unavailableSince, 15*time.Minute, now)
+
+			if replicas != tc.expectedReplicas {
+				t.Fatalf("expected %d, got %d", tc.expectedReplicas, replicas)
+			}
+		})
+	}
+}
+
+func Test_Resource_setFallbackReplicas(t *testing.T) {
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		replicas            int
+		expectedChanged     bool
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:            "case 0: scale up",
+			replicas:        2,
+			expectedChanged: true,
+			expectedAnnotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+			},
+		},
+		{
+			name: "case 1: unchanged",
+			annotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+			},
+			replicas: 2,
+			expectedAnnotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+			},
+		},
+		{
+			name: "case 2: scale back keeps other spot node pools",
+			annotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+				annotation.SpotFallbackReplicasPrefix + "spot2": "1",
+			},
+			expectedChanged: true,
+			expectedAnnotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot2": "1",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			scheme := runtime.NewScheme()
+			err := capiexp.AddToScheme(scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			machinePool := &capiexp.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "ondemand1",
+					Namespace:   "org-test",
+					Annotations: tc.annotations,
+				},
+			}
+			ctrlClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(machinePool).Build()
+
+			r := &Resource{ctrlClient: ctrlClient}
+
+			changed, err := r.setFallbackReplicas(context.Background(), "org-test", "ondemand1", "spot1", tc.replicas)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+			if changed != tc.expectedChanged {
+				t.Fatalf("expected %t, got %t", tc.expectedChanged, changed)
+			}
+
+			updated := capiexp.MachinePool{}
+			err = ctrlClient.Get(context.Background(), client.ObjectKeyFromObject(machinePool), &updated)
+			if err != nil {
+				t.Fatalf("unexpected error %#v", err)
+			}
+
+			if !cmp.Equal(updated.Annotations, tc.expectedAnnotations) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedAnnotations, updated.Annotations))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package spotinstances
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelClusterID  = "cluster_id"
+	labelNodePoolID = "node_pool_id"
+)
+
+var (
+	evictionNotices = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azure_operator_spot_instance_eviction_notices_total",
+			Help: "Counter representing the spot instances which received an eviction notice.",
+		},
+		[]string{labelClusterID, labelNodePoolID},
+	)
+
+	fallbackReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azure_operator_spot_fallback_replicas",
+			Help: "Gauge representing the number of instances added to the fallback node pool of a spot node pool lacking capacity.",
+		},
+		[]string{labelClusterID, labelNodePoolID},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(evictionNotices)
+	prometheus.MustRegister(fallbackReplicas)
+}
+
+func reportEvictionNotice(clusterID, nodePoolID string) {
+	evictionNotices.WithLabelValues(clusterID, nodePoolID).Inc()
+}
+
+func reportFallbackReplicas(clusterID, nodePoolID string, replicas int) {
+	fallbackReplicas.WithLabelValues(clusterID, nodePoolID).Set(float64(replicas))
+}
@@ -0,0 +1,88 @@
+package spotinstances
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+)
+
+const (
+	// Name is the identifier of the resource.
+	Name = "spotinstances"
+)
+
+type Config struct {
+	ClientFactory       client.OrganizationFactory
+	CtrlClient          ctrlclient.Client
+	EventRecorder       *event.Recorder
+	Logger              micrologger.Logger
+	TenantClientFactory tenantcluster.Factory
+}
+
+// Resource reports the eviction notices of spot node pool instances and
+// scales up the fallback on-demand node pool while spot capacity is
+// unavailable.
+type Resource struct {
+	clientFactory       client.OrganizationFactory
+	ctrlClient          ctrlclient.Client
+	reportedNotices     *reportedNotices
+	eventRecorder       *event.Recorder
+	logger              micrologger.Logger
+	tenantClientFactory tenantcluster.Factory
+}
+
+func New(config Config) (*Resource, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.EventRecorder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.EventRecorder must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.TenantClientFactory == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TenantClientFactory must not be empty", config)
+	}
+
+	r := &Resource{
+		clientFactory:       config.ClientFactory,
+		ctrlClient:          config.CtrlClient,
+		reportedNotices:     newReportedNotices(),
+		eventRecorder:       config.EventRecorder,
+		logger:              config.Logger,
+		tenantClientFactory: config.TenantClientFactory,
+	}
+
+	return r, nil
+}
+
+// Name returns the resource name.
+func (r *Resource) Name() string {
+	return Name
+}
+
+// getOwnerMachinePool returns the MachinePool object owning the current resource.
+func (r *Resource) getOwnerMachinePool(ctx context.Context, obj metav1.ObjectMeta) (*capiexp.MachinePool, error) {
+	for _, ref := range obj.OwnerReferences {
+		if ref.Kind == "MachinePool" && ref.APIVersion == capiexp.GroupVersion.String() {
+			machinePool := &capiexp.MachinePool{}
+			err := r.ctrlClient.Get(ctx, ctrlclient.ObjectKey{Name: ref.Name, Namespace: obj.Namespace}, machinePool)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return machinePool, nil
+		}
+	}
+
+	return nil, nil
+}
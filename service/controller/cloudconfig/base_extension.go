@@ -6,6 +6,7 @@ import (
 	"github.com/giantswarm/certs/v4/pkg/certs"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
 	"github.com/giantswarm/azure-operator/v8/service/controller/encrypter"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 	"github.com/giantswarm/azure-operator/v8/service/controller/setting"
@@ -37,7 +38,7 @@ func (e *baseExtension) templateData(certFiles []certs.File) templateData {
 
 	subnetName := key.WorkerSubnetName(e.customObject)
 	if e.azureMachinePool != nil {
-		subnetName = e.azureMachinePool.Name
+		subnetName = key.NodePoolSubnetName(e.azureMachinePool)
 	}
 
 	return templateData{
@@ -61,6 +62,7 @@ func (e *baseExtension) templateData(certFiles []certs.File) templateData {
 			SubscriptionID:              e.subscriptionID,
 			TenantID:                    e.azureClientCredentialsConfig.TenantID,
 			VnetName:                    key.VnetName(e.customObject),
+			VnetResourceGroup:           key.VnetResourceGroupName(e.customObject),
 			UseManagedIdentityExtension: e.azure.MSI.Enabled,
 		},
 		certificateDecrypterUnitParams{
@@ -69,5 +71,8 @@ func (e *baseExtension) templateData(certFiles []certs.File) templateData {
 		ingressLBFileParams{
 			ClusterDNSDomain: key.ClusterDNSDomain(e.customObject),
 		},
+		scheduledEventsAgentParams{
+			SpotEvictionNoticeAnnotation: annotation.NodeSpotEvictionNotice,
+		},
 	}
 }
@@ -0,0 +1,234 @@
+package cloudconfig
+
+import (
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	k8scloudconfig "github.com/giantswarm/k8scloudconfig/v17/pkg/template"
+	"github.com/giantswarm/microerror"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+	"github.com/giantswarm/azure-operator/v8/service/controller/templates"
+	"github.com/giantswarm/azure-operator/v8/service/controller/templates/ignition"
+	"github.com/giantswarm/azure-operator/v8/service/network"
+)
+
+const (
+	calicoManifestPath    = "k8s-resource/calico-policy-only.yaml"
+	kubeProxyConfigPath   = "config/kube-proxy.yaml"
+	calicoConfigMapName   = "calico-config"
+	calicoNodeName        = "calico-node"
+	calicoCNINetworkKey   = "cni_network_config"
+	yamlDocumentSeparator = "\n---\n"
+
+	// nodeCIDRMaskSizeIPv4 is the size of the IPv4 pod range allocated to
+	// each node, which is the controller manager default.
+	nodeCIDRMaskSizeIPv4 = 24
+	// nodeCIDRMaskSizeIPv6 is the size of the IPv6 pod range allocated to
+	// each node. The IPv6 pod range of the cluster embeds the IPv4 one, see
+	// network.ComputeIPv6, so this gives the same number of node ranges in
+	// both families.
+	nodeCIDRMaskSizeIPv6 = network.IPv6RangeMaskBits + nodeCIDRMaskSizeIPv4
+)
+
+type kubeProxyConfigParams struct {
+	ClusterCIDRs        string
+	ConntrackMaxPerCore int
+}
+
+type calicoNodeFelixEnvParams struct {
+	IPv6Support bool
+}
+
+// clusterCIDRs returns the pod ranges of the cluster, which are the IPv4 and
+// IPv6 Calico ranges for dual-stack clusters.
+func clusterCIDRs(customObject providerv1alpha1.AzureConfig) (string, error) {
+	if key.VnetIPv6CIDR(customObject) == "" {
+		return key.CalicoCIDR(customObject), nil
+	}
+
+	_, vnet, err := net.ParseCIDR(key.VnetCIDR(customObject))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	_, vnet6, err := net.ParseCIDR(key.VnetIPv6CIDR(customObject))
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	subnets, err := network.Compute(*vnet)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	ipv6Subnets, err := network.ComputeIPv6(*vnet6, *subnets)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return key.CalicoCIDR(customObject) + "," + ipv6Subnets.Calico.String(), nil
+}
+
+// controllerManagerPodCIDRArgs returns the controller manager flags
+// allocating the pod ranges of the nodes.
+func controllerManagerPodCIDRArgs(customObject providerv1alpha1.AzureConfig, podCIDRs string) []string {
+	args := []string{
+		"--allocate-node-cidrs=true",
+		"--cluster-cidr=" + podCIDRs,
+	}
+
+	if key.VnetIPv6CIDR(customObject) != "" {
+		args = append(args,
+			"--node-cidr-mask-size-ipv4="+strconv.Itoa(nodeCIDRMaskSizeIPv4),
+			"--node-cidr-mask-size-ipv6="+strconv.Itoa(nodeCIDRMaskSizeIPv6),
+		)
+	}
+
+	return args
+}
+
+// renderDualStackFiles replaces the kube-proxy configuration and the Calico
+// settings rendered by k8scloudconfig with the ones of dual-stack clusters.
+// kube-proxy gets both pod ranges, the CNI plugin assigns IPv6 pod IPs and
+// Felix enforces network policies for the IPv6 traffic.
+func renderDualStackFiles(files k8scloudconfig.Files, cluster providerv1alpha1.Cluster, podCIDRs string) error {
+	{
+		params := kubeProxyConfigParams{
+			ClusterCIDRs:        podCIDRs,
+			ConntrackMaxPerCore: cluster.Kubernetes.NetworkSetup.KubeProxy.ConntrackMaxPerCore,
+		}
+
+		kubeProxyConfig, err := templates.Render([]string{ignition.KubeProxyConfig}, params)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		files[kubeProxyConfigPath] = base64.StdEncoding.EncodeToString([]byte(kubeProxyConfig))
+	}
+
+	{
+		cniNetworkConfig, err := templates.Render([]string{ignition.CalicoCNINetworkConfig}, nil)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		felixEnv, err := templates.Render([]string{ignition.CalicoNodeFelixEnv}, calicoNodeFelixEnvParams{IPv6Support: true})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		var env []corev1.EnvVar
+		err = yaml.Unmarshal([]byte(felixEnv), &env)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = updateCalicoManifest(files, cniNetworkConfig, env)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// updateCalicoManifest sets the CNI network configuration in the calico-config
+// ConfigMap and the given environment variables in the calico-node containers
+// of the Calico manifest.
+func updateCalicoManifest(files k8scloudconfig.Files, cniNetworkConfig string, env []corev1.EnvVar) error {
+	encoded, ok := files[calicoManifestPath]
+	if !ok {
+		return microerror.Maskf(invalidConfigError, "file %#q not found", calicoManifestPath)
+	}
+
+	manifest, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var configMapFound, daemonSetFound bool
+	documents := strings.Split(string(manifest), yamlDocumentSeparator)
+	for i, document := range documents {
+		var object metav1.PartialObjectMetadata
+		err = yaml.Unmarshal([]byte(document), &object)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		var updated interface{}
+		switch {
+		case object.Kind == "ConfigMap" && object.Name == calicoConfigMapName:
+			var configMap corev1.ConfigMap
+			err = yaml.Unmarshal([]byte(document), &configMap)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			configMap.Data[calicoCNINetworkKey] = cniNetworkConfig
+			updated = configMap
+			configMapFound = true
+		case object.Kind == "DaemonSet" && object.Name == calicoNodeName:
+			var daemonSet appsv1.DaemonSet
+			err = yaml.Unmarshal([]byte(document), &daemonSet)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			for j, container := range daemonSet.Spec.Template.Spec.Containers {
+				if container.Name == calicoNodeName {
+					daemonSet.Spec.Template.Spec.Containers[j].Env = setEnv(container.Env, env)
+					daemonSetFound = true
+				}
+			}
+			updated = daemonSet
+		default:
+			continue
+		}
+
+		b, err := yaml.Marshal(updated)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		documents[i] = string(b)
+	}
+
+	if !configMapFound {
+		return microerror.Maskf(invalidConfigError, "file %#q does not contain ConfigMap %#q", calicoManifestPath, calicoConfigMapName)
+	}
+	if !daemonSetFound {
+		return microerror.Maskf(invalidConfigError, "file %#q does not contain DaemonSet %#q", calicoManifestPath, calicoNodeName)
+	}
+
+	files[calicoManifestPath] = base64.StdEncoding.EncodeToString([]byte(strings.Join(documents, yamlDocumentSeparator)))
+
+	return nil
+}
+
+// setEnv returns the environment variables with the given ones replacing the
+// ones of the same name, in place, and the others appended.
+func setEnv(current, env []corev1.EnvVar) []corev1.EnvVar {
+	replaced := map[string]bool{}
+	updated := make([]corev1.EnvVar, 0, len(current)+len(env))
+	for _, c := range current {
+		for _, e := range env {
+			if c.Name == e.Name {
+				c = e
+				replaced[e.Name] = true
+			}
+		}
+		updated = append(updated, c)
+	}
+	for _, e := range env {
+		if !replaced[e.Name] {
+			updated = append(updated, e)
+		}
+	}
+
+	return updated
+}
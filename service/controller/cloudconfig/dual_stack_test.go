@@ -0,0 +1,109 @@
+package cloudconfig
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	k8scloudconfig "github.com/giantswarm/k8scloudconfig/v17/pkg/template"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_renderDualStackFiles(t *testing.T) {
+	packagePath, err := k8scloudconfig.GetPackagePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := k8scloudconfig.Params{
+		CalicoPolicyOnly: true,
+	}
+	params.Cluster.Kubernetes.NetworkSetup.KubeProxy.ConntrackMaxPerCore = 32768
+	files, err := k8scloudconfig.RenderFiles(k8scloudconfig.GetIgnitionPath(packagePath), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = renderDualStackFiles(files, params.Cluster, "10.1.128.0/17,fd00:1234:a01:8000::/49")
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	kubeProxyConfig := decodeFile(t, files, kubeProxyConfigPath)
+	if !strings.Contains(kubeProxyConfig, "clusterCIDR: 10.1.128.0/17,fd00:1234:a01:8000::/49\n") {
+		t.Fatalf("kube-proxy configuration does not contain both pod ranges\n\n%s\n", kubeProxyConfig)
+	}
+	if !strings.Contains(kubeProxyConfig, "maxPerCore: 32768\n") {
+		t.Fatalf("kube-proxy configuration does not contain conntrack settings\n\n%s\n", kubeProxyConfig)
+	}
+
+	var configMap corev1.ConfigMap
+	var daemonSet appsv1.DaemonSet
+	for _, document := range strings.Split(decodeFile(t, files, calicoManifestPath), yamlDocumentSeparator) {
+		var object metav1.PartialObjectMetadata
+		err = yaml.Unmarshal([]byte(document), &object)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case object.Kind == "ConfigMap" && object.Name == calicoConfigMapName:
+			err = yaml.Unmarshal([]byte(document), &configMap)
+		case object.Kind == "DaemonSet" && object.Name == calicoNodeName:
+			err = yaml.Unmarshal([]byte(document), &daemonSet)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !strings.Contains(configMap.Data[calicoCNINetworkKey], `[{"subnet": "usePodCidrIPv6"}]`) {
+		t.Fatalf("CNI network configuration does not assign IPv6 pod IPs\n\n%s\n", configMap.Data[calicoCNINetworkKey])
+	}
+	if configMap.Data["veth_mtu"] == "" {
+		t.Fatalf("expected other calico-config settings to be kept")
+	}
+
+	var ipv6Support []string
+	for _, container := range daemonSet.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "FELIX_IPV6SUPPORT" {
+				ipv6Support = append(ipv6Support, env.Value)
+			}
+		}
+	}
+	if len(ipv6Support) != 1 || ipv6Support[0] != "true" {
+		t.Fatalf("expected FELIX_IPV6SUPPORT to be set once to true, got %v", ipv6Support)
+	}
+}
+
+func Test_controllerManagerPodCIDRArgs(t *testing.T) {
+	customObject := providerv1alpha1.AzureConfig{}
+
+	args := controllerManagerPodCIDRArgs(customObject, "10.1.128.0/17")
+	if strings.Contains(strings.Join(args, " "), "--node-cidr-mask-size") {
+		t.Fatalf("expected no node CIDR mask sizes for single-stack clusters, got %v", args)
+	}
+
+	customObject.Annotations = map[string]string{annotation.VirtualNetworkIPv6CIDR: "fd00:1234:a01::/48"}
+	args = controllerManagerPodCIDRArgs(customObject, "10.1.128.0/17,fd00:1234:a01:8000::/49")
+	expected := "--allocate-node-cidrs=true --cluster-cidr=10.1.128.0/17,fd00:1234:a01:8000::/49 --node-cidr-mask-size-ipv4=24 --node-cidr-mask-size-ipv6=56"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("expected %q, got %q", expected, strings.Join(args, " "))
+	}
+}
+
+func decodeFile(t *testing.T, files k8scloudconfig.Files, path string) string {
+	b, err := base64.StdEncoding.DecodeString(files[path])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
@@ -31,6 +31,11 @@ func (c CloudConfig) NewMasterTemplate(ctx context.Context, data IgnitionTemplat
 		k8sAPIExtraArgs = append(k8sAPIExtraArgs, oidcExtraArgs...)
 	}
 
+	podCIDRs, err := clusterCIDRs(data.CustomObject)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
 	var params k8scloudconfig.Params
 	{
 		be := baseExtension{
@@ -79,10 +84,7 @@ func (c CloudConfig) NewMasterTemplate(ctx context.Context, data IgnitionTemplat
 						ReadOnly: true,
 					},
 				},
-				CommandExtraArgs: []string{
-					"--allocate-node-cidrs=true",
-					"--cluster-cidr=" + data.CustomObject.Spec.Azure.VirtualNetwork.CalicoSubnetCIDR,
-				},
+				CommandExtraArgs: controllerManagerPodCIDRArgs(data.CustomObject, podCIDRs),
 			},
 			Kubelet: k8scloudconfig.KubernetesDockerOptions{
 				RunExtraArgs: []string{
@@ -120,6 +122,13 @@ func (c CloudConfig) NewMasterTemplate(ctx context.Context, data IgnitionTemplat
 		return "", microerror.Mask(err)
 	}
 
+	if key.VnetIPv6CIDR(data.CustomObject) != "" {
+		err = renderDualStackFiles(params.Files, params.Cluster, podCIDRs)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
 	return newCloudConfig(k8scloudconfig.MasterTemplate, params)
 }
 
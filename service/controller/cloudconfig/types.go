@@ -16,6 +16,7 @@ type templateData struct {
 	cloudProviderConfFileParams
 	certificateDecrypterUnitParams
 	ingressLBFileParams
+	scheduledEventsAgentParams
 }
 
 type azureCNIFileParams struct {
@@ -40,6 +41,7 @@ type cloudProviderConfFileParams struct {
 	SubscriptionID              string
 	TenantID                    string
 	VnetName                    string
+	VnetResourceGroup           string
 	UseManagedIdentityExtension bool
 }
 
@@ -51,6 +53,10 @@ type ingressLBFileParams struct {
 	ClusterDNSDomain string
 }
 
+type scheduledEventsAgentParams struct {
+	SpotEvictionNoticeAnnotation string
+}
+
 type IgnitionTemplateData struct {
 	AzureMachinePool *capzexp.AzureMachinePool
 	AzureCluster     *capz.AzureCluster
@@ -45,6 +45,18 @@ func (c CloudConfig) NewWorkerTemplate(ctx context.Context, data IgnitionTemplat
 				},
 			},
 		}
+		// Taint nodes when they register, so that no pods are scheduled on
+		// them before the taints are applied.
+		var taints string
+		taints, err = key.NodePoolKubeletTaints(data.MachinePool)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+		if taints != "" {
+			params.Kubernetes.Kubelet.CommandExtraArgs = []string{
+				fmt.Sprintf("--register-with-taints=%s", taints),
+			}
+		}
 		params.EnableCronJobTimeZone = true
 		params.Extension = &workerExtension{
 			baseExtension: be,
@@ -72,6 +84,19 @@ func (c CloudConfig) NewWorkerTemplate(ctx context.Context, data IgnitionTemplat
 		if err != nil {
 			return "", microerror.Mask(err)
 		}
+
+		if key.VnetIPv6CIDR(data.CustomObject) != "" {
+			var podCIDRs string
+			podCIDRs, err = clusterCIDRs(data.CustomObject)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+
+			err = renderDualStackFiles(params.Files, params.Cluster, podCIDRs)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+		}
 	}
 
 	c.logger.Debugf(ctx, "rendering cloudconfig with kubelet labels %v", params.Cluster.Kubernetes.Kubelet.Labels)
@@ -112,6 +137,19 @@ func (we *workerExtension) Files() ([]k8scloudconfig.FileAsset, error) {
 			},
 			Permissions: CloudProviderFilePermission,
 		},
+		{
+			AssetContent: ignition.ScheduledEventsAgent,
+			Path:         "/opt/bin/azure-scheduled-events",
+			Owner: k8scloudconfig.Owner{
+				Group: k8scloudconfig.Group{
+					Name: FileOwnerGroupName,
+				},
+				User: k8scloudconfig.User{
+					Name: FileOwnerUserName,
+				},
+			},
+			Permissions: FilePermission,
+		},
 	}
 
 	data := we.templateData(we.certFiles)
@@ -190,6 +228,11 @@ func (we *workerExtension) Units() ([]k8scloudconfig.UnitAsset, error) {
 			Name:         "var-lib-kubelet.mount",
 			Enabled:      true,
 		},
+		{
+			AssetContent: ignition.ScheduledEventsAgentUnit,
+			Name:         "azure-scheduled-events.service",
+			Enabled:      true,
+		},
 		{
 			AssetContent: ignition.VNICConfigurationUnit,
 			Name:         "vnic-configuration.service",
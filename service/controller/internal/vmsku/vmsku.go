@@ -18,7 +18,11 @@ const (
 	CapabilitySupported = "True"
 
 	CapabilityAcceleratedNetworking = "AcceleratedNetworkingEnabled"
+	CapabilityEphemeralOSDisk       = "EphemeralOSDiskSupported"
+	CapabilityGPUs                  = "GPUs"
+	CapabilityMemoryGB              = "MemoryGB"
 	CapabilityPremiumIO             = "PremiumIO"
+	CapabilityVCPUs                 = "vCPUs"
 )
 
 type Config struct {
@@ -73,6 +77,28 @@ func (v *VMSKUs) HasCapability(ctx context.Context, vmType string, name string)
 	return false, nil
 }
 
+// Capability returns the value of the given capability of the VM type, e.g.
+// the number of vCPUs. It returns an empty string if the VM type doesn't
+// define the capability.
+func (v *VMSKUs) Capability(ctx context.Context, vmType string, name string) (string, error) {
+	err := v.ensureInitialized(ctx)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	vmsku, found := v.skus[vmType]
+	if !found {
+		return "", microerror.Maskf(skuNotFoundError, vmType)
+	}
+	if vmsku.Capabilities != nil {
+		for _, capability := range *vmsku.Capabilities {
+			if capability.Name != nil && *capability.Name == name && capability.Value != nil {
+				return *capability.Value, nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func (v *VMSKUs) ensureInitialized(ctx context.Context) error {
 	v.initMutex.Lock()
 	defer v.initMutex.Unlock()
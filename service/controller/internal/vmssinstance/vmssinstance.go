@@ -0,0 +1,31 @@
+// Package vmssinstance implements operations on single VMSS instances shared
+// by the handlers replacing or repairing nodes.
+package vmssinstance
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/microerror"
+)
+
+// Reimage reimages the VMSS instance with the given ID. The OS disk of the
+// instance is recreated from the VMSS model, data disks are kept.
+func Reimage(ctx context.Context, vmssClient *compute.VirtualMachineScaleSetsClient, resourceGroup, vmssName, instanceID string) error {
+	ids := &compute.VirtualMachineScaleSetReimageParameters{
+		InstanceIds: to.StringSlicePtr([]string{
+			instanceID,
+		}),
+	}
+	res, err := vmssClient.Reimage(ctx, resourceGroup, vmssName, ids)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = vmssClient.ReimageResponder(res.Response())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -53,3 +53,39 @@ var missingMachinePoolLabelError = &microerror.Error{
 func IsMissingMachinePoolLabelError(err error) bool {
 	return microerror.Cause(err) == missingMachinePoolLabelError
 }
+
+var invalidTaintError = &microerror.Error{
+	Kind: "invalidTaintError",
+}
+
+// IsInvalidTaint asserts invalidTaintError.
+func IsInvalidTaint(err error) bool {
+	return microerror.Cause(err) == invalidTaintError
+}
+
+var invalidLabelError = &microerror.Error{
+	Kind: "invalidLabelError",
+}
+
+// IsInvalidLabel asserts invalidLabelError.
+func IsInvalidLabel(err error) bool {
+	return microerror.Cause(err) == invalidLabelError
+}
+
+var invalidDiskConfigError = &microerror.Error{
+	Kind: "invalidDiskConfigError",
+}
+
+// IsInvalidDiskConfig asserts invalidDiskConfigError.
+func IsInvalidDiskConfig(err error) bool {
+	return microerror.Cause(err) == invalidDiskConfigError
+}
+
+var invalidDrainConfigError = &microerror.Error{
+	Kind: "invalidDrainConfigError",
+}
+
+// IsInvalidDrainConfig asserts invalidDrainConfigError.
+func IsInvalidDrainConfig(err error) bool {
+	return microerror.Cause(err) == invalidDrainConfigError
+}
@@ -3,9 +3,13 @@ package key
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	apiextensionsannotations "github.com/giantswarm/apiextensions/v6/pkg/annotation"
 	"github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
@@ -15,6 +19,8 @@ import (
 	k8smetaannotation "github.com/giantswarm/k8smetadata/pkg/annotation"
 	"github.com/giantswarm/microerror"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -52,6 +58,7 @@ const (
 	prefixWorker              = "worker"
 	subnetDeploymentPrefix    = "subnet"
 	virtualNetworkSuffix      = "VirtualNetwork"
+	vnetSetupDeploymentName   = "virtual_network_setup"
 	vpnGatewaySubnet          = "GatewaySubnet"
 	vpnGatewaySuffix          = "VPNGateway"
 
@@ -77,6 +84,44 @@ const (
 
 	credentialDefaultNamespace = "giantswarm"
 	credentialDefaultName      = "credential-default" // nolint:gosec
+
+	// defaultNodePoolMaxSurge doubles the node pool during rolling updates,
+	// which replaces all the outdated instances at once.
+	defaultNodePoolMaxSurge       = "100%"
+	defaultNodePoolMaxUnavailable = 0
+
+	// capzDefaultMaxSurge and capzDefaultMaxUnavailable are the rolling update
+	// values the CAPZ CRD defaults AzureMachinePool deployment strategies to.
+	capzDefaultMaxSurge       = 1
+	capzDefaultMaxUnavailable = 0
+
+	// defaultSpotFallbackAfter is how long spot capacity must be unavailable
+	// before the fallback node pool is scaled up.
+	defaultSpotFallbackAfter = 15 * time.Minute
+
+	// NodePoolDrainTimeout is how long node pool nodes are drained before
+	// they are terminated regardless of the pods left on them.
+	NodePoolDrainTimeout = 15 * time.Minute
+
+	// Node pool data disks mounted to /var/lib/docker and /var/lib/kubelet
+	// are identified by their name suffix. Node pools without them get
+	// disks of the default size at the default LUNs.
+	NodePoolDockerDiskName         = "docker"
+	NodePoolKubeletDiskName        = "kubelet"
+	defaultNodePoolDockerDiskLun   = 21
+	defaultNodePoolKubeletDiskLun  = 22
+	defaultNodePoolDockerDiskSize  = 50
+	defaultNodePoolKubeletDiskSize = 100
+
+	// NodePoolUpgradeModeInPlace is the value of the upgrade mode annotation
+	// to upgrade node pool instances in place.
+	NodePoolUpgradeModeInPlace = "in-place"
+
+	// Policies applied when the node pool state machine exceeds the deadline
+	// of a state.
+	NodePoolUpgradeTimeoutPolicyReport   = "report"
+	NodePoolUpgradeTimeoutPolicyRetry    = "retry"
+	NodePoolUpgradeTimeoutPolicyRollback = "rollback"
 )
 
 // Container image versions for k8scloudconfig.
@@ -379,6 +424,32 @@ func IsSucceededProvisioningState(s string) bool {
 	return s == "Succeeded"
 }
 
+// NodePoolKubeletLabels returns the kubelet labels of the node pool nodes,
+// including the labels defined in the MachinePool annotation.
+func NodePoolKubeletLabels(machinePool *capiexp.MachinePool) (string, error) {
+	labels, err := KubeletLabelsNodePool(machinePool)
+	if err != nil {
+		return labels, microerror.Mask(err)
+	}
+
+	nodePoolLabels, err := NodePoolLabels(machinePool)
+	if err != nil {
+		return labels, microerror.Mask(err)
+	}
+
+	keys := make([]string, 0, len(nodePoolLabels))
+	for k := range nodePoolLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		labels = ensureLabel(labels, k, nodePoolLabels[k])
+	}
+
+	return labels, nil
+}
+
 // These are the same labels that kubernetesd adds when creating/updating an AzureConfig.
 func KubeletLabelsNodePool(getter LabelsGetter) (string, error) {
 	var labels string
@@ -431,6 +502,10 @@ func WorkerSubnetName(customObject providerv1alpha1.AzureConfig) string {
 	return fmt.Sprintf("%s-%s-%s", ClusterID(&customObject), virtualNetworkSuffix, workerSubnetSuffix)
 }
 
+func WorkerSubnetNameFromClusterAPIObject(getter LabelsGetter) string {
+	return fmt.Sprintf("%s-%s-%s", ClusterName(getter), virtualNetworkSuffix, workerSubnetSuffix)
+}
+
 func MasterInstanceName(customObject providerv1alpha1.AzureConfig, instanceID string) string {
 	idB36, err := vmssInstanceIDBase36(instanceID)
 	if err != nil {
@@ -449,6 +524,15 @@ func MasterNICName(customObject providerv1alpha1.AzureConfig) string {
 	return fmt.Sprintf("%s-master-%s-nic", ClusterID(&customObject), ClusterID(&customObject))
 }
 
+// MasterVMSize returns the desired VM size of the master nodes.
+func MasterVMSize(customObject providerv1alpha1.AzureConfig) string {
+	if len(customObject.Spec.Azure.Masters) == 0 {
+		return ""
+	}
+
+	return customObject.Spec.Azure.Masters[0].VMSize
+}
+
 func MasterVMSSName(customObject providerv1alpha1.AzureConfig) string {
 	return fmt.Sprintf("%s-master-%s", ClusterID(&customObject), ClusterID(&customObject))
 }
@@ -648,13 +732,118 @@ func ToString(v interface{}) (string, error) {
 
 // VnetName returns name of the virtual network.
 func VnetName(customObject providerv1alpha1.AzureConfig) string {
+	if vnet, ok := existingVnet(customObject); ok {
+		return vnet.ResourceName
+	}
+
 	return fmt.Sprintf("%s-%s", ClusterID(&customObject), virtualNetworkSuffix)
 }
 
+// VnetResourceGroupName returns name of the resource group of the virtual
+// network, which is the cluster resource group unless the cluster uses an
+// existing virtual network.
+func VnetResourceGroupName(customObject providerv1alpha1.AzureConfig) string {
+	if vnet, ok := existingVnet(customObject); ok {
+		return vnet.ResourceGroup
+	}
+
+	return ResourceGroupName(customObject)
+}
+
+// VnetSetupDeploymentName returns name of the ARM deployment setting up the
+// virtual network and the cluster subnets. It is prefixed with the cluster ID
+// for existing virtual networks because the deployment lives in the resource
+// group of the virtual network, which may be shared by several clusters.
+func VnetSetupDeploymentName(customObject providerv1alpha1.AzureConfig) string {
+	if _, ok := existingVnet(customObject); ok {
+		return fmt.Sprintf("%s-%s", ClusterID(&customObject), vnetSetupDeploymentName)
+	}
+
+	return vnetSetupDeploymentName
+}
+
+// ExistingVnetID returns the resource ID of the existing virtual network the
+// cluster uses, or an empty string if the cluster owns its virtual network.
+func ExistingVnetID(customObject providerv1alpha1.AzureConfig) string {
+	return customObject.Annotations[annotation.ExistingVirtualNetworkID]
+}
+
+// UsesExistingVnet returns true if the AzureCluster references an existing
+// virtual network which is neither created nor deleted by the operator.
+func UsesExistingVnet(azureCluster *capz.AzureCluster) bool {
+	return azureCluster.Spec.NetworkSpec.Vnet.ID != ""
+}
+
+// AzureClusterVnetResourceGroup returns name of the resource group of the
+// virtual network of the AzureCluster.
+func AzureClusterVnetResourceGroup(azureCluster *capz.AzureCluster) string {
+	if azureCluster.Spec.NetworkSpec.Vnet.ResourceGroup != "" {
+		return azureCluster.Spec.NetworkSpec.Vnet.ResourceGroup
+	}
+
+	return ClusterID(azureCluster)
+}
+
+func existingVnet(customObject providerv1alpha1.AzureConfig) (azure.Resource, bool) {
+	if ExistingVnetID(customObject) == "" {
+		return azure.Resource{}, false
+	}
+
+	vnet, err := azure.ParseResourceID(ExistingVnetID(customObject))
+	if err != nil {
+		return azure.Resource{}, false
+	}
+
+	return vnet, true
+}
+
 func VnetCIDR(customObject providerv1alpha1.AzureConfig) string {
 	return customObject.Spec.Azure.VirtualNetwork.CIDR
 }
 
+// VnetIPv6CIDR returns the IPv6 range of the virtual network of a dual-stack
+// cluster, or an empty string for single-stack clusters.
+func VnetIPv6CIDR(customObject providerv1alpha1.AzureConfig) string {
+	return customObject.Annotations[annotation.VirtualNetworkIPv6CIDR]
+}
+
+// VnetCIDRBlocks returns the IPv4 range and, for dual-stack clusters, the IPv6
+// range of the virtual network.
+func VnetCIDRBlocks(customObject providerv1alpha1.AzureConfig) []string {
+	cidrBlocks := []string{VnetCIDR(customObject)}
+	if VnetIPv6CIDR(customObject) != "" {
+		cidrBlocks = append(cidrBlocks, VnetIPv6CIDR(customObject))
+	}
+
+	return cidrBlocks
+}
+
+// DualStackEnabled returns true if the cluster gets IPv6 ranges in addition to
+// the IPv4 ranges for its virtual network and node pool subnets.
+func DualStackEnabled(getter AnnotationsGetter) bool {
+	return getter.GetAnnotations()[annotation.DualStack] == "true"
+}
+
+// AzureClusterVnetIPv6CIDR returns the IPv6 range of the virtual network of a
+// dual-stack cluster, or an empty string for single-stack clusters.
+func AzureClusterVnetIPv6CIDR(azureCluster *capz.AzureCluster) string {
+	if len(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks) < 2 {
+		return ""
+	}
+
+	return azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks[1]
+}
+
+// SubnetIPv6CIDR returns the IPv6 range of a node pool subnet of a dual-stack
+// cluster, or an empty string for single-stack clusters.
+func SubnetIPv6CIDR(subnet capz.SubnetSpec) string {
+	if len(subnet.CIDRBlocks) < 2 {
+		return ""
+	}
+
+	return subnet.CIDRBlocks[1]
+}
+
 // VNetGatewaySubnetName returns the name of the subnet for the vpn gateway.
 func VNetGatewaySubnetName() string {
 	return vpnGatewaySubnet
@@ -678,6 +867,28 @@ func WorkerInstanceName(clusterID, instanceID string) string {
 	return fmt.Sprintf("%s-worker-%s-%06s", clusterID, clusterID, idB36)
 }
 
+// NodePoolDrainPDBDeletionGracePeriod returns the time after which pods whose
+// eviction is blocked by a PodDisruptionBudget are deleted while draining
+// nodes of the node pool. It is zero, i.e. pods are never deleted, when the
+// annotation is not set. The grace period must be shorter than
+// NodePoolDrainTimeout, otherwise blocked pods would never be deleted.
+func NodePoolDrainPDBDeletionGracePeriod(azureMachinePool *capzexp.AzureMachinePool) (time.Duration, error) {
+	v, exists := azureMachinePool.Annotations[annotation.DrainPDBDeletionGracePeriod]
+	if !exists {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, microerror.Maskf(invalidDrainConfigError, "annotation %#q must be a non-negative duration, got %#q", annotation.DrainPDBDeletionGracePeriod, v)
+	}
+	if d >= NodePoolDrainTimeout {
+		return 0, microerror.Maskf(invalidDrainConfigError, "annotation %#q must be shorter than the drain timeout of %s, got %#q", annotation.DrainPDBDeletionGracePeriod, NodePoolDrainTimeout, v)
+	}
+
+	return d, nil
+}
+
 func NodePoolDeploymentName(azureMachinePool *capzexp.AzureMachinePool) string {
 	return NodePoolVMSSName(azureMachinePool)
 }
@@ -742,6 +953,266 @@ func NodePoolMaxReplicas(machinePool *capiexp.MachinePool) int32 {
 	return int32(size) // nolint:gosec
 }
 
+// NodePoolScalingMinReplicas returns the minimum size the node pool VMSS is
+// deployed and tagged with for the cluster autoscaler. It includes the
+// instances added while spot node pools lack capacity.
+func NodePoolScalingMinReplicas(machinePool *capiexp.MachinePool) int32 {
+	return NodePoolMinReplicas(machinePool) + NodePoolSpotFallbackReplicas(machinePool)
+}
+
+// NodePoolScalingMaxReplicas returns the maximum size the node pool VMSS is
+// deployed and tagged with for the cluster autoscaler. It is never lower than
+// the scaling minimum size.
+func NodePoolScalingMaxReplicas(machinePool *capiexp.MachinePool) int32 {
+	size := NodePoolMaxReplicas(machinePool)
+	if minReplicas := NodePoolScalingMinReplicas(machinePool); size < minReplicas {
+		return minReplicas
+	}
+
+	return size
+}
+
+// NodePoolSpotFallbackReplicas returns the number of instances added to the
+// node pool on behalf of spot node pools lacking capacity.
+func NodePoolSpotFallbackReplicas(machinePool *capiexp.MachinePool) int32 {
+	var replicas int32
+	for k, v := range machinePool.Annotations {
+		if !strings.HasPrefix(k, annotation.SpotFallbackReplicasPrefix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			continue
+		}
+
+		replicas += int32(n) // nolint:gosec
+	}
+
+	return replicas
+}
+
+// NodePoolSpotFallbackAfter returns how long spot capacity must be
+// unavailable before the fallback node pool is scaled up.
+func NodePoolSpotFallbackAfter(azureMachinePool *capzexp.AzureMachinePool) (time.Duration, error) {
+	v, ok := azureMachinePool.Annotations[annotation.SpotFallbackAfter]
+	if !ok {
+		return defaultSpotFallbackAfter, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, microerror.Maskf(wrongTypeError, "%#q must be a positive duration but is %#q", annotation.SpotFallbackAfter, v)
+	}
+
+	return d, nil
+}
+
+// NodePoolLabels returns the labels of the node pool nodes defined in the
+// MachinePool annotation.
+func NodePoolLabels(machinePool *capiexp.MachinePool) (map[string]string, error) {
+	value := strings.TrimSpace(machinePool.Annotations[annotation.NodePoolLabels])
+	if value == "" {
+		return nil, nil
+	}
+
+	labels := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+
+		k, v, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, microerror.Maskf(invalidLabelError, "label %#q must have the format key=value", entry)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return nil, microerror.Maskf(invalidLabelError, "label %#q has an invalid key: %s", entry, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return nil, microerror.Maskf(invalidLabelError, "label %#q has an invalid value: %s", entry, strings.Join(errs, ", "))
+		}
+		if isReservedKey(k) {
+			return nil, microerror.Maskf(invalidLabelError, "label %#q uses a reserved namespace", entry)
+		}
+
+		labels[k] = v
+	}
+
+	return labels, nil
+}
+
+// isReservedKey returns true for label and taint keys which are set by
+// kubelet, Kubernetes or the operator itself.
+func isReservedKey(k string) bool {
+	prefix, _, found := strings.Cut(k, "/")
+	if !found {
+		return false
+	}
+
+	for _, namespace := range []string{"kubernetes.io", "k8s.io", "giantswarm.io"} {
+		if prefix == namespace || strings.HasSuffix(prefix, "."+namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NodePoolTaints returns the taints of the node pool nodes defined in the
+// MachinePool annotation.
+func NodePoolTaints(machinePool *capiexp.MachinePool) ([]v1.Taint, error) {
+	value := strings.TrimSpace(machinePool.Annotations[annotation.NodePoolTaints])
+	if value == "" {
+		return nil, nil
+	}
+
+	var taints []v1.Taint
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+
+		keyValue, effect, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q must have the format key=value:Effect", entry)
+		}
+
+		taint := v1.Taint{Effect: v1.TaintEffect(effect)}
+		taint.Key, taint.Value, _ = strings.Cut(keyValue, "=")
+
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q has unknown effect %#q", entry, effect)
+		}
+		if taint.Key == "" {
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q must have a key", entry)
+		}
+		if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q has an invalid key: %s", entry, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q has an invalid value: %s", entry, strings.Join(errs, ", "))
+		}
+		if isReservedKey(taint.Key) {
+			return nil, microerror.Maskf(invalidTaintError, "taint %#q uses a reserved namespace", entry)
+		}
+
+		taints = append(taints, taint)
+	}
+
+	return taints, nil
+}
+
+// NodePoolKubeletTaints returns the taints defined in the MachinePool
+// annotation in the format of the kubelet --register-with-taints flag, so that
+// nodes are tainted when they register.
+func NodePoolKubeletTaints(machinePool *capiexp.MachinePool) (string, error) {
+	taints, err := NodePoolTaints(machinePool)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	var values []string
+	for _, t := range taints {
+		values = append(values, fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect))
+	}
+
+	return strings.Join(values, ","), nil
+}
+
+// NodePoolMaxSurge returns how many instances can be created above the node
+// pool size during a rolling update. The MachinePool annotation takes
+// precedence over the AzureMachinePool deployment strategy. A deployment
+// strategy equal to the CAPZ CRD default is treated as unset, see
+// hasDefaultRollingUpdate.
+func NodePoolMaxSurge(machinePool *capiexp.MachinePool, azureMachinePool *capzexp.AzureMachinePool) intstr.IntOrString {
+	if v, ok := machinePool.Annotations[annotation.NodePoolMaxSurge]; ok {
+		return intstr.Parse(v)
+	}
+
+	rollingUpdate := azureMachinePool.Spec.Strategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.MaxSurge != nil && !hasDefaultRollingUpdate(azureMachinePool) {
+		return *rollingUpdate.MaxSurge
+	}
+
+	return intstr.FromString(defaultNodePoolMaxSurge)
+}
+
+// NodePoolMaxUnavailable returns how many instances can be unavailable during
+// a rolling update. The MachinePool annotation takes precedence over the
+// AzureMachinePool deployment strategy. A deployment strategy equal to the CAPZ
+// CRD default is treated as unset, see hasDefaultRollingUpdate.
+func NodePoolMaxUnavailable(machinePool *capiexp.MachinePool, azureMachinePool *capzexp.AzureMachinePool) intstr.IntOrString {
+	if v, ok := machinePool.Annotations[annotation.NodePoolMaxUnavailable]; ok {
+		return intstr.Parse(v)
+	}
+
+	rollingUpdate := azureMachinePool.Spec.Strategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.MaxUnavailable != nil && !hasDefaultRollingUpdate(azureMachinePool) {
+		return *rollingUpdate.MaxUnavailable
+	}
+
+	return intstr.FromInt(defaultNodePoolMaxUnavailable)
+}
+
+// hasDefaultRollingUpdate returns true when the AzureMachinePool rolling update
+// strategy is the one set by the CAPZ CRD defaulting (maxSurge 1 and
+// maxUnavailable 0). The CRD defaults the strategy of every AzureMachinePool,
+// so these values can't be told apart from unset ones and would otherwise
+// replace only one instance at a time instead of the whole node pool.
+// Node pools wanting exactly this behaviour have to set the MachinePool
+// annotations.
+func hasDefaultRollingUpdate(azureMachinePool *capzexp.AzureMachinePool) bool {
+	rollingUpdate := azureMachinePool.Spec.Strategy.RollingUpdate
+	if rollingUpdate == nil {
+		return true
+	}
+
+	maxSurge := rollingUpdate.MaxSurge
+	maxUnavailable := rollingUpdate.MaxUnavailable
+
+	return (maxSurge == nil || *maxSurge == intstr.FromInt(capzDefaultMaxSurge)) &&
+		(maxUnavailable == nil || *maxUnavailable == intstr.FromInt(capzDefaultMaxUnavailable))
+}
+
+// NodePoolInPlaceUpgrade returns true when the node pool instances have to be
+// reimaged in place rather than replaced by new instances during upgrades.
+func NodePoolInPlaceUpgrade(azureMachinePool *capzexp.AzureMachinePool) bool {
+	return azureMachinePool.Annotations[annotation.UpgradeMode] == NodePoolUpgradeModeInPlace
+}
+
+// NodePoolUpgradePaused returns true when the node pool state machine has to
+// be paused.
+func NodePoolUpgradePaused(azureMachinePool *capzexp.AzureMachinePool) bool {
+	return azureMachinePool.Annotations[annotation.UpgradePaused] == "true"
+}
+
+// NodePoolUpgradeAborted returns true when the node pool upgrade has to be
+// aborted and rolled back.
+func NodePoolUpgradeAborted(azureMachinePool *capzexp.AzureMachinePool) bool {
+	return azureMachinePool.Annotations[annotation.UpgradeAbort] == "true"
+}
+
+// NodePoolUpgradeTimeoutPolicy returns the policy applied when the node pool
+// state machine exceeds the deadline of a state. Unknown values fall back to
+// reporting only.
+func NodePoolUpgradeTimeoutPolicy(azureMachinePool *capzexp.AzureMachinePool) string {
+	switch v := azureMachinePool.Annotations[annotation.UpgradeTimeoutPolicy]; v {
+	case NodePoolUpgradeTimeoutPolicyRetry, NodePoolUpgradeTimeoutPolicyRollback:
+		return v
+	default:
+		return NodePoolUpgradeTimeoutPolicyReport
+	}
+}
+
+// NodePoolSubnetName returns the name of the subnet of the node pool. It is
+// the node pool name unless the AzureMachinePool selects an existing subnet.
+func NodePoolSubnetName(azureMachinePool *capzexp.AzureMachinePool) string {
+	if azureMachinePool.Spec.Template.SubnetName != "" {
+		return azureMachinePool.Spec.Template.SubnetName
+	}
+
+	return azureMachinePool.Name
+}
+
 func NodePoolVMSSName(azureMachinePool *capzexp.AzureMachinePool) string {
 	return fmt.Sprintf("%s-%s", "nodepool", azureMachinePool.Name)
 }
@@ -771,6 +1242,137 @@ func NodePoolSpotInstancesMaxPrice(azureMachinePool *capzexp.AzureMachinePool) s
 	return azureMachinePool.Spec.Template.SpotVMOptions.MaxPrice.AsDec().String()
 }
 
+// NodePoolDataDisks returns the data disks of the node pool, adding the
+// Docker and kubelet disks with the default size when they are not defined.
+func NodePoolDataDisks(azureMachinePool *capzexp.AzureMachinePool) []capz.DataDisk {
+	disks := append([]capz.DataDisk{}, azureMachinePool.Spec.Template.DataDisks...)
+
+	defaults := []capz.DataDisk{
+		{NameSuffix: NodePoolDockerDiskName, DiskSizeGB: defaultNodePoolDockerDiskSize, Lun: to.Int32Ptr(defaultNodePoolDockerDiskLun)},
+		{NameSuffix: NodePoolKubeletDiskName, DiskSizeGB: defaultNodePoolKubeletDiskSize, Lun: to.Int32Ptr(defaultNodePoolKubeletDiskLun)},
+	}
+	for _, d := range defaults {
+		if nodePoolDataDisk(azureMachinePool, d.NameSuffix) == nil {
+			disks = append(disks, d)
+		}
+	}
+
+	return disks
+}
+
+// NodePoolDockerDisk returns the data disk mounted to /var/lib/docker.
+func NodePoolDockerDisk(azureMachinePool *capzexp.AzureMachinePool) capz.DataDisk {
+	return nodePoolDataDiskOrDefault(azureMachinePool, NodePoolDockerDiskName)
+}
+
+// NodePoolKubeletDisk returns the data disk mounted to /var/lib/kubelet.
+func NodePoolKubeletDisk(azureMachinePool *capzexp.AzureMachinePool) capz.DataDisk {
+	return nodePoolDataDiskOrDefault(azureMachinePool, NodePoolKubeletDiskName)
+}
+
+func nodePoolDataDisk(azureMachinePool *capzexp.AzureMachinePool, nameSuffix string) *capz.DataDisk {
+	for i, d := range azureMachinePool.Spec.Template.DataDisks {
+		if d.NameSuffix == nameSuffix {
+			return &azureMachinePool.Spec.Template.DataDisks[i]
+		}
+	}
+
+	return nil
+}
+
+func nodePoolDataDiskOrDefault(azureMachinePool *capzexp.AzureMachinePool, nameSuffix string) capz.DataDisk {
+	for _, d := range NodePoolDataDisks(azureMachinePool) {
+		if d.NameSuffix == nameSuffix {
+			return d
+		}
+	}
+
+	return capz.DataDisk{}
+}
+
+// NodePoolEphemeralOSDisk returns true if the node pool instances use an
+// ephemeral OS disk stored on the local VM storage.
+func NodePoolEphemeralOSDisk(azureMachinePool *capzexp.AzureMachinePool) bool {
+	settings := azureMachinePool.Spec.Template.OSDisk.DiffDiskSettings
+	return settings != nil && settings.Option == string(compute.Local)
+}
+
+// NodePoolOSDiskEncryptionSetID returns the ID of the disk encryption set used
+// to encrypt the OS disk with a customer-managed key, if any.
+func NodePoolOSDiskEncryptionSetID(azureMachinePool *capzexp.AzureMachinePool) string {
+	return diskEncryptionSetID(azureMachinePool.Spec.Template.OSDisk.ManagedDisk)
+}
+
+// DataDiskEncryptionSetID returns the ID of the disk encryption set used to
+// encrypt the data disk with a customer-managed key, if any.
+func DataDiskEncryptionSetID(disk capz.DataDisk) string {
+	return diskEncryptionSetID(disk.ManagedDisk)
+}
+
+func diskEncryptionSetID(managedDisk *capz.ManagedDiskParameters) string {
+	if managedDisk == nil || managedDisk.DiskEncryptionSet == nil {
+		return ""
+	}
+
+	return managedDisk.DiskEncryptionSet.ID
+}
+
+// ValidateNodePoolDisks returns an invalidDiskConfigError if the OS and data
+// disks of the node pool can't be deployed.
+func ValidateNodePoolDisks(azureMachinePool *capzexp.AzureMachinePool) error {
+	osDisk := azureMachinePool.Spec.Template.OSDisk
+
+	if osDisk.DiffDiskSettings != nil && !NodePoolEphemeralOSDisk(azureMachinePool) {
+		return microerror.Maskf(invalidDiskConfigError, "OS disk diff disk settings option must be %#q, got %#q", compute.Local, osDisk.DiffDiskSettings.Option)
+	}
+	if NodePoolEphemeralOSDisk(azureMachinePool) {
+		// See https://docs.microsoft.com/en-us/azure/virtual-machines/ephemeral-os-disks.
+		if osDisk.CachingType != "" && osDisk.CachingType != string(compute.CachingTypesReadOnly) {
+			return microerror.Maskf(invalidDiskConfigError, "ephemeral OS disk caching type must be %#q, got %#q", compute.CachingTypesReadOnly, osDisk.CachingType)
+		}
+		if NodePoolOSDiskEncryptionSetID(azureMachinePool) != "" {
+			return microerror.Maskf(invalidDiskConfigError, "ephemeral OS disk can't be encrypted with a disk encryption set")
+		}
+	}
+	if !isValidCachingType(osDisk.CachingType) {
+		return microerror.Maskf(invalidDiskConfigError, "OS disk caching type %#q is invalid", osDisk.CachingType)
+	}
+
+	nameSuffixes := map[string]bool{}
+	luns := map[int32]bool{}
+	for _, d := range NodePoolDataDisks(azureMachinePool) {
+		if d.Lun == nil {
+			return microerror.Maskf(invalidDiskConfigError, "data disk %#q has no LUN", d.NameSuffix)
+		}
+		if nameSuffixes[d.NameSuffix] {
+			return microerror.Maskf(invalidDiskConfigError, "data disk name suffix %#q is used more than once", d.NameSuffix)
+		}
+		if luns[*d.Lun] {
+			return microerror.Maskf(invalidDiskConfigError, "data disk LUN %d is used more than once", *d.Lun)
+		}
+		if d.DiskSizeGB <= 0 {
+			return microerror.Maskf(invalidDiskConfigError, "data disk %#q size must be greater than zero", d.NameSuffix)
+		}
+		if !isValidCachingType(d.CachingType) {
+			return microerror.Maskf(invalidDiskConfigError, "data disk %#q caching type %#q is invalid", d.NameSuffix, d.CachingType)
+		}
+
+		nameSuffixes[d.NameSuffix] = true
+		luns[*d.Lun] = true
+	}
+
+	return nil
+}
+
+func isValidCachingType(cachingType string) bool {
+	switch compute.CachingTypes(cachingType) {
+	case "", compute.CachingTypesNone, compute.CachingTypesReadOnly, compute.CachingTypesReadWrite:
+		return true
+	default:
+		return false
+	}
+}
+
 func CGroupVersion(machinePool *capiexp.MachinePool) string {
 	cgroupsVersion := "v2"
 	if machinePool.Annotations != nil {
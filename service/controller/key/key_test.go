@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
+	apiextensionsannotations "github.com/giantswarm/apiextensions/v6/pkg/annotation"
 	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
 )
 
@@ -158,10 +165,67 @@ func Test_Functions_for_AzureResourceKeys(t *testing.T) {
 			Func:           VnetName,
 			ExpectedResult: fmt.Sprintf("%s-%s", clusterID, virtualNetworkSuffix),
 		},
+		{
+			Func:           VnetResourceGroupName,
+			ExpectedResult: clusterID,
+		},
+		{
+			Func:           VnetSetupDeploymentName,
+			ExpectedResult: vnetSetupDeploymentName,
+		},
+	}
+
+	customObject := providerv1alpha1.AzureConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				label.Cluster: clusterID,
+			},
+		},
+		Spec: providerv1alpha1.AzureConfigSpec{
+			Cluster: providerv1alpha1.Cluster{
+				ID: clusterID,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		actualRes := tc.Func(customObject)
+		if actualRes != tc.ExpectedResult {
+			t.Fatalf("Expected %s but was %s", tc.ExpectedResult, actualRes)
+		}
+	}
+}
+
+func Test_Functions_for_ExistingVnetKeys(t *testing.T) {
+	clusterID := "eggs2"
+
+	testCases := []struct {
+		Func           func(providerv1alpha1.AzureConfig) string
+		ExpectedResult string
+	}{
+		{
+			Func:           VnetName,
+			ExpectedResult: "hub",
+		},
+		{
+			Func:           VnetResourceGroupName,
+			ExpectedResult: "network",
+		},
+		{
+			Func:           VnetSetupDeploymentName,
+			ExpectedResult: fmt.Sprintf("%s-%s", clusterID, vnetSetupDeploymentName),
+		},
+		{
+			Func:           MasterSubnetName,
+			ExpectedResult: fmt.Sprintf("%s-%s-%s", clusterID, virtualNetworkSuffix, masterSubnetSuffix),
+		},
 	}
 
 	customObject := providerv1alpha1.AzureConfig{
 		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotation.ExistingVirtualNetworkID: "/subscriptions/s/resourceGroups/network/providers/Microsoft.Network/virtualNetworks/hub",
+			},
 			Labels: map[string]string{
 				label.Cluster: clusterID,
 			},
@@ -380,3 +444,464 @@ func Test_InstanceIDFromNode(t *testing.T) {
 		}
 	}
 }
+
+func Test_NodePoolTaints(t *testing.T) {
+	testCases := []struct {
+		value        string
+		desired      []v1.Taint
+		errorMatcher func(error) bool
+	}{
+		{
+			value: "",
+		},
+		{
+			value: "nvidia.com/gpu=present:NoSchedule, dedicated:NoExecute",
+			desired: []v1.Taint{
+				{Key: "nvidia.com/gpu", Value: "present", Effect: v1.TaintEffectNoSchedule},
+				{Key: "dedicated", Effect: v1.TaintEffectNoExecute},
+			},
+		},
+		{
+			value:        "dedicated=gpu", // Missing effect.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "dedicated=gpu:Sometimes", // Unknown effect.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "=gpu:NoSchedule", // Missing key.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "dedicated team=gpu:NoSchedule", // Invalid key.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "dedicated=gpu/a100:NoSchedule", // Invalid value.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "node.kubernetes.io/unschedulable:NoSchedule", // Reserved namespace.
+			errorMatcher: IsInvalidTaint,
+		},
+		{
+			value:        "giantswarm.io/role=worker:NoSchedule", // Reserved namespace.
+			errorMatcher: IsInvalidTaint,
+		},
+	}
+
+	for _, tc := range testCases {
+		machinePool := &capiexp.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{annotation.NodePoolTaints: tc.value},
+			},
+		}
+
+		effective, err := NodePoolTaints(machinePool)
+
+		if tc.errorMatcher != nil {
+			if !tc.errorMatcher(err) {
+				t.Fatalf("expected %#v got %#v", true, false)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			if !reflect.DeepEqual(effective, tc.desired) {
+				t.Fatalf("Expected taints %v but got %v", tc.desired, effective)
+			}
+		}
+	}
+}
+
+func Test_NodePoolKubeletTaints(t *testing.T) {
+	testCases := []struct {
+		value        string
+		desired      string
+		errorMatcher func(error) bool
+	}{
+		{
+			value: "",
+		},
+		{
+			value:   "nvidia.com/gpu=present:NoSchedule, dedicated:NoExecute",
+			desired: "nvidia.com/gpu=present:NoSchedule,dedicated=:NoExecute",
+		},
+		{
+			value:        "dedicated=gpu", // Missing effect.
+			errorMatcher: IsInvalidTaint,
+		},
+	}
+
+	for _, tc := range testCases {
+		machinePool := &capiexp.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{annotation.NodePoolTaints: tc.value},
+			},
+		}
+
+		effective, err := NodePoolKubeletTaints(machinePool)
+
+		if tc.errorMatcher != nil {
+			if !tc.errorMatcher(err) {
+				t.Fatalf("expected %#v got %#v", true, false)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			if effective != tc.desired {
+				t.Fatalf("Expected taints %q but got %q", tc.desired, effective)
+			}
+		}
+	}
+}
+
+func Test_NodePoolLabels(t *testing.T) {
+	testCases := []struct {
+		value        string
+		desired      map[string]string
+		errorMatcher func(error) bool
+	}{
+		{
+			value: "",
+		},
+		{
+			value: "team=data, example.com/gpu=",
+			desired: map[string]string{
+				"team":            "data",
+				"example.com/gpu": "",
+			},
+		},
+		{
+			value:        "team", // Missing value.
+			errorMatcher: IsInvalidLabel,
+		},
+		{
+			value:        "node-role.kubernetes.io/worker=", // Reserved namespace.
+			errorMatcher: IsInvalidLabel,
+		},
+		{
+			value:        "te am=data", // Invalid key.
+			errorMatcher: IsInvalidLabel,
+		},
+	}
+
+	for _, tc := range testCases {
+		machinePool := &capiexp.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{annotation.NodePoolLabels: tc.value},
+			},
+		}
+
+		effective, err := NodePoolLabels(machinePool)
+
+		if tc.errorMatcher != nil {
+			if !tc.errorMatcher(err) {
+				t.Fatalf("expected %#v got %#v", true, false)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			if !reflect.DeepEqual(effective, tc.desired) {
+				t.Fatalf("Expected labels %v but got %v", tc.desired, effective)
+			}
+		}
+	}
+}
+
+func Test_NodePoolDrainPDBDeletionGracePeriod(t *testing.T) {
+	testCases := []struct {
+		annotations  map[string]string
+		desired      time.Duration
+		errorMatcher func(error) bool
+	}{
+		{
+			desired: 0,
+		},
+		{
+			annotations: map[string]string{annotation.DrainPDBDeletionGracePeriod: "10m"},
+			desired:     10 * time.Minute,
+		},
+		{
+			annotations:  map[string]string{annotation.DrainPDBDeletionGracePeriod: "soon"},
+			errorMatcher: IsInvalidDrainConfig,
+		},
+		{
+			annotations:  map[string]string{annotation.DrainPDBDeletionGracePeriod: "-5m"},
+			errorMatcher: IsInvalidDrainConfig,
+		},
+		{
+			annotations:  map[string]string{annotation.DrainPDBDeletionGracePeriod: "15m"}, // Not shorter than the drain timeout.
+			errorMatcher: IsInvalidDrainConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		azureMachinePool := &capzexp.AzureMachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: tc.annotations,
+			},
+		}
+
+		effective, err := NodePoolDrainPDBDeletionGracePeriod(azureMachinePool)
+
+		if tc.errorMatcher != nil {
+			if !tc.errorMatcher(err) {
+				t.Fatalf("expected %#v got %#v", true, false)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			if effective != tc.desired {
+				t.Fatalf("Expected grace period %v but got %v", tc.desired, effective)
+			}
+		}
+	}
+}
+
+func Test_NodePoolReplicasWithSpotFallback(t *testing.T) {
+	testCases := []struct {
+		annotations       map[string]string
+		desiredMin        int32
+		desiredMax        int32
+		desiredScalingMin int32
+		desiredScalingMax int32
+	}{
+		{
+			desiredMin:        3,
+			desiredMax:        3,
+			desiredScalingMin: 3,
+			desiredScalingMax: 3,
+		},
+		{
+			annotations: map[string]string{
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+				annotation.SpotFallbackReplicasPrefix + "spot2": "1",
+			},
+			desiredMin:        3,
+			desiredMax:        3,
+			desiredScalingMin: 6,
+			desiredScalingMax: 6,
+		},
+		{
+			annotations: map[string]string{
+				apiextensionsannotations.NodePoolMinSize:        "1",
+				apiextensionsannotations.NodePoolMaxSize:        "10",
+				annotation.SpotFallbackReplicasPrefix + "spot1": "2",
+				annotation.SpotFallbackReplicasPrefix + "spot2": "invalid",
+			},
+			desiredMin:        1,
+			desiredMax:        10,
+			desiredScalingMin: 3,
+			desiredScalingMax: 10,
+		},
+	}
+
+	for _, tc := range testCases {
+		replicas := int32(3)
+		machinePool := &capiexp.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: tc.annotations,
+			},
+			Spec: capiexp.MachinePoolSpec{Replicas: &replicas},
+		}
+
+		if minReplicas := NodePoolMinReplicas(machinePool); minReplicas != tc.desiredMin {
+			t.Fatalf("Expected min replicas %d but got %d", tc.desiredMin, minReplicas)
+		}
+		if maxReplicas := NodePoolMaxReplicas(machinePool); maxReplicas != tc.desiredMax {
+			t.Fatalf("Expected max replicas %d but got %d", tc.desiredMax, maxReplicas)
+		}
+		if minReplicas := NodePoolScalingMinReplicas(machinePool); minReplicas != tc.desiredScalingMin {
+			t.Fatalf("Expected scaling min replicas %d but got %d", tc.desiredScalingMin, minReplicas)
+		}
+		if maxReplicas := NodePoolScalingMaxReplicas(machinePool); maxReplicas != tc.desiredScalingMax {
+			t.Fatalf("Expected scaling max replicas %d but got %d", tc.desiredScalingMax, maxReplicas)
+		}
+	}
+}
+
+func Test_NodePoolRollingUpdate(t *testing.T) {
+	intOrString := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	testCases := []struct {
+		name                   string
+		annotations            map[string]string
+		rollingUpdate          *capzexp.MachineRollingUpdateDeployment
+		expectedMaxSurge       intstr.IntOrString
+		expectedMaxUnavailable intstr.IntOrString
+	}{
+		{
+			name:                   "no strategy uses operator defaults",
+			expectedMaxSurge:       intstr.FromString("100%"),
+			expectedMaxUnavailable: intstr.FromInt(0),
+		},
+		{
+			name: "CAPZ defaulted strategy uses operator defaults",
+			rollingUpdate: &capzexp.MachineRollingUpdateDeployment{
+				MaxSurge:       intOrString(intstr.FromInt(1)),
+				MaxUnavailable: intOrString(intstr.FromInt(0)),
+			},
+			expectedMaxSurge:       intstr.FromString("100%"),
+			expectedMaxUnavailable: intstr.FromInt(0),
+		},
+		{
+			name: "custom strategy is honoured",
+			rollingUpdate: &capzexp.MachineRollingUpdateDeployment{
+				MaxSurge:       intOrString(intstr.FromString("25%")),
+				MaxUnavailable: intOrString(intstr.FromInt(1)),
+			},
+			expectedMaxSurge:       intstr.FromString("25%"),
+			expectedMaxUnavailable: intstr.FromInt(1),
+		},
+		{
+			name: "annotations take precedence over CAPZ defaulted strategy",
+			annotations: map[string]string{
+				annotation.NodePoolMaxSurge:       "1",
+				annotation.NodePoolMaxUnavailable: "0",
+			},
+			rollingUpdate: &capzexp.MachineRollingUpdateDeployment{
+				MaxSurge:       intOrString(intstr.FromInt(1)),
+				MaxUnavailable: intOrString(intstr.FromInt(0)),
+			},
+			expectedMaxSurge:       intstr.FromInt(1),
+			expectedMaxUnavailable: intstr.FromInt(0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machinePool := &capiexp.MachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			azureMachinePool := &capzexp.AzureMachinePool{
+				Spec: capzexp.AzureMachinePoolSpec{
+					Strategy: capzexp.AzureMachinePoolDeploymentStrategy{
+						RollingUpdate: tc.rollingUpdate,
+					},
+				},
+			}
+
+			if maxSurge := NodePoolMaxSurge(machinePool, azureMachinePool); maxSurge != tc.expectedMaxSurge {
+				t.Fatalf("maxSurge == %s, want %s", maxSurge.String(), tc.expectedMaxSurge.String())
+			}
+			if maxUnavailable := NodePoolMaxUnavailable(machinePool, azureMachinePool); maxUnavailable != tc.expectedMaxUnavailable {
+				t.Fatalf("maxUnavailable == %s, want %s", maxUnavailable.String(), tc.expectedMaxUnavailable.String())
+			}
+		})
+	}
+}
+
+func Test_NodePoolDataDisks(t *testing.T) {
+	testCases := []struct {
+		dataDisks     []capz.DataDisk
+		desiredDocker capz.DataDisk
+		desiredCount  int
+	}{
+		{
+			desiredDocker: capz.DataDisk{NameSuffix: "docker", DiskSizeGB: 50, Lun: to.Int32Ptr(21)},
+			desiredCount:  2,
+		},
+		{
+			dataDisks: []capz.DataDisk{
+				{NameSuffix: "docker", DiskSizeGB: 200, Lun: to.Int32Ptr(10), CachingType: "None"},
+				{NameSuffix: "data", DiskSizeGB: 500, Lun: to.Int32Ptr(30)},
+			},
+			desiredDocker: capz.DataDisk{NameSuffix: "docker", DiskSizeGB: 200, Lun: to.Int32Ptr(10), CachingType: "None"},
+			desiredCount:  3,
+		},
+	}
+
+	for _, tc := range testCases {
+		azureMachinePool := &capzexp.AzureMachinePool{
+			Spec: capzexp.AzureMachinePoolSpec{
+				Template: capzexp.AzureMachinePoolMachineTemplate{DataDisks: tc.dataDisks},
+			},
+		}
+
+		if disks := NodePoolDataDisks(azureMachinePool); len(disks) != tc.desiredCount {
+			t.Fatalf("Expected %d data disks but got %d", tc.desiredCount, len(disks))
+		}
+		if docker := NodePoolDockerDisk(azureMachinePool); !reflect.DeepEqual(docker, tc.desiredDocker) {
+			t.Fatalf("Expected docker disk %v but got %v", tc.desiredDocker, docker)
+		}
+	}
+}
+
+func Test_ValidateNodePoolDisks(t *testing.T) {
+	testCases := []struct {
+		osDisk       capz.OSDisk
+		dataDisks    []capz.DataDisk
+		errorMatcher func(error) bool
+	}{
+		{
+			// Defaults.
+		},
+		{
+			osDisk: capz.OSDisk{
+				DiffDiskSettings: &capz.DiffDiskSettings{Option: "Local"},
+				CachingType:      "ReadOnly",
+			},
+			dataDisks: []capz.DataDisk{
+				{NameSuffix: "docker", DiskSizeGB: 100, Lun: to.Int32Ptr(21), CachingType: "ReadOnly", ManagedDisk: &capz.ManagedDiskParameters{StorageAccountType: "Premium_LRS", DiskEncryptionSet: &capz.DiskEncryptionSetParameters{ID: "des"}}},
+			},
+		},
+		{
+			osDisk: capz.OSDisk{
+				DiffDiskSettings: &capz.DiffDiskSettings{Option: "Local"},
+				CachingType:      "ReadWrite", // Ephemeral OS disks are read only.
+			},
+			errorMatcher: IsInvalidDiskConfig,
+		},
+		{
+			osDisk: capz.OSDisk{
+				DiffDiskSettings: &capz.DiffDiskSettings{Option: "Local"},
+				ManagedDisk:      &capz.ManagedDiskParameters{DiskEncryptionSet: &capz.DiskEncryptionSetParameters{ID: "des"}},
+			},
+			errorMatcher: IsInvalidDiskConfig,
+		},
+		{
+			dataDisks: []capz.DataDisk{
+				{NameSuffix: "data", DiskSizeGB: 100, Lun: to.Int32Ptr(21)}, // LUN of the default docker disk.
+			},
+			errorMatcher: IsInvalidDiskConfig,
+		},
+		{
+			dataDisks: []capz.DataDisk{
+				{NameSuffix: "data", DiskSizeGB: 100, Lun: to.Int32Ptr(30), CachingType: "Sometimes"},
+			},
+			errorMatcher: IsInvalidDiskConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		azureMachinePool := &capzexp.AzureMachinePool{
+			Spec: capzexp.AzureMachinePoolSpec{
+				Template: capzexp.AzureMachinePoolMachineTemplate{
+					OSDisk:    tc.osDisk,
+					DataDisks: tc.dataDisks,
+				},
+			},
+		}
+
+		err := ValidateNodePoolDisks(azureMachinePool)
+
+		if tc.errorMatcher != nil {
+			if !tc.errorMatcher(err) {
+				t.Fatalf("expected %#v got %#v", true, false)
+			}
+		} else if err != nil {
+			t.Fatalf("expected %#v got %#v", nil, err)
+		}
+	}
+}
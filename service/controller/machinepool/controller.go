@@ -24,6 +24,7 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool/handler/machinepooldependents"
 	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool/handler/machinepoolownerreference"
 	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool/handler/machinepoolupgrade"
+	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool/handler/nodelabelstaints"
 	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool/handler/nodestatus"
 )
 
@@ -167,6 +168,20 @@ func NewMachinePoolResourceSet(config ControllerConfig) ([]resource.Interface, e
 		}
 	}
 
+	var nodelabelstaintsResource resource.Interface
+	{
+		c := nodelabelstaints.Config{
+			CtrlClient:          config.K8sClient.CtrlClient(),
+			Logger:              config.Logger,
+			TenantClientFactory: cachedTenantClientFactory,
+		}
+
+		nodelabelstaintsResource, err = nodelabelstaints.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var machinepoolUpgradeResource resource.Interface
 	{
 		c := machinepoolupgrade.Config{
@@ -186,6 +201,7 @@ func NewMachinePoolResourceSet(config ControllerConfig) ([]resource.Interface, e
 		machinepoolDependentsResource,
 		ownerReferencesResource,
 		nodestatusResource,
+		nodelabelstaintsResource,
 		machinepoolUpgradeResource,
 	}
 
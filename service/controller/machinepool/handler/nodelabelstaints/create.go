@@ -0,0 +1,226 @@
+package nodelabelstaints
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/key"
+)
+
+// EnsureCreated corrects the labels and taints of the existing Nodes of the
+// node pool when they drift from the ones defined in the MachinePool
+// annotations, e.g. because the annotations changed after the nodes
+// registered, and removes the ones it applied before which are not defined
+// anymore. New nodes register with the labels and taints through the kubelet
+// flags rendered in their ignition, so they are only patched to track the
+// applied keys. Labels and taints not applied by the operator are left
+// untouched.
+func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
+	machinePool, err := key.ToMachinePool(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !machinePool.DeletionTimestamp.IsZero() {
+		r.logger.Debugf(ctx, "object is being deleted")
+		r.logger.Debugf(ctx, "canceling resource")
+		return nil
+	}
+
+	labels, taints, err := desiredLabelsAndTaints(&machinePool)
+	if key.IsInvalidLabel(err) || key.IsInvalidTaint(err) {
+		r.logger.Debugf(ctx, "MachinePool labels or taints are invalid: %s", err.Error())
+		r.logger.Debugf(ctx, "canceling resource")
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.ctrlClient, machinePool.ObjectMeta)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	tenantClusterK8sClient, err := r.tenantClientFactory.GetClient(ctx, cluster)
+	if tenantcluster.IsAPINotAvailableError(err) {
+		r.logger.Debugf(ctx, "tenant API not available yet")
+		r.logger.Debugf(ctx, "canceling resource")
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	nodeList := corev1.NodeList{}
+	err = tenantClusterK8sClient.List(ctx, &nodeList, ctrlclient.MatchingLabels{label.MachinePool: machinePool.Name})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, node := range nodeList.Items {
+		desired := applyLabelsAndTaints(node, labels, taints)
+		if nodeUpToDate(node, desired) {
+			continue
+		}
+
+		r.logger.Debugf(ctx, "updating labels and taints of node %#q", node.Name)
+
+		// Taints are a list, which merge patches replace as a whole. The
+		// patch fails on conflict instead of dropping taints added to the
+		// node since it was listed, e.g. by the node lifecycle controller.
+		err = tenantClusterK8sClient.Patch(ctx, &desired, ctrlclient.MergeFromWithOptions(&node, ctrlclient.MergeFromWithOptimisticLock{}))
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if apierrors.IsConflict(err) {
+			r.logger.Debugf(ctx, "conflict trying to save object in k8s API concurrently")
+			r.logger.Debugf(ctx, "cancelling resource")
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		r.logger.Debugf(ctx, "updated labels and taints of node %#q", node.Name)
+	}
+
+	return nil
+}
+
+func desiredLabelsAndTaints(machinePool *capiexp.MachinePool) (map[string]string, []corev1.Taint, error) {
+	labels, err := key.NodePoolLabels(machinePool)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	taints, err := key.NodePoolTaints(machinePool)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return labels, taints, nil
+}
+
+// applyLabelsAndTaints returns a copy of the node with the given labels and
+// taints, without the labels and taints the operator applied before and
+// which are not desired anymore. The applied keys are tracked in the node
+// annotations.
+func applyLabelsAndTaints(node corev1.Node, labels map[string]string, taints []corev1.Taint) corev1.Node {
+	desired := *node.DeepCopy()
+
+	if desired.Labels == nil {
+		desired.Labels = map[string]string{}
+	}
+	for _, k := range managedKeys(node, annotation.NodeManagedLabels) {
+		if _, ok := labels[k]; !ok {
+			delete(desired.Labels, k)
+		}
+	}
+	var labelKeys []string
+	for k, v := range labels {
+		desired.Labels[k] = v
+		labelKeys = append(labelKeys, k)
+	}
+	if len(desired.Labels) == 0 && node.Labels == nil {
+		desired.Labels = nil
+	}
+
+	// Taints are identified by their key and effect, see
+	// https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration/.
+	// Taints the node registered with keep their position, so that nodes
+	// without drift are not patched.
+	managed := map[string]bool{}
+	for _, k := range managedKeys(node, annotation.NodeManagedTaints) {
+		managed[k] = true
+	}
+	wanted := map[string]corev1.Taint{}
+	var taintKeys []string
+	for _, t := range taints {
+		wanted[taintKey(t)] = t
+		taintKeys = append(taintKeys, taintKey(t))
+	}
+	var desiredTaints []corev1.Taint
+	applied := map[string]bool{}
+	for _, t := range node.Spec.Taints {
+		k := taintKey(t)
+		if w, ok := wanted[k]; ok {
+			if w.Value != t.Value {
+				t = w
+			}
+			desiredTaints = append(desiredTaints, t)
+			applied[k] = true
+		} else if !managed[k] {
+			desiredTaints = append(desiredTaints, t)
+		}
+	}
+	for _, t := range taints {
+		if !applied[taintKey(t)] {
+			desiredTaints = append(desiredTaints, t)
+		}
+	}
+	desired.Spec.Taints = desiredTaints
+
+	setManagedKeys(&desired, annotation.NodeManagedLabels, labelKeys)
+	setManagedKeys(&desired, annotation.NodeManagedTaints, taintKeys)
+
+	return desired
+}
+
+// nodeUpToDate returns true if the node already has the desired labels, taints
+// and annotations.
+func nodeUpToDate(node, desired corev1.Node) bool {
+	return reflect.DeepEqual(node.Labels, desired.Labels) &&
+		equalTaints(node.Spec.Taints, desired.Spec.Taints) &&
+		reflect.DeepEqual(node.Annotations, desired.Annotations)
+}
+
+// equalTaints compares taints ignoring the time NoExecute taints were added.
+func equalTaints(a, b []corev1.Taint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].MatchTaint(&b[i]) || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func managedKeys(node corev1.Node, annotationName string) []string {
+	v := node.Annotations[annotationName]
+	if v == "" {
+		return nil
+	}
+
+	return strings.Split(v, ",")
+}
+
+func setManagedKeys(node *corev1.Node, annotationName string, keys []string) {
+	if len(keys) == 0 {
+		delete(node.Annotations, annotationName)
+		return
+	}
+
+	sort.Strings(keys)
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[annotationName] = strings.Join(keys, ",")
+}
+
+func taintKey(t corev1.Taint) string {
+	return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+}
@@ -0,0 +1,280 @@
+package nodelabelstaints
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/mock/mock_tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+func Test_applyLabelsAndTaints(t *testing.T) {
+	userTaint := corev1.Taint{Key: "user", Effect: corev1.TaintEffectNoSchedule}
+	gpuTaint := corev1.Taint{Key: "gpu", Value: "present", Effect: corev1.TaintEffectNoSchedule}
+
+	testCases := []struct {
+		name             string
+		node             corev1.Node
+		labels           map[string]string
+		taints           []corev1.Taint
+		expectedNode     corev1.Node
+		expectedUpToDate bool
+	}{
+		{
+			name: "case 0: add labels and taints",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"user": "label"},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{userTaint}},
+			},
+			labels: map[string]string{"team": "data"},
+			taints: []corev1.Taint{gpuTaint},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"user": "label", "team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{userTaint, gpuTaint}},
+			},
+		},
+		{
+			name: "case 1: remove labels and taints not desired anymore",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"user": "label", "team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{userTaint, gpuTaint}},
+			},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      map[string]string{"user": "label"},
+					Annotations: map[string]string{},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{userTaint}},
+			},
+		},
+		{
+			name: "case 2: replace values",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "web"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "gpu", Value: "absent", Effect: corev1.TaintEffectNoSchedule}}},
+			},
+			labels: map[string]string{"team": "data"},
+			taints: []corev1.Taint{gpuTaint},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{gpuTaint}},
+			},
+		},
+		{
+			name: "case 3: node up to date",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+					},
+				},
+			},
+			labels: map[string]string{"team": "data"},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+					},
+				},
+			},
+			expectedUpToDate: true,
+		},
+		{
+			name: "case 4: node registered with the taints only tracks the applied keys",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "data"},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{gpuTaint, userTaint}},
+			},
+			labels: map[string]string{"team": "data"},
+			taints: []corev1.Taint{gpuTaint},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "data"},
+					Annotations: map[string]string{
+						annotation.NodeManagedLabels: "team",
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{gpuTaint, userTaint}},
+			},
+		},
+		{
+			name: "case 5: tracked node without drift is up to date",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{gpuTaint, userTaint}},
+			},
+			taints: []corev1.Taint{gpuTaint},
+			expectedNode: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotation.NodeManagedTaints: "gpu:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{gpuTaint, userTaint}},
+			},
+			expectedUpToDate: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			desired := applyLabelsAndTaints(tc.node, tc.labels, tc.taints)
+
+			if !cmp.Equal(desired, tc.expectedNode) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedNode, desired))
+			}
+
+			upToDate := nodeUpToDate(tc.node, desired)
+			if upToDate != tc.expectedUpToDate {
+				t.Fatalf("expected %t, got %t", tc.expectedUpToDate, upToDate)
+			}
+		})
+	}
+}
+
+// Test_Resource_EnsureCreated_Conflict checks that taints added to a node
+// after it was listed are not dropped by the patch of the managed taints.
+func Test_Resource_EnsureCreated_Conflict(t *testing.T) {
+	ctx := context.Background()
+
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "c0001",
+			Namespace: "org-test",
+		},
+	}
+	replicas := int32(1)
+	machinePool := &capiexp.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "np001",
+			Namespace: "org-test",
+			Labels: map[string]string{
+				capi.ClusterLabelName: "c0001",
+			},
+			Annotations: map[string]string{
+				annotation.NodePoolTaints: "dedicated=gpu:NoSchedule",
+			},
+		},
+		Spec: capiexp.MachinePoolSpec{Replicas: &replicas},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker-0",
+			Labels: map[string]string{label.MachinePool: "np001"},
+		},
+	}
+	unreachable := corev1.Taint{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute}
+
+	ctrlClient := unittest.FakeK8sClient(cluster, machinePool).CtrlClient()
+	wcCtrlClient := &concurrentTaintClient{
+		Client: fake.NewClientBuilder().WithObjects(node).Build(),
+		taint:  unreachable,
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+	tenantClientFactory.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(wcCtrlClient, nil).AnyTimes()
+
+	r, err := New(Config{
+		CtrlClient:          ctrlClient,
+		Logger:              microloggertest.New(),
+		TenantClientFactory: tenantClientFactory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.EnsureCreated(ctx, machinePool)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	updated := &corev1.Node{}
+	err = wcCtrlClient.Get(ctx, client.ObjectKeyFromObject(node), updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(updated.Spec.Taints, []corev1.Taint{unreachable}) {
+		t.Fatalf("\n\n%s\n", cmp.Diff([]corev1.Taint{unreachable}, updated.Spec.Taints))
+	}
+}
+
+// concurrentTaintClient adds a taint to the node before its first patch, like
+// the node lifecycle controller could between listing and patching the node.
+type concurrentTaintClient struct {
+	client.Client
+
+	taint   corev1.Taint
+	tainted bool
+}
+
+func (c *concurrentTaintClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if !c.tainted {
+		c.tainted = true
+
+		node := &corev1.Node{}
+		err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), node)
+		if err != nil {
+			return err
+		}
+		node.Spec.Taints = append(node.Spec.Taints, c.taint)
+		err = c.Client.Update(ctx, node)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,11 @@
+package nodelabelstaints
+
+import (
+	"context"
+)
+
+// EnsureDeleted does nothing, the Nodes are deleted together with the node
+// pool.
+func (r *Resource) EnsureDeleted(ctx context.Context, obj interface{}) error {
+	return nil
+}
@@ -23,6 +23,7 @@ primaryScaleSetName: {{ .PrimaryScaleSetName }}
 subnetName: {{ .SubnetName }}
 securityGroupName: {{ .SecurityGroupName }}
 vnetName: {{ .VnetName }}
+vnetResourceGroup: {{ .VnetResourceGroup }}
 vmType: vmss
 routeTableName: {{ .RouteTableName }}
 useManagedIdentityExtension: {{ .UseManagedIdentityExtension }}
@@ -0,0 +1,65 @@
+package ignition
+
+// KubeProxyConfig replaces the kube-proxy configuration of k8scloudconfig for
+// dual-stack clusters, so that kube-proxy tells IPv4 and IPv6 pod traffic
+// apart from external traffic.
+const KubeProxyConfig = `apiVersion: kubeproxy.config.k8s.io/v1alpha1
+clientConnection:
+  kubeconfig: /etc/kubernetes/config/proxy-kubeconfig.yaml
+kind: KubeProxyConfiguration
+mode: iptables
+clusterCIDR: {{ .ClusterCIDRs }}
+{{- if .ConntrackMaxPerCore }}
+conntrack:
+  maxPerCore: {{ .ConntrackMaxPerCore }}
+{{- end }}
+metricsBindAddress: 0.0.0.0:10249
+bindAddressHardFail: true
+`
+
+// CalicoCNINetworkConfig is the CNI network configuration installed by Calico
+// on each node of dual-stack clusters. The host-local IPAM plugin assigns pod
+// IPs from both the IPv4 and the IPv6 pod range of the node.
+const CalicoCNINetworkConfig = `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {
+      "type": "calico",
+      "log_level": "info",
+      "log_file_path": "/var/log/calico/cni/cni.log",
+      "datastore_type": "kubernetes",
+      "nodename": "__KUBERNETES_NODE_NAME__",
+      "mtu": 1500,
+      "ipam": {
+          "type": "host-local",
+          "ranges": [
+              [{"subnet": "usePodCidr"}],
+              [{"subnet": "usePodCidrIPv6"}]
+          ]
+      },
+      "policy": {
+          "type": "k8s"
+      },
+      "kubernetes": {
+          "kubeconfig": "__KUBECONFIG_FILEPATH__"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true,
+      "capabilities": {"portMappings": true}
+    },
+    {
+      "type": "bandwidth",
+      "capabilities": {"bandwidth": true}
+    }
+  ]
+}`
+
+// CalicoNodeFelixEnv is the Felix configuration of the calico-node
+// containers of dual-stack clusters, overriding the environment variables
+// set by k8scloudconfig.
+const CalicoNodeFelixEnv = `- name: FELIX_IPV6SUPPORT
+  value: "{{ .IPv6Support }}"
+`
@@ -0,0 +1,87 @@
+package ignition
+
+// ScheduledEventsAgent polls the Azure scheduled events of the VM. Planned
+// redeployments are reported as the RedeployScheduled node condition, which is
+// picked up by node auto repair. Spot instance evictions are announced at
+// least 30 seconds ahead only, while the operator reconciles node pools every
+// few minutes, so the node is annotated, cordoned and drained locally instead
+// of by the operator. Pods are drained through the eviction API, so pod
+// disruption budgets are respected and pods whose eviction they block are
+// left on the node until Azure evicts the VM. The drain rules and the pod
+// disruption budget deletion grace period of node pool upgrades are not
+// applied within this window.
+const ScheduledEventsAgent = `#!/bin/bash
+set -o pipefail
+
+IMDS=http://169.254.169.254/metadata
+CONDITION=RedeployScheduled
+KUBECONFIG=/etc/kubernetes/kubeconfig/kubelet.yaml
+NODE="$(hostname | tr '[:upper:]' '[:lower:]')"
+
+imds() {
+  curl -sf -H Metadata:true --noproxy '*' "$IMDS/$1"
+}
+
+set_condition() {
+  now="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+  patch="$(jq -cn --arg status "$1" --arg reason "$2" --arg message "$3" --arg now "$now" --arg type "$CONDITION" \
+    '{status: {conditions: [{type: $type, status: $status, reason: $reason, message: $message, lastHeartbeatTime: $now, lastTransitionTime: $now}]}}')"
+  curl -sf -X PATCH \
+    --cacert /etc/kubernetes/ssl/worker-ca.pem \
+    --cert /etc/kubernetes/ssl/worker-crt.pem \
+    --key /etc/kubernetes/ssl/worker-key.pem \
+    -H "Content-Type: application/strategic-merge-patch+json" \
+    --data "$patch" \
+    "$SERVER/api/v1/nodes/$NODE/status" >/dev/null
+}
+
+until SERVER="$(/opt/bin/kubectl --kubeconfig=$KUBECONFIG config view -o jsonpath='{.clusters[0].cluster.server}')" && [ -n "$SERVER" ]; do
+  sleep 10
+done
+until VM_NAME="$(imds "instance/compute/name?api-version=2021-02-01&format=text")" && [ -n "$VM_NAME" ]; do
+  sleep 10
+done
+
+drain() {
+  notice="$(date -u -d "$2" +%Y-%m-%dT%H:%M:%SZ 2>/dev/null || date -u +%Y-%m-%dT%H:%M:%SZ)"
+  echo "spot eviction $1 scheduled at $2, draining node $NODE"
+  /opt/bin/kubectl --kubeconfig=$KUBECONFIG annotate node "$NODE" --overwrite "{{.SpotEvictionNoticeAnnotation}}=$notice"
+  /opt/bin/kubectl --kubeconfig=$KUBECONFIG drain "$NODE" --ignore-daemonsets --delete-emptydir-data --force --grace-period=20 --timeout=25s
+}
+
+reported=""
+drained=""
+while true; do
+  if events="$(imds "scheduledevents?api-version=2020-07-01")"; then
+    preempt="$(echo "$events" | jq -r --arg vm "$VM_NAME" \
+      '[.Events[] | select(.EventType == "Preempt" and (.Resources | index($vm) != null))][0] | if . == null then "" else "\(.EventId) \(.NotBefore)" end')"
+
+    if [ -n "$preempt" ] && [ "$drained" != "${preempt%% *}" ]; then
+      drain "${preempt%% *}" "${preempt#* }" && drained="${preempt%% *}"
+    fi
+
+    redeploy="$(echo "$events" | jq -r --arg vm "$VM_NAME" \
+      '[.Events[] | select(.EventType == "Redeploy" and (.Resources | index($vm) != null))][0] | if . == null then "" else "\(.EventId) \(.NotBefore)" end')"
+
+    if [ -n "$redeploy" ] && [ "$reported" != "True" ]; then
+      set_condition True RedeployScheduled "Azure scheduled event ${redeploy%% *} redeploys the VM not before ${redeploy#* }" && reported=True
+    elif [ -z "$redeploy" ] && [ "$reported" != "False" ]; then
+      set_condition False NoRedeployScheduled "No redeployment of the VM is scheduled by Azure" && reported=False
+    fi
+  fi
+
+  sleep 10
+done
+`
+
+const ScheduledEventsAgentUnit = `[Unit]
+Description=Azure scheduled events agent
+Wants=k8s-kubelet.service
+After=k8s-kubelet.service certificate-decrypter.service
+[Service]
+Restart=always
+RestartSec=10
+ExecStart=/opt/bin/azure-scheduled-events
+[Install]
+WantedBy=multi-user.target
+`
@@ -12,15 +12,16 @@ import (
 	"github.com/giantswarm/operatorkit/v7/pkg/resource"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/wrapper/metricsresource"
 	"github.com/giantswarm/operatorkit/v7/pkg/resource/wrapper/retryresource"
-	"github.com/giantswarm/tenantcluster/v6/pkg/tenantcluster"
 	"k8s.io/apimachinery/pkg/labels"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/azure-operator/v8/client"
 	"github.com/giantswarm/azure-operator/v8/pkg/credential"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
 	"github.com/giantswarm/azure-operator/v8/pkg/project"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 	"github.com/giantswarm/azure-operator/v8/service/collector"
 	"github.com/giantswarm/azure-operator/v8/service/controller/unhealthynode/handler/terminateunhealthynode"
 )
@@ -30,7 +31,9 @@ type ControllerConfig struct {
 	Logger    micrologger.Logger
 
 	AzureMetricsCollector collector.AzureAPIMetrics
+	ClientFactory         *client.Factory
 	CredentialProvider    credential.Provider
+	DryRun                bool
 	SentryDSN             string
 }
 
@@ -110,40 +113,37 @@ func NewController(config ControllerConfig) (*controller.Controller, error) {
 func newTerminateUnhealthyNodeResources(config ControllerConfig, certsSearcher *certs.Searcher) ([]resource.Interface, error) {
 	var err error
 
-	var clientFactory *client.Factory
+	var organizationClientFactory client.OrganizationFactory
 	{
-		c := client.FactoryConfig{
-			AzureAPIMetrics:    config.AzureMetricsCollector,
-			CacheDuration:      30 * time.Minute,
-			CredentialProvider: config.CredentialProvider,
-			Logger:             config.Logger,
+		c := client.OrganizationFactoryConfig{
+			CtrlClient: config.K8sClient.CtrlClient(),
+			Factory:    config.ClientFactory,
+			Logger:     config.Logger,
 		}
+		organizationClientFactory = client.NewOrganizationFactory(c)
+	}
 
-		clientFactory, err = client.NewFactory(c)
+	var cachedTenantClientFactory tenantcluster.Factory
+	{
+		tenantClientFactory, err := tenantcluster.NewFactory(certsSearcher, config.Logger)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
-	}
 
-	var organizationClientFactory client.OrganizationFactory
-	{
-		c := client.OrganizationFactoryConfig{
-			CtrlClient: config.K8sClient.CtrlClient(),
-			Factory:    clientFactory,
-			Logger:     config.Logger,
+		cachedTenantClientFactory, err = tenantcluster.NewCachedFactory(tenantClientFactory, config.Logger)
+		if err != nil {
+			return nil, microerror.Mask(err)
 		}
-		organizationClientFactory = client.NewOrganizationFactory(c)
 	}
 
-	var tenantRestConfigProvider *tenantcluster.TenantCluster
+	var eventRecorder *event.Recorder
 	{
-		c := tenantcluster.Config{
-			CertID:        certs.APICert,
-			CertsSearcher: certsSearcher,
-			Logger:        config.Logger,
+		c := event.Config{
+			CtrlClient: config.K8sClient.CtrlClient(),
+			Logger:     config.Logger,
 		}
 
-		tenantRestConfigProvider, err = tenantcluster.New(c)
+		eventRecorder, err = event.New(c)
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
@@ -152,10 +152,12 @@ func newTerminateUnhealthyNodeResources(config ControllerConfig, certsSearcher *
 	var terminateUnhealthyNodeResource resource.Interface
 	{
 		c := terminateunhealthynode.Config{
-			AzureClientsFactory:      &organizationClientFactory,
-			CtrlClient:               config.K8sClient.CtrlClient(),
-			Logger:                   config.Logger,
-			TenantRestConfigProvider: tenantRestConfigProvider,
+			AzureClientsFactory: &organizationClientFactory,
+			CtrlClient:          config.K8sClient.CtrlClient(),
+			DryRun:              config.DryRun,
+			EventRecorder:       eventRecorder,
+			Logger:              config.Logger,
+			TenantClientFactory: cachedTenantClientFactory,
 		}
 
 		terminateUnhealthyNodeResource, err = terminateunhealthynode.New(c)
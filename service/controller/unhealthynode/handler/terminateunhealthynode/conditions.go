@@ -0,0 +1,128 @@
+package terminateunhealthynode
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+const (
+	notReadyReason = "NotReady"
+)
+
+// defaultUnhealthyConditions are the node conditions reported by
+// node-problem-detector which make nodes unhealthy unless the cluster defines
+// its own. RedeployScheduled is set by the scheduled events agent running on
+// the nodes when Azure plans to redeploy them.
+var defaultUnhealthyConditions = []string{
+	"KernelDeadlock",
+	"ReadonlyFilesystem",
+	"ContainerRuntimeUnhealthy",
+	"RedeployScheduled",
+}
+
+// parseConditions parses a comma separated list of node condition types. An
+// empty value disables the detection of unhealthy nodes by condition.
+func parseConditions(value string) ([]string, error) {
+	var conditions []string
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if strings.ContainsAny(c, " \t/") {
+			return nil, microerror.Maskf(invalidRepairPolicyError, "%#q must be a comma separated list of node condition types but contains %#q", annotation.NodeAutoRepairUnhealthyConditions, c)
+		}
+
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+// unhealthyCondition returns the first of the given conditions the node
+// reported as true for longer than the grace period, or an empty string. The
+// grace period is counted from the last repair of the node too, so that the
+// conditions of repaired nodes can be reset before they are considered again.
+func unhealthyCondition(node corev1.Node, conditions []string, gracePeriod time.Duration, now time.Time) string {
+	var repaired time.Time
+	if v, ok := node.Annotations[annotation.NodeAutoRepairRepairedTimestamp]; ok {
+		repaired, _ = time.Parse(time.RFC3339, v)
+	}
+
+	for _, c := range conditions {
+		for _, nc := range node.Status.Conditions {
+			if string(nc.Type) != c || nc.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			since := nc.LastTransitionTime.Time
+			if repaired.After(since) {
+				since = repaired
+			}
+			if now.Sub(since) >= gracePeriod {
+				return c
+			}
+		}
+	}
+
+	return ""
+}
+
+// nodesWithUnhealthyConditions returns the nodes with unhealthy conditions
+// which are not part of the given nodes yet, and the reasons of all nodes to
+// be repaired keyed by node name.
+func nodesWithUnhealthyConditions(nodes []corev1.Node, badNodes []corev1.Node, conditions []string, gracePeriod time.Duration, now time.Time) ([]corev1.Node, map[string]string) {
+	reasons := map[string]string{}
+	for _, n := range badNodes {
+		reasons[n.Name] = notReadyReason
+	}
+
+	var unhealthy []corev1.Node
+	for _, n := range nodes {
+		if _, ok := reasons[n.Name]; ok {
+			continue
+		}
+
+		if c := unhealthyCondition(n, conditions, gracePeriod, now); c != "" {
+			reasons[n.Name] = c
+			unhealthy = append(unhealthy, n)
+		}
+	}
+
+	return unhealthy, reasons
+}
+
+// markRepaired annotates the repaired node with the time of the repair.
+// Deleted nodes are ignored.
+func (r *Resource) markRepaired(ctx context.Context, ctrlClient client.Client, node corev1.Node, now time.Time) error {
+	n := corev1.Node{}
+	err := ctrlClient.Get(ctx, client.ObjectKey{Name: node.Name}, &n)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p := client.MergeFrom(n.DeepCopy())
+	if n.Annotations == nil {
+		n.Annotations = map[string]string{}
+	}
+	n.Annotations[annotation.NodeAutoRepairRepairedTimestamp] = now.Format(time.RFC3339)
+
+	err = ctrlClient.Patch(ctx, &n, p)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
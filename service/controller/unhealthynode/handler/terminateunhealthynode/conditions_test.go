@@ -0,0 +1,173 @@
+package terminateunhealthynode
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_nodesWithUnhealthyConditions(t *testing.T) {
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		nodes           []corev1.Node
+		badNodes        []corev1.Node
+		conditions      []string
+		expectedNodes   []string
+		expectedReasons map[string]string
+	}{
+		{
+			name: "case 0: healthy nodes",
+			nodes: []corev1.Node{
+				withCondition(newNamedNode("a"), "KernelDeadlock", corev1.ConditionFalse, now.Add(-time.Hour)),
+				newNamedNode("b"),
+			},
+			conditions:      defaultUnhealthyConditions,
+			expectedReasons: map[string]string{},
+		},
+		{
+			name: "case 1: kernel deadlock and pending redeploy",
+			nodes: []corev1.Node{
+				withCondition(newNamedNode("a"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour)),
+				withCondition(newNamedNode("b"), "RedeployScheduled", corev1.ConditionTrue, now.Add(-11*time.Minute)),
+				newNamedNode("c"),
+			},
+			conditions:    defaultUnhealthyConditions,
+			expectedNodes: []string{"a", "b"},
+			expectedReasons: map[string]string{
+				"a": "KernelDeadlock",
+				"b": "RedeployScheduled",
+			},
+		},
+		{
+			name: "case 2: conditions within grace period are ignored",
+			nodes: []corev1.Node{
+				withCondition(newNamedNode("a"), "ReadonlyFilesystem", corev1.ConditionTrue, now.Add(-time.Minute)),
+			},
+			conditions:      defaultUnhealthyConditions,
+			expectedReasons: map[string]string{},
+		},
+		{
+			name: "case 3: recently repaired nodes are ignored",
+			nodes: []corev1.Node{
+				withRepaired(withCondition(newNamedNode("a"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour)), now.Add(-5*time.Minute)),
+				withRepaired(withCondition(newNamedNode("b"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour)), now.Add(-30*time.Minute)),
+			},
+			conditions:    defaultUnhealthyConditions,
+			expectedNodes: []string{"b"},
+			expectedReasons: map[string]string{
+				"b": "KernelDeadlock",
+			},
+		},
+		{
+			name: "case 4: conditions not configured for the cluster are ignored",
+			nodes: []corev1.Node{
+				withCondition(newNamedNode("a"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour)),
+				withCondition(newNamedNode("b"), "FrequentKubeletRestart", corev1.ConditionTrue, now.Add(-time.Hour)),
+			},
+			conditions:    []string{"FrequentKubeletRestart"},
+			expectedNodes: []string{"b"},
+			expectedReasons: map[string]string{
+				"b": "FrequentKubeletRestart",
+			},
+		},
+		{
+			name: "case 5: NotReady nodes keep their reason",
+			nodes: []corev1.Node{
+				withCondition(newNamedNode("a"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour)),
+			},
+			badNodes:   []corev1.Node{newNamedNode("a")},
+			conditions: defaultUnhealthyConditions,
+			expectedReasons: map[string]string{
+				"a": notReadyReason,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			nodes, reasons := nodesWithUnhealthyConditions(tc.nodes, tc.badNodes, tc.conditions, defaultConditionGracePeriod, now)
+
+			var names []string
+			for _, n := range nodes {
+				names = append(names, n.Name)
+			}
+			sort.Strings(names)
+
+			if !cmp.Equal(names, tc.expectedNodes) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedNodes, names))
+			}
+			if !cmp.Equal(reasons, tc.expectedReasons) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedReasons, reasons))
+			}
+		})
+	}
+}
+
+func Test_Resource_markRepaired(t *testing.T) {
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+	node := newNamedNode("a")
+	ctrlClient := fake.NewClientBuilder().WithObjects(&node).Build()
+
+	r := &Resource{}
+
+	err := r.markRepaired(context.Background(), ctrlClient, node, now)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	// Deleted nodes are ignored.
+	err = r.markRepaired(context.Background(), ctrlClient, newNamedNode("b"), now)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	updated := corev1.Node{}
+	err = ctrlClient.Get(context.Background(), client.ObjectKey{Name: node.Name}, &updated)
+	if err != nil {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	expected := "2023-07-20T12:00:00Z"
+	if updated.Annotations[annotation.NodeAutoRepairRepairedTimestamp] != expected {
+		t.Fatalf("expected %q, got %q", expected, updated.Annotations[annotation.NodeAutoRepairRepairedTimestamp])
+	}
+}
+
+func newNamedNode(name string) corev1.Node {
+	node := newNode("a", corev1.ConditionTrue)
+	node.Name = name
+
+	return node
+}
+
+func withCondition(node corev1.Node, conditionType string, status corev1.ConditionStatus, since time.Time) corev1.Node {
+	node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+		Type:               corev1.NodeConditionType(conditionType),
+		Status:             status,
+		LastTransitionTime: metav1.NewTime(since),
+	})
+
+	return node
+}
+
+func withRepaired(node corev1.Node, repaired time.Time) corev1.Node {
+	node.Annotations = map[string]string{
+		annotation.NodeAutoRepairRepairedTimestamp: repaired.Format(time.RFC3339),
+	}
+
+	return node
+}
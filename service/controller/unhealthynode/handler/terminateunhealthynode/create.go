@@ -2,9 +2,7 @@ package terminateunhealthynode
 
 import (
 	"context"
-
-	"github.com/giantswarm/errors/tenant"
-	"github.com/giantswarm/tenantcluster/v6/pkg/tenantcluster"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
 	"github.com/giantswarm/apiextensions/v6/pkg/annotation"
@@ -13,16 +11,23 @@ import (
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/rest"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	azopannotation "github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/etcdhealth"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/controller/internal/vmssinstance"
 	"github.com/giantswarm/azure-operator/v8/service/controller/key"
 )
 
 const (
-	nodeTerminationTickThreshold = 6
+	NodeRepairedReason      = "NodeRepaired"
+	NodeRepairDryRunReason  = "NodeRepairDryRun"
+	NodeRepairSkippedReason = "NodeRepairSkipped"
+	InvalidRepairPolicy     = "InvalidRepairPolicy"
 )
 
 func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
@@ -38,10 +43,20 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		return nil
 	}
 
-	var tenantClusterK8sClient client.Client
+	policy, err := newRepairPolicy(cr.Annotations)
+	if IsInvalidRepairPolicy(err) {
+		r.logger.Debugf(ctx, "node auto repair policy is invalid: %s", err)
+		r.logger.Debugf(ctx, "canceling resource")
+
+		return r.emitEvent(ctx, &cr, event.TypeWarning, InvalidRepairPolicy, "Nodes are not repaired: %s", err)
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var tenantClusterK8sClient k8sclient.Interface
 	{
-		tenantClusterK8sClient, err = r.getTenantClusterClient(ctx, &cr)
-		if tenant.IsAPINotAvailable(err) || tenantcluster.IsTimeout(err) {
+		tenantClusterK8sClient, err = r.tenantClientFactory.GetAllClients(ctx, &cr)
+		if tenantcluster.IsAPINotAvailableError(err) {
 			// The kubernetes API is not reachable. This usually happens when a new cluster is being created.
 			// This makes the whole controller to fail and stops next handlers from being executed even if they are
 			// safe to run. We don't want that to happen so we just return and we'll try again during next loop.
@@ -57,10 +72,10 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 	var detectorService *detector.Detector
 	{
 		detectorConfig := detector.Config{
-			K8sClient: tenantClusterK8sClient,
+			K8sClient: tenantClusterK8sClient.CtrlClient(),
 			Logger:    r.logger,
 
-			NotReadyTickThreshold: nodeTerminationTickThreshold,
+			NotReadyTickThreshold: policy.TickThreshold,
 		}
 
 		detectorService, err = detector.NewDetector(detectorConfig)
@@ -69,76 +84,230 @@ func (r *Resource) EnsureCreated(ctx context.Context, obj interface{}) error {
 		}
 	}
 
-	nodesToTerminate, err := detectorService.DetectBadNodes(ctx)
+	nodesToRepair, err := detectorService.DetectBadNodes(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var nodeList corev1.NodeList
+	err = tenantClusterK8sClient.CtrlClient().List(ctx, &nodeList)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	if len(nodesToTerminate) > 0 {
-		for _, n := range nodesToTerminate {
-			err := r.terminateNode(ctx, n, cr)
+	now := time.Now().UTC()
+	conditionNodes, reasons := nodesWithUnhealthyConditions(nodeList.Items, nodesToRepair, policy.UnhealthyConditions, policy.ConditionGracePeriod, now)
+	nodesToRepair = append(nodesToRepair, conditionNodes...)
+
+	if len(nodesToRepair) == 0 {
+		r.skippedRepairs.update(string(cr.UID), nil)
+		return nil
+	}
+
+	percentages := unhealthyPercentages(nodeList.Items, policy.UnhealthyConditions, policy.ConditionGracePeriod, now)
+	repairs := recentRepairs(cr.Annotations[r.historyAnnotation()], now)
+	var repaired int
+	var masterRepaired bool
+	skipped := map[string]skippedRepair{}
+	for _, n := range nodesToRepair {
+		pool := n.Labels[label.MachinePool]
+		if isMaster(n) {
+			singleMaster := masterCount(nodeList.Items) == 1
+			if singleMaster && !policy.RepairSingleMaster {
+				skipped[n.Name] = newSkippedRepair(skipSingleMaster, "Master node %s is unhealthy (%s) but it is the only master node of the cluster, set the %#q Cluster annotation to %#q to reimage it", n.Name, reasons[n.Name], azopannotation.NodeAutoRepairSingleMaster, "true")
+				continue
+			}
+
+			blocker := masterRepairBlocker(nodeList.Items, n)
+			if masterRepaired {
+				blocker = "another master node is being repaired"
+			}
+			if blocker != "" {
+				skipped[n.Name] = newSkippedRepair(skipMasterBlocked, "Master node %s is unhealthy (%s) but %s", n.Name, reasons[n.Name], blocker)
+				continue
+			}
+
+			// The etcd member of a single master runs on the unhealthy
+			// master itself, there are no other members to keep healthy.
+			if !singleMaster {
+				err = etcdhealth.Check(ctx, tenantClusterK8sClient)
+				if etcdhealth.IsUnhealthy(err) {
+					skipped[n.Name] = newSkippedRepair(skipEtcdUnhealthy, "Master node %s is unhealthy (%s) but etcd is not healthy: %s", n.Name, reasons[n.Name], err)
+					continue
+				} else if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+		} else if percentages[pool] > policy.MaxUnhealthyPercentage {
+			skipped[n.Name] = newSkippedRepair(skipMaxUnhealthy, "Node %s is unhealthy (%s) but %d%% of the nodes of node pool %s are unhealthy, more than the maximum of %d%%", n.Name, reasons[n.Name], percentages[pool], pool, policy.MaxUnhealthyPercentage)
+			continue
+		}
+
+		if len(repairs) >= policy.MaxRepairsPerHour {
+			skipped[n.Name] = newSkippedRepair(skipRateLimited, "Node %s is unhealthy (%s) but %d nodes were repaired within the last hour, the maximum is %d", n.Name, reasons[n.Name], len(repairs), policy.MaxRepairsPerHour)
+			continue
+		}
+
+		action := policy.Action
+		if isMaster(n) {
+			// Masters keep their etcd data disk only when they are reimaged.
+			action = ActionReimage
+		}
+
+		if r.dryRun {
+			instanceID, err := key.InstanceIDFromNode(n)
 			if err != nil {
 				return microerror.Mask(err)
 			}
+
+			r.logger.Debugf(ctx, "dry-run: would repair node %#q (instance %s) with action %s", n.Name, instanceID, action)
+
+			if action == ActionDelete {
+				reportNodeTermination(cr.Name, n.Name, instanceID, true)
+			}
+			reportNodeDryRunRepair(cr.Name, action)
+
+			repairs = append(repairs, now)
+			repaired++
+			if isMaster(n) {
+				masterRepaired = true
+			}
+
+			err = r.saveRepairs(ctx, cr, repairs)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.emitEvent(ctx, &cr, event.TypeNormal, NodeRepairDryRunReason, "Node %s (instance %s) is unhealthy (%s) and would have been repaired with action %s in dry-run mode", n.Name, instanceID, reasons[n.Name], action)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			continue
 		}
 
-		// reset tick counters on all nodes in cluster to have a graceful period after terminating nodes
-		err := detectorService.ResetTickCounters(ctx)
+		instanceID, err := r.repairNode(ctx, n, cr, action)
 		if err != nil {
 			return microerror.Mask(err)
 		}
-		r.logger.Debugf(ctx, "resetting tick node counters on all nodes in tenant cluster")
-	}
 
-	return nil
-}
+		repairs = append(repairs, now)
+		repaired++
+		if isMaster(n) {
+			masterRepaired = true
+		}
 
-func (r *Resource) getTenantClusterClient(ctx context.Context, cluster *capi.Cluster) (client.Client, error) {
-	var k8sClient k8sclient.Interface
-	{
-		restConfig, err := r.tenantRestConfigProvider.NewRestConfig(ctx, key.ClusterID(cluster), cluster.Spec.ControlPlaneEndpoint.Host)
+		// Repairs are recorded right away, so that the rate limit holds
+		// even when a later repair of this loop fails.
+		err = r.saveRepairs(ctx, cr, repairs)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = r.markRepaired(ctx, tenantClusterK8sClient.CtrlClient(), n, now)
 		if err != nil {
-			return nil, microerror.Mask(err)
+			return microerror.Mask(err)
 		}
 
-		k8sClient, err = k8sclient.NewClients(k8sclient.ClientsConfig{
-			Logger:     r.logger,
-			RestConfig: rest.CopyConfig(restConfig),
-		})
+		err = r.emitEvent(ctx, &cr, event.TypeNormal, NodeRepairedReason, "Node %s (instance %s) was unhealthy (%s) and has been repaired with action %s", n.Name, instanceID, reasons[n.Name], action)
 		if err != nil {
-			return nil, microerror.Mask(err)
+			return microerror.Mask(err)
 		}
 	}
 
-	return k8sClient.CtrlClient(), nil
+	// Skipped repairs are reported once until the reason they are skipped
+	// for changes.
+	for _, name := range r.skippedRepairs.update(string(cr.UID), skipped) {
+		err = r.emitEvent(ctx, &cr, event.TypeWarning, NodeRepairSkippedReason, "%s", skipped[name].Message)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// Tick counters are only reset after real repairs, so that dry-run mode
+	// does not delay the detection of unhealthy nodes.
+	if repaired > 0 && !r.dryRun {
+		// reset tick counters on all nodes in cluster to have a graceful period after repairing nodes
+		err = detectorService.ResetTickCounters(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		r.logger.Debugf(ctx, "resetting tick node counters on all nodes in tenant cluster")
+	}
+
+	return nil
 }
 
-func (r *Resource) terminateNode(ctx context.Context, node corev1.Node, cluster capi.Cluster) error {
-	if node.Labels["role"] != "worker" {
-		// We can only terminate workers in azure.
-		r.logger.Debugf(ctx, "Termination of master nodes is not supported on Azure")
-		return nil
+func (r *Resource) repairNode(ctx context.Context, node corev1.Node, cluster capi.Cluster, action string) (string, error) {
+	instanceID, err := key.InstanceIDFromNode(node)
+	if err != nil {
+		return "", microerror.Mask(err)
 	}
 
 	vmssClient, err := r.azureClientsFactory.GetVirtualMachineScaleSetsClient(ctx, cluster.ObjectMeta)
 	if err != nil {
-		return microerror.Mask(err)
+		return "", microerror.Mask(err)
 	}
 
-	// Scale VMSS up by one.
 	var vmssName string
-	{
+	if isMaster(node) {
+		vmssName = key.MasterVMSSNameFromClusterAPIObject(&cluster)
+	} else {
 		r.logger.Debugf(ctx, "Retrieving AzureMachinePool CR")
 		amp := capzexp.AzureMachinePool{}
 		err := r.ctrlClient.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: node.Labels[label.MachinePool]}, &amp)
 		if err != nil {
-			return microerror.Mask(err)
+			return "", microerror.Mask(err)
 		}
 		r.logger.Debugf(ctx, "Retrieved AzureMachinePool CR")
 
-		r.logger.Debugf(ctx, "Retrieving VMSS")
 		vmssName = key.NodePoolVMSSName(&amp)
-		vmss, err := vmssClient.Get(ctx, cluster.Name, vmssName)
+	}
+
+	switch action {
+	case ActionRestart:
+		err = r.restartInstance(ctx, vmssClient, cluster.Name, vmssName, instanceID)
+	case ActionReimage:
+		r.logger.Debugf(ctx, "Reimaging instance with ID %q of vmss %q", instanceID, vmssName)
+		err = vmssinstance.Reimage(ctx, vmssClient, cluster.Name, vmssName, instanceID)
+		if err == nil {
+			r.logger.Debugf(ctx, "Reimaged instance with ID %q of vmss %q", instanceID, vmssName)
+		}
+	default:
+		err = r.deleteInstance(ctx, vmssClient, cluster.Name, vmssName, instanceID)
+		if err == nil {
+			// expose metric about node termination
+			reportNodeTermination(cluster.Name, node.Name, instanceID, false)
+		}
+	}
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	reportNodeRepair(cluster.Name, action)
+
+	return instanceID, nil
+}
+
+func (r *Resource) restartInstance(ctx context.Context, vmssClient *compute.VirtualMachineScaleSetsClient, resourceGroup, vmssName, instanceID string) error {
+	r.logger.Debugf(ctx, "Restarting instance with ID %q of vmss %q", instanceID, vmssName)
+	res, err := vmssClient.Restart(ctx, resourceGroup, vmssName, &compute.VirtualMachineScaleSetVMInstanceIDs{InstanceIds: &[]string{instanceID}})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	_, err = vmssClient.RestartResponder(res.Response())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	r.logger.Debugf(ctx, "Restarted instance with ID %q of vmss %q", instanceID, vmssName)
+
+	return nil
+}
+
+func (r *Resource) deleteInstance(ctx context.Context, vmssClient *compute.VirtualMachineScaleSetsClient, resourceGroup, vmssName, instanceID string) error {
+	// Scale VMSS up by one.
+	{
+		r.logger.Debugf(ctx, "Retrieving VMSS")
+		vmss, err := vmssClient.Get(ctx, resourceGroup, vmssName)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -153,7 +322,7 @@ func (r *Resource) terminateNode(ctx context.Context, node corev1.Node, cluster
 			},
 		}
 
-		_, err = vmssClient.Update(ctx, cluster.Name, vmssName, update)
+		_, err = vmssClient.Update(ctx, resourceGroup, vmssName, update)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -162,15 +331,9 @@ func (r *Resource) terminateNode(ctx context.Context, node corev1.Node, cluster
 	}
 
 	// Terminate faulty node.
-	var instanceID string
 	{
-		instanceID, err := key.InstanceIDFromNode(node)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-
 		r.logger.Debugf(ctx, "Deleting instance with ID %q from vmss %q", instanceID, vmssName)
-		res, err := vmssClient.DeleteInstances(ctx, cluster.Name, vmssName, compute.VirtualMachineScaleSetVMInstanceRequiredIDs{InstanceIds: &[]string{instanceID}})
+		res, err := vmssClient.DeleteInstances(ctx, resourceGroup, vmssName, compute.VirtualMachineScaleSetVMInstanceRequiredIDs{InstanceIds: &[]string{instanceID}})
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -181,7 +344,47 @@ func (r *Resource) terminateNode(ctx context.Context, node corev1.Node, cluster
 		r.logger.Debugf(ctx, "Deleted instance with ID %q from vmss %q", instanceID, vmssName)
 	}
 
-	// expose metric about node termination
-	reportNodeTermination(cluster.Name, node.Name, instanceID)
+	return nil
+}
+
+// saveRepairs records the repairs of the last hour in the Cluster CR, so that
+// the rate limit applies across reconciliation loops.
+func (r *Resource) saveRepairs(ctx context.Context, cluster capi.Cluster, repairs []time.Time) error {
+	cr := capi.Cluster{}
+	err := r.ctrlClient.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, &cr)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[r.historyAnnotation()] = formatRepairs(repairs)
+
+	err = r.ctrlClient.Update(ctx, &cr)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// historyAnnotation returns the annotation the repairs are recorded in. Repairs
+// of dry-run mode are recorded separately, so that they do not rate limit
+// repairs once dry-run mode is turned off.
+func (r *Resource) historyAnnotation() string {
+	if r.dryRun {
+		return azopannotation.NodeAutoRepairDryRunHistory
+	}
+
+	return azopannotation.NodeAutoRepairHistory
+}
+
+func (r *Resource) emitEvent(ctx context.Context, cluster *capi.Cluster, eventType, reason, messageFmt string, args ...interface{}) error {
+	err := r.eventRecorder.Emit(ctx, cluster, eventType, reason, messageFmt, args...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
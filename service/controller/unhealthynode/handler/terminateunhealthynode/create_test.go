@@ -0,0 +1,304 @@
+package terminateunhealthynode
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	apiextensionsannotation "github.com/giantswarm/apiextensions/v6/pkg/annotation"
+	"github.com/giantswarm/k8sclient/v7/pkg/k8sclienttest"
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	azureclient "github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/mock/mock_tenantcluster"
+	"github.com/giantswarm/azure-operator/v8/service/unittest"
+)
+
+var errVMSSNotAvailable = errors.New("vmss not available")
+
+func Test_Resource_EnsureCreated_DryRun(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name                  string
+		dryRun                bool
+		expectedVMSSCalls     int
+		expectedNodeWrites    []string
+		expectedHistory       int
+		expectedDryRunHistory int
+		errorMatcher          func(error) bool
+	}{
+		{
+			name:                  "case 0: dry-run only records the repair",
+			dryRun:                true,
+			expectedDryRunHistory: 1,
+		},
+		{
+			name:              "case 1: repairs call the VMSS API",
+			expectedVMSSCalls: 1,
+			errorMatcher: func(err error) bool {
+				return errors.Is(err, errVMSSNotAvailable)
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "c1a2b",
+					Namespace: "org-test",
+					UID:       "cluster-uid",
+					Annotations: map[string]string{
+						apiextensionsannotation.NodeTerminateUnhealthy: "enabled",
+					},
+				},
+			}
+			ctrlClient := unittest.FakeK8sClient(cluster).CtrlClient()
+
+			unhealthy := withCondition(newNamedNode("nodepool-a1b2c-000000"), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour))
+			// The tick counter of the healthy node would be reset after a
+			// repair.
+			healthy0 := newNamedNode("nodepool-a1b2c-000001")
+			healthy0.Annotations = map[string]string{"giantswarm.io/node-not-ready-tick": "0"}
+			healthy1 := newNamedNode("nodepool-a1b2c-000002")
+			wcCtrlClient := &writeRecordingClient{
+				Client: fake.NewClientBuilder().WithObjects(&unhealthy, &healthy0, &healthy1).Build(),
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+			tenantClientFactory.EXPECT().GetAllClients(gomock.Any(), gomock.Any()).Return(k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: wcCtrlClient,
+				K8sClient:  kubernetesfake.NewSimpleClientset(),
+			}), nil).AnyTimes()
+
+			eventRecorder, err := event.New(event.Config{
+				CtrlClient: ctrlClient,
+				Logger:     microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			azureClientsFactory := &fakeClientFactory{}
+
+			r, err := New(Config{
+				AzureClientsFactory: azureClientsFactory,
+				CtrlClient:          ctrlClient,
+				DryRun:              tc.dryRun,
+				EventRecorder:       eventRecorder,
+				Logger:              microloggertest.New(),
+				TenantClientFactory: tenantClientFactory,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.EnsureCreated(ctx, cluster)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if azureClientsFactory.vmssCalls != tc.expectedVMSSCalls {
+				t.Fatalf("VMSS client requested %d times, want %d", azureClientsFactory.vmssCalls, tc.expectedVMSSCalls)
+			}
+			if !cmp.Equal(wcCtrlClient.writes, tc.expectedNodeWrites) {
+				t.Fatalf("node writes\n\n%s\n", cmp.Diff(tc.expectedNodeWrites, wcCtrlClient.writes))
+			}
+
+			updated := &capi.Cluster{}
+			err = ctrlClient.Get(ctx, client.ObjectKeyFromObject(cluster), updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(recentRepairs(updated.Annotations[annotation.NodeAutoRepairHistory], now)); n != tc.expectedHistory {
+				t.Fatalf("%d repairs recorded, want %d", n, tc.expectedHistory)
+			}
+			if n := len(recentRepairs(updated.Annotations[annotation.NodeAutoRepairDryRunHistory], now)); n != tc.expectedDryRunHistory {
+				t.Fatalf("%d dry-run repairs recorded, want %d", n, tc.expectedDryRunHistory)
+			}
+		})
+	}
+}
+
+func Test_Resource_EnsureCreated_SingleMaster(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name              string
+		annotations       map[string]string
+		expectedVMSSCalls int
+		expectedEvents    []string
+		errorMatcher      func(error) bool
+	}{
+		{
+			name:           "case 0: single master is not repaired by default",
+			expectedEvents: []string{NodeRepairSkippedReason},
+		},
+		{
+			name: "case 1: single master is reimaged when allowed",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairSingleMaster: "true",
+			},
+			expectedVMSSCalls: 1,
+			errorMatcher: func(err error) bool {
+				return errors.Is(err, errVMSSNotAvailable)
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			ctx := context.Background()
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "c1a2b",
+					Namespace: "org-test",
+					UID:       "cluster-uid",
+					Annotations: map[string]string{
+						apiextensionsannotation.NodeTerminateUnhealthy: "enabled",
+					},
+				},
+			}
+			for k, v := range tc.annotations {
+				cluster.Annotations[k] = v
+			}
+			ctrlClient := unittest.FakeK8sClient(cluster).CtrlClient()
+
+			master := withCondition(newMaster("c1a2b-master-c1a2b-000000", corev1.ConditionTrue), "KernelDeadlock", corev1.ConditionTrue, now.Add(-time.Hour))
+			worker := newNamedNode("nodepool-a1b2c-000000")
+			wcCtrlClient := fake.NewClientBuilder().WithObjects(&master, &worker).Build()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tenantClientFactory := mock_tenantcluster.NewMockFactory(ctrl)
+			tenantClientFactory.EXPECT().GetAllClients(gomock.Any(), gomock.Any()).Return(k8sclienttest.NewClients(k8sclienttest.ClientsConfig{
+				CtrlClient: wcCtrlClient,
+				K8sClient:  kubernetesfake.NewSimpleClientset(),
+			}), nil).AnyTimes()
+
+			eventRecorder, err := event.New(event.Config{
+				CtrlClient: ctrlClient,
+				Logger:     microloggertest.New(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			azureClientsFactory := &fakeClientFactory{}
+
+			r, err := New(Config{
+				AzureClientsFactory: azureClientsFactory,
+				CtrlClient:          ctrlClient,
+				EventRecorder:       eventRecorder,
+				Logger:              microloggertest.New(),
+				TenantClientFactory: tenantClientFactory,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.EnsureCreated(ctx, cluster)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if azureClientsFactory.vmssCalls != tc.expectedVMSSCalls {
+				t.Fatalf("VMSS client requested %d times, want %d", azureClientsFactory.vmssCalls, tc.expectedVMSSCalls)
+			}
+
+			var events corev1.EventList
+			err = ctrlClient.List(ctx, &events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reasons []string
+			for _, e := range events.Items {
+				reasons = append(reasons, e.Reason)
+			}
+			if !cmp.Equal(reasons, tc.expectedEvents) {
+				t.Fatalf("events\n\n%s\n", cmp.Diff(tc.expectedEvents, reasons))
+			}
+		})
+	}
+}
+
+// fakeClientFactory fails all VMSS requests and counts them. Any other Azure
+// client request panics.
+type fakeClientFactory struct {
+	azureclient.Interface
+
+	vmssCalls int
+}
+
+func (f *fakeClientFactory) GetVirtualMachineScaleSetsClient(ctx context.Context, objectMeta metav1.ObjectMeta) (*compute.VirtualMachineScaleSetsClient, error) {
+	f.vmssCalls++
+	return nil, errVMSSNotAvailable
+}
+
+// writeRecordingClient records the names of the objects written through it.
+type writeRecordingClient struct {
+	client.Client
+
+	writes []string
+}
+
+func (c *writeRecordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.writes = append(c.writes, obj.GetName())
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeRecordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.writes = append(c.writes, obj.GetName())
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *writeRecordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.writes = append(c.writes, obj.GetName())
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *writeRecordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.writes = append(c.writes, obj.GetName())
+	return c.Client.Update(ctx, obj, opts...)
+}
@@ -30,3 +30,12 @@ var unsupportedOperationError = &microerror.Error{
 func IsUnsupportedOperation(err error) bool {
 	return microerror.Cause(err) == unsupportedOperationError
 }
+
+var invalidRepairPolicyError = &microerror.Error{
+	Kind: "invalidRepairPolicyError",
+}
+
+// IsInvalidRepairPolicy asserts invalidRepairPolicyError.
+func IsInvalidRepairPolicy(err error) bool {
+	return microerror.Cause(err) == invalidRepairPolicyError
+}
@@ -0,0 +1,54 @@
+package terminateunhealthynode
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func isMaster(node corev1.Node) bool {
+	return node.Labels["role"] == "master"
+}
+
+func masterCount(nodes []corev1.Node) int {
+	var masters int
+	for _, node := range nodes {
+		if isMaster(node) {
+			masters++
+		}
+	}
+
+	return masters
+}
+
+// masterRepairBlocker returns why the given unhealthy master must not be
+// repaired yet, or an empty string when it can be repaired. Masters are
+// repaired one at a time and only when the remaining masters keep etcd
+// quorum. Single masters have no quorum to keep, whether they are repaired is
+// decided by the repair policy.
+func masterRepairBlocker(nodes []corev1.Node, unhealthy corev1.Node) string {
+	if masterCount(nodes) == 1 {
+		return ""
+	}
+
+	var masters, readyOthers int
+	for _, node := range nodes {
+		if !isMaster(node) {
+			continue
+		}
+
+		masters++
+		if node.Name != unhealthy.Name && nodeReady(node) {
+			readyOthers++
+		}
+	}
+
+	if readyOthers < masters-1 {
+		return fmt.Sprintf("%d other master nodes are not ready", masters-1-readyOthers)
+	}
+	if quorum := masters/2 + 1; readyOthers < quorum {
+		return fmt.Sprintf("the %d remaining master nodes do not keep etcd quorum of %d", readyOthers, quorum)
+	}
+
+	return ""
+}
@@ -0,0 +1,72 @@
+package terminateunhealthynode
+
+import (
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_masterRepairBlocker(t *testing.T) {
+	testCases := []struct {
+		name            string
+		nodes           []corev1.Node
+		expectedBlocker string
+	}{
+		{
+			name: "case 0: single master is left to the repair policy",
+			nodes: []corev1.Node{
+				newMaster("master-0", corev1.ConditionFalse),
+				newNode("a", corev1.ConditionTrue),
+			},
+		},
+		{
+			name: "case 1: other masters ready",
+			nodes: []corev1.Node{
+				newMaster("master-0", corev1.ConditionFalse),
+				newMaster("master-1", corev1.ConditionTrue),
+				newMaster("master-2", corev1.ConditionTrue),
+				newNode("a", corev1.ConditionFalse),
+			},
+		},
+		{
+			name: "case 2: another master is not ready",
+			nodes: []corev1.Node{
+				newMaster("master-0", corev1.ConditionFalse),
+				newMaster("master-1", corev1.ConditionUnknown),
+				newMaster("master-2", corev1.ConditionTrue),
+				newMaster("master-3", corev1.ConditionTrue),
+				newMaster("master-4", corev1.ConditionTrue),
+			},
+			expectedBlocker: "1 other master nodes are not ready",
+		},
+		{
+			name: "case 3: two masters cannot keep quorum",
+			nodes: []corev1.Node{
+				newMaster("master-0", corev1.ConditionFalse),
+				newMaster("master-1", corev1.ConditionTrue),
+			},
+			expectedBlocker: "the 1 remaining master nodes do not keep etcd quorum of 2",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			blocker := masterRepairBlocker(tc.nodes, tc.nodes[0])
+
+			if blocker != tc.expectedBlocker {
+				t.Fatalf("expected %q, got %q", tc.expectedBlocker, blocker)
+			}
+		})
+	}
+}
+
+func newMaster(name string, ready corev1.ConditionStatus) corev1.Node {
+	node := newNode("", ready)
+	node.Name = name
+	node.Labels["role"] = "master"
+
+	return node
+}
@@ -1,6 +1,8 @@
 package terminateunhealthynode
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -14,19 +16,47 @@ var (
 			Name: "azure_operator_unhealthy_node_termination",
 			Help: "Gauge representing node termination due to node auto repair feature.",
 		},
-		[]string{"cluster_id", "terminated_node", "terminated_instance_id"},
+		[]string{"cluster_id", "terminated_node", "terminated_instance_id", "dry_run"},
+	)
+	nodeAutoRepairRepairs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azure_operator_unhealthy_node_repairs_total",
+			Help: "Number of unhealthy nodes repaired by the node auto repair feature per repair action.",
+		},
+		[]string{"cluster_id", "action"},
+	)
+	nodeAutoRepairDryRunRepairs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azure_operator_unhealthy_node_dry_run_repairs_total",
+			Help: "Number of unhealthy nodes the node auto repair feature would have repaired in dry-run mode per repair action.",
+		},
+		[]string{"cluster_id", "action"},
 	)
 )
 
 func init() {
 	prometheus.MustRegister(nodeAutoRepairTermination)
+	prometheus.MustRegister(nodeAutoRepairRepairs)
+	prometheus.MustRegister(nodeAutoRepairDryRunRepairs)
 
 }
 
 // reportNodeTermination is a utility function for updating metrics related to
 // node auto repair node termination.
-func reportNodeTermination(clusterID string, nodeName string, instanceID string) {
+func reportNodeTermination(clusterID string, nodeName string, instanceID string, dryRun bool) {
 	nodeAutoRepairTermination.WithLabelValues(
-		clusterID, nodeName, instanceID,
+		clusterID, nodeName, instanceID, strconv.FormatBool(dryRun),
 	).Set(gaugeValue)
 }
+
+// reportNodeRepair counts a node repaired by node auto repair with the given
+// action.
+func reportNodeRepair(clusterID string, action string) {
+	nodeAutoRepairRepairs.WithLabelValues(clusterID, action).Inc()
+}
+
+// reportNodeDryRunRepair counts a node node auto repair would have repaired
+// with the given action in dry-run mode.
+func reportNodeDryRunRepair(clusterID string, action string) {
+	nodeAutoRepairDryRunRepairs.WithLabelValues(clusterID, action).Inc()
+}
@@ -0,0 +1,177 @@
+package terminateunhealthynode
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+const (
+	ActionDelete  = "delete"
+	ActionReimage = "reimage"
+	ActionRestart = "restart"
+
+	defaultConditionGracePeriod   = 10 * time.Minute
+	defaultTickThreshold          = 6
+	defaultMaxRepairsPerHour      = 5
+	defaultMaxUnhealthyPercentage = 40
+
+	repairRateLimitWindow = time.Hour
+)
+
+// repairPolicy defines when and how unhealthy nodes of a cluster are
+// repaired. It is configured with annotations on the Cluster CR.
+type repairPolicy struct {
+	Action                 string
+	ConditionGracePeriod   time.Duration
+	MaxRepairsPerHour      int
+	MaxUnhealthyPercentage int
+	RepairSingleMaster     bool
+	TickThreshold          int
+	UnhealthyConditions    []string
+}
+
+// newRepairPolicy returns the repair policy defined by the given Cluster
+// annotations. Settings which are not defined have their default value.
+func newRepairPolicy(annotations map[string]string) (repairPolicy, error) {
+	policy := repairPolicy{
+		Action:                 ActionDelete,
+		ConditionGracePeriod:   defaultConditionGracePeriod,
+		MaxRepairsPerHour:      defaultMaxRepairsPerHour,
+		MaxUnhealthyPercentage: defaultMaxUnhealthyPercentage,
+		TickThreshold:          defaultTickThreshold,
+		UnhealthyConditions:    defaultUnhealthyConditions,
+	}
+
+	if v, ok := annotations[annotation.NodeAutoRepairAction]; ok {
+		switch v {
+		case ActionDelete, ActionReimage, ActionRestart:
+			policy.Action = v
+		default:
+			return repairPolicy{}, microerror.Maskf(invalidRepairPolicyError, "%#q must be one of %#q, %#q or %#q but is %#q", annotation.NodeAutoRepairAction, ActionDelete, ActionReimage, ActionRestart, v)
+		}
+	}
+
+	var err error
+	policy.TickThreshold, err = positiveInt(annotations, annotation.NodeAutoRepairTickThreshold, policy.TickThreshold)
+	if err != nil {
+		return repairPolicy{}, microerror.Mask(err)
+	}
+	policy.MaxRepairsPerHour, err = positiveInt(annotations, annotation.NodeAutoRepairMaxRepairsPerHour, policy.MaxRepairsPerHour)
+	if err != nil {
+		return repairPolicy{}, microerror.Mask(err)
+	}
+	policy.MaxUnhealthyPercentage, err = positiveInt(annotations, annotation.NodeAutoRepairMaxUnhealthyPercentage, policy.MaxUnhealthyPercentage)
+	if err != nil {
+		return repairPolicy{}, microerror.Mask(err)
+	}
+	if policy.MaxUnhealthyPercentage > 100 {
+		return repairPolicy{}, microerror.Maskf(invalidRepairPolicyError, "%#q must not be greater than 100 but is %d", annotation.NodeAutoRepairMaxUnhealthyPercentage, policy.MaxUnhealthyPercentage)
+	}
+	if v, ok := annotations[annotation.NodeAutoRepairConditionGracePeriod]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return repairPolicy{}, microerror.Maskf(invalidRepairPolicyError, "%#q must be a non-negative duration like %#q but is %#q", annotation.NodeAutoRepairConditionGracePeriod, "10m", v)
+		}
+		policy.ConditionGracePeriod = d
+	}
+	if v, ok := annotations[annotation.NodeAutoRepairSingleMaster]; ok {
+		policy.RepairSingleMaster, err = strconv.ParseBool(v)
+		if err != nil {
+			return repairPolicy{}, microerror.Maskf(invalidRepairPolicyError, "%#q must be %#q or %#q but is %#q", annotation.NodeAutoRepairSingleMaster, "true", "false", v)
+		}
+	}
+	if v, ok := annotations[annotation.NodeAutoRepairUnhealthyConditions]; ok {
+		policy.UnhealthyConditions, err = parseConditions(v)
+		if err != nil {
+			return repairPolicy{}, microerror.Mask(err)
+		}
+	}
+
+	return policy, nil
+}
+
+func positiveInt(annotations map[string]string, key string, defaultValue int) (int, error) {
+	v, ok := annotations[key]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 1 {
+		return 0, microerror.Maskf(invalidRepairPolicyError, "%#q must be a positive integer but is %#q", key, v)
+	}
+
+	return i, nil
+}
+
+// recentRepairs returns the repair times of the given history which are
+// within the rate limit window. Invalid entries are ignored.
+func recentRepairs(history string, now time.Time) []time.Time {
+	var repairs []time.Time
+	for _, v := range strings.Split(history, ",") {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		if now.Sub(t) >= repairRateLimitWindow {
+			continue
+		}
+
+		repairs = append(repairs, t)
+	}
+
+	sort.Slice(repairs, func(i, j int) bool {
+		return repairs[i].Before(repairs[j])
+	})
+
+	return repairs
+}
+
+func formatRepairs(repairs []time.Time) string {
+	var values []string
+	for _, t := range repairs {
+		values = append(values, t.UTC().Format(time.RFC3339))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// unhealthyPercentages returns the percentage of nodes per node pool which are
+// not ready or have one of the given unhealthy conditions, keyed by the
+// machine pool ID of the nodes.
+func unhealthyPercentages(nodes []corev1.Node, conditions []string, gracePeriod time.Duration, now time.Time) map[string]int {
+	total := map[string]int{}
+	unhealthy := map[string]int{}
+	for _, node := range nodes {
+		pool := node.Labels[label.MachinePool]
+		total[pool]++
+		if !nodeReady(node) || unhealthyCondition(node, conditions, gracePeriod, now) != "" {
+			unhealthy[pool]++
+		}
+	}
+
+	percentages := map[string]int{}
+	for pool, n := range total {
+		percentages[pool] = unhealthy[pool] * 100 / n
+	}
+
+	return percentages
+}
+
+func nodeReady(node corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package terminateunhealthynode
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/apiextensions/v6/pkg/label"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/azure-operator/v8/pkg/annotation"
+)
+
+func Test_newRepairPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedPolicy repairPolicy
+		errorMatcher   func(error) bool
+	}{
+		{
+			name: "case 0: defaults",
+			expectedPolicy: repairPolicy{
+				Action:                 ActionDelete,
+				ConditionGracePeriod:   10 * time.Minute,
+				MaxRepairsPerHour:      5,
+				MaxUnhealthyPercentage: 40,
+				TickThreshold:          6,
+				UnhealthyConditions:    []string{"KernelDeadlock", "ReadonlyFilesystem", "ContainerRuntimeUnhealthy", "RedeployScheduled"},
+			},
+		},
+		{
+			name: "case 1: all settings",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairAction:                 "reimage",
+				annotation.NodeAutoRepairConditionGracePeriod:   "3m",
+				annotation.NodeAutoRepairMaxRepairsPerHour:      "2",
+				annotation.NodeAutoRepairMaxUnhealthyPercentage: "25",
+				annotation.NodeAutoRepairSingleMaster:           "true",
+				annotation.NodeAutoRepairTickThreshold:          "10",
+				annotation.NodeAutoRepairUnhealthyConditions:    "KernelDeadlock, FrequentKubeletRestart",
+			},
+			expectedPolicy: repairPolicy{
+				Action:                 ActionReimage,
+				ConditionGracePeriod:   3 * time.Minute,
+				MaxRepairsPerHour:      2,
+				MaxUnhealthyPercentage: 25,
+				RepairSingleMaster:     true,
+				TickThreshold:          10,
+				UnhealthyConditions:    []string{"KernelDeadlock", "FrequentKubeletRestart"},
+			},
+		},
+		{
+			name: "case 2: conditions disabled",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairUnhealthyConditions: "",
+			},
+			expectedPolicy: repairPolicy{
+				Action:                 ActionDelete,
+				ConditionGracePeriod:   10 * time.Minute,
+				MaxRepairsPerHour:      5,
+				MaxUnhealthyPercentage: 40,
+				TickThreshold:          6,
+			},
+		},
+		{
+			name: "case 3: unknown action",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairAction: "replace",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+		{
+			name: "case 4: zero repairs per hour",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairMaxRepairsPerHour: "0",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+		{
+			name: "case 5: percentage above 100",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairMaxUnhealthyPercentage: "150",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+		{
+			name: "case 6: threshold is not a number",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairTickThreshold: "six",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+		{
+			name: "case 7: grace period is not a duration",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairConditionGracePeriod: "10",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+		{
+			name: "case 8: single master repair is not a bool",
+			annotations: map[string]string{
+				annotation.NodeAutoRepairSingleMaster: "yes",
+			},
+			errorMatcher: IsInvalidRepairPolicy,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			policy, err := newRepairPolicy(tc.annotations)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !cmp.Equal(policy, tc.expectedPolicy) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedPolicy, policy))
+			}
+		})
+	}
+}
+
+func Test_recentRepairs(t *testing.T) {
+	now := time.Date(2023, 7, 20, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name            string
+		history         string
+		expectedHistory string
+	}{
+		{
+			name: "case 0: no history",
+		},
+		{
+			name:            "case 1: repairs older than an hour are dropped",
+			history:         "2023-07-20T10:30:00Z,2023-07-20T11:00:00Z,2023-07-20T11:45:00Z",
+			expectedHistory: "2023-07-20T11:45:00Z",
+		},
+		{
+			name:            "case 2: invalid entries are ignored and repairs sorted",
+			history:         "2023-07-20T11:50:00Z,garbage,2023-07-20T11:10:00Z",
+			expectedHistory: "2023-07-20T11:10:00Z,2023-07-20T11:50:00Z",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			history := formatRepairs(recentRepairs(tc.history, now))
+
+			if history != tc.expectedHistory {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedHistory, history))
+			}
+		})
+	}
+}
+
+func Test_unhealthyPercentages(t *testing.T) {
+	nodes := []corev1.Node{
+		newNode("a", corev1.ConditionTrue),
+		newNode("a", corev1.ConditionFalse),
+		newNode("a", corev1.ConditionTrue),
+		newNode("a", corev1.ConditionUnknown),
+		newNode("b", corev1.ConditionTrue),
+	}
+
+	percentages := unhealthyPercentages(nodes, nil, defaultConditionGracePeriod, time.Now())
+
+	expected := map[string]int{"a": 50, "b": 0}
+	if !cmp.Equal(percentages, expected) {
+		t.Fatalf("\n\n%s\n", cmp.Diff(expected, percentages))
+	}
+}
+
+func newNode(pool string, ready corev1.ConditionStatus) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{label.MachinePool: pool},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready},
+			},
+		},
+	}
+}
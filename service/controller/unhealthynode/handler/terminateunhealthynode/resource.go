@@ -3,10 +3,11 @@ package terminateunhealthynode
 import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	"github.com/giantswarm/tenantcluster/v6/pkg/tenantcluster"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	azureclient "github.com/giantswarm/azure-operator/v8/client"
+	"github.com/giantswarm/azure-operator/v8/pkg/event"
+	"github.com/giantswarm/azure-operator/v8/pkg/tenantcluster"
 )
 
 const (
@@ -14,17 +15,22 @@ const (
 )
 
 type Config struct {
-	AzureClientsFactory      *azureclient.OrganizationFactory
-	CtrlClient               client.Client
-	Logger                   micrologger.Logger
-	TenantRestConfigProvider *tenantcluster.TenantCluster
+	AzureClientsFactory azureclient.Interface
+	CtrlClient          client.Client
+	DryRun              bool
+	EventRecorder       *event.Recorder
+	Logger              micrologger.Logger
+	TenantClientFactory tenantcluster.Factory
 }
 
 type Resource struct {
-	azureClientsFactory      *azureclient.OrganizationFactory
-	ctrlClient               client.Client
-	logger                   micrologger.Logger
-	tenantRestConfigProvider *tenantcluster.TenantCluster
+	azureClientsFactory azureclient.Interface
+	ctrlClient          client.Client
+	dryRun              bool
+	eventRecorder       *event.Recorder
+	logger              micrologger.Logger
+	skippedRepairs      *skippedRepairs
+	tenantClientFactory tenantcluster.Factory
 }
 
 func New(config Config) (*Resource, error) {
@@ -34,18 +40,24 @@ func New(config Config) (*Resource, error) {
 	if config.CtrlClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
 	}
+	if config.EventRecorder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.EventRecorder must not be empty", config)
+	}
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
-	if config.TenantRestConfigProvider == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.TenantRestConfigProvider must not be empty", config)
+	if config.TenantClientFactory == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TenantClientFactory must not be empty", config)
 	}
 
 	r := &Resource{
-		azureClientsFactory:      config.AzureClientsFactory,
-		ctrlClient:               config.CtrlClient,
-		logger:                   config.Logger,
-		tenantRestConfigProvider: config.TenantRestConfigProvider,
+		azureClientsFactory: config.AzureClientsFactory,
+		ctrlClient:          config.CtrlClient,
+		dryRun:              config.DryRun,
+		eventRecorder:       config.EventRecorder,
+		logger:              config.Logger,
+		skippedRepairs:      newSkippedRepairs(),
+		tenantClientFactory: config.TenantClientFactory,
 	}
 
 	return r, nil
@@ -0,0 +1,71 @@
+package terminateunhealthynode
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	skipEtcdUnhealthy = "EtcdUnhealthy"
+	skipMasterBlocked = "MasterBlocked"
+	skipMaxUnhealthy  = "MaxUnhealthy"
+	skipRateLimited   = "RateLimited"
+	skipSingleMaster  = "SingleMaster"
+)
+
+// skippedRepair is an unhealthy node that was not repaired.
+type skippedRepair struct {
+	// Reason is the kind of check preventing the repair. The message changes
+	// with every loop, e.g. with the number of unhealthy nodes, so events
+	// are only emitted when the reason changes.
+	Reason  string
+	Message string
+}
+
+func newSkippedRepair(reason, messageFmt string, args ...interface{}) skippedRepair {
+	return skippedRepair{
+		Reason:  reason,
+		Message: fmt.Sprintf(messageFmt, args...),
+	}
+}
+
+// skippedRepairs remembers why the repair of unhealthy nodes was skipped in
+// the last loop of each cluster.
+type skippedRepairs struct {
+	mutex   sync.Mutex
+	reasons map[string]map[string]string
+}
+
+func newSkippedRepairs() *skippedRepairs {
+	return &skippedRepairs{
+		reasons: map[string]map[string]string{},
+	}
+}
+
+// update replaces the skipped repairs recorded for the cluster and returns the
+// sorted names of the nodes skipped for another reason than in the last loop.
+// Nodes repaired or healthy again are forgotten.
+func (s *skippedRepairs) update(cluster string, skipped map[string]skippedRepair) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.reasons[cluster]
+	current := map[string]string{}
+	var changed []string
+	for name, repair := range skipped {
+		current[name] = repair.Reason
+		if previous[name] != repair.Reason {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	if len(current) == 0 {
+		delete(s.reasons, cluster)
+	} else {
+		s.reasons[cluster] = current
+	}
+
+	return changed
+}
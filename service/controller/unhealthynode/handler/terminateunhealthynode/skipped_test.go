@@ -0,0 +1,72 @@
+package terminateunhealthynode
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_skippedRepairs_update(t *testing.T) {
+	s := newSkippedRepairs()
+
+	testCases := []struct {
+		name            string
+		skipped         map[string]skippedRepair
+		expectedChanged []string
+	}{
+		{
+			name: "case 0: new skipped repairs are reported",
+			skipped: map[string]skippedRepair{
+				"worker-1": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 60),
+				"worker-0": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 60),
+			},
+			expectedChanged: []string{"worker-0", "worker-1"},
+		},
+		{
+			name: "case 1: same reasons with other messages are not reported",
+			skipped: map[string]skippedRepair{
+				"worker-0": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 70),
+				"worker-1": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 70),
+			},
+		},
+		{
+			name: "case 2: changed reason is reported",
+			skipped: map[string]skippedRepair{
+				"worker-0": newSkippedRepair(skipRateLimited, "rate limited"),
+				"worker-1": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 70),
+			},
+			expectedChanged: []string{"worker-0"},
+		},
+		{
+			name: "case 3: nodes no longer skipped are forgotten",
+			skipped: map[string]skippedRepair{
+				"worker-0": newSkippedRepair(skipRateLimited, "rate limited"),
+			},
+		},
+		{
+			name: "case 4: node skipped again is reported",
+			skipped: map[string]skippedRepair{
+				"worker-0": newSkippedRepair(skipRateLimited, "rate limited"),
+				"worker-1": newSkippedRepair(skipMaxUnhealthy, "%d%% unhealthy", 70),
+			},
+			expectedChanged: []string{"worker-1"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			changed := s.update("cluster-uid", tc.skipped)
+
+			if !cmp.Equal(changed, tc.expectedChanged) {
+				t.Fatalf("\n\n%s\n", cmp.Diff(tc.expectedChanged, changed))
+			}
+		})
+	}
+
+	if changed := s.update("other-cluster-uid", map[string]skippedRepair{"worker-0": newSkippedRepair(skipRateLimited, "rate limited")}); len(changed) != 1 {
+		t.Fatalf("skipped repairs of other clusters must be tracked separately")
+	}
+}
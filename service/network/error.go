@@ -0,0 +1,12 @@
+package network
+
+import "github.com/giantswarm/microerror"
+
+var invalidNetworkError = &microerror.Error{
+	Kind: "invalidNetworkError",
+}
+
+// IsInvalidNetwork asserts invalidNetworkError.
+func IsInvalidNetwork(err error) bool {
+	return microerror.Cause(err) == invalidNetworkError
+}
@@ -0,0 +1,126 @@
+package network
+
+import (
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// IPv6RangeMaskBits is the mask size of the installation IPv6 range from
+	// which the IPv6 ranges of dual-stack tenant clusters are derived.
+	IPv6RangeMaskBits = 32
+
+	// Azure only supports /64 IPv6 subnets.
+	ipv6SubnetMask = 64
+
+	ipv6MaskSize = 128
+)
+
+// IPv6VirtualNetwork returns the IPv6 range of a dual-stack virtual network.
+//
+// The IPv6 range is derived by embedding the IPv4 range of the virtual network
+// right after the /32 IPv6 range of the installation. This way the IPv6 ranges
+// don't overlap as long as the IPv4 ranges allocated by IPAM don't.
+//
+// Example:
+//
+//	ipv6Range: fd00:1234::/32
+//	vnet: 10.1.0.0/16
+//	returned: fd00:1234:a01::/48
+func IPv6VirtualNetwork(ipv6Range, vnet net.IPNet) (net.IPNet, error) {
+	ones, bits := ipv6Range.Mask.Size()
+	if bits != ipv6MaskSize || ones != IPv6RangeMaskBits {
+		return net.IPNet{}, microerror.Maskf(invalidNetworkError, "IPv6 range %s must be a /%d IPv6 network", ipv6Range.String(), IPv6RangeMaskBits)
+	}
+
+	vnetOnes, vnetBits := vnet.Mask.Size()
+	if vnetBits != ipv4MaskSize {
+		return net.IPNet{}, microerror.Maskf(invalidNetworkError, "virtual network %s must be an IPv4 network", vnet.String())
+	}
+
+	return embedIPv4(ipv6Range.IP, vnet.IP, IPv6RangeMaskBits+vnetOnes), nil
+}
+
+// IPv6Subnet returns the /64 IPv6 range of the given IPv4 subnet of a
+// dual-stack virtual network, see IPv6VirtualNetwork.
+//
+// Example:
+//
+//	vnet6: fd00:1234:a01::/48
+//	subnet: 10.1.3.0/24
+//	returned: fd00:1234:a01:300::/64
+func IPv6Subnet(vnet6, subnet net.IPNet) (net.IPNet, error) {
+	n, err := ipv6Network(vnet6, subnet, ipv6SubnetMask)
+	if err != nil {
+		return net.IPNet{}, microerror.Mask(err)
+	}
+
+	return n, nil
+}
+
+// ComputeIPv6 computes the IPv6 counterparts of the subnets computed by
+// Compute within the IPv6 range of the dual-stack virtual network. The Calico
+// range keeps its size relative to the virtual network, all the other subnets
+// are /64.
+func ComputeIPv6(vnet6 net.IPNet, subnets Subnets) (*Subnets, error) {
+	var err error
+
+	ipv6Subnets := &Subnets{
+		Parent: vnet6,
+	}
+
+	calicoOnes, _ := subnets.Calico.Mask.Size()
+	ipv6Subnets.Calico, err = ipv6Network(vnet6, subnets.Calico, IPv6RangeMaskBits+calicoOnes)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ipv6Subnets.Master, err = IPv6Subnet(vnet6, subnets.Master)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ipv6Subnets.Worker, err = IPv6Subnet(vnet6, subnets.Worker)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	ipv6Subnets.VPN, err = IPv6Subnet(vnet6, subnets.VPN)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return ipv6Subnets, nil
+}
+
+func ipv6Network(vnet6, subnet net.IPNet, ones int) (net.IPNet, error) {
+	vnet6Ones, bits := vnet6.Mask.Size()
+	if bits != ipv6MaskSize {
+		return net.IPNet{}, microerror.Maskf(invalidNetworkError, "virtual network %s must be an IPv6 network", vnet6.String())
+	}
+
+	_, subnetBits := subnet.Mask.Size()
+	if subnetBits != ipv4MaskSize {
+		return net.IPNet{}, microerror.Maskf(invalidNetworkError, "subnet %s must be an IPv4 network", subnet.String())
+	}
+
+	n := embedIPv4(vnet6.IP, subnet.IP, ones)
+	if ones < vnet6Ones || !vnet6.Contains(n.IP) {
+		return net.IPNet{}, microerror.Maskf(invalidNetworkError, "IPv6 range %s of subnet %s is not within virtual network %s", n.String(), subnet.String(), vnet6.String())
+	}
+
+	return n, nil
+}
+
+// embedIPv4 returns the network with the given mask size whose first 32 bits
+// are the ones of prefix, followed by the 32 bits of the IPv4 address ip.
+func embedIPv4(prefix net.IP, ip net.IP, ones int) net.IPNet {
+	embedded := make(net.IP, net.IPv6len)
+	copy(embedded, prefix.To16()[:IPv6RangeMaskBits/8])
+	copy(embedded[IPv6RangeMaskBits/8:], ip.To4())
+
+	mask := net.CIDRMask(ones, ipv6MaskSize)
+
+	return net.IPNet{IP: embedded.Mask(mask), Mask: mask}
+}
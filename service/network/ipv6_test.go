@@ -0,0 +1,194 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestIPv6VirtualNetwork(t *testing.T) {
+	testCases := []struct {
+		description  string
+		ipv6Range    string
+		vnet         string
+		expectedVNet string
+		errorMatcher func(error) bool
+	}{
+		{
+			"ok",
+			"fd00:1234::/32",
+			"10.1.0.0/16",
+			"fd00:1234:a01::/48",
+			nil,
+		},
+		{
+			"ok with smaller vnet",
+			"fd00:1234::/32",
+			"10.1.64.0/18",
+			"fd00:1234:a01:4000::/50",
+			nil,
+		},
+		{
+			"ipv6 range too big",
+			"fd00::/16",
+			"10.1.0.0/16",
+			"",
+			IsInvalidNetwork,
+		},
+		{
+			"ipv4 range instead of ipv6 range",
+			"10.0.0.0/8",
+			"10.1.0.0/16",
+			"",
+			IsInvalidNetwork,
+		},
+		{
+			"ipv6 vnet",
+			"fd00:1234::/32",
+			"fd00:1234:a01::/48",
+			"",
+			IsInvalidNetwork,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, ipv6Range, err := net.ParseCIDR(tc.ipv6Range)
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+			_, vnet, err := net.ParseCIDR(tc.vnet)
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			vnet6, err := IPv6VirtualNetwork(*ipv6Range, *vnet)
+			if tc.errorMatcher != nil {
+				if !tc.errorMatcher(err) {
+					t.Fatalf("expected %#v got %#v", true, false)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expected %#v got %#v", nil, err)
+				}
+
+				if vnet6.String() != tc.expectedVNet {
+					t.Errorf("expected %s got %s", tc.expectedVNet, vnet6.String())
+				}
+			}
+		})
+	}
+}
+
+func TestIPv6Subnet(t *testing.T) {
+	testCases := []struct {
+		description    string
+		vnet6          string
+		subnet         string
+		expectedSubnet string
+		errorMatcher   func(error) bool
+	}{
+		{
+			"ok",
+			"fd00:1234:a01::/48",
+			"10.1.3.0/24",
+			"fd00:1234:a01:300::/64",
+			nil,
+		},
+		{
+			"ok with smaller subnet",
+			"fd00:1234:a01::/48",
+			"10.1.3.128/25",
+			"fd00:1234:a01:380::/64",
+			nil,
+		},
+		{
+			"subnet outside of vnet",
+			"fd00:1234:a01::/48",
+			"10.2.3.0/24",
+			"",
+			IsInvalidNetwork,
+		},
+		{
+			"ipv4 vnet",
+			"10.1.0.0/16",
+			"10.1.3.0/24",
+			"",
+			IsInvalidNetwork,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			_, vnet6, err := net.ParseCIDR(tc.vnet6)
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+			_, subnet, err := net.ParseCIDR(tc.subnet)
+			if err != nil {
+				t.Fatalf("expected %#v got %#v", nil, err)
+			}
+
+			subnet6, err := IPv6Subnet(*vnet6, *subnet)
+			if tc.errorMatcher != nil {
+				if !tc.errorMatcher(err) {
+					t.Fatalf("expected %#v got %#v", true, false)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expected %#v got %#v", nil, err)
+				}
+
+				if subnet6.String() != tc.expectedSubnet {
+					t.Errorf("expected %s got %s", tc.expectedSubnet, subnet6.String())
+				}
+			}
+		})
+	}
+}
+
+func TestComputeIPv6Subnets(t *testing.T) {
+	_, vnet, err := net.ParseCIDR("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("expected %#v got %#v", nil, err)
+	}
+	_, vnet6, err := net.ParseCIDR("fd00:1234:a00::/48")
+	if err != nil {
+		t.Fatalf("expected %#v got %#v", nil, err)
+	}
+
+	subnets, err := Compute(*vnet)
+	if err != nil {
+		t.Fatalf("expected %#v got %#v", nil, err)
+	}
+
+	ipv6Subnets, err := ComputeIPv6(*vnet6, *subnets)
+	if err != nil {
+		t.Fatalf("expected %#v got %#v", nil, err)
+	}
+
+	expectedSubnets := Subnets{
+		Calico: mustParseCIDR(t, "fd00:1234:a00:8000::/49"),
+		Master: mustParseCIDR(t, "fd00:1234:a00::/64"),
+		Parent: mustParseCIDR(t, "fd00:1234:a00::/48"),
+		VPN:    mustParseCIDR(t, "fd00:1234:a00:200::/64"),
+		Worker: mustParseCIDR(t, "fd00:1234:a00:100::/64"),
+	}
+
+	printSubnets := func(s Subnets) string {
+		return fmt.Sprintf("Calico: %s\nMaster: %s\nParent: %s\nVPN: %s\nWorker: %s\n", s.Calico, s.Master, s.Parent, s.VPN, s.Worker)
+	}
+
+	if !ipv6Subnets.Equal(expectedSubnets) {
+		t.Errorf("\ngot\n%s\nexpected\n%s", printSubnets(*ipv6Subnets), printSubnets(expectedSubnets))
+	}
+}
+
+func mustParseCIDR(t *testing.T, cidr string) net.IPNet {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("expected %#v got %#v", nil, err)
+	}
+
+	return *n
+}
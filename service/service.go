@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	oldcapiexpv1alpha3 "github.com/giantswarm/apiextensions/v6/pkg/apis/capiexp/v1alpha3"
 	oldcapzexpv1alpha3 "github.com/giantswarm/apiextensions/v6/pkg/apis/capzexp/v1alpha3"
 	corev1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/core/v1alpha1"
 	providerv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/provider/v1alpha1"
+	securityv1alpha1 "github.com/giantswarm/apiextensions/v6/pkg/apis/security/v1alpha1"
 	exporterkitcollector "github.com/giantswarm/exporterkit/collector"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8sclient"
 	"github.com/giantswarm/k8sclient/v7/pkg/k8srestconfig"
@@ -32,8 +34,12 @@ import (
 	"github.com/giantswarm/azure-operator/v8/client"
 	"github.com/giantswarm/azure-operator/v8/flag"
 	"github.com/giantswarm/azure-operator/v8/pkg/credential"
+	"github.com/giantswarm/azure-operator/v8/pkg/drainer"
 	"github.com/giantswarm/azure-operator/v8/pkg/employees"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/ipam"
+	"github.com/giantswarm/azure-operator/v8/pkg/handler/nodes/state"
 	"github.com/giantswarm/azure-operator/v8/pkg/label"
+	"github.com/giantswarm/azure-operator/v8/pkg/lifecyclehook"
 	"github.com/giantswarm/azure-operator/v8/pkg/locker"
 	"github.com/giantswarm/azure-operator/v8/pkg/project"
 	"github.com/giantswarm/azure-operator/v8/service/collector"
@@ -41,6 +47,7 @@ import (
 	"github.com/giantswarm/azure-operator/v8/service/controller/azureconfig"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachine"
 	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool"
+	"github.com/giantswarm/azure-operator/v8/service/controller/azuremachinepool/handler/nodepool"
 	"github.com/giantswarm/azure-operator/v8/service/controller/cluster"
 	"github.com/giantswarm/azure-operator/v8/service/controller/machinepool"
 	"github.com/giantswarm/azure-operator/v8/service/controller/setting"
@@ -63,11 +70,13 @@ type Config struct {
 }
 
 type Service struct {
-	Version *version.Service
+	IPAMInspector *ipam.Inspector
+	Version       *version.Service
 
 	bootOnce          sync.Once
 	operatorCollector *exporterkitcollector.Set
 	controllers       []*operatorkitcontroller.Controller
+	ipamLeakChecker   *ipam.LeakChecker
 }
 
 // New creates a new configured service object.
@@ -201,6 +210,7 @@ func New(config Config) (*Service, error) {
 			SchemeBuilder: k8sclient.SchemeBuilder{
 				corev1alpha1.AddToScheme,
 				providerv1alpha1.AddToScheme,
+				securityv1alpha1.AddToScheme,
 				releasev1alpha1.AddToScheme,
 				capi.AddToScheme,
 				capz.AddToScheme,
@@ -227,6 +237,33 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	var drainRules drainer.Rules
+	{
+		drainRules = drainer.Rules{
+			DeleteEmptyDirData: config.Viper.GetBool(config.Flag.Service.Drain.DeleteEmptyDirData),
+			DeleteLocalPVData:  config.Viper.GetBool(config.Flag.Service.Drain.DeleteLocalPVData),
+			EvictByPriority:    config.Viper.GetBool(config.Flag.Service.Drain.EvictByPriority),
+			IgnoreDaemonSets:   config.Viper.GetBool(config.Flag.Service.Drain.IgnoreDaemonSets),
+			SkipMirrorPods:     config.Viper.GetBool(config.Flag.Service.Drain.SkipMirrorPods),
+		}
+	}
+
+	var lifecycleHookWebhookAllowlist lifecyclehook.WebhookAllowlist
+	{
+		lifecycleHookWebhookAllowlist, err = lifecyclehook.ParseWebhookAllowlist(config.Viper.GetString(config.Flag.Service.NodePool.LifecycleHookWebhookAllowlist))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var nodePoolStateDeadlines state.DeadlineMap
+	{
+		nodePoolStateDeadlines, err = nodepool.ParseStateDeadlines(config.Viper.GetString(config.Flag.Service.NodePool.StateDeadlines))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var kubeLockLocker locker.Interface
 	{
 		c := locker.KubeLockLockerConfig{
@@ -249,6 +286,39 @@ func New(config Config) (*Service, error) {
 		ipamNetworkRange = *ipnet
 	}
 
+	var ipamIPv6NetworkRange net.IPNet
+	if config.Viper.GetString(config.Flag.Service.Installation.Guest.IPAM.Network.IPv6CIDR) != "" {
+		_, ipnet, err := net.ParseCIDR(config.Viper.GetString(config.Flag.Service.Installation.Guest.IPAM.Network.IPv6CIDR))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		ipamIPv6NetworkRange = *ipnet
+	}
+
+	var ipamPoolSelector *ipam.PoolSelector
+	{
+		pools, err := ipam.ParsePools(config.Viper.GetString(config.Flag.Service.Installation.Guest.IPAM.Pools))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		c := ipam.PoolSelectorConfig{
+			CtrlClient: k8sClient.CtrlClient(),
+			Logger:     config.Logger,
+
+			DefaultPool: ipam.Pool{
+				NetworkRange: ipamNetworkRange,
+				MaskBits:     config.Viper.GetInt(config.Flag.Service.Installation.Guest.IPAM.Network.SubnetMaskBits),
+			},
+			Pools: pools,
+		}
+
+		ipamPoolSelector, err = ipam.NewPoolSelector(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var reservedCIDRs []net.IPNet
 	{
 		_, ipnet, err := net.ParseCIDR(config.Viper.GetString(config.Flag.Service.Azure.HostCluster.CIDR))
@@ -322,11 +392,30 @@ func New(config Config) (*Service, error) {
 		}
 	}
 
+	// The client factory caches the Azure clients of the organizations. It is
+	// shared by all controllers and the IPAM collectors so that the clients
+	// are only created once per credential secret.
+	var clientFactory *client.Factory
+	{
+		c := client.FactoryConfig{
+			AzureAPIMetrics:    azureCollector,
+			CacheDuration:      30 * time.Minute,
+			CredentialProvider: credentialProvider,
+			Logger:             config.Logger,
+		}
+
+		clientFactory, err = client.NewFactory(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var controllers []*operatorkitcontroller.Controller
 
 	var azureClusterController *operatorkitcontroller.Controller
 	{
 		c := azurecluster.ControllerConfig{
+			ClientFactory:      clientFactory,
 			CredentialProvider: credentialProvider,
 			K8sClient:          k8sClient,
 			Logger:             config.Logger,
@@ -374,13 +463,14 @@ func New(config Config) (*Service, error) {
 		c := azureconfig.ControllerConfig{
 			Azure:                 azure,
 			AzureMetricsCollector: azureCollector,
-			ClusterVNetMaskBits:   config.Viper.GetInt(config.Flag.Service.Installation.Guest.IPAM.Network.SubnetMaskBits),
+			ClientFactory:         clientFactory,
 			CredentialProvider:    credentialProvider,
 			CPAzureClientSet:      cpAzureClientSet,
 			DockerhubToken:        config.Viper.GetString(config.Flag.Service.Registry.DockerhubToken),
 			Ignition:              Ignition,
 			InstallationName:      config.Viper.GetString(config.Flag.Service.Installation.Name),
-			IPAMNetworkRange:      ipamNetworkRange,
+			IPAMIPv6NetworkRange:  ipamIPv6NetworkRange,
+			IPAMPoolSelector:      ipamPoolSelector,
 			IPAMReservedCIDRs:     reservedCIDRs,
 			K8sClient:             k8sClient,
 			Locker:                kubeLockLocker,
@@ -406,28 +496,32 @@ func New(config Config) (*Service, error) {
 	var azureMachinePoolController *operatorkitcontroller.Controller
 	{
 		c := azuremachinepool.ControllerConfig{
-			APIServerSecurePort:   config.Viper.GetInt(config.Flag.Service.Cluster.Kubernetes.API.SecurePort),
-			Azure:                 azure,
-			AzureMetricsCollector: azureCollector,
-			CalicoCIDRSize:        config.Viper.GetInt(config.Flag.Service.Cluster.Calico.CIDR),
-			CalicoMTU:             config.Viper.GetInt(config.Flag.Service.Cluster.Calico.MTU),
-			CalicoSubnet:          config.Viper.GetString(config.Flag.Service.Cluster.Calico.Subnet),
-			ClusterIPRange:        config.Viper.GetString(config.Flag.Service.Cluster.Kubernetes.API.ClusterIPRange),
-			CredentialProvider:    credentialProvider,
-			CPAzureClientSet:      cpAzureClientSet,
-			DockerhubToken:        config.Viper.GetString(config.Flag.Service.Registry.DockerhubToken),
-			EtcdPrefix:            config.Viper.GetString(config.Flag.Service.Cluster.Etcd.Prefix),
-			Ignition:              Ignition,
-			InstallationName:      config.Viper.GetString(config.Flag.Service.Installation.Name),
-			K8sClient:             k8sClient,
-			Locker:                kubeLockLocker,
-			Logger:                config.Logger,
-			OIDC:                  OIDC,
-			RegistryDomain:        config.Viper.GetString(config.Flag.Service.Registry.Domain),
-			SentryDSN:             sentryDSN,
-			SSHUserList:           sshUserList,
-			SSOPublicKey:          config.Viper.GetString(config.Flag.Service.Tenant.SSH.SSOPublicKey),
-			VMSSMSIEnabled:        config.Viper.GetBool(config.Flag.Service.Azure.MSI.Enabled),
+			APIServerSecurePort:           config.Viper.GetInt(config.Flag.Service.Cluster.Kubernetes.API.SecurePort),
+			Azure:                         azure,
+			AzureMetricsCollector:         azureCollector,
+			CalicoCIDRSize:                config.Viper.GetInt(config.Flag.Service.Cluster.Calico.CIDR),
+			CalicoMTU:                     config.Viper.GetInt(config.Flag.Service.Cluster.Calico.MTU),
+			CalicoSubnet:                  config.Viper.GetString(config.Flag.Service.Cluster.Calico.Subnet),
+			ClientFactory:                 clientFactory,
+			ClusterIPRange:                config.Viper.GetString(config.Flag.Service.Cluster.Kubernetes.API.ClusterIPRange),
+			CredentialProvider:            credentialProvider,
+			CPAzureClientSet:              cpAzureClientSet,
+			DockerhubToken:                config.Viper.GetString(config.Flag.Service.Registry.DockerhubToken),
+			DrainRules:                    drainRules,
+			EtcdPrefix:                    config.Viper.GetString(config.Flag.Service.Cluster.Etcd.Prefix),
+			Ignition:                      Ignition,
+			InstallationName:              config.Viper.GetString(config.Flag.Service.Installation.Name),
+			K8sClient:                     k8sClient,
+			LifecycleHookWebhookAllowlist: lifecycleHookWebhookAllowlist,
+			Locker:                        kubeLockLocker,
+			Logger:                        config.Logger,
+			NodePoolStateDeadlines:        nodePoolStateDeadlines,
+			OIDC:                          OIDC,
+			RegistryDomain:                config.Viper.GetString(config.Flag.Service.Registry.Domain),
+			SentryDSN:                     sentryDSN,
+			SSHUserList:                   sshUserList,
+			SSOPublicKey:                  config.Viper.GetString(config.Flag.Service.Tenant.SSH.SSOPublicKey),
+			VMSSMSIEnabled:                config.Viper.GetBool(config.Flag.Service.Azure.MSI.Enabled),
 		}
 
 		azureMachinePoolController, err = azuremachinepool.NewController(c)
@@ -442,6 +536,7 @@ func New(config Config) (*Service, error) {
 	{
 		c := azuremachine.ControllerConfig{
 			AzureMetricsCollector: azureCollector,
+			ClientFactory:         clientFactory,
 			CredentialProvider:    credentialProvider,
 			K8sClient:             k8sClient,
 			Logger:                config.Logger,
@@ -492,7 +587,9 @@ func New(config Config) (*Service, error) {
 	{
 		c := unhealthynode.ControllerConfig{
 			AzureMetricsCollector: azureCollector,
+			ClientFactory:         clientFactory,
 			CredentialProvider:    credentialProvider,
+			DryRun:                config.Viper.GetBool(config.Flag.Service.UnhealthyNode.DryRun),
 			K8sClient:             k8sClient,
 			Logger:                config.Logger,
 			SentryDSN:             sentryDSN,
@@ -506,6 +603,86 @@ func New(config Config) (*Service, error) {
 		controllers = append(controllers, terminateUnhealthyNodeController)
 	}
 
+	var ipamSubnetCollector *ipam.AzureMachinePoolSubnetCollector
+	var ipamVirtualNetworkCollector *ipam.VirtualNetworkCollector
+	{
+		var organizationClientFactory client.OrganizationFactory
+		{
+			c := client.OrganizationFactoryConfig{
+				CtrlClient: k8sClient.CtrlClient(),
+				Factory:    clientFactory,
+				Logger:     config.Logger,
+			}
+			organizationClientFactory = client.NewOrganizationFactory(c)
+		}
+
+		{
+			c := ipam.AzureMachinePoolSubnetCollectorConfig{
+				AzureClientFactory: organizationClientFactory,
+				CtrlClient:         k8sClient.CtrlClient(),
+				Logger:             config.Logger,
+			}
+
+			ipamSubnetCollector, err = ipam.NewAzureMachineSubnetCollector(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		{
+			c := ipam.VirtualNetworkCollectorConfig{
+				AzureMetricsCollector: azureCollector,
+				CredentialProvider:    credentialProvider,
+				K8sClient:             k8sClient,
+				InstallationName:      config.Viper.GetString(config.Flag.Service.Installation.Name),
+				Logger:                config.Logger,
+
+				ReservedCIDRs: reservedCIDRs,
+			}
+
+			ipamVirtualNetworkCollector, err = ipam.NewVirtualNetworkCollector(c)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+	}
+
+	var ipamInspector *ipam.Inspector
+	{
+		c := ipam.InspectorConfig{
+			CtrlClient:              k8sClient.CtrlClient(),
+			Logger:                  config.Logger,
+			PoolSelector:            ipamPoolSelector,
+			SubnetCollector:         ipamSubnetCollector,
+			VirtualNetworkCollector: ipamVirtualNetworkCollector,
+		}
+
+		ipamInspector, err = ipam.NewInspector(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var ipamLeakChecker *ipam.LeakChecker
+	{
+		c := ipam.LeakCheckerConfig{
+			CtrlClient:              k8sClient.CtrlClient(),
+			Logger:                  config.Logger,
+			PoolSelector:            ipamPoolSelector,
+			SubnetCollector:         ipamSubnetCollector,
+			VirtualNetworkCollector: ipamVirtualNetworkCollector,
+
+			Inspector: ipamInspector,
+			Interval:  config.Viper.GetDuration(config.Flag.Service.Installation.Guest.IPAM.LeakCheck.Interval),
+			Reclaim:   config.Viper.GetBool(config.Flag.Service.Installation.Guest.IPAM.LeakCheck.Reclaim),
+		}
+
+		ipamLeakChecker, err = ipam.NewLeakChecker(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var versionService *version.Service
 	{
 		c := version.Config{
@@ -526,7 +703,9 @@ func New(config Config) (*Service, error) {
 	s := &Service{
 		bootOnce:          sync.Once{},
 		controllers:       controllers,
+		ipamLeakChecker:   ipamLeakChecker,
 		operatorCollector: collectorSet,
+		IPAMInspector:     ipamInspector,
 		Version:           versionService,
 	}
 
@@ -541,6 +720,8 @@ func (s *Service) Boot(ctx context.Context) {
 		}
 
 		go s.operatorCollector.Boot(context.Background())
+
+		go s.ipamLeakChecker.Boot(ctx)
 	})
 }
 